@@ -4,8 +4,6 @@ import (
 	"fmt"
 
 	"github.com/spf13/cobra"
-
-	"github.com/chris/shy/internal/db"
 )
 
 var (
@@ -33,7 +31,7 @@ func runCloseSession(cmd *cobra.Command, args []string) error {
 	}
 
 	// Open database
-	database, err := db.New(dbPath)
+	database, err := openDatabase()
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
 	}
@@ -5,8 +5,6 @@ import (
 	"strings"
 
 	"github.com/spf13/cobra"
-
-	"github.com/chris/shy/internal/db"
 )
 
 func init() {
@@ -46,7 +44,7 @@ func registerCompletions() {
 
 // getSourceAppsFromDB queries the database for unique source apps
 func getSourceAppsFromDB() []string {
-	database, err := db.New(dbPath)
+	database, err := openDatabase()
 	if err != nil {
 		return nil
 	}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	dedupNormalize bool
+	dedupForce     bool
+)
+
+var dedupCmd = &cobra.Command{
+	Use:   "dedup",
+	Short: "Find and merge commands that differ only in whitespace",
+	Long: `Report clusters of near-duplicate commands: stored texts that are the same
+once trimmed and with runs of whitespace outside quotes collapsed, such as
+"git status " and "git  status". Each cluster lists its normalized text and
+how many commands use each stored form.
+
+--normalize --force rewrites every command of a cluster to its normalized
+text, so completion and summary counts treat them as one. Without --force,
+--normalize only reports what it would rewrite.`,
+	Args: cobra.NoArgs,
+	RunE: runDedup,
+}
+
+func init() {
+	rootCmd.AddCommand(dedupCmd)
+	dedupCmd.Flags().BoolVar(&dedupNormalize, "normalize", false, "Rewrite each cluster's commands to its normalized text (needs --force)")
+	dedupCmd.Flags().BoolVar(&dedupForce, "force", false, "Rewrite without a dry run")
+}
+
+func runDedup(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+	out := cmd.OutOrStdout()
+	if dedupForce && !dedupNormalize {
+		return fmt.Errorf("shy dedup: --force only applies to --normalize")
+	}
+
+	database, err := openDatabase()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer database.Close()
+
+	clusters, err := database.FindNearDuplicates()
+	if err != nil {
+		return err
+	}
+	if len(clusters) == 0 {
+		fmt.Fprintln(out, "No near-duplicate commands")
+		return nil
+	}
+
+	if dedupNormalize && dedupForce {
+		rewritten, err := database.NormalizeNearDuplicates(clusters)
+		if err != nil {
+			return fmt.Errorf("failed to normalize commands: %w", err)
+		}
+		fmt.Fprintf(out, "Rewrote %d command(s) in %d cluster(s)\n", rewritten, len(clusters))
+		return nil
+	}
+
+	rewrites := 0
+	for _, cluster := range clusters {
+		fmt.Fprintf(out, "%6d  %s\n", cluster.Total(), cluster.Normalized)
+		for _, t := range cluster.Texts {
+			fmt.Fprintf(out, "%6s  %6d  %q\n", "", t.Count, t.Text)
+		}
+		rewrites += cluster.Rewrites()
+	}
+	fmt.Fprintf(out, "%d cluster(s), %d command(s) to rewrite\n", len(clusters), rewrites)
+	if dedupNormalize {
+		fmt.Fprintln(out, "Run with --force to rewrite them")
+	}
+	return nil
+}
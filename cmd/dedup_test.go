@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chris/shy/internal/db"
+	"github.com/chris/shy/pkg/models"
+)
+
+func runDedupForTest(t *testing.T, dbPath string, args ...string) (string, error) {
+	t.Helper()
+	defer func() { dedupNormalize, dedupForce = false, false }()
+	var buf bytes.Buffer
+	rootCmd.SetOut(&buf)
+	rootCmd.SetArgs(append([]string{"dedup", "--db", dbPath}, args...))
+	err := rootCmd.Execute()
+	rootCmd.SetOut(nil)
+	rootCmd.SetArgs(nil)
+	return buf.String(), err
+}
+
+func TestDedupNormalize(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	database, err := db.NewForTesting(dbPath)
+	require.NoError(t, err)
+	for i, text := range []string{"make  test", "make test", "make test ", "ls"} {
+		_, err := database.InsertCommand(&models.Command{CommandText: text, WorkingDir: "/home/test", Timestamp: int64(1704470400 + i)})
+		require.NoError(t, err)
+	}
+	database.Close()
+
+	report := "" +
+		"     3  make test\n" +
+		"             1  \"make  test\"\n" +
+		"             1  \"make test\"\n" +
+		"             1  \"make test \"\n" +
+		"1 cluster(s), 2 command(s) to rewrite\n"
+	output, err := runDedupForTest(t, dbPath)
+	require.NoError(t, err)
+	assert.Equal(t, report, output)
+
+	output, err = runDedupForTest(t, dbPath, "--normalize")
+	require.NoError(t, err)
+	assert.Equal(t, report+"Run with --force to rewrite them\n", output, "--normalize alone is a dry run")
+
+	_, err = runDedupForTest(t, dbPath, "--force")
+	assert.ErrorContains(t, err, "--force only applies to --normalize")
+
+	output, err = runDedupForTest(t, dbPath, "--normalize", "--force")
+	require.NoError(t, err)
+	assert.Equal(t, "Rewrote 2 command(s) in 1 cluster(s)\n", output)
+
+	output, err = runDedupForTest(t, dbPath)
+	require.NoError(t, err)
+	assert.Equal(t, "No near-duplicate commands\n", output)
+}
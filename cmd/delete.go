@@ -5,8 +5,6 @@ import (
 	"strconv"
 
 	"github.com/spf13/cobra"
-
-	"github.com/chris/shy/internal/db"
 )
 
 var deleteCmd = &cobra.Command{
@@ -35,7 +33,7 @@ func runDelete(cmd *cobra.Command, args []string) error {
 		ids[i] = id
 	}
 
-	database, err := db.New(dbPath)
+	database, err := openDatabase()
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
 	}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/chris/shy/internal/db"
+	"github.com/chris/shy/internal/db/migrations"
+)
+
+// expectedCommandColumns are the columns of the commands table after all migrations
+var expectedCommandColumns = []string{
+	"id", "timestamp", "exit_status", "duration", "command_text",
+	"working_dir_id", "git_context_id", "source_id", "is_duplicate", "env", "deleted_at",
+	"timestamp_ns",
+}
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the database schema and migration status",
+	Long:  "Print the database path, schema version, row count, columns and indexes, and report any problems. Opens the database read-only and never modifies it.",
+	Args:  cobra.NoArgs,
+	RunE:  runDoctor,
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	out := cmd.OutOrStdout()
+	var issues []string
+
+	path, err := db.ResolvePath(dbPath)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Database:       %s\n", path)
+
+	if _, err := os.Stat(path); err != nil {
+		issues = append(issues, fmt.Sprintf("database file not found (%v); run shy init-db", err))
+		return printDoctorResult(out, issues)
+	}
+
+	database, err := db.NewWithOptions(path, db.Options{ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer database.Close()
+
+	journalMode, err := database.JournalMode()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Backend:        sqlite (journal_mode=%s)\n", journalMode)
+
+	version, err := database.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Schema version: %d (latest %d)\n", version, migrations.Latest())
+	if version < migrations.Latest() {
+		issues = append(issues, fmt.Sprintf("migration pending: schema version %d, latest %d; run shy init-db", version, migrations.Latest()))
+	} else if version > migrations.Latest() {
+		issues = append(issues, fmt.Sprintf("schema version %d is newer than this shy build supports (%d)", version, migrations.Latest()))
+	}
+
+	exists, err := database.TableExists()
+	if err != nil {
+		return err
+	}
+	if !exists {
+		issues = append(issues, "commands table does not exist")
+		return printDoctorResult(out, issues)
+	}
+
+	count, err := database.CountCommands()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Commands:       %d\n", count)
+
+	schema, err := database.GetTableSchema()
+	if err != nil {
+		return err
+	}
+	var columns []string
+	var columnNames []string
+	for _, col := range schema {
+		name, _ := col["name"].(string)
+		colType, _ := col["type"].(string)
+		columns = append(columns, strings.TrimSpace(name+" "+colType))
+		columnNames = append(columnNames, name)
+	}
+	fmt.Fprintf(out, "Columns:        %s\n", strings.Join(columns, ", "))
+	for _, name := range expectedCommandColumns {
+		if !slices.Contains(columnNames, name) {
+			issues = append(issues, fmt.Sprintf("commands table is missing column %s", name))
+		}
+	}
+
+	indexes, err := database.ListIndexes()
+	if err != nil {
+		return err
+	}
+	expectedIndexes := migrations.IndexNames()
+	present := 0
+	for _, name := range expectedIndexes {
+		if slices.Contains(indexes, name) {
+			present++
+		} else {
+			issues = append(issues, fmt.Sprintf("missing index %s", name))
+		}
+	}
+	fmt.Fprintf(out, "Indexes:        %d/%d present\n", present, len(expectedIndexes))
+
+	return printDoctorResult(out, issues)
+}
+
+// printDoctorResult prints "OK" or the list of issues found
+func printDoctorResult(out io.Writer, issues []string) error {
+	fmt.Fprintln(out)
+	if len(issues) == 0 {
+		fmt.Fprintln(out, "OK")
+		return nil
+	}
+
+	fmt.Fprintln(out, "Issues:")
+	for _, issue := range issues {
+		fmt.Fprintf(out, "  - %s\n", issue)
+	}
+	return nil
+}
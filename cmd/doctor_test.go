@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"bytes"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chris/shy/internal/db"
+	"github.com/chris/shy/pkg/models"
+)
+
+func runDoctorForTest(t *testing.T, dbPath string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	rootCmd.SetOut(&buf)
+	rootCmd.SetArgs([]string{"doctor", "--db", dbPath})
+	require.NoError(t, rootCmd.Execute())
+	rootCmd.SetOut(nil)
+	rootCmd.SetArgs(nil)
+	return buf.String()
+}
+
+func TestDoctorHealthyDatabase(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	database, err := db.NewForTesting(dbPath)
+	require.NoError(t, err)
+	_, err = database.InsertCommand(models.NewCommand("ls", "/tmp", 0))
+	require.NoError(t, err)
+	database.Close()
+
+	output := runDoctorForTest(t, dbPath)
+
+	assert.Contains(t, output, "Database:       "+dbPath)
+	assert.Contains(t, output, "Backend:        sqlite")
+	assert.Contains(t, output, "Commands:       1")
+	assert.Contains(t, output, "command_text TEXT")
+	assert.Contains(t, output, "Indexes:        8/8 present")
+	assert.Contains(t, output, "\nOK\n")
+	assert.NotContains(t, output, "Issues:")
+}
+
+func TestDoctorReportsPendingMigrationAndMissingIndex(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	database, err := db.NewForTesting(dbPath)
+	require.NoError(t, err)
+	database.Close()
+
+	conn, err := sql.Open("sqlite", dbPath)
+	require.NoError(t, err)
+	_, err = conn.Exec("DROP INDEX idx_timestamp_desc")
+	require.NoError(t, err)
+	_, err = conn.Exec("PRAGMA user_version = 2")
+	require.NoError(t, err)
+	conn.Close()
+
+	output := runDoctorForTest(t, dbPath)
+
+	assert.Contains(t, output, "Issues:")
+	assert.Contains(t, output, "migration pending: schema version 2, latest 7")
+	assert.Contains(t, output, "missing index idx_timestamp_desc")
+	assert.NotContains(t, output, "\nOK\n")
+
+	// doctor must not have run the pending migration
+	conn, err = sql.Open("sqlite", dbPath)
+	require.NoError(t, err)
+	defer conn.Close()
+	var version int
+	require.NoError(t, conn.QueryRow("PRAGMA user_version").Scan(&version))
+	assert.Equal(t, 2, version)
+}
+
+func TestDoctorReportsMissingTimestampNsColumn(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	database, err := db.NewForTesting(dbPath)
+	require.NoError(t, err)
+	database.Close()
+
+	conn, err := sql.Open("sqlite", dbPath)
+	require.NoError(t, err)
+	_, err = conn.Exec("ALTER TABLE commands DROP COLUMN timestamp_ns")
+	require.NoError(t, err)
+	conn.Close()
+
+	output := runDoctorForTest(t, dbPath)
+
+	assert.Contains(t, output, "Issues:")
+	assert.Contains(t, output, "commands table is missing column timestamp_ns")
+	assert.NotContains(t, output, "\nOK\n")
+}
+
+func TestDoctorMissingDatabaseIsNotCreated(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "nested", "history.db")
+
+	output := runDoctorForTest(t, dbPath)
+
+	assert.Contains(t, output, "database file not found")
+	_, err := os.Stat(filepath.Dir(dbPath))
+	assert.True(t, os.IsNotExist(err), "doctor should not create the database directory")
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/chris/shy/internal/db"
+	"github.com/chris/shy/pkg/models"
+)
+
+var (
+	exportSince   string
+	exportUntil   string
+	exportLimit   int
+	exportAfterID int64
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export commands as newline-delimited JSON",
+	Long: `Write commands to stdout as newline-delimited JSON, oldest first, in the
+format read by shy insert-batch. Each line also carries the command's id.
+
+Large histories can be exported in pages: --limit caps a page and --after-id
+resumes after the last id of the previous page. Paging uses the id as a
+cursor, so each page is cheap and stable even as new commands arrive.
+Combined with --since/--until, the id of the last line written lets an
+interrupted export resume where it stopped:
+
+  shy export --since 2026-01-01 --limit 10000 > page1.ndjson
+  shy export --since 2026-01-01 --limit 10000 --after-id <last id> > page2.ndjson`,
+	Args: cobra.NoArgs,
+	RunE: runExport,
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	exportCmd.Flags().StringVar(&exportSince, "since", "", "Only export commands on or after this date (YYYY-MM-DD)")
+	exportCmd.Flags().StringVar(&exportUntil, "until", "", "Only export commands on or before this date (YYYY-MM-DD)")
+	exportCmd.Flags().IntVar(&exportLimit, "limit", 0, "Maximum number of commands to export (default: all)")
+	exportCmd.Flags().Int64Var(&exportAfterID, "after-id", 0, "Only export commands with an id greater than this (resume cursor)")
+}
+
+func runExport(cmd *cobra.Command, args []string) error {
+	if exportLimit < 0 {
+		return fmt.Errorf("invalid --limit %d: must not be negative", exportLimit)
+	}
+	opts := db.ExportOptions{AfterID: exportAfterID, Limit: exportLimit}
+	if exportSince != "" {
+		since, err := time.ParseInLocation("2006-01-02", exportSince, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid --since date %q: expected YYYY-MM-DD", exportSince)
+		}
+		opts.StartTime = since.Unix()
+	}
+	if exportUntil != "" {
+		until, err := time.ParseInLocation("2006-01-02", exportUntil, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid --until date %q: expected YYYY-MM-DD", exportUntil)
+		}
+		// --until is inclusive: stop at the start of the following day
+		opts.EndTime = until.AddDate(0, 0, 1).Unix()
+	}
+
+	database, err := openDatabase()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer database.Close()
+
+	commands, err := database.ExportCommands(opts)
+	if err != nil {
+		return err
+	}
+
+	prog := newProgress(cmd, "Exporting", len(commands))
+	defer prog.done()
+	enc := json.NewEncoder(cmd.OutOrStdout())
+	for i, c := range commands {
+		if err := enc.Encode(exportEntry(c)); err != nil {
+			return fmt.Errorf("failed to write command %d: %w", c.ID, err)
+		}
+		prog.update(i + 1)
+	}
+	return nil
+}
+
+// exportEntry converts a stored command to an insert-batch line
+func exportEntry(c models.Command) batchCommand {
+	entry := batchCommand{
+		ID:          c.ID,
+		Timestamp:   c.Timestamp,
+		ExitStatus:  c.ExitStatus,
+		CommandText: c.CommandText,
+		WorkingDir:  c.WorkingDir,
+		Env:         c.Env,
+	}
+	if c.GitRepo != nil {
+		entry.GitRepo = *c.GitRepo
+	}
+	if c.GitBranch != nil {
+		entry.GitBranch = *c.GitBranch
+	}
+	if c.Duration != nil {
+		entry.Duration = *c.Duration
+	}
+	if c.SourceApp != nil {
+		entry.SourceApp = *c.SourceApp
+	}
+	if c.SourcePid != nil {
+		entry.SourcePid = *c.SourcePid
+	}
+	// A whole-second start time is implied by timestamp
+	if c.TimestampNs != c.Timestamp*int64(time.Second) {
+		entry.TimestampNs = c.TimestampNs
+	}
+	return entry
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chris/shy/internal/db"
+	"github.com/chris/shy/pkg/models"
+)
+
+func runExportForTest(t *testing.T, dbPath string, args ...string) string {
+	t.Helper()
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	rootCmd.SetArgs(append([]string{"export", "--db", dbPath}, args...))
+	require.NoError(t, rootCmd.Execute())
+	rootCmd.SetOut(nil)
+	rootCmd.SetArgs(nil)
+	exportSince, exportUntil, exportLimit, exportAfterID = "", "", 0, 0
+	return out.String()
+}
+
+func setupExportScenario(t *testing.T) string {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	database, err := db.NewForTesting(dbPath)
+	require.NoError(t, err)
+	defer database.Close()
+
+	day := time.Date(2026, 3, 10, 9, 0, 0, 0, time.Local)
+	repo, branch := "github.com/chris/shy", "main"
+	for i, text := range []string{"git status", "make build", "make test", "git push", "ls"} {
+		duration := int64(100 * (i + 1))
+		c := &models.Command{
+			CommandText: text,
+			WorkingDir:  "/home/user/shy",
+			GitRepo:     &repo,
+			GitBranch:   &branch,
+			Duration:    &duration,
+			Timestamp:   day.AddDate(0, 0, i).Unix(),
+		}
+		if text == "git push" {
+			c.Env = map[string]string{"AWS_PROFILE": "staging"}
+			c.TimestampNs = c.Timestamp*int64(time.Second) + 250_000_000
+		}
+		_, err := database.InsertCommand(c)
+		require.NoError(t, err)
+	}
+	return dbPath
+}
+
+// lastExportID returns the id of the last line of an export
+func lastExportID(t *testing.T, export string) int64 {
+	t.Helper()
+	lines := strings.Split(strings.TrimSpace(export), "\n")
+	var entry batchCommand
+	require.NoError(t, json.Unmarshal([]byte(lines[len(lines)-1]), &entry))
+	return entry.ID
+}
+
+func TestExport(t *testing.T) {
+	dbPath := setupExportScenario(t)
+
+	full := runExportForTest(t, dbPath)
+	lines := strings.Split(strings.TrimSpace(full), "\n")
+	require.Len(t, lines, 5)
+
+	var first batchCommand
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+	assert.Equal(t, int64(1), first.ID)
+	assert.Equal(t, "git status", first.CommandText)
+	assert.Equal(t, "/home/user/shy", first.WorkingDir)
+	assert.Equal(t, "main", first.GitBranch)
+	assert.Equal(t, int64(100), first.Duration)
+}
+
+func TestExportPagesConcatenateToFullExport(t *testing.T) {
+	dbPath := setupExportScenario(t)
+
+	full := runExportForTest(t, dbPath)
+	page1 := runExportForTest(t, dbPath, "--limit", "3")
+	assert.Len(t, strings.Split(strings.TrimSpace(page1), "\n"), 3)
+	page2 := runExportForTest(t, dbPath, "--limit", "3", "--after-id", strconv.FormatInt(lastExportID(t, page1), 10))
+
+	assert.Equal(t, full, page1+page2)
+}
+
+func TestExportDateRange(t *testing.T) {
+	dbPath := setupExportScenario(t)
+
+	// Commands are one per day from 2026-03-10; --until is inclusive
+	export := runExportForTest(t, dbPath, "--since", "2026-03-11", "--until", "2026-03-12")
+	lines := strings.Split(strings.TrimSpace(export), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"make build"`)
+	assert.Contains(t, lines[1], `"make test"`)
+
+	// Resuming within the range skips what was already written
+	resumed := runExportForTest(t, dbPath, "--since", "2026-03-11", "--until", "2026-03-12", "--after-id", strconv.FormatInt(lastExportID(t, lines[0]), 10))
+	assert.Equal(t, lines[1]+"\n", resumed)
+}
+
+func TestExportRoundTripsThroughInsertBatch(t *testing.T) {
+	export := runExportForTest(t, setupExportScenario(t))
+
+	copyPath := filepath.Join(t.TempDir(), "copy.db")
+	out, errOut := runInsertBatchForTest(t, copyPath, export)
+	assert.Equal(t, "Inserted 5 commands\n", out)
+	assert.Empty(t, errOut)
+
+	assert.Contains(t, export, `"env":{"AWS_PROFILE":"staging"}`)
+	assert.Equal(t, 1, strings.Count(export, `"timestamp_ns":`), "only a sub-second start time is exported")
+	assert.Equal(t, export, runExportForTest(t, copyPath))
+}
+
+// TestExportProgressGoesToStderr tests that progress is drawn on a terminal
+// stderr only, leaving the exported lines on stdout intact
+func TestExportProgressGoesToStderr(t *testing.T) {
+	dbPath := setupExportScenario(t)
+	oldIsTerminal := stderrIsTerminal
+	t.Cleanup(func() { stderrIsTerminal = oldIsTerminal })
+
+	var stderr bytes.Buffer
+	rootCmd.SetErr(&stderr)
+	t.Cleanup(func() { rootCmd.SetErr(nil) })
+
+	stderrIsTerminal = func() bool { return true }
+	out := runExportForTest(t, dbPath)
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	require.Len(t, lines, 5)
+	for _, line := range lines {
+		var entry batchCommand
+		assert.NoError(t, json.Unmarshal([]byte(line), &entry), "stdout holds only NDJSON: %q", line)
+	}
+	assert.Contains(t, stderr.String(), "Exporting 5/5")
+	assert.True(t, strings.HasSuffix(stderr.String(), "\r\033[K"), "the progress line is cleared")
+
+	// Piped or redirected stderr stays silent
+	stderr.Reset()
+	stderrIsTerminal = func() bool { return false }
+	assert.Equal(t, out, runExportForTest(t, dbPath))
+	assert.Empty(t, stderr.String())
+}
@@ -2,10 +2,12 @@ package cmd
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -19,6 +21,35 @@ import (
 	"github.com/chris/shy/pkg/models"
 )
 
+// Errors fc returns for the failures a caller may want to tell apart, with
+// zsh's messages. Wrapped errors add the argument at fault; errors.Is
+// matches them all the same.
+var (
+	ErrNoMatches     = errors.New("shy fc: no matching events found")
+	ErrEventNotFound = errors.New("shy fc: event not found")
+	ErrTooManyArgs   = errors.New("shy fc: too many arguments")
+)
+
+// Exit codes of the errors above. Any other error exits 1.
+const (
+	exitTooManyArgs   = 2
+	exitNoMatches     = 3
+	exitEventNotFound = 4
+)
+
+// exitCode returns the process exit code for an error from a command
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, ErrTooManyArgs):
+		return exitTooManyArgs
+	case errors.Is(err, ErrNoMatches):
+		return exitNoMatches
+	case errors.Is(err, ErrEventNotFound):
+		return exitEventNotFound
+	}
+	return 1
+}
+
 var fcCmd = &cobra.Command{
 	Use:                "fc [flags] [first [last]]",
 	Short:              "Process command history (fc builtin)",
@@ -46,6 +77,8 @@ var fcCmd = &cobra.Command{
 			}
 		}
 
+		flags.internal = fcSessionScoped(flags)
+
 		// Set flags on cmd so runFc can read them
 		cmd.Flags().Set("list", fmt.Sprintf("%t", flags.list))
 		cmd.Flags().Set("no-numbers", fmt.Sprintf("%t", flags.noNum))
@@ -59,14 +92,26 @@ var fcCmd = &cobra.Command{
 		cmd.Flags().Set("match", flags.pattern)
 		cmd.Flags().Set("internal", fmt.Sprintf("%t", flags.internal))
 		cmd.Flags().Set("local", fmt.Sprintf("%t", flags.local))
+		cmd.Flags().Set("count", fmt.Sprintf("%t", flags.count))
+		cmd.Flags().Set("exit", fmt.Sprintf("%t", flags.exitStatus))
+		cmd.Flags().Set("zsh-compat", fmt.Sprintf("%t", flags.zshCompat))
+		cmd.Flags().Set("min-duration", flags.minDur)
+		cmd.Flags().Set("app", flags.app)
 		cmd.Flags().Set("editor", flags.editor)
 		cmd.Flags().Set("quick-exec", fmt.Sprintf("%t", flags.quickExec))
+		cmd.Flags().Set("confirm", fmt.Sprintf("%t", flags.confirm))
+		cmd.Flags().Set("yes", fmt.Sprintf("%t", flags.yes))
 		cmd.Flags().Set("write", flags.writeFile)
 		cmd.Flags().Set("write-specified", fmt.Sprintf("%t", flags.writeSpecified))
 		cmd.Flags().Set("append", flags.appendFile)
 		cmd.Flags().Set("read", flags.readFile)
+		cmd.Flags().Set("keep-comments", fmt.Sprintf("%t", flags.keepComments))
 		cmd.Flags().Set("push", flags.pushDB)
 		cmd.Flags().Set("pop", fmt.Sprintf("%t", flags.popDB))
+		cmd.Flags().Set("set", flags.setID)
+		cmd.Flags().Set("force", fmt.Sprintf("%t", flags.force))
+		cmd.Flags().Set("session", fmt.Sprintf("%t", flags.session))
+		cmd.Flags().Set("no-session", fmt.Sprintf("%t", flags.noSession))
 
 		// Run fc with parsed arguments
 		err = runFc(cmd, parsedArgs)
@@ -91,6 +136,11 @@ type listModeFlags struct {
 	pattern    string
 	internal   bool
 	local      bool
+	count      bool
+	exitStatus bool
+	zshCompat  bool
+	minDur     string // --min-duration, e.g. "5s"
+	app        string // --app: source app, e.g. "bash"
 }
 
 // HistoryRange represents a parsed history range with metadata
@@ -106,12 +156,19 @@ type fcFlags struct {
 	list           bool
 	editor         string // -e flag: specify editor to use
 	quickExec      bool   // -s flag: re-execute without editing
+	confirm        bool   // --confirm flag: prompt y/N before executing
+	yes            bool   // --yes flag: never prompt before executing
 	writeFile      string // -W flag: write history to file
 	writeSpecified bool   // whether -W was specified (even without file)
 	appendFile     string // -A flag: append history to file
 	readFile       string // -R flag: read history from file
+	keepComments   bool   // --keep-comments flag: keep the -W file's header
 	pushDB         string // -p flag: push current database, start using new one
 	popDB          bool   // -P flag: pop back to previous database
+	setID          string // --set flag: event ID whose stored text to replace
+	force          bool   // --force flag: replace without prompting
+	session        bool   // --session flag: scope to the current session, like -I
+	noSession      bool   // --no-session flag: ignore SHY_FC_SESSION for this call
 	help           bool
 }
 
@@ -158,6 +215,27 @@ func parseListModeFlag(arg string, args []string, i int, flags *listModeFlags) (
 	case "-L", "--local":
 		flags.local = true
 		return i, true, nil
+	case "--count":
+		flags.count = true
+		return i, true, nil
+	case "-x", "--exit":
+		flags.exitStatus = true
+		return i, true, nil
+	case "--zsh-compat":
+		flags.zshCompat = true
+		return i, true, nil
+	case "--min-duration":
+		if i+1 >= len(args) {
+			return i, true, fmt.Errorf("--min-duration requires a duration (e.g. 5s)")
+		}
+		flags.minDur = args[i+1]
+		return i + 1, true, nil
+	case "--app":
+		if i+1 >= len(args) {
+			return i, true, fmt.Errorf("--app requires a source app (e.g. bash)")
+		}
+		flags.app = args[i+1]
+		return i + 1, true, nil
 	default:
 		return i, false, nil
 	}
@@ -227,6 +305,10 @@ func parseFcArgsAndFlags(args []string) ([]string, fcFlags, []string, error) {
 				flags.editor = args[i]
 			case "-s", "--quick-exec":
 				flags.quickExec = true
+			case "--confirm":
+				flags.confirm = true
+			case "--yes":
+				flags.yes = true
 			case "-W", "--write":
 				flags.writeSpecified = true
 				// -W can be specified without an argument (no-op case)
@@ -248,6 +330,8 @@ func parseFcArgsAndFlags(args []string) ([]string, fcFlags, []string, error) {
 				}
 				i++
 				flags.readFile = args[i]
+			case "--keep-comments":
+				flags.keepComments = true
 			case "-p", "--push":
 				if i+1 >= len(args) || strings.HasPrefix(args[i+1], "-") {
 					return nil, flags, nil, fmt.Errorf("-p requires a database path")
@@ -256,6 +340,18 @@ func parseFcArgsAndFlags(args []string) ([]string, fcFlags, []string, error) {
 				flags.pushDB = args[i]
 			case "-P", "--pop":
 				flags.popDB = true
+			case "--set":
+				if i+1 >= len(args) || strings.HasPrefix(args[i+1], "-") {
+					return nil, flags, nil, fmt.Errorf("--set requires an event ID")
+				}
+				i++
+				flags.setID = args[i]
+			case "--force":
+				flags.force = true
+			case "--session":
+				flags.session = true
+			case "--no-session":
+				flags.noSession = true
 			case "--db":
 				// Parent flag - save it to process later
 				if i+1 < len(args) {
@@ -296,11 +392,21 @@ func parseFcArgsAndFlags(args []string) ([]string, fcFlags, []string, error) {
 		return nil, flags, nil, fmt.Errorf("cannot use -W, -A, -R, -p, or -P together")
 	}
 
+	// --keep-comments is about the structure of history files
+	if flags.keepComments && !flags.writeSpecified && flags.readFile == "" {
+		return nil, flags, nil, fmt.Errorf("--keep-comments requires -W or -R")
+	}
+
 	// Validate -s and -e are not used together
 	if flags.quickExec && flags.editor != "" {
 		return nil, flags, nil, fmt.Errorf("cannot use -s and -e together")
 	}
 
+	// --set edits a single stored command; it doesn't mix with other modes
+	if flags.setID != "" && (flags.list || fileOpCount > 0) {
+		return nil, flags, nil, fmt.Errorf("cannot use --set with -l, -W, -A, -R, -p, or -P")
+	}
+
 	// Push/pop cannot be used with -l (list mode)
 	if (flags.pushDB != "" || flags.popDB) && flags.list {
 		return nil, flags, nil, fmt.Errorf("cannot use -p/-P with -l")
@@ -321,7 +427,12 @@ func addListModeFlags(cmd *cobra.Command) {
 	cmd.Flags().BoolP("elapsed", "D", false, "Display elapsed time since command")
 	cmd.Flags().StringP("match", "m", "", "Filter by glob pattern")
 	cmd.Flags().BoolP("internal", "I", false, "Show only commands from current session")
-	cmd.Flags().BoolP("local", "L", false, "Show only local commands (currently same as no filter)")
+	cmd.Flags().BoolP("local", "L", false, "Show only commands run in the current directory")
+	cmd.Flags().Bool("count", false, "Print only the number of matching commands")
+	cmd.Flags().BoolP("exit", "x", false, "Display each command's exit status")
+	cmd.Flags().Bool("zsh-compat", false, "Lay out lines exactly as zsh's fc -l does (* marks other sessions' events)")
+	cmd.Flags().String("min-duration", "", "Show only commands that ran at least this long (e.g. 5s, 1m30s)")
+	cmd.Flags().String("app", "", "Show only commands recorded by this source app (e.g. bash)")
 }
 
 func init() {
@@ -332,10 +443,17 @@ func init() {
 	addListModeFlags(fcCmd)
 	fcCmd.Flags().StringP("editor", "e", "", "Specify editor to use")
 	fcCmd.Flags().BoolP("quick-exec", "s", false, "Re-execute without editing")
+	fcCmd.Flags().Bool("confirm", false, "Show commands and prompt y/N before executing")
+	fcCmd.Flags().Bool("yes", false, "Execute without prompting for confirmation")
 	fcCmd.Flags().StringP("write", "W", "", "Write history to file")
 	fcCmd.Flags().Bool("write-specified", false, "Internal: tracks if -W was specified")
 	fcCmd.Flags().StringP("append", "A", "", "Append history to file")
 	fcCmd.Flags().StringP("read", "R", "", "Read history from file")
+	fcCmd.Flags().Bool("keep-comments", false, "With -W, keep the file's leading comments and blank lines (-R always skips them)")
+	fcCmd.Flags().String("set", "", "Replace the stored text of an event: --set <id> \"new text\"")
+	fcCmd.Flags().Bool("force", false, "Replace with --set without prompting for confirmation")
+	fcCmd.Flags().Bool("session", false, "Scope to the current session (same as -I)")
+	fcCmd.Flags().Bool("no-session", false, "Use the whole history even when SHY_FC_SESSION is set")
 	// Hide the internal write-specified flag from help
 	fcCmd.Flags().MarkHidden("write-specified")
 }
@@ -354,14 +472,26 @@ func resetFcFlags(cmd *cobra.Command) {
 	cmd.Flags().Set("match", "")
 	cmd.Flags().Set("internal", "false")
 	cmd.Flags().Set("local", "false")
+	cmd.Flags().Set("count", "false")
+	cmd.Flags().Set("exit", "false")
+	cmd.Flags().Set("zsh-compat", "false")
+	cmd.Flags().Set("min-duration", "")
+	cmd.Flags().Set("app", "")
 	cmd.Flags().Set("editor", "")
 	cmd.Flags().Set("quick-exec", "false")
+	cmd.Flags().Set("confirm", "false")
+	cmd.Flags().Set("yes", "false")
 	cmd.Flags().Set("write", "")
 	cmd.Flags().Set("write-specified", "false")
 	cmd.Flags().Set("append", "")
 	cmd.Flags().Set("read", "")
+	cmd.Flags().Set("keep-comments", "false")
 	cmd.Flags().Set("push", "")
 	cmd.Flags().Set("pop", "false")
+	cmd.Flags().Set("set", "")
+	cmd.Flags().Set("force", "false")
+	cmd.Flags().Set("session", "false")
+	cmd.Flags().Set("no-session", "false")
 
 	// Clear the "changed" status for all flags so they don't appear as modified
 	cmd.Flags().Visit(func(f *pflag.Flag) {
@@ -369,6 +499,34 @@ func resetFcFlags(cmd *cobra.Command) {
 	})
 }
 
+// fcSessionScoped reports whether fc should filter to the current session.
+// -I and --session always do. Otherwise SHY_FC_SESSION=1 makes it the default
+// inside a shy session, and -L (which scopes to the current directory
+// instead) or --no-session turn that default off.
+func fcSessionScoped(flags fcFlags) bool {
+	if flags.internal || flags.session {
+		return true
+	}
+	if flags.local || flags.noSession {
+		return false
+	}
+	scoped, _ := strconv.ParseBool(os.Getenv("SHY_FC_SESSION"))
+	return scoped && os.Getenv("SHY_SESSION_PID") != ""
+}
+
+// fcLocalDir returns the directory -L restricts fc to: the current working
+// directory, or "" when -L is not set
+func fcLocalDir(local bool) (string, error) {
+	if !local {
+		return "", nil
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("shy fc -L: failed to get current directory: %w", err)
+	}
+	return cwd, nil
+}
+
 // getSessionPid retrieves the current session PID from the SHY_SESSION_PID environment variable
 func getSessionPid() (int64, error) {
 	pidStr := os.Getenv("SHY_SESSION_PID")
@@ -457,6 +615,8 @@ func runFc(cmd *cobra.Command, args []string) error {
 	fcReadFile, _ := cmd.Flags().GetString("read")
 	fcPushDB, _ := cmd.Flags().GetString("push")
 	fcPopDB, _ := cmd.Flags().GetBool("pop")
+	fcSetID, _ := cmd.Flags().GetString("set")
+	fcForce, _ := cmd.Flags().GetBool("force")
 
 	// Handle -W without file path (no-op)
 	if fcWriteSpecified && fcWriteFile == "" {
@@ -472,7 +632,7 @@ func runFc(cmd *cobra.Command, args []string) error {
 	}
 
 	// Open database
-	database, err := db.New(dbPath)
+	database, err := openDatabase()
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil
@@ -483,9 +643,13 @@ func runFc(cmd *cobra.Command, args []string) error {
 
 	// Dispatch to appropriate mode handler based on flags
 	switch {
+	case fcSetID != "":
+		// --set: Replace a stored command's text
+		return runSetMode(cmd, args, database, fcSetID, fcForce)
+
 	case fcReadFile != "":
 		// -R: Import history from file
-		return runReadMode(fcReadFile, database)
+		return runReadMode(cmd, fcReadFile, database)
 
 	case fcWriteFile != "" || fcAppendFile != "":
 		// -W/-A: Export history to file
@@ -502,16 +666,58 @@ func runFc(cmd *cobra.Command, args []string) error {
 }
 
 // runReadMode handles -R flag: import history from a file
-func runReadMode(filePath string, database *db.DB) error {
-	return readHistoryFromFile(filePath, database)
+func runReadMode(cmd *cobra.Command, filePath string, database *db.DB) error {
+	prog := newProgress(cmd, "Reading", 0)
+	defer prog.done()
+	return readHistoryFromFile(filePath, database, prog)
+}
+
+// runSetMode handles --set: replace the stored text of one event in place.
+// Unlike substitution, which only affects what is re-executed, this rewrites
+// the history row, so it asks for confirmation unless --force is given.
+func runSetMode(cmd *cobra.Command, args []string, database *db.DB, setID string, force bool) error {
+	id, err := strconv.ParseInt(setID, 10, 64)
+	if err != nil || id <= 0 {
+		return fmt.Errorf("shy fc --set: invalid event ID %q: must be a positive integer", setID)
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("shy fc --set: expected the new command text as a single argument")
+	}
+	text := args[0]
+	if strings.TrimSpace(text) == "" {
+		return fmt.Errorf("shy fc --set: new command text is empty")
+	}
+
+	existing, err := database.GetCommand(id)
+	if err != nil {
+		return fmt.Errorf("shy fc --set: event %d not found", id)
+	}
+	if existing.CommandText == text {
+		return nil
+	}
+
+	if !force {
+		lines := []string{fmt.Sprintf("%d: %s", id, existing.CommandText), fmt.Sprintf("%d: %s", id, text)}
+		ok, err := confirmPrompt(cmd.OutOrStdout(), "shy fc", lines, "replace")
+		if err != nil || !ok {
+			return err
+		}
+	}
+
+	if err := database.UpdateCommandText(id, text); err != nil {
+		return fmt.Errorf("failed to update command: %w", err)
+	}
+	return nil
 }
 
 // runWriteMode handles -W/-A flags: export history to a file
 func runWriteMode(cmd *cobra.Command, args []string, database *db.DB, writeFile, appendFile string) error {
 	// Get flags needed for write mode
-	fcPattern, _ := cmd.Flags().GetString("match")
-	fcInternal, _ := cmd.Flags().GetBool("internal")
 	fcReverse, _ := cmd.Flags().GetBool("reverse")
+	filter, err := fcRangeFilter(cmd, database)
+	if err != nil {
+		return err
+	}
 
 	// Parse substitutions
 	substitutions, remainingArgs, err := parseSubstitutions(args)
@@ -527,16 +733,11 @@ func runWriteMode(cmd *cobra.Command, args []string, database *db.DB, writeFile,
 	}
 
 	// Get commands from database with filters
-	commands, err := getCommandsWithFilters(database, histRange.First, histRange.Last, fcPattern, fcInternal, true)
+	commands, err := getCommandsWithFilters(database, histRange.First, histRange.Last, filter, true, listOrder(fcReverse, histRange))
 	if err != nil {
 		return err
 	}
 
-	// Apply reverse if requested via flag OR if range was specified in reverse order
-	if fcReverse || histRange.WasReversed {
-		reverseCommands(commands)
-	}
-
 	// Write or append to file
 	filePath := writeFile
 	isAppend := false
@@ -548,7 +749,16 @@ func runWriteMode(cmd *cobra.Command, args []string, database *db.DB, writeFile,
 	if isAppend {
 		return appendHistoryToFile(filePath, commands)
 	}
-	return writeHistoryToFile(filePath, commands)
+
+	// The header has to be read before the file is truncated
+	var header []string
+	if keepComments, _ := cmd.Flags().GetBool("keep-comments"); keepComments {
+		header, err = readHistoryHeader(filePath)
+		if err != nil {
+			return err
+		}
+	}
+	return writeHistoryToFile(filePath, header, commands)
 }
 
 // runListMode handles -l flag: list history commands
@@ -562,8 +772,13 @@ func runListMode(cmd *cobra.Command, args []string, database *db.DB) error {
 	fcTimeEU, _ := cmd.Flags().GetBool("european")
 	fcTimeCustom, _ := cmd.Flags().GetString("time-format")
 	fcElapsedTime, _ := cmd.Flags().GetBool("elapsed")
-	fcPattern, _ := cmd.Flags().GetString("match")
-	fcInternal, _ := cmd.Flags().GetBool("internal")
+	fcCount, _ := cmd.Flags().GetBool("count")
+	fcExit, _ := cmd.Flags().GetBool("exit")
+	fcZshCompat, _ := cmd.Flags().GetBool("zsh-compat")
+	filter, err := fcRangeFilter(cmd, database)
+	if err != nil {
+		return err
+	}
 
 	// Parse substitutions
 	substitutions, remainingArgs, err := parseSubstitutions(args)
@@ -577,15 +792,21 @@ func runListMode(cmd *cobra.Command, args []string, database *db.DB) error {
 		return err
 	}
 
+	// --count: print only the number of matches
+	if fcCount {
+		return runCountMode(cmd, database, histRange.First, histRange.Last, filter)
+	}
+
 	// Get commands from database with filters
-	commands, err := getCommandsWithFilters(database, histRange.First, histRange.Last, fcPattern, fcInternal, false)
+	commands, err := getCommandsWithFilters(database, histRange.First, histRange.Last, filter, false, listOrder(fcReverse, histRange))
 	if err != nil {
 		return err
 	}
 
-	// Apply reverse if requested via flag OR if range was specified in reverse order
-	if fcReverse || histRange.WasReversed {
-		reverseCommands(commands)
+	// zsh marks events from other sessions; without a session nothing is foreign
+	var sessionPid int64
+	if fcZshCompat {
+		sessionPid, _ = getSessionPid()
 	}
 
 	// Output commands
@@ -596,6 +817,22 @@ func runListMode(cmd *cobra.Command, args []string, database *db.DB) error {
 			commandText = applySubstitutions(commandText, substitutions)
 		}
 
+		if fcZshCompat {
+			var fields []string
+			if fcExit {
+				fields = append(fields, fmt.Sprintf("[%d]", c.ExitStatus))
+			}
+			if timeStr := formatZshTimestamp(c.Timestamp, fcTimeCustom, fcTimeISO, fcTimeUS, fcTimeEU, fcShowTime); timeStr != "" {
+				fields = append(fields, timeStr)
+			}
+			if fcElapsedTime {
+				fields = append(fields, formatZshDuration(c.Duration))
+			}
+			foreign := sessionPid != 0 && c.SourcePid != nil && *c.SourcePid != sessionPid
+			fmt.Fprintln(cmd.OutOrStdout(), formatZshListLine(c.ID, foreign, fcNoNum, fields, commandText))
+			continue
+		}
+
 		// Build output line
 		var line string
 
@@ -604,6 +841,14 @@ func runListMode(cmd *cobra.Command, args []string, database *db.DB) error {
 			line = fmt.Sprintf("%5d", c.ID)
 		}
 
+		// Add exit status if -x flag is set
+		if fcExit {
+			if line != "" {
+				line += " "
+			}
+			line += fmt.Sprintf("[%d]", c.ExitStatus)
+		}
+
 		// Add timestamp if any time flag is set
 		timeStr := formatTimestamp(c.Timestamp, fcTimeCustom, fcTimeISO, fcTimeUS, fcTimeEU, fcShowTime)
 		if timeStr != "" {
@@ -634,13 +879,79 @@ func runListMode(cmd *cobra.Command, args []string, database *db.DB) error {
 	return nil
 }
 
+// formatZshListLine lays out one fc -l entry byte for byte as zsh does: the
+// event number right-aligned in five columns, a marker column ('*' for an
+// event from another session), a space, then each field followed by two
+// spaces, then the command with its newlines written as \n.
+func formatZshListLine(id int64, foreign, noNum bool, fields []string, text string) string {
+	var b strings.Builder
+	if !noNum {
+		marker := ' '
+		if foreign {
+			marker = '*'
+		}
+		fmt.Fprintf(&b, "%5d%c ", id, marker)
+	}
+	for _, f := range fields {
+		b.WriteString(f)
+		b.WriteString("  ")
+	}
+	b.WriteString(strings.ReplaceAll(text, "\n", `\n`))
+	return b.String()
+}
+
+// formatZshTimestamp is formatTimestamp with zsh's own formats: -d alone is
+// hh:mm, and -f and -E do not zero-pad the day and month
+func formatZshTimestamp(timestamp int64, timeCustom string, timeISO, timeUS, timeEU, showTime bool) string {
+	t := time.Unix(timestamp, 0).UTC()
+	switch {
+	case timeCustom != "":
+		return strftime.Format(timeCustom, t)
+	case timeISO:
+		return t.Format("2006-01-02 15:04")
+	case timeUS:
+		return t.Format("1/2/06 15:04")
+	case timeEU:
+		return t.Format("2.1.2006 15:04")
+	case showTime:
+		return t.Format("15:04")
+	}
+	return ""
+}
+
+// formatZshDuration formats a duration as zsh's fc -D does: whole minutes
+// (unpadded, however many) and two-digit seconds
+func formatZshDuration(durationMs *int64) string {
+	var totalSeconds int64
+	if durationMs != nil {
+		totalSeconds = *durationMs / 1000
+	}
+	return fmt.Sprintf("%d:%02d", totalSeconds/60, totalSeconds%60)
+}
+
+// runCountMode handles --count: prints the number of commands -l would list.
+// Like -l, a filtered query with no matches exits non-zero (after printing 0).
+func runCountMode(cmd *cobra.Command, database *db.DB, first, last int64, filter db.RangeFilter) error {
+	count, err := database.CountCommandsByRange(first, last, filter)
+	if err != nil {
+		return fmt.Errorf("failed to count commands: %w", err)
+	}
+
+	fmt.Fprintln(cmd.OutOrStdout(), count)
+
+	if count == 0 && filter != (db.RangeFilter{}) {
+		return ErrNoMatches
+	}
+	return nil
+}
+
 // runEditMode handles default mode: edit and execute commands
 func runEditMode(cmd *cobra.Command, args []string, database *db.DB) error {
 	// Get flags needed for edit mode
-	fcPattern, _ := cmd.Flags().GetString("match")
-	fcInternal, _ := cmd.Flags().GetBool("internal")
 	fcEditor, _ := cmd.Flags().GetString("editor")
 	fcQuickExec, _ := cmd.Flags().GetBool("quick-exec")
+	fcConfirm, _ := cmd.Flags().GetBool("confirm")
+	fcYes, _ := cmd.Flags().GetBool("yes")
 
 	// Parse substitutions
 	substitutions, remainingArgs, err := parseSubstitutions(args)
@@ -659,8 +970,13 @@ func runEditMode(cmd *cobra.Command, args []string, database *db.DB) error {
 		return fmt.Errorf("shy fc: history events can't be executed backwards, aborted")
 	}
 
+	filter, err := fcRangeFilter(cmd, database)
+	if err != nil {
+		return err
+	}
+
 	// Delegate to existing edit-and-execute handler
-	return editAndExecuteMode(cmd, database, histRange.First, histRange.Last, substitutions, fcPattern, fcInternal, fcEditor, fcQuickExec)
+	return editAndExecuteMode(cmd, database, histRange.First, histRange.Last, substitutions, filter, fcEditor, fcQuickExec, fcConfirm, fcYes)
 }
 
 // parseHistoryRangeForFileOp parses range for file operations (defaults to ALL commands)
@@ -691,54 +1007,103 @@ func parseHistoryRangeForEdit(args []string, database *db.DB) (HistoryRange, err
 	return parseHistoryRange(args, database, false)
 }
 
-// getCommandsWithFilters retrieves commands with optional pattern and session filtering
-func getCommandsWithFilters(database *db.DB, first, last int64, pattern string, internal bool, allowEmpty bool) ([]models.Command, error) {
-	var commands []models.Command
-	var err error
-	hasFilters := pattern != "" || internal
+// getCommandsWithFilters retrieves the commands of a range that pass filter,
+// already sorted in the requested order
+func getCommandsWithFilters(database *db.DB, first, last int64, filter db.RangeFilter, allowEmpty bool, order db.SortOrder) ([]models.Command, error) {
+	commands, err := database.GetCommandsByRangeOrdered(first, last, filter, order)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commands: %w", err)
+	}
+
+	// Only error on empty results if we have filters and allowEmpty is false
+	// Empty database with no filters is not an error
+	if len(commands) == 0 && filter != (db.RangeFilter{}) && !allowEmpty {
+		return nil, ErrNoMatches
+	}
+
+	return commands, nil
+}
+
+// fcRangeFilter builds the range filter from the -m, -I, -L, --app and
+// --min-duration flags
+func fcRangeFilter(cmd *cobra.Command, database *db.DB) (db.RangeFilter, error) {
+	var filter db.RangeFilter
 
-	if internal {
-		// Get current session PID
-		sessionPid, err := getSessionPid()
+	if pattern, _ := cmd.Flags().GetString("match"); pattern != "" {
+		filter.Pattern = globToLike(pattern)
+	}
+
+	if internal, _ := cmd.Flags().GetBool("internal"); internal {
+		pid, err := getSessionPid()
 		if err != nil {
-			return nil, err
+			return filter, err
 		}
+		filter.SessionPid = pid
+	}
 
-		if pattern != "" {
-			// Both internal and pattern filtering
-			likePattern := globToLike(pattern)
-			commands, err = database.GetCommandsByRangeWithPatternInternal(first, last, sessionPid, likePattern)
-		} else {
-			// Internal filtering only
-			commands, err = database.GetCommandsByRangeInternal(first, last, sessionPid)
-		}
-	} else if pattern != "" {
-		// Pattern filtering only
-		likePattern := globToLike(pattern)
-		commands, err = database.GetCommandsByRangeWithPattern(first, last, likePattern)
-	} else {
-		// No filtering
-		commands, err = database.GetCommandsByRangeFull(first, last)
+	local, _ := cmd.Flags().GetBool("local")
+	workingDir, err := fcLocalDir(local)
+	if err != nil {
+		return filter, err
+	}
+	filter.WorkingDir = workingDir
+
+	app, _ := cmd.Flags().GetString("app")
+	if err := validateSourceApp(database, app); err != nil {
+		return filter, err
 	}
+	filter.SourceApp = app
 
+	minDuration, err := minDurationFlag(cmd)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get commands: %w", err)
+		return filter, err
 	}
+	filter.MinDuration = minDuration
 
-	// Only error on empty results if we have filters and allowEmpty is false
-	// Empty database with no filters is not an error
-	if len(commands) == 0 && hasFilters && !allowEmpty {
-		return nil, fmt.Errorf("shy fc: no matching events found")
+	return filter, nil
+}
+
+// validateSourceApp checks --app against the apps commands have been
+// recorded from, listing them when it names none of them
+func validateSourceApp(database *db.DB, app string) error {
+	if app == "" {
+		return nil
+	}
+	apps, err := database.GetUniqueSourceApps()
+	if err != nil {
+		return err
+	}
+	if slices.Contains(apps, app) {
+		return nil
+	}
+	if len(apps) == 0 {
+		return fmt.Errorf("shy fc: unknown app %q: no commands have a source app", app)
 	}
+	return fmt.Errorf("shy fc: unknown app %q (known apps: %s)", app, strings.Join(apps, ", "))
+}
 
-	return commands, nil
+// minDurationFlag parses --min-duration into milliseconds, the unit durations
+// are stored in (the extended history format's seconds are scaled on import).
+// An empty flag returns 0, which disables the filter.
+func minDurationFlag(cmd *cobra.Command) (int64, error) {
+	value, _ := cmd.Flags().GetString("min-duration")
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("shy fc: invalid --min-duration %q: expected a duration such as 500ms, 5s or 1m30s", value)
+	}
+	return d.Milliseconds(), nil
 }
 
-// reverseCommands reverses a slice of commands in place
-func reverseCommands(commands []models.Command) {
-	for i, j := 0, len(commands)-1; i < j; i, j = i+1, j-1 {
-		commands[i], commands[j] = commands[j], commands[i]
+// listOrder returns the order for listing: descending if requested via flag
+// OR if the range was specified in reverse order
+func listOrder(reverse bool, histRange HistoryRange) db.SortOrder {
+	if reverse || histRange.WasReversed {
+		return db.Descending
 	}
+	return db.Ascending
 }
 
 // substitution represents an old=new string substitution
@@ -818,7 +1183,7 @@ func parseEventID(arg string, mostRecent int64, database *db.DB) (int64, error)
 		return 0, err
 	}
 	if matchID == 0 {
-		return 0, fmt.Errorf("shy fc: event not found: %s", arg)
+		return 0, fmt.Errorf("%w: %s", ErrEventNotFound, arg)
 	}
 	return matchID, nil
 }
@@ -900,7 +1265,7 @@ func parseHistoryRange(args []string, database *db.DB, listMode bool) (HistoryRa
 		}
 
 	default:
-		return HistoryRange{}, fmt.Errorf("shy fc: too many arguments")
+		return HistoryRange{}, ErrTooManyArgs
 	}
 
 	return HistoryRange{
@@ -949,8 +1314,39 @@ func formatExtendedLine(timestamp int64, durationMs int64, command string) strin
 	return fmt.Sprintf(": %d:%d;%s\n", timestamp, durationSec, command)
 }
 
-// writeHistoryToFile writes commands to a file in zsh extended history format
-func writeHistoryToFile(filePath string, commands []models.Command) error {
+// readHistoryHeader returns the comment and blank lines a history file starts
+// with, up to its first command, or nil when the file does not exist. -R
+// skips these lines, so writing them back with -W --keep-comments lets a
+// curated file round-trip through the database unchanged.
+func readHistoryHeader(filePath string) ([]string, error) {
+	file, err := os.Open(filePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("shy fc: cannot read %s: %w", filePath, err)
+	}
+	defer file.Close()
+
+	var header []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			break
+		}
+		header = append(header, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading file: %w", err)
+	}
+	return header, nil
+}
+
+// writeHistoryToFile writes header lines, then commands in zsh extended
+// history format, to a file
+func writeHistoryToFile(filePath string, header []string, commands []models.Command) error {
 	// Create parent directories if they don't exist
 	dir := filepath.Dir(filePath)
 	if dir != "" && dir != "." {
@@ -969,6 +1365,12 @@ func writeHistoryToFile(filePath string, commands []models.Command) error {
 	writer := bufio.NewWriter(file)
 	defer writer.Flush()
 
+	for _, line := range header {
+		if _, err := writer.WriteString(line + "\n"); err != nil {
+			return fmt.Errorf("failed to write to file: %w", err)
+		}
+	}
+
 	for _, cmd := range commands {
 		duration := int64(0)
 		if cmd.Duration != nil {
@@ -1021,7 +1423,7 @@ func appendHistoryToFile(filePath string, commands []models.Command) error {
 
 // readHistoryFromFile reads commands from a file and imports them into the database
 // Automatically detects simple vs extended format
-func readHistoryFromFile(filePath string, database *db.DB) error {
+func readHistoryFromFile(filePath string, database *db.DB, prog *progress) error {
 	// Check if file exists and is readable
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -1044,7 +1446,8 @@ func readHistoryFromFile(filePath string, database *db.DB) error {
 	scanner := bufio.NewScanner(file)
 	currentTime := time.Now().Unix()
 
-	for scanner.Scan() {
+	for lines := 1; scanner.Scan(); lines++ {
+		prog.update(lines)
 		line := scanner.Text()
 
 		// Skip blank lines
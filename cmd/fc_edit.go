@@ -1,7 +1,9 @@
 package cmd
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"strings"
@@ -14,44 +16,26 @@ import (
 
 // Injectable function variables for testing
 var (
-	invokeEditorFunc = invokeEditorReal
-	executeShellFunc = executeShellReal
+	invokeEditorFunc           = invokeEditorReal
+	executeShellFunc           = executeShellReal
+	stdinIsTerminal            = stdinIsTerminalReal
+	confirmInput     io.Reader = os.Stdin
 )
 
 var osExit = os.Exit
 
 // editAndExecuteMode orchestrates the edit-and-execute workflow
 func editAndExecuteMode(cmd *cobra.Command, database *db.DB, first, last int64,
-	substitutions []substitution, fcPattern string, fcInternal bool,
-	fcEditor string, fcQuickExec bool) error {
+	substitutions []substitution, filter db.RangeFilter,
+	fcEditor string, fcQuickExec bool, fcConfirm, fcYes bool) error {
 
 	// 1. Validate range (backwards check)
 	if first > last {
 		return fmt.Errorf("shy fc: history events can't be executed backwards, aborted")
 	}
 
-	// 2. Get commands from database (respect pattern/internal filters)
-	var commands []models.Command
-	var err error
-
-	if fcInternal {
-		sessionPid, err := getSessionPid()
-		if err != nil {
-			return err
-		}
-		if fcPattern != "" {
-			likePattern := globToLike(fcPattern)
-			commands, err = database.GetCommandsByRangeWithPatternInternal(first, last, sessionPid, likePattern)
-		} else {
-			commands, err = database.GetCommandsByRangeInternal(first, last, sessionPid)
-		}
-	} else if fcPattern != "" {
-		likePattern := globToLike(fcPattern)
-		commands, err = database.GetCommandsByRangeWithPattern(first, last, likePattern)
-	} else {
-		commands, err = database.GetCommandsByRange(first, last)
-	}
-
+	// 2. Get commands from database (respect the -m, -I, -L, --app and --min-duration filters)
+	commands, err := database.GetCommandsByRangeOrdered(first, last, filter, db.Ascending)
 	if err != nil {
 		return fmt.Errorf("failed to get commands: %w", err)
 	}
@@ -82,7 +66,15 @@ func editAndExecuteMode(cmd *cobra.Command, database *db.DB, first, last int64,
 	}
 
 	// 6. If -s flag: execute directly, skip editor
+	// Substituted commands are never seen in an editor, so confirm them
+	// interactively unless --yes was given
 	if fcQuickExec {
+		if needsConfirm(fcConfirm || (len(substitutions) > 0 && stdinIsTerminal()), fcYes) {
+			ok, err := confirmExecute(cmd.OutOrStdout(), commandTexts)
+			if err != nil || !ok {
+				return err
+			}
+		}
 		return executeCommands(database, commandTexts)
 	}
 
@@ -128,10 +120,56 @@ func editAndExecuteMode(cmd *cobra.Command, database *db.DB, first, last int64,
 		return nil
 	}
 
-	// 11. Execute edited commands
+	// 11. Confirm if requested, then execute edited commands
+	if needsConfirm(fcConfirm, fcYes) {
+		ok, err := confirmExecute(cmd.OutOrStdout(), editedCommands)
+		if err != nil || !ok {
+			return err
+		}
+	}
 	return executeCommands(database, editedCommands)
 }
 
+// needsConfirm reports whether to prompt before executing; --yes always wins
+func needsConfirm(want, yes bool) bool {
+	return want && !yes
+}
+
+// confirmExecute prints the commands about to run and asks y/N
+func confirmExecute(out io.Writer, commands []string) (bool, error) {
+	return confirmPrompt(out, "shy fc", commands, "execute")
+}
+
+// confirmPrompt prints lines, then asks "<name>: <action>? [y/N]"
+// Anything other than y or yes (case-insensitive) declines
+func confirmPrompt(out io.Writer, name string, lines []string, action string) (bool, error) {
+	for _, line := range lines {
+		fmt.Fprintln(out, line)
+	}
+	fmt.Fprintf(out, "%s: %s? [y/N] ", name, action)
+
+	line, err := bufio.NewReader(confirmInput).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, fmt.Errorf("failed to read confirmation: %w", err)
+	}
+
+	answer := strings.ToLower(strings.TrimSpace(line))
+	if answer == "y" || answer == "yes" {
+		return true, nil
+	}
+	fmt.Fprintf(out, "%s: aborted\n", name)
+	return false, nil
+}
+
+// stdinIsTerminalReal reports whether stdin is an interactive terminal
+func stdinIsTerminalReal() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
 // getEditor determines which editor to use
 func getEditor(fcEditor string) (string, error) {
 	// Priority order:
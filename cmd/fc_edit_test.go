@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"bytes"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -52,6 +53,7 @@ func setupCommandCapture(t *testing.T) (*commandCapture, func()) {
 		commands: []string{},
 	}
 	oldExec := executeShellFunc
+	oldIsTerminal := stdinIsTerminal
 
 	executeShellFunc = func(shell, cmdText string) error {
 		capture.mu.Lock()
@@ -59,9 +61,23 @@ func setupCommandCapture(t *testing.T) (*commandCapture, func()) {
 		capture.commands = append(capture.commands, cmdText)
 		return nil
 	}
+	// Tests are non-interactive unless they opt in
+	stdinIsTerminal = func() bool { return false }
 
 	return capture, func() {
 		executeShellFunc = oldExec
+		stdinIsTerminal = oldIsTerminal
+	}
+}
+
+// setupConfirmInput injects the answer read by the y/N confirmation prompt
+// Returns a cleanup function that should be deferred
+func setupConfirmInput(t *testing.T, answer string) func() {
+	oldInput := confirmInput
+	confirmInput = strings.NewReader(answer)
+
+	return func() {
+		confirmInput = oldInput
 	}
 }
 
@@ -106,7 +122,7 @@ func TestScenario_EditAndExecuteSingleCommand(t *testing.T) {
 	assert.Equal(t, []string{"git log"}, capture.commands)
 
 	// Verify command was added to history
-	commands, err := database.GetCommandsByRange(1, 100)
+	commands, err := database.GetCommandsByRangeOrdered(1, 100, db.RangeFilter{}, db.Ascending)
 	require.NoError(t, err)
 	assert.Equal(t, 2, len(commands)) // Original + executed
 	assert.Equal(t, "git log", commands[1].CommandText)
@@ -184,6 +200,61 @@ func TestScenario_QuickExecuteWithMultipleSubstitutions(t *testing.T) {
 	rootCmd.SetArgs(nil)
 }
 
+// TestScenario_QuickExecuteHonorsLocalAndApp tests that -s runs only the
+// range's commands that pass -L and --app, like fc -l lists them
+func TestScenario_QuickExecuteHonorsLocalAndApp(t *testing.T) {
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+
+	tempDir := t.TempDir()
+	dbPath := filepath.Join(tempDir, "history.db")
+	database, err := db.NewForTesting(dbPath)
+	require.NoError(t, err)
+	defer database.Close()
+
+	zsh, bash := "zsh", "bash"
+	pid := int64(100)
+	for i, c := range []struct {
+		text string
+		dir  string
+		app  *string
+	}{
+		{"make build", cwd, &zsh},
+		{"ls /tmp", "/tmp", &zsh},
+		{"make test", cwd, &bash},
+	} {
+		_, err := database.InsertCommand(&models.Command{
+			Timestamp:   int64(1234567890 + i),
+			CommandText: c.text,
+			WorkingDir:  c.dir,
+			SourceApp:   c.app,
+			SourcePid:   &pid,
+		})
+		require.NoError(t, err)
+	}
+
+	capture, cleanupExec := setupCommandCapture(t)
+	defer cleanupExec()
+
+	run := func(args ...string) {
+		defer resetFcFlags(fcCmd)
+		capture.commands = nil
+		rootCmd.SetArgs(append([]string{"fc", "-s", "--db", dbPath}, args...))
+		require.NoError(t, rootCmd.Execute())
+	}
+
+	run("-L", "1", "3")
+	assert.Equal(t, []string{"make build", "make test"}, capture.commands)
+
+	run("--app", "zsh", "1", "3")
+	assert.Equal(t, []string{"make build", "ls /tmp"}, capture.commands)
+
+	run("-L", "--app", "bash", "1", "3")
+	assert.Equal(t, []string{"make test"}, capture.commands)
+
+	rootCmd.SetArgs(nil)
+}
+
 // TestScenario_CannotCombineSWithE tests flag conflict validation
 func TestScenario_CannotCombineSWithE(t *testing.T) {
 	defer resetFcFlags(fcCmd)
@@ -686,3 +757,128 @@ func TestScenario_EditRangeWithNegativeNumbers(t *testing.T) {
 
 	rootCmd.SetArgs(nil)
 }
+
+// setupConfirmScenario creates a database with a single command for confirm tests
+func setupConfirmScenario(t *testing.T) (string, *db.DB) {
+	tempDir := t.TempDir()
+	dbPath := filepath.Join(tempDir, "history.db")
+	database, err := db.NewForTesting(dbPath)
+	require.NoError(t, err)
+
+	_, err = database.InsertCommand(&models.Command{
+		Timestamp:   1234567892,
+		CommandText: "git push origin main",
+		WorkingDir:  "/tmp",
+		ExitStatus:  0,
+	})
+	require.NoError(t, err)
+
+	return dbPath, database
+}
+
+// TestScenario_ConfirmAcceptedExecutes tests --confirm with a yes answer
+func TestScenario_ConfirmAcceptedExecutes(t *testing.T) {
+	defer resetFcFlags(fcCmd)
+
+	dbPath, database := setupConfirmScenario(t)
+	defer database.Close()
+
+	capture, cleanupExec := setupCommandCapture(t)
+	defer cleanupExec()
+	cleanupInput := setupConfirmInput(t, "y\n")
+	defer cleanupInput()
+
+	var buf bytes.Buffer
+	rootCmd.SetOut(&buf)
+	rootCmd.SetArgs([]string{"fc", "-s", "--confirm", "git=svn", "--db", dbPath, "1"})
+	err := rootCmd.Execute()
+	require.NoError(t, err)
+
+	assert.Contains(t, buf.String(), "svn push origin main\n")
+	assert.Contains(t, buf.String(), "execute? [y/N]")
+	assert.Equal(t, []string{"svn push origin main"}, capture.commands)
+
+	rootCmd.SetOut(nil)
+	rootCmd.SetArgs(nil)
+}
+
+// TestScenario_ConfirmDeclinedAborts tests that declining runs nothing and records nothing
+func TestScenario_ConfirmDeclinedAborts(t *testing.T) {
+	defer resetFcFlags(fcCmd)
+
+	dbPath, database := setupConfirmScenario(t)
+	defer database.Close()
+
+	capture, cleanupExec := setupCommandCapture(t)
+	defer cleanupExec()
+	cleanupInput := setupConfirmInput(t, "\n")
+	defer cleanupInput()
+
+	var buf bytes.Buffer
+	rootCmd.SetOut(&buf)
+	rootCmd.SetArgs([]string{"fc", "-s", "--confirm", "git=svn", "--db", dbPath, "1"})
+	err := rootCmd.Execute()
+	require.NoError(t, err)
+
+	assert.Contains(t, buf.String(), "shy fc: aborted")
+	assert.Empty(t, capture.commands)
+
+	commands, err := database.GetCommandsByRangeOrdered(1, 100, db.RangeFilter{}, db.Ascending)
+	require.NoError(t, err)
+	assert.Equal(t, 1, len(commands))
+
+	rootCmd.SetOut(nil)
+	rootCmd.SetArgs(nil)
+}
+
+// TestScenario_SubstitutionPromptsOnTerminal tests the default prompt for -s with old=new
+func TestScenario_SubstitutionPromptsOnTerminal(t *testing.T) {
+	defer resetFcFlags(fcCmd)
+
+	dbPath, database := setupConfirmScenario(t)
+	defer database.Close()
+
+	capture, cleanupExec := setupCommandCapture(t)
+	defer cleanupExec()
+	stdinIsTerminal = func() bool { return true }
+	cleanupInput := setupConfirmInput(t, "n\n")
+	defer cleanupInput()
+
+	var buf bytes.Buffer
+	rootCmd.SetOut(&buf)
+	rootCmd.SetArgs([]string{"fc", "-s", "git=svn", "--db", dbPath, "1"})
+	err := rootCmd.Execute()
+	require.NoError(t, err)
+
+	assert.Contains(t, buf.String(), "execute? [y/N]")
+	assert.Empty(t, capture.commands)
+
+	rootCmd.SetOut(nil)
+	rootCmd.SetArgs(nil)
+}
+
+// TestScenario_YesSkipsConfirm tests that --yes executes without prompting
+func TestScenario_YesSkipsConfirm(t *testing.T) {
+	defer resetFcFlags(fcCmd)
+
+	dbPath, database := setupConfirmScenario(t)
+	defer database.Close()
+
+	capture, cleanupExec := setupCommandCapture(t)
+	defer cleanupExec()
+	stdinIsTerminal = func() bool { return true }
+	cleanupInput := setupConfirmInput(t, "n\n")
+	defer cleanupInput()
+
+	var buf bytes.Buffer
+	rootCmd.SetOut(&buf)
+	rootCmd.SetArgs([]string{"fc", "-s", "--confirm", "--yes", "git=svn", "--db", dbPath, "1"})
+	err := rootCmd.Execute()
+	require.NoError(t, err)
+
+	assert.NotContains(t, buf.String(), "execute? [y/N]")
+	assert.Equal(t, []string{"svn push origin main"}, capture.commands)
+
+	rootCmd.SetOut(nil)
+	rootCmd.SetArgs(nil)
+}
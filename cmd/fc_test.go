@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -956,8 +957,8 @@ func TestInternalScenario16_InternalFilterWhenSessionIsClosed(t *testing.T) {
 	rootCmd.SetArgs(nil)
 }
 
-// Scenario 17: Local filter behaves identically to no filter
-func TestLocalScenario17_LocalFilterBehavesIdenticallyToNoFilter(t *testing.T) {
+// Scenario 17: Local filter lists only the current directory's commands
+func TestLocalScenario17_LocalFilterScopesToCurrentDirectory(t *testing.T) {
 	defer resetFcFlags(fcCmd)
 
 	tempDir := t.TempDir()
@@ -967,6 +968,10 @@ func TestLocalScenario17_LocalFilterBehavesIdenticallyToNoFilter(t *testing.T) {
 	require.NoError(t, err)
 	defer database.Close()
 
+	t.Chdir(tempDir)
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+
 	// Setup test data
 	sourceApp1 := "zsh"
 	sourcePid1 := int64(12345)
@@ -982,19 +987,20 @@ func TestLocalScenario17_LocalFilterBehavesIdenticallyToNoFilter(t *testing.T) {
 
 	commands := []struct {
 		text   string
+		dir    string
 		app    *string
 		pid    *int64
 		active *bool
 	}{
-		{"ls", &sourceApp1, &sourcePid1, &sourceActive1},
-		{"pwd", &sourceApp2, &sourcePid2, &sourceActive2},
-		{"echo test", &sourceApp3, &sourcePid3, &sourceActive3},
+		{"ls", cwd, &sourceApp1, &sourcePid1, &sourceActive1},
+		{"pwd", "/home/test", &sourceApp2, &sourcePid2, &sourceActive2},
+		{"echo test", cwd, &sourceApp3, &sourcePid3, &sourceActive3},
 	}
 
 	for _, cmd := range commands {
 		c := &models.Command{
 			CommandText:  cmd.text,
-			WorkingDir:   "/home/test",
+			WorkingDir:   cmd.dir,
 			ExitStatus:   0,
 			Timestamp:    int64(1704470400),
 			SourceApp:    cmd.app,
@@ -1014,6 +1020,7 @@ func TestLocalScenario17_LocalFilterBehavesIdenticallyToNoFilter(t *testing.T) {
 	require.NoError(t, err)
 
 	outputNoFlag := bufNoFlag.String()
+	assert.Contains(t, outputNoFlag, "pwd")
 
 	// Run "shy fc -l -L" with -L flag
 	var bufWithL bytes.Buffer
@@ -1023,16 +1030,28 @@ func TestLocalScenario17_LocalFilterBehavesIdenticallyToNoFilter(t *testing.T) {
 	err = rootCmd.Execute()
 	require.NoError(t, err)
 
-	outputWithL := bufWithL.String()
+	// Then: only commands run in the current directory, from any session
+	assert.Equal(t, "    1  ls\n    3  echo test\n", bufWithL.String())
+
+	// -L composes with -m
+	var bufWithMatch bytes.Buffer
+	rootCmd.SetOut(&bufWithMatch)
+	rootCmd.SetArgs([]string{"fc", "-l", "-L", "-m", "echo*", "--db", dbPath})
 
-	// Then: outputs should be identical
-	assert.Equal(t, outputNoFlag, outputWithL, "-L flag should produce identical output to no flag")
+	err = rootCmd.Execute()
+	require.NoError(t, err)
+	assert.Equal(t, "    3  echo test\n", bufWithMatch.String())
+
+	// Nothing matching in this directory is an error, like -m
+	rootCmd.SetArgs([]string{"fc", "-l", "-L", "-m", "pwd", "--db", dbPath})
+	err = rootCmd.Execute()
+	assert.EqualError(t, err, "shy fc: no matching events found")
 
 	rootCmd.SetArgs(nil)
 }
 
-// Scenario 18: Local filter with range produces same results as without
-func TestLocalScenario18_LocalFilterWithRangeProducesSameResults(t *testing.T) {
+// Scenario 18: Local filter composes with a range
+func TestLocalScenario18_LocalFilterWithRange(t *testing.T) {
 	defer resetFcFlags(fcCmd)
 
 	tempDir := t.TempDir()
@@ -1042,13 +1061,22 @@ func TestLocalScenario18_LocalFilterWithRangeProducesSameResults(t *testing.T) {
 	require.NoError(t, err)
 	defer database.Close()
 
+	t.Chdir(tempDir)
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+
 	// Setup test data
-	commands := []string{"cmd1", "cmd2", "cmd3", "cmd4"}
+	commands := []struct{ text, dir string }{
+		{"cmd1", cwd},
+		{"cmd2", cwd},
+		{"cmd3", "/home/test"},
+		{"cmd4", cwd},
+	}
 
-	for _, cmdText := range commands {
+	for _, cmd := range commands {
 		c := &models.Command{
-			CommandText: cmdText,
-			WorkingDir:  "/home/test",
+			CommandText: cmd.text,
+			WorkingDir:  cmd.dir,
 			ExitStatus:  0,
 			Timestamp:   int64(1704470400),
 		}
@@ -1056,16 +1084,6 @@ func TestLocalScenario18_LocalFilterWithRangeProducesSameResults(t *testing.T) {
 		require.NoError(t, err)
 	}
 
-	// Run "shy fc -l 2 4" without -L flag (IDs 2-4)
-	var bufNoFlag bytes.Buffer
-	rootCmd.SetOut(&bufNoFlag)
-	rootCmd.SetArgs([]string{"fc", "-l", "2", "4", "--db", dbPath})
-
-	err = rootCmd.Execute()
-	require.NoError(t, err)
-
-	outputNoFlag := bufNoFlag.String()
-
 	// Run "shy fc -l 2 4 -L" with -L flag
 	var bufWithL bytes.Buffer
 	rootCmd.SetOut(&bufWithL)
@@ -1076,15 +1094,21 @@ func TestLocalScenario18_LocalFilterWithRangeProducesSameResults(t *testing.T) {
 
 	outputWithL := bufWithL.String()
 
-	// Then: outputs should be identical
-	assert.Equal(t, outputNoFlag, outputWithL, "-L flag with range should produce identical output to no flag")
-
-	// Verify the output contains the expected commands
+	// Then: the range still selects event numbers, limited to this directory
 	assert.Contains(t, outputWithL, "cmd2")
-	assert.Contains(t, outputWithL, "cmd3")
 	assert.Contains(t, outputWithL, "cmd4")
+	assert.NotContains(t, outputWithL, "cmd3")
 	assert.NotContains(t, outputWithL, "cmd1")
 
+	// --count agrees with the listing
+	var bufCount bytes.Buffer
+	rootCmd.SetOut(&bufCount)
+	rootCmd.SetArgs([]string{"fc", "-l", "2", "4", "-L", "--count", "--db", dbPath})
+
+	err = rootCmd.Execute()
+	require.NoError(t, err)
+	assert.Equal(t, "2\n", bufCount.String())
+
 	rootCmd.SetArgs(nil)
 }
 
@@ -1719,7 +1743,7 @@ func TestFileOp_ReadSimpleFormat(t *testing.T) {
 	require.NoError(t, err)
 
 	// Verify commands were imported
-	commands, err := database.GetCommandsByRange(1, 100)
+	commands, err := database.GetCommandsByRangeOrdered(1, 100, db.RangeFilter{}, db.Ascending)
 	require.NoError(t, err)
 	require.Len(t, commands, 3)
 	assert.Equal(t, "echo \"imported 1\"", commands[0].CommandText)
@@ -1755,7 +1779,7 @@ func TestFileOp_ReadExtendedFormat(t *testing.T) {
 	require.NoError(t, err)
 
 	// Verify commands were imported with timestamps and durations
-	commands, err := database.GetCommandsByRangeFull(1, 100)
+	commands, err := database.GetCommandsByRangeOrdered(1, 100, db.RangeFilter{}, db.Ascending)
 	require.NoError(t, err)
 	require.Len(t, commands, 3)
 
@@ -1799,7 +1823,7 @@ func TestFileOp_ReadMixedFormat(t *testing.T) {
 	require.NoError(t, err)
 
 	// Verify all commands were imported
-	commands, err := database.GetCommandsByRangeFull(1, 100)
+	commands, err := database.GetCommandsByRangeOrdered(1, 100, db.RangeFilter{}, db.Ascending)
 	require.NoError(t, err)
 	require.Len(t, commands, 3)
 
@@ -1837,7 +1861,7 @@ func TestFileOp_ReadSkipsBlankLinesAndComments(t *testing.T) {
 	require.NoError(t, err)
 
 	// Verify only commands were imported (no comments or blank lines)
-	commands, err := database.GetCommandsByRange(1, 100)
+	commands, err := database.GetCommandsByRangeOrdered(1, 100, db.RangeFilter{}, db.Ascending)
 	require.NoError(t, err)
 	require.Len(t, commands, 3)
 	assert.Equal(t, "echo \"line 1\"", commands[0].CommandText)
@@ -1869,6 +1893,61 @@ func TestFileOp_ReadNonExistentFile_Error(t *testing.T) {
 	rootCmd.SetArgs(nil)
 }
 
+// Test -R then -W --keep-comments: a curated file keeps its header comments
+// and blank lines through the database
+func TestFileOp_KeepCommentsRoundTrip(t *testing.T) {
+	defer resetFcFlags(fcCmd)
+
+	tempDir := t.TempDir()
+	dbPath := filepath.Join(tempDir, "history.db")
+	historyFile := filepath.Join(tempDir, "curated.history")
+
+	database, err := db.NewForTesting(dbPath)
+	require.NoError(t, err)
+	defer database.Close()
+
+	content := "# Deploy recipes\n#   kept by hand\n\n: 1700000000:3;make deploy\n: 1700000100:0;git push --tags\n"
+	require.NoError(t, os.WriteFile(historyFile, []byte(content), 0600))
+
+	// Run: shy fc -R curated.history --keep-comments
+	rootCmd.SetArgs([]string{"fc", "-R", historyFile, "--keep-comments", "--db", dbPath})
+	require.NoError(t, rootCmd.Execute())
+
+	commands, err := database.GetCommandsByRangeOrdered(1, 100, db.RangeFilter{}, db.Ascending)
+	require.NoError(t, err)
+	require.Len(t, commands, 2, "comments are never recorded")
+
+	// Run: shy fc -W curated.history --keep-comments
+	rootCmd.SetArgs([]string{"fc", "-W", historyFile, "--keep-comments", "--db", dbPath})
+	require.NoError(t, rootCmd.Execute())
+
+	written, err := os.ReadFile(historyFile)
+	require.NoError(t, err)
+	assert.Equal(t, content, string(written), "the file round-trips unchanged")
+
+	// Without the option -W writes only the commands, as before
+	rootCmd.SetArgs([]string{"fc", "-W", historyFile, "--db", dbPath})
+	require.NoError(t, rootCmd.Execute())
+	written, err = os.ReadFile(historyFile)
+	require.NoError(t, err)
+	assert.Equal(t, ": 1700000000:3;make deploy\n: 1700000100:0;git push --tags\n", string(written))
+
+	// A new file has no header to keep
+	newFile := filepath.Join(tempDir, "new.history")
+	rootCmd.SetArgs([]string{"fc", "-W", newFile, "--keep-comments", "--db", dbPath})
+	require.NoError(t, rootCmd.Execute())
+	written, err = os.ReadFile(newFile)
+	require.NoError(t, err)
+	assert.Equal(t, ": 1700000000:3;make deploy\n: 1700000100:0;git push --tags\n", string(written))
+
+	rootCmd.SetArgs([]string{"fc", "-l", "--keep-comments", "--db", dbPath})
+	err = rootCmd.Execute()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--keep-comments requires -W or -R")
+
+	rootCmd.SetArgs(nil)
+}
+
 // Test -W with pattern filter
 func TestFileOp_WriteWithPatternFilter(t *testing.T) {
 	defer resetFcFlags(fcCmd)
@@ -2084,3 +2163,691 @@ func TestFc_QuickExecAndEditorMutuallyExclusive(t *testing.T) {
 
 	rootCmd.SetArgs(nil)
 }
+
+// setupCountScenario creates a database with commands across two sessions for --count tests
+func setupCountScenario(t *testing.T) string {
+	t.Helper()
+	tempDir := t.TempDir()
+	dbPath := filepath.Join(tempDir, "history.db")
+
+	database, err := db.NewForTesting(dbPath)
+	require.NoError(t, err)
+	defer database.Close()
+
+	app := "zsh"
+	pid1, pid2 := int64(12345), int64(67890)
+	active := true
+
+	commands := []struct {
+		text string
+		pid  *int64
+	}{
+		{"git status", &pid1},
+		{"git commit", &pid2},
+		{"ls", &pid1},
+		{"git push", &pid1},
+		{"git status", &pid1},
+		{"git pull", &pid2},
+	}
+
+	for i, cmd := range commands {
+		_, err := database.InsertCommand(&models.Command{
+			CommandText:  cmd.text,
+			WorkingDir:   "/home/test",
+			ExitStatus:   0,
+			Timestamp:    int64(1704470400 + i),
+			SourceApp:    &app,
+			SourcePid:    cmd.pid,
+			SourceActive: &active,
+		})
+		require.NoError(t, err)
+	}
+
+	return dbPath
+}
+
+func TestFcCount(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		sessionPid string
+		expected   string
+		wantErr    bool
+	}{
+		{name: "all commands", args: []string{"-l", "--count"}, expected: "6\n"},
+		{name: "pattern dedups like -l", args: []string{"-l", "-m", "git*", "--count"}, expected: "4\n"},
+		{name: "pattern with range", args: []string{"-l", "-m", "git*", "--count", "1", "3"}, expected: "2\n"},
+		{name: "internal with pattern", args: []string{"-l", "-I", "-m", "git*", "--count"}, sessionPid: "12345", expected: "2\n"},
+		{name: "internal only", args: []string{"-l", "-I", "--count"}, sessionPid: "67890", expected: "2\n"},
+		{name: "no matches", args: []string{"-l", "-m", "svn*", "--count"}, expected: "0\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer resetFcFlags(fcCmd)
+			dbPath := setupCountScenario(t)
+
+			if tt.sessionPid != "" {
+				os.Setenv("SHY_SESSION_PID", tt.sessionPid)
+				defer os.Unsetenv("SHY_SESSION_PID")
+			}
+
+			var buf bytes.Buffer
+			rootCmd.SetOut(&buf)
+			rootCmd.SetArgs(append([]string{"fc", "--db", dbPath}, tt.args...))
+
+			err := rootCmd.Execute()
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "shy fc: no matching events found")
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.expected, buf.String())
+
+			rootCmd.SetOut(nil)
+			rootCmd.SetArgs(nil)
+		})
+	}
+}
+
+// TestFcNegativeRange tests that negative endpoints count back from the most
+// recent event, as in zsh, and compose with -m and -I
+func TestFcNegativeRange(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		sessionPid string
+		expected   []string
+	}{
+		{name: "last five", args: []string{"-l", "-5", "-1"}, expected: []string{"2", "3", "4", "5", "6"}},
+		{name: "single -1 lists the latest", args: []string{"-l", "-1"}, expected: []string{"6"}},
+		{name: "positive then negative", args: []string{"-l", "2", "-2"}, expected: []string{"2", "3", "4", "5"}},
+		{name: "negative then positive is reversed", args: []string{"-l", "-2", "2"}, expected: []string{"5", "4", "3", "2"}},
+		{name: "clamped to the first event", args: []string{"-l", "-10", "-5"}, expected: []string{"1", "2"}},
+		{name: "with pattern", args: []string{"-l", "-m", "git*", "-4", "-1"}, expected: []string{"4", "5", "6"}},
+		{name: "with internal", args: []string{"-l", "-I", "-3", "-1"}, sessionPid: "12345", expected: []string{"4", "5"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer resetFcFlags(fcCmd)
+			dbPath := setupCountScenario(t)
+
+			if tt.sessionPid != "" {
+				os.Setenv("SHY_SESSION_PID", tt.sessionPid)
+				defer os.Unsetenv("SHY_SESSION_PID")
+			}
+
+			var buf bytes.Buffer
+			rootCmd.SetOut(&buf)
+			rootCmd.SetArgs(append([]string{"fc", "--db", dbPath}, tt.args...))
+			require.NoError(t, rootCmd.Execute())
+
+			var ids []string
+			for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+				ids = append(ids, strings.Fields(line)[0])
+			}
+			assert.Equal(t, tt.expected, ids)
+
+			rootCmd.SetOut(nil)
+			rootCmd.SetArgs(nil)
+		})
+	}
+}
+
+func TestFcReverseMatchesReversedAscending(t *testing.T) {
+	for _, extra := range [][]string{{}, {"-m", "git*"}} {
+		t.Run(strings.Join(append([]string{"fc", "-l"}, extra...), " "), func(t *testing.T) {
+			defer resetFcFlags(fcCmd)
+			dbPath := setupCountScenario(t)
+
+			run := func(args ...string) []string {
+				var buf bytes.Buffer
+				rootCmd.SetOut(&buf)
+				rootCmd.SetArgs(append([]string{"fc", "--db", dbPath, "-l"}, args...))
+				require.NoError(t, rootCmd.Execute())
+				rootCmd.SetOut(nil)
+				rootCmd.SetArgs(nil)
+				return strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+			}
+
+			ascending := run(append(extra, "1", "6")...)
+			reversed := run(append(extra, "-r", "1", "6")...)
+			reversedRange := run(append(extra, "6", "1")...)
+			require.Greater(t, len(ascending), 1)
+
+			for i, j := 0, len(ascending)-1; i < j; i, j = i+1, j-1 {
+				ascending[i], ascending[j] = ascending[j], ascending[i]
+			}
+			assert.Equal(t, ascending, reversed)
+			assert.Equal(t, ascending, reversedRange)
+		})
+	}
+}
+
+// setupExitStatusScenario creates a database with succeeding and failing commands
+func setupExitStatusScenario(t *testing.T) string {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+
+	database, err := db.NewForTesting(dbPath)
+	require.NoError(t, err)
+	defer database.Close()
+
+	app := "zsh"
+	pid1, pid2 := int64(12345), int64(67890)
+	active := true
+
+	commands := []struct {
+		text   string
+		status int
+		pid    *int64
+	}{
+		{"git status", 0, &pid1},
+		{"go build", 2, &pid2},
+		{"go test", 1, &pid1},
+	}
+
+	for i, cmd := range commands {
+		_, err := database.InsertCommand(&models.Command{
+			CommandText:  cmd.text,
+			WorkingDir:   "/home/test",
+			ExitStatus:   cmd.status,
+			Timestamp:    int64(1704470400 + i),
+			SourceApp:    &app,
+			SourcePid:    cmd.pid,
+			SourceActive: &active,
+		})
+		require.NoError(t, err)
+	}
+
+	return dbPath
+}
+
+func TestFcExitStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		command    string
+		args       []string
+		sessionPid string
+		expected   string
+	}{
+		{
+			name:     "success and failure rows",
+			command:  "fc",
+			args:     []string{"-l", "-x", "1", "3"},
+			expected: "    1 [0]  git status\n    2 [2]  go build\n    3 [1]  go test\n",
+		},
+		{
+			name:     "without numbers",
+			command:  "fc",
+			args:     []string{"-l", "-x", "-n", "1", "3"},
+			expected: "[0]  git status\n[2]  go build\n[1]  go test\n",
+		},
+		{
+			name:     "reversed",
+			command:  "fc",
+			args:     []string{"-l", "--exit", "-r", "1", "3"},
+			expected: "    3 [1]  go test\n    2 [2]  go build\n    1 [0]  git status\n",
+		},
+		{
+			name:     "with pattern",
+			command:  "fc",
+			args:     []string{"-l", "-x", "-m", "go*", "1", "3"},
+			expected: "    2 [2]  go build\n    3 [1]  go test\n",
+		},
+		{
+			name:       "with session filter",
+			command:    "fc",
+			args:       []string{"-l", "-x", "-I", "1", "3"},
+			sessionPid: "12345",
+			expected:   "    1 [0]  git status\n    3 [1]  go test\n",
+		},
+		{
+			name:     "history",
+			command:  "history",
+			args:     []string{"-x", "1", "2"},
+			expected: "    1 [0]  git status\n    2 [2]  go build\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer resetFcFlags(fcCmd)
+			dbPath := setupExitStatusScenario(t)
+
+			if tt.sessionPid != "" {
+				os.Setenv("SHY_SESSION_PID", tt.sessionPid)
+				defer os.Unsetenv("SHY_SESSION_PID")
+			}
+
+			var buf bytes.Buffer
+			rootCmd.SetOut(&buf)
+			rootCmd.SetArgs(append([]string{tt.command, "--db", dbPath}, tt.args...))
+
+			require.NoError(t, rootCmd.Execute())
+			assert.Equal(t, tt.expected, buf.String())
+
+			rootCmd.SetOut(nil)
+			rootCmd.SetArgs(nil)
+		})
+	}
+}
+
+// TestFcZshCompat asserts the exact bytes of --zsh-compat lines: five-column
+// event numbers with a marker column, two spaces after each field, and
+// multi-line commands on one line
+func TestFcZshCompat(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	database, err := db.NewForTesting(dbPath)
+	require.NoError(t, err)
+
+	app := "zsh"
+	pid1, pid2 := int64(12345), int64(67890)
+	active := true
+	for i := 1; i <= 12; i++ {
+		pid := &pid1
+		if i == 11 {
+			pid = &pid2
+		}
+		text := fmt.Sprintf("echo %d", i)
+		if i == 12 {
+			text = "for f in *; do\n  echo $f\ndone"
+		}
+		duration := int64(i) * 61500
+		_, err := database.InsertCommand(&models.Command{
+			CommandText:  text,
+			WorkingDir:   "/home/test",
+			Timestamp:    int64(1704470400 + i*60), // 2024-01-05 16:01 UTC onwards
+			Duration:     &duration,
+			SourceApp:    &app,
+			SourcePid:    pid,
+			SourceActive: &active,
+		})
+		require.NoError(t, err)
+	}
+	require.NoError(t, database.Close())
+
+	tests := []struct {
+		name       string
+		args       []string
+		sessionPid string
+		expected   string
+	}{
+		{
+			name:     "numbers",
+			args:     []string{"-l", "9", "12"},
+			expected: "    9  echo 9\n   10  echo 10\n   11  echo 11\n   12  for f in *; do\\n  echo $f\\ndone\n",
+		},
+		{
+			name:       "other sessions marked",
+			args:       []string{"-l", "10", "11"},
+			sessionPid: "12345",
+			expected:   "   10  echo 10\n   11* echo 11\n",
+		},
+		{
+			name:     "time and elapsed",
+			args:     []string{"-l", "-d", "-D", "9", "10"},
+			expected: "    9  16:09  9:13  echo 9\n   10  16:10  10:15  echo 10\n",
+		},
+		{
+			name:     "european dates",
+			args:     []string{"-l", "-E", "9", "9"},
+			expected: "    9  5.1.2024 16:09  echo 9\n",
+		},
+		{
+			name:     "without numbers",
+			args:     []string{"-l", "-n", "10", "11"},
+			expected: "echo 10\necho 11\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer resetFcFlags(fcCmd)
+			if tt.sessionPid != "" {
+				os.Setenv("SHY_SESSION_PID", tt.sessionPid)
+				defer os.Unsetenv("SHY_SESSION_PID")
+			}
+
+			var buf bytes.Buffer
+			rootCmd.SetOut(&buf)
+			rootCmd.SetArgs(append([]string{"fc", "--db", dbPath, "--zsh-compat"}, tt.args...))
+
+			require.NoError(t, rootCmd.Execute())
+			assert.Equal(t, tt.expected, buf.String())
+
+			rootCmd.SetOut(nil)
+			rootCmd.SetArgs(nil)
+		})
+	}
+}
+
+func TestFcSet(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		answer   string
+		expected string
+		output   string
+	}{
+		{
+			name:     "force replaces without prompting",
+			args:     []string{"--set", "2", "--force", "go build ./..."},
+			expected: "go build ./...",
+			output:   "",
+		},
+		{
+			name:     "confirmed replace",
+			args:     []string{"--set", "2", "go build ./..."},
+			answer:   "y\n",
+			expected: "go build ./...",
+			output:   "2: go build\n2: go build ./...\nshy fc: replace? [y/N] ",
+		},
+		{
+			name:     "declined replace",
+			args:     []string{"--set", "2", "go build ./..."},
+			answer:   "n\n",
+			expected: "go build",
+			output:   "2: go build\n2: go build ./...\nshy fc: replace? [y/N] shy fc: aborted\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer resetFcFlags(fcCmd)
+			defer setupConfirmInput(t, tt.answer)()
+			dbPath := setupExitStatusScenario(t)
+
+			var buf bytes.Buffer
+			rootCmd.SetOut(&buf)
+			rootCmd.SetArgs(append([]string{"fc", "--db", dbPath}, tt.args...))
+
+			require.NoError(t, rootCmd.Execute())
+			assert.Equal(t, tt.output, buf.String())
+
+			rootCmd.SetOut(nil)
+			rootCmd.SetArgs(nil)
+
+			database, err := db.New(dbPath)
+			require.NoError(t, err)
+			defer database.Close()
+			cmd, err := database.GetCommand(2)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, cmd.CommandText)
+		})
+	}
+}
+
+func TestFcSetErrors(t *testing.T) {
+	dbPath := setupExitStatusScenario(t)
+
+	for _, args := range [][]string{
+		{"--set", "99", "--force", "ls"},
+		{"--set", "abc", "--force", "ls"},
+		{"--set", "2", "--force"},
+		{"--set", "2", "-l"},
+	} {
+		t.Run(strings.Join(args, " "), func(t *testing.T) {
+			defer resetFcFlags(fcCmd)
+			rootCmd.SetOut(&bytes.Buffer{})
+			rootCmd.SetErr(&bytes.Buffer{})
+			rootCmd.SetArgs(append([]string{"fc", "--db", dbPath}, args...))
+
+			assert.Error(t, rootCmd.Execute())
+
+			rootCmd.SetOut(nil)
+			rootCmd.SetErr(nil)
+			rootCmd.SetArgs(nil)
+		})
+	}
+}
+
+// TestFcSessionDefault tests that SHY_FC_SESSION scopes fc to the current
+// session by default, that --no-session restores the whole history and that
+// -L replaces the session scope with the current directory
+func TestFcSessionDefault(t *testing.T) {
+	defer resetFcFlags(fcCmd)
+
+	tempDir := t.TempDir()
+	dbPath := filepath.Join(tempDir, "history.db")
+
+	database, err := db.NewForTesting(dbPath)
+	require.NoError(t, err)
+	defer database.Close()
+
+	t.Chdir(tempDir)
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+
+	app := "zsh"
+	active := true
+	mine, other := int64(12345), int64(67890)
+	for i, c := range []struct {
+		text string
+		pid  *int64
+	}{
+		{"ls", &mine},
+		{"echo test", &other},
+		{"git status", &mine},
+	} {
+		_, err := database.InsertCommand(&models.Command{
+			CommandText:  c.text,
+			WorkingDir:   cwd,
+			Timestamp:    int64(1704470400 + i),
+			SourceApp:    &app,
+			SourcePid:    c.pid,
+			SourceActive: &active,
+		})
+		require.NoError(t, err)
+	}
+
+	run := func(args ...string) string {
+		var buf bytes.Buffer
+		rootCmd.SetOut(&buf)
+		rootCmd.SetArgs(append([]string{"fc", "-l", "--db", dbPath}, args...))
+		require.NoError(t, rootCmd.Execute())
+		rootCmd.SetArgs(nil)
+		return buf.String()
+	}
+
+	os.Setenv("SHY_SESSION_PID", "12345")
+	defer os.Unsetenv("SHY_SESSION_PID")
+
+	// Without SHY_FC_SESSION, --session opts in like -I
+	assert.Contains(t, run(), "echo test")
+	assert.NotContains(t, run("--session"), "echo test")
+
+	os.Setenv("SHY_FC_SESSION", "1")
+	defer os.Unsetenv("SHY_FC_SESSION")
+
+	output := run()
+	assert.Contains(t, output, "ls")
+	assert.Contains(t, output, "git status")
+	assert.NotContains(t, output, "echo test")
+
+	// Ranges stay event numbers, limited to the session
+	output = run("2", "3")
+	assert.Contains(t, output, "git status")
+	assert.NotContains(t, output, "echo test")
+	assert.NotContains(t, output, "ls")
+
+	assert.Contains(t, run("--no-session"), "echo test")
+	assert.Contains(t, run("-L"), "echo test")
+
+	// Outside a shy session there is nothing to scope to
+	os.Unsetenv("SHY_SESSION_PID")
+	assert.Contains(t, run(), "echo test")
+}
+
+// TestFcMinDuration tests --min-duration against commands imported from an
+// extended history file, whose durations are in seconds and stored in
+// milliseconds, so the threshold is compared in the same unit end-to-end
+func TestFcMinDuration(t *testing.T) {
+	defer resetFcFlags(fcCmd)
+
+	tempDir := t.TempDir()
+	dbPath := filepath.Join(tempDir, "history.db")
+	inputFile := filepath.Join(tempDir, "import.txt")
+
+	content := ": 1600000000:3;make build\n: 1600000001:0;ls\n: 1600000002:12;go test ./...\n"
+	require.NoError(t, os.WriteFile(inputFile, []byte(content), 0600))
+
+	rootCmd.SetArgs([]string{"fc", "-R", inputFile, "--db", dbPath})
+	require.NoError(t, rootCmd.Execute())
+	resetFcFlags(fcCmd)
+
+	run := func(args ...string) (string, error) {
+		defer resetFcFlags(fcCmd)
+		var buf bytes.Buffer
+		rootCmd.SetOut(&buf)
+		rootCmd.SetErr(&buf)
+		rootCmd.SetArgs(append([]string{"fc", "-l", "-n", "--db", dbPath}, args...))
+		err := rootCmd.Execute()
+		return buf.String(), err
+	}
+
+	out, err := run("--min-duration", "5s", "1")
+	require.NoError(t, err)
+	assert.Equal(t, "go test ./...\n", out)
+
+	// The threshold is inclusive: a 3s command matches 3s but not 3.001s
+	out, err = run("--min-duration", "3s", "1")
+	require.NoError(t, err)
+	assert.Equal(t, "make build\ngo test ./...\n", out)
+
+	out, err = run("--min-duration", "3001ms", "1")
+	require.NoError(t, err)
+	assert.Equal(t, "go test ./...\n", out)
+
+	// A bare number has no unit and is rejected rather than guessed at
+	_, err = run("--min-duration", "5", "1")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid --min-duration")
+
+	rootCmd.SetArgs(nil)
+}
+
+// TestFcApp tests that --app lists only one shell's commands, composing with
+// -m, -I and ranges, and that an unknown app names the known ones
+func TestFcApp(t *testing.T) {
+	defer resetFcFlags(fcCmd)
+
+	tempDir := t.TempDir()
+	dbPath := filepath.Join(tempDir, "history.db")
+
+	database, err := db.NewForTesting(dbPath)
+	require.NoError(t, err)
+	defer database.Close()
+
+	zsh, bash := "zsh", "bash"
+	zshPid, bashPid, otherBashPid := int64(100), int64(200), int64(300)
+	active := true
+	for i, c := range []struct {
+		text string
+		app  *string
+		pid  *int64
+	}{
+		{"ls", &zsh, &zshPid},
+		{"git status", &bash, &bashPid},
+		{"make", &zsh, &zshPid},
+		{"git log", &bash, &otherBashPid},
+		{"echo done", &bash, &bashPid},
+	} {
+		_, err := database.InsertCommand(&models.Command{
+			CommandText:  c.text,
+			WorkingDir:   "/home/test",
+			Timestamp:    int64(1704470400 + i),
+			SourceApp:    c.app,
+			SourcePid:    c.pid,
+			SourceActive: &active,
+		})
+		require.NoError(t, err)
+	}
+
+	run := func(args ...string) (string, error) {
+		defer resetFcFlags(fcCmd)
+		var buf bytes.Buffer
+		rootCmd.SetOut(&buf)
+		rootCmd.SetArgs(append([]string{"fc", "-l", "--db", dbPath}, args...))
+		err := rootCmd.Execute()
+		return buf.String(), err
+	}
+
+	out, err := run("--app", "bash")
+	require.NoError(t, err)
+	assert.Equal(t, "    2  git status\n    4  git log\n    5  echo done\n", out)
+
+	out, err = run("--app", "bash", "-m", "git*")
+	require.NoError(t, err)
+	assert.Equal(t, "    2  git status\n    4  git log\n", out)
+
+	out, err = run("--app", "bash", "3", "4")
+	require.NoError(t, err)
+	assert.Equal(t, "    4  git log\n", out)
+
+	out, err = run("--app", "zsh", "--count")
+	require.NoError(t, err)
+	assert.Equal(t, "2\n", out)
+
+	t.Setenv("SHY_SESSION_PID", "200")
+	out, err = run("--app", "bash", "-I")
+	require.NoError(t, err)
+	assert.Equal(t, "    2  git status\n    5  echo done\n", out)
+
+	_, err = run("--app", "fish")
+	assert.EqualError(t, err, `shy fc: unknown app "fish" (known apps: bash, zsh)`)
+
+	// history shares the list flags
+	var buf bytes.Buffer
+	rootCmd.SetOut(&buf)
+	rootCmd.SetArgs([]string{"history", "--app", "zsh", "--db", dbPath})
+	require.NoError(t, rootCmd.Execute())
+	assert.Equal(t, "    1  ls\n    3  make\n", buf.String())
+
+	rootCmd.SetArgs(nil)
+}
+
+// TestFcErrorKinds tests that fc's failures keep their messages, match their
+// sentinel errors and map to distinct exit codes
+func TestFcErrorKinds(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		message  string
+		sentinel error
+		exitCode int
+	}{
+		{name: "no matches", args: []string{"-l", "-m", "docker*"},
+			message: "shy fc: no matching events found", sentinel: ErrNoMatches, exitCode: 3},
+		{name: "count with no matches", args: []string{"-l", "--count", "-m", "docker*"},
+			message: "shy fc: no matching events found", sentinel: ErrNoMatches, exitCode: 3},
+		{name: "event not found", args: []string{"-l", "nonexistent"},
+			message: "shy fc: event not found: nonexistent", sentinel: ErrEventNotFound, exitCode: 4},
+		{name: "too many arguments", args: []string{"-l", "1", "2", "3"},
+			message: "shy fc: too many arguments", sentinel: ErrTooManyArgs, exitCode: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer resetFcFlags(fcCmd)
+			dbPath := setupCountScenario(t)
+
+			rootCmd.SetOut(&bytes.Buffer{})
+			rootCmd.SetErr(&bytes.Buffer{})
+			rootCmd.SetArgs(append([]string{"fc", "--db", dbPath}, tt.args...))
+			err := rootCmd.Execute()
+			rootCmd.SetOut(nil)
+			rootCmd.SetErr(nil)
+			rootCmd.SetArgs(nil)
+
+			assert.EqualError(t, err, tt.message)
+			assert.True(t, errors.Is(err, tt.sentinel))
+			assert.Equal(t, tt.exitCode, exitCode(err))
+		})
+	}
+
+	assert.Equal(t, 1, exitCode(errors.New("failed to open database")), "other errors exit 1")
+	assert.Equal(t, exitNoMatches, exitCode(fmt.Errorf("wrapped: %w", ErrNoMatches)))
+}
@@ -4,8 +4,6 @@ import (
 	"fmt"
 
 	"github.com/spf13/cobra"
-
-	"github.com/chris/shy/internal/db"
 )
 
 var fzfCmd = &cobra.Command{
@@ -19,7 +17,7 @@ All filtering is done interactively within fzf.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cmd.SilenceUsage = true
 
-		database, err := db.New(dbPath)
+		database, err := openDatabase()
 		if err != nil {
 			return fmt.Errorf("failed to open database: %w", err)
 		}
@@ -109,6 +109,11 @@ var historyCmd = &cobra.Command{
 		fcCmd.Flags().Set("match", flags.pattern)
 		fcCmd.Flags().Set("internal", fmt.Sprintf("%t", flags.internal))
 		fcCmd.Flags().Set("local", fmt.Sprintf("%t", flags.local))
+		fcCmd.Flags().Set("count", fmt.Sprintf("%t", flags.count))
+		fcCmd.Flags().Set("exit", fmt.Sprintf("%t", flags.exitStatus))
+		fcCmd.Flags().Set("zsh-compat", fmt.Sprintf("%t", flags.zshCompat))
+		fcCmd.Flags().Set("min-duration", flags.minDur)
+		fcCmd.Flags().Set("app", flags.app)
 
 		// Run fc command with parsed arguments
 		// Pass fcCmd so it reads the flags we just set
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/chris/shy/internal/importer"
+	"github.com/chris/shy/pkg/models"
+)
+
+// importNow is injectable for testing: it dates CSV rows without a timestamp
+var importNow = time.Now
+
+var (
+	importFrom      string
+	importMap       string
+	importHasHeader bool
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import --from atuin|mcfly|fish|sh|csv|tsv <file>",
+	Short: "Import history from Atuin, McFly, fish, a plain sh history file or CSV",
+	Long: `Read the command history from another tool's SQLite database, or from a
+shell's own history file, and insert it in a single transaction:
+
+  shy import --from atuin ~/.local/share/atuin/history.db
+  shy import --from mcfly ~/.local/share/mcfly/history.db
+  shy import --from fish ~/.local/share/fish/fish_history
+  shy import --from sh ~/.ash_history
+
+The other database is opened read-only. Commands already in shy's history
+(same text, directory and time) are skipped, so an import can be re-run
+after the other tool has recorded more. Imported sessions show up under the
+source app "atuin" or "mcfly".
+
+fish and sh histories record no directory or session. A plain sh history,
+as POSIX sh, BusyBox ash and dash write it, has no times either: its
+commands are dated back from the file's modification time, one second
+apart, so re-importing it after it has grown adds its old lines again.
+
+CSV and TSV files are read with --map, which assigns 1-indexed columns to
+the fields timestamp, command, dir, exit, duration, repo, branch and
+session; command is required:
+
+  shy import --from csv --map timestamp=1,command=3,dir=2 --has-header history.csv
+
+Timestamps are Unix seconds, RFC 3339 or "2006-01-02 15:04:05" in local
+time. Unmapped fields and empty cells are left empty. Rows without a
+timestamp are all dated at the time of the import, so re-importing such a
+file adds its rows again instead of skipping them.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runImport,
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+	importCmd.Flags().StringVar(&importFrom, "from", "", "Format of the history: "+strings.Join(importFormats(), ", "))
+	importCmd.Flags().StringVar(&importMap, "map", "", "CSV columns of the fields, e.g. timestamp=1,command=3,dir=2")
+	importCmd.Flags().BoolVar(&importHasHeader, "has-header", false, "Skip the CSV file's first row")
+	importCmd.MarkFlagRequired("from")
+}
+
+// importFormats lists every --from value
+func importFormats() []string {
+	return slices.Concat(importer.Formats, importer.CSVFormats)
+}
+
+func runImport(cmd *cobra.Command, args []string) error {
+	if !slices.Contains(importFormats(), importFrom) {
+		return fmt.Errorf("invalid --from %q: expected %s", importFrom, strings.Join(importFormats(), ", "))
+	}
+
+	commands, err := readImport(args[0])
+	if err != nil {
+		return fmt.Errorf("shy import: %w", err)
+	}
+
+	database, err := openDatabase()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer database.Close()
+
+	// De-dup against stored history and within the import itself
+	type key struct {
+		timestamp int64
+		text, dir string
+	}
+	seen := make(map[key]bool)
+	var fresh []*models.Command
+	prog := newProgress(cmd, "Importing", len(commands))
+	for i, c := range commands {
+		prog.update(i + 1)
+		k := key{c.Timestamp, c.CommandText, c.WorkingDir}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		exists, err := database.HasCommand(c.Timestamp, c.CommandText, c.WorkingDir)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			fresh = append(fresh, c)
+		}
+	}
+
+	ids, err := database.InsertCommands(fresh)
+	prog.done()
+	if err != nil {
+		return fmt.Errorf("failed to insert commands: %w", err)
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "Imported %d commands (%d already present)\n", len(ids), len(commands)-len(ids))
+	return nil
+}
+
+// readImport reads the file in the --from format, applying --map and
+// --has-header to CSV and TSV files
+func readImport(path string) ([]*models.Command, error) {
+	if !slices.Contains(importer.CSVFormats, importFrom) {
+		if importMap != "" || importHasHeader {
+			return nil, fmt.Errorf("--map and --has-header only apply to %s", strings.Join(importer.CSVFormats, " and "))
+		}
+		return importer.Read(importFrom, path)
+	}
+	if importMap == "" {
+		return nil, fmt.Errorf("--from %s needs --map, e.g. --map timestamp=1,command=2", importFrom)
+	}
+	mapping, err := importer.ParseCSVMapping(importMap)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --map: %w", err)
+	}
+	return importer.ReadCSV(importFrom, path, mapping, importHasHeader, importNow())
+}
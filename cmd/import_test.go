@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"bytes"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chris/shy/internal/db"
+)
+
+func runImportForTest(t *testing.T, dbPath string, args ...string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	rootCmd.SetArgs(append([]string{"import", "--db", dbPath}, args...))
+	err := rootCmd.Execute()
+	rootCmd.SetOut(nil)
+	rootCmd.SetArgs(nil)
+	importFrom = ""
+	importMap = ""
+	importHasHeader = false
+	for _, name := range []string{"from", "map", "has-header"} {
+		importCmd.Flags().Lookup(name).Changed = false
+	}
+	return out.String(), err
+}
+
+// buildImportFixture loads one of the importer's fixture schemas into a
+// fresh SQLite file
+func buildImportFixture(t *testing.T, name string) string {
+	t.Helper()
+	schema, err := os.ReadFile(filepath.Join("..", "internal", "importer", "testdata", name+".sql"))
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), name+".db")
+	conn, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Exec(string(schema))
+	require.NoError(t, err)
+	return path
+}
+
+func TestImport(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+
+	for _, format := range []string{"atuin", "mcfly"} {
+		t.Run(format, func(t *testing.T) {
+			fixture := buildImportFixture(t, format)
+
+			out, err := runImportForTest(t, dbPath, "--from", format, fixture)
+			require.NoError(t, err)
+			assert.Equal(t, "Imported 2 commands (0 already present)\n", out)
+
+			// A second import of the same file adds nothing
+			out, err = runImportForTest(t, dbPath, "--from", format, fixture)
+			require.NoError(t, err)
+			assert.Equal(t, "Imported 0 commands (2 already present)\n", out)
+		})
+	}
+
+	database, err := db.NewForTesting(dbPath)
+	require.NoError(t, err)
+	defer database.Close()
+	count, err := database.CountCommands()
+	require.NoError(t, err)
+	assert.Equal(t, 4, count)
+
+	apps, err := database.GetUniqueSourceApps()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"atuin", "mcfly"}, apps)
+}
+
+func TestImportFish(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	fixture := filepath.Join("..", "internal", "importer", "testdata", "fish_history")
+
+	out, err := runImportForTest(t, dbPath, "--from", "fish", fixture)
+	require.NoError(t, err)
+	assert.Equal(t, "Imported 3 commands (0 already present)\n", out)
+
+	out, err = runImportForTest(t, dbPath, "--from", "fish", fixture)
+	require.NoError(t, err)
+	assert.Equal(t, "Imported 0 commands (3 already present)\n", out)
+}
+
+func TestImportUnknownFormat(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+
+	_, err := runImportForTest(t, dbPath, "--from", "nushell", buildImportFixture(t, "mcfly"))
+	assert.ErrorContains(t, err, `invalid --from "nushell"`)
+
+	_, err = runImportForTest(t, dbPath, buildImportFixture(t, "mcfly"))
+	assert.ErrorContains(t, err, `required flag(s) "from" not set`)
+}
+
+func TestImportCSV(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	fixture := filepath.Join("..", "internal", "importer", "testdata", "history.csv")
+	args := []string{"--from", "csv", "--map", "timestamp=1,command=3,dir=2,exit=4", "--has-header", fixture}
+
+	out, err := runImportForTest(t, dbPath, args...)
+	require.NoError(t, err)
+	assert.Equal(t, "Imported 3 commands (0 already present)\n", out)
+
+	out, err = runImportForTest(t, dbPath, args...)
+	require.NoError(t, err)
+	assert.Equal(t, "Imported 0 commands (3 already present)\n", out)
+
+	database, err := db.NewForTesting(dbPath)
+	require.NoError(t, err)
+	defer database.Close()
+	exists, err := database.HasCommand(1770215400, "git status", "/home/test/proj")
+	require.NoError(t, err)
+	assert.True(t, exists, "columns land in the mapped fields")
+}
+
+func TestImportCSVFlags(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	fixture := filepath.Join("..", "internal", "importer", "testdata", "history.csv")
+
+	_, err := runImportForTest(t, dbPath, "--from", "csv", fixture)
+	assert.ErrorContains(t, err, "--from csv needs --map")
+
+	_, err = runImportForTest(t, dbPath, "--from", "csv", "--map", "dir=2", fixture)
+	assert.ErrorContains(t, err, "invalid --map: the mapping needs a command column")
+
+	_, err = runImportForTest(t, dbPath, "--from", "fish", "--has-header", fixture)
+	assert.ErrorContains(t, err, "--map and --has-header only apply to csv and tsv")
+}
+
+// TestImportCSVWithoutTimestamp tests that rows without a timestamp share
+// the import's time, so a re-import is only skipped within the same second
+func TestImportCSVWithoutTimestamp(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	fixture := filepath.Join(t.TempDir(), "history.csv")
+	require.NoError(t, os.WriteFile(fixture, []byte("git status,/home/test\nmake,/home/test\n"), 0644))
+	args := []string{"--from", "csv", "--map", "command=1,dir=2", fixture}
+
+	importTime := time.Unix(1770215400, 0)
+	importNow = func() time.Time { return importTime }
+	t.Cleanup(func() { importNow = time.Now })
+
+	out, err := runImportForTest(t, dbPath, args...)
+	require.NoError(t, err)
+	assert.Equal(t, "Imported 2 commands (0 already present)\n", out)
+
+	out, err = runImportForTest(t, dbPath, args...)
+	require.NoError(t, err)
+	assert.Equal(t, "Imported 0 commands (2 already present)\n", out, "the same import time matches")
+
+	// A later import dates the rows anew, so they are not recognized
+	importTime = importTime.Add(time.Minute)
+	out, err = runImportForTest(t, dbPath, args...)
+	require.NoError(t, err)
+	assert.Equal(t, "Imported 2 commands (0 already present)\n", out)
+
+	database, err := db.NewForTesting(dbPath)
+	require.NoError(t, err)
+	defer database.Close()
+	commands, err := database.GetCommandsByDateRange(0, importTime.Unix()+1, nil)
+	require.NoError(t, err)
+	require.Len(t, commands, 4)
+	assert.Equal(t, commands[0].Timestamp, commands[1].Timestamp, "one import time for every row")
+}
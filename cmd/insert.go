@@ -2,24 +2,27 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
-	"github.com/chris/shy/internal/db"
 	"github.com/chris/shy/internal/git"
 	"github.com/chris/shy/pkg/models"
 )
 
 var (
-	command   string
-	dir       string
-	status    int
-	gitRepo   string
-	gitBranch string
-	timestamp int64
-	duration  int64
-	sourceApp string
-	sourcePid int64
+	command     string
+	dir         string
+	status      int
+	gitRepo     string
+	gitBranch   string
+	timestamp   int64
+	timestampNs int64
+	duration    int64
+	sourceApp   string
+	sourcePid   int64
+	envVars     []string
 )
 
 var insertCmd = &cobra.Command{
@@ -38,9 +41,11 @@ func init() {
 	insertCmd.Flags().StringVar(&gitRepo, "git-repo", "", "Git repository URL")
 	insertCmd.Flags().StringVar(&gitBranch, "git-branch", "", "Git branch name")
 	insertCmd.Flags().Int64Var(&timestamp, "timestamp", 0, "Unix timestamp (default: current time)")
+	insertCmd.Flags().Int64Var(&timestampNs, "timestamp-ns", 0, "Start time in Unix nanoseconds, for ordering within a second (default: the --timestamp second)")
 	insertCmd.Flags().Int64Var(&duration, "duration", 0, "Command duration in milliseconds")
 	insertCmd.Flags().StringVar(&sourceApp, "source-app", "", "Source shell application (e.g., 'zsh', 'bash')")
 	insertCmd.Flags().Int64Var(&sourcePid, "source-pid", 0, "Source shell session PID")
+	insertCmd.Flags().StringArrayVar(&envVars, "env", nil, "Environment variable captured with the command, as NAME=VALUE (repeatable)")
 
 	insertCmd.MarkFlagRequired("command")
 	insertCmd.MarkFlagRequired("dir")
@@ -62,7 +67,7 @@ func runInsert(cmd *cobra.Command, args []string) error {
 	}
 
 	// Open database
-	database, err := db.New(dbPath)
+	database, err := openDatabase()
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
 	}
@@ -75,39 +80,16 @@ func runInsert(cmd *cobra.Command, args []string) error {
 	if timestamp != 0 {
 		cmdModel.Timestamp = timestamp
 	}
+	if err := setTimestampNs(cmdModel, timestamp, timestampNs); err != nil {
+		return err
+	}
 
 	// Set duration if provided
 	if duration > 0 {
 		cmdModel.Duration = &duration
 	}
 
-	// Handle git context
-	var finalGitRepo *string
-	var finalGitBranch *string
-
-	// If explicit git context provided, use it
-	if gitRepo != "" || gitBranch != "" {
-		if gitRepo != "" {
-			finalGitRepo = &gitRepo
-		}
-		if gitBranch != "" {
-			finalGitBranch = &gitBranch
-		}
-	} else {
-		// Auto-detect git context
-		gitCtx, err := git.DetectGitContext(dir)
-		if err == nil && gitCtx != nil {
-			if gitCtx.Repo != "" {
-				finalGitRepo = &gitCtx.Repo
-			}
-			if gitCtx.Branch != "" {
-				finalGitBranch = &gitCtx.Branch
-			}
-		}
-	}
-
-	cmdModel.GitRepo = finalGitRepo
-	cmdModel.GitBranch = finalGitBranch
+	cmdModel.GitRepo, cmdModel.GitBranch = resolveGitContext(dir, gitRepo, gitBranch)
 
 	// Set source tracking fields if provided
 	if sourceApp != "" {
@@ -120,6 +102,12 @@ func runInsert(cmd *cobra.Command, args []string) error {
 		cmdModel.SourceActive = &active
 	}
 
+	env, err := parseEnvVars(envVars)
+	if err != nil {
+		return err
+	}
+	cmdModel.Env = env
+
 	cmdModel.TrimCommandText()
 
 	// Insert command
@@ -131,3 +119,68 @@ func runInsert(cmd *cobra.Command, args []string) error {
 	fmt.Printf("Inserted command with ID: %d\n", id)
 	return nil
 }
+
+// parseEnvVars converts --env NAME=VALUE pairs to a map, or nil when none
+// were given. A later pair for the same name wins.
+func parseEnvVars(pairs []string) (map[string]string, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+	env := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid --env %q: expected NAME=VALUE", pair)
+		}
+		env[name] = value
+	}
+	return env, nil
+}
+
+// resolveGitContext returns the git repo and branch to record for a command.
+// An explicit repo or branch is used as given; otherwise the git context is
+// auto-detected from the working directory.
+func resolveGitContext(workingDir, repo, branch string) (*string, *string) {
+	var finalGitRepo *string
+	var finalGitBranch *string
+
+	// If explicit git context provided, use it
+	if repo != "" || branch != "" {
+		if repo != "" {
+			finalGitRepo = &repo
+		}
+		if branch != "" {
+			finalGitBranch = &branch
+		}
+		return finalGitRepo, finalGitBranch
+	}
+
+	// Auto-detect git context
+	gitCtx, err := git.DetectGitContext(workingDir)
+	if err == nil && gitCtx != nil {
+		if gitCtx.Repo != "" {
+			finalGitRepo = &gitCtx.Repo
+		}
+		if gitCtx.Branch != "" {
+			finalGitBranch = &gitCtx.Branch
+		}
+	}
+	return finalGitRepo, finalGitBranch
+}
+
+// setTimestampNs records a start time in nanoseconds, supplying the second
+// (floored) when timestamp is 0. A timestamp from another second than
+// timestampNs is an error, since the second decides the day a command is
+// listed under. A timestampNs of 0 leaves the command unchanged.
+func setTimestampNs(c *models.Command, timestamp, timestampNs int64) error {
+	if timestampNs == 0 {
+		return nil
+	}
+	second := time.Unix(0, timestampNs).Unix()
+	if timestamp != 0 && timestamp != second {
+		return fmt.Errorf("timestamp %d and timestamp_ns %d are different seconds", timestamp, timestampNs)
+	}
+	c.Timestamp = second
+	c.TimestampNs = timestampNs
+	return nil
+}
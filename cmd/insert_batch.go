@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/chris/shy/pkg/models"
+)
+
+// maxBatchLineSize bounds a single JSON line, so long multi-line commands fit
+const maxBatchLineSize = 10 * 1024 * 1024
+
+// batchCommand is one line of insert-batch input. Fields mirror models.Command;
+// timestamp defaults to now and the git context is auto-detected when neither
+// git_repo nor git_branch is given, as with shy insert. id is written by
+// shy export and ignored on insert.
+type batchCommand struct {
+	ID          int64             `json:"id,omitempty"`
+	Timestamp   int64             `json:"timestamp"`
+	ExitStatus  int               `json:"exit_status"`
+	CommandText string            `json:"command_text"`
+	WorkingDir  string            `json:"working_dir"`
+	GitRepo     string            `json:"git_repo"`
+	GitBranch   string            `json:"git_branch"`
+	Duration    int64             `json:"duration"`
+	SourceApp   string            `json:"source_app"`
+	SourcePid   int64             `json:"source_pid"`
+	Env         map[string]string `json:"env,omitempty"`
+	TimestampNs int64             `json:"timestamp_ns,omitempty"`
+}
+
+var insertBatchCmd = &cobra.Command{
+	Use:   "insert-batch",
+	Short: "Insert commands from newline-delimited JSON on stdin",
+	Long: `Read newline-delimited JSON command objects from stdin and insert them in a single transaction.
+
+Each line is an object with the fields command_text and working_dir (required),
+and optionally timestamp, timestamp_ns (the start time in nanoseconds), exit_status,
+duration, git_repo, git_branch, source_app, source_pid and env (an object of
+captured environment variables). Malformed lines are skipped with a warning.`,
+	Args: cobra.NoArgs,
+	RunE: runInsertBatch,
+}
+
+func init() {
+	rootCmd.AddCommand(insertBatchCmd)
+}
+
+func runInsertBatch(cmd *cobra.Command, args []string) error {
+	commands, err := readBatchCommands(cmd.InOrStdin(), cmd.ErrOrStderr())
+	if err != nil {
+		return err
+	}
+
+	database, err := openDatabase()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer database.Close()
+
+	ids, err := database.InsertCommands(commands)
+	if err != nil {
+		return fmt.Errorf("failed to insert commands: %w", err)
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "Inserted %d commands\n", len(ids))
+	return nil
+}
+
+// readBatchCommands parses insert-batch input into commands, warning about
+// and skipping lines that are not valid command objects. Blank lines and
+// commands with a leading space (excluded from history) are skipped silently.
+func readBatchCommands(in io.Reader, warn io.Writer) ([]*models.Command, error) {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), maxBatchLineSize)
+
+	now := time.Now().Unix()
+	detected := make(map[string][2]*string) // auto-detected git context per working dir
+	var commands []*models.Command
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var entry batchCommand
+		if err := json.Unmarshal(line, &entry); err != nil {
+			fmt.Fprintf(warn, "shy insert-batch: line %d: invalid JSON: %v, skipping\n", lineNum, err)
+			continue
+		}
+		if entry.CommandText == "" {
+			fmt.Fprintf(warn, "shy insert-batch: line %d: command_text is required, skipping\n", lineNum)
+			continue
+		}
+		if entry.WorkingDir == "" {
+			fmt.Fprintf(warn, "shy insert-batch: line %d: working_dir is required, skipping\n", lineNum)
+			continue
+		}
+		if entry.CommandText[0] == ' ' {
+			continue
+		}
+
+		cmdModel, err := entry.toCommand(now)
+		if err != nil {
+			fmt.Fprintf(warn, "shy insert-batch: line %d: %v, skipping\n", lineNum, err)
+			continue
+		}
+		if entry.GitRepo != "" || entry.GitBranch != "" {
+			cmdModel.GitRepo, cmdModel.GitBranch = resolveGitContext(entry.WorkingDir, entry.GitRepo, entry.GitBranch)
+		} else {
+			gitCtx, ok := detected[entry.WorkingDir]
+			if !ok {
+				gitCtx[0], gitCtx[1] = resolveGitContext(entry.WorkingDir, "", "")
+				detected[entry.WorkingDir] = gitCtx
+			}
+			cmdModel.GitRepo, cmdModel.GitBranch = gitCtx[0], gitCtx[1]
+		}
+		commands = append(commands, cmdModel)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read input: %w", err)
+	}
+
+	return commands, nil
+}
+
+// toCommand converts a batch entry to a command, applying the shy insert
+// defaults. The git context is filled in by the caller.
+func (e batchCommand) toCommand(now int64) (*models.Command, error) {
+	cmdModel := models.NewCommand(e.CommandText, e.WorkingDir, e.ExitStatus)
+	cmdModel.Timestamp = now
+	if e.Timestamp != 0 {
+		cmdModel.Timestamp = e.Timestamp
+	}
+	if err := setTimestampNs(cmdModel, e.Timestamp, e.TimestampNs); err != nil {
+		return nil, err
+	}
+	if e.Duration > 0 {
+		duration := e.Duration
+		cmdModel.Duration = &duration
+	}
+
+	if e.SourceApp != "" {
+		sourceApp := e.SourceApp
+		cmdModel.SourceApp = &sourceApp
+	}
+	if e.SourcePid > 0 {
+		sourcePid := e.SourcePid
+		cmdModel.SourcePid = &sourcePid
+		// If source PID is provided, assume session is active
+		active := true
+		cmdModel.SourceActive = &active
+	}
+	if len(e.Env) > 0 {
+		cmdModel.Env = e.Env
+	}
+
+	cmdModel.TrimCommandText()
+	return cmdModel, nil
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chris/shy/internal/db"
+)
+
+func runInsertBatchForTest(t *testing.T, dbPath, input string) (string, string) {
+	t.Helper()
+	var out, errOut bytes.Buffer
+	rootCmd.SetIn(strings.NewReader(input))
+	rootCmd.SetOut(&out)
+	rootCmd.SetErr(&errOut)
+	rootCmd.SetArgs([]string{"insert-batch", "--db", dbPath})
+	require.NoError(t, rootCmd.Execute())
+	rootCmd.SetIn(nil)
+	rootCmd.SetOut(nil)
+	rootCmd.SetErr(nil)
+	rootCmd.SetArgs(nil)
+	return out.String(), errOut.String()
+}
+
+func TestInsertBatch(t *testing.T) {
+	tempDir := t.TempDir()
+	dbPath := filepath.Join(tempDir, "history.db")
+
+	input := strings.Join([]string{
+		`{"command_text": "make build", "working_dir": "/home/user/shy", "timestamp": 1000, "exit_status": 2, "duration": 1500, "git_repo": "github.com/chris/shy", "git_branch": "main"}`,
+		`{"command_text": "ls", "working_dir": "` + tempDir + `", "timestamp": 1001, "source_app": "zsh", "source_pid": 4242}`,
+		``,
+		`{"command_text": " secret", "working_dir": "/tmp", "timestamp": 1002}`,
+	}, "\n")
+
+	out, errOut := runInsertBatchForTest(t, dbPath, input)
+	assert.Equal(t, "Inserted 2 commands\n", out)
+	assert.Empty(t, errOut)
+
+	database, err := db.New(dbPath)
+	require.NoError(t, err)
+	defer database.Close()
+
+	commands, err := database.GetCommandsByDateRange(0, 2000, nil)
+	require.NoError(t, err)
+	require.Len(t, commands, 2)
+
+	build := commands[0]
+	assert.Equal(t, "make build", build.CommandText)
+	assert.Equal(t, "/home/user/shy", build.WorkingDir)
+	assert.Equal(t, 2, build.ExitStatus)
+	require.NotNil(t, build.Duration)
+	assert.Equal(t, int64(1500), *build.Duration)
+	require.NotNil(t, build.GitBranch)
+	assert.Equal(t, "main", *build.GitBranch)
+
+	ls := commands[1]
+	assert.Equal(t, "ls", ls.CommandText)
+	assert.Nil(t, ls.GitRepo, "temp dir is not a git repo")
+	require.NotNil(t, ls.SourcePid)
+	assert.Equal(t, int64(4242), *ls.SourcePid)
+	require.NotNil(t, ls.SourceActive)
+	assert.True(t, *ls.SourceActive)
+}
+
+func TestInsertBatchSkipsMalformedLines(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+
+	input := strings.Join([]string{
+		`{"command_text": "git status", "working_dir": "/home/user/shy", "timestamp": 1000, "git_repo": "r"}`,
+		`not json`,
+		`{"working_dir": "/home/user/shy"}`,
+		`{"command_text": "pwd"}`,
+		`{"command_text": "git push", "working_dir": "/home/user/shy", "timestamp": 1001, "git_repo": "r"}`,
+		`{"command_text": "git fetch", "working_dir": "/home/user/shy", "timestamp": 1003, "timestamp_ns": 1002700000000, "git_repo": "r"}`,
+	}, "\n")
+
+	out, errOut := runInsertBatchForTest(t, dbPath, input)
+	assert.Equal(t, "Inserted 2 commands\n", out)
+	assert.Contains(t, errOut, "line 2: invalid JSON")
+	assert.Contains(t, errOut, "line 3: command_text is required")
+	assert.Contains(t, errOut, "line 4: working_dir is required")
+	assert.Contains(t, errOut, "line 6: timestamp 1003 and timestamp_ns 1002700000000 are different seconds")
+
+	database, err := db.New(dbPath)
+	require.NoError(t, err)
+	defer database.Close()
+
+	count, err := database.CountCommands()
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
@@ -7,6 +7,7 @@ import (
 	"sync"
 	"testing"
 
+	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -302,6 +303,47 @@ func TestInsertCommandWithSourceTracking(t *testing.T) {
 	rootCmd.SetArgs(nil)
 }
 
+// Test insert command with captured environment variables
+func TestInsertCommandWithEnv(t *testing.T) {
+	tempDir := t.TempDir()
+	dbPath := filepath.Join(tempDir, "history.db")
+	defer insertCmd.Flags().Lookup("env").Value.(pflag.SliceValue).Replace(nil)
+
+	rootCmd.SetArgs([]string{
+		"insert",
+		"--command", "terraform plan",
+		"--dir", tempDir,
+		"--env", "AWS_PROFILE=staging",
+		"--env", "VIRTUAL_ENV=/home/test/.venv",
+		"--db", dbPath,
+	})
+	require.NoError(t, rootCmd.Execute())
+	rootCmd.SetArgs(nil)
+
+	database, err := db.NewForTesting(dbPath)
+	require.NoError(t, err)
+	defer database.Close()
+
+	cmd, err := database.GetCommand(1)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"AWS_PROFILE": "staging", "VIRTUAL_ENV": "/home/test/.venv"}, cmd.Env)
+}
+
+func TestParseEnvVars(t *testing.T) {
+	env, err := parseEnvVars(nil)
+	require.NoError(t, err)
+	assert.Nil(t, env, "no --env leaves the command without env")
+
+	env, err = parseEnvVars([]string{"A=1", "B=x=y", "A=2", "EMPTY="})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"A": "2", "B": "x=y", "EMPTY": ""}, env)
+
+	_, err = parseEnvVars([]string{"NOVALUE"})
+	assert.ErrorContains(t, err, `invalid --env "NOVALUE"`)
+	_, err = parseEnvVars([]string{"=1"})
+	assert.Error(t, err)
+}
+
 // Scenario 33: Bash session tracking works independently
 func TestScenario33_BashSessionTrackingWorksIndependently(t *testing.T) {
 	tempDir := t.TempDir()
@@ -365,7 +407,7 @@ func TestScenario33_BashSessionTrackingWorksIndependently(t *testing.T) {
 
 	// When: I run "shy fc -l -I" in zsh session (PID 11111)
 	// Then: I should only see "zsh-cmd"
-	commands, err := database.GetCommandsByRangeInternal(1, 100, 11111)
+	commands, err := database.GetCommandsByRangeOrdered(1, 100, db.RangeFilter{SessionPid: 11111}, db.Ascending)
 	require.NoError(t, err)
 	assert.Len(t, commands, 1)
 	assert.Equal(t, "zsh-cmd", commands[0].CommandText)
@@ -509,3 +551,58 @@ func TestInsertCommandWithLeadingSpace(t *testing.T) {
 		rootCmd.SetArgs(nil)
 	})
 }
+
+// TestInsertTimestampNs tests that --timestamp-ns records a sub-second start
+// time and supplies the second when --timestamp is not given
+func TestInsertTimestampNs(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	initDB, err := db.NewForTesting(dbPath)
+	require.NoError(t, err)
+	initDB.Close()
+
+	startNs := int64(1704067200_250_000_000)
+	for _, args := range [][]string{
+		{"--timestamp", "1704067200", "--timestamp-ns", "1704067200250000000"},
+		{"--timestamp-ns", "1704067200250000000"},
+		{"--timestamp", "1704067200"},
+	} {
+		rootCmd.SetArgs(append([]string{"insert", "--command", "ls", "--dir", "/home/user", "--db", dbPath}, args...))
+		require.NoError(t, rootCmd.Execute())
+		timestamp, timestampNs = 0, 0
+	}
+	rootCmd.SetArgs(nil)
+
+	database, err := db.NewForTesting(dbPath)
+	require.NoError(t, err)
+	defer database.Close()
+	for id, wantNs := range map[int64]int64{1: startNs, 2: startNs, 3: 1704067200_000_000_000} {
+		cmd, err := database.GetCommand(id)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1704067200), cmd.Timestamp, "command %d", id)
+		assert.Equal(t, wantNs, cmd.TimestampNs, "command %d", id)
+	}
+}
+
+// TestInsertTimestampNsOtherSecond tests that a --timestamp from another
+// second than --timestamp-ns is rejected rather than stored
+func TestInsertTimestampNsOtherSecond(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	initDB, err := db.NewForTesting(dbPath)
+	require.NoError(t, err)
+	initDB.Close()
+
+	// 23:59:59.7 rounded up would be listed under the next day
+	rootCmd.SetArgs([]string{"insert", "--command", "ls", "--dir", "/home/user", "--db", dbPath,
+		"--timestamp", "1704067200", "--timestamp-ns", "1704067199700000000"})
+	err = rootCmd.Execute()
+	rootCmd.SetArgs(nil)
+	timestamp, timestampNs = 0, 0
+	assert.ErrorContains(t, err, "timestamp 1704067200 and timestamp_ns 1704067199700000000 are different seconds")
+
+	database, err := db.NewForTesting(dbPath)
+	require.NoError(t, err)
+	defer database.Close()
+	count, err := database.CountCommands()
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
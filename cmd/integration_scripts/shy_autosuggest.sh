@@ -12,6 +12,7 @@
 #
 # Configuration:
 #   ZSH_AUTOSUGGEST_HISTORY_IGNORE - Pattern to exclude from suggestions (same as default)
+#   SHY_AUTOSUGGEST_BRANCH=1       - Prefer commands run on the current git branch
 
 # Strategy: Simple history matching using shy
 _zsh_autosuggest_strategy_shy_history() {
@@ -27,6 +28,11 @@ _zsh_autosuggest_strategy_shy_history() {
     shy_args+=(--exclude "$ZSH_AUTOSUGGEST_HISTORY_IGNORE")
   fi
 
+  # Prefer the current branch's commands if enabled
+  if [[ $SHY_AUTOSUGGEST_BRANCH == 1 ]]; then
+    shy_args+=(--branch)
+  fi
+
   # Query shy for suggestion (suppress errors)
   local result
   result=$(shy "${shy_args[@]}" 2>/dev/null)
@@ -9,6 +9,8 @@
 # Configuration:
 #   SHY_DISABLE=1    - Temporarily disable command tracking
 #   SHY_DB_PATH      - Custom database path (default: $XDG_DATA_HOME/shy/history.db or ~/.local/share/shy/history.db)
+#   SHY_CAPTURE_ENV  - Space-separated environment variables to record with each command
+#                      (e.g. "VIRTUAL_ENV AWS_PROFILE KUBECONFIG"); unset variables are skipped
 #
 # Troubleshooting:
 #   Errors are logged to: $XDG_DATA_HOME/shy/error.log or ~/.local/share/shy/error.log
@@ -29,6 +31,7 @@ zmodload zsh/datetime
 __shy_cmd=""
 __shy_cmd_dir=""
 __shy_cmd_start=""
+__shy_cmd_start_ns=""
 
 # Hook called before command execution
 __shy_preexec() {
@@ -43,6 +46,8 @@ __shy_preexec() {
 	# Capture start time in milliseconds (string manipulation to avoid float/sci notation)
 	local t=$EPOCHREALTIME
 	__shy_cmd_start="${t%.*}${${t#*.}:0:3}"
+	# And in nanoseconds, to order commands within one second
+	__shy_cmd_start_ns="${t%.*}${(r:9::0:)${t#*.}}"
 }
 
 # Hook called after command execution
@@ -74,8 +79,9 @@ __shy_precmd() {
 		# Calculate duration in milliseconds
 		duration=$((end_time - __shy_cmd_start))
 
-		# Timestamp for database (seconds since epoch)
-		timestamp=$(( (__shy_cmd_start + 500) / 1000 ))
+		# Timestamp for database (seconds since epoch), floored so it is the
+		# second of --timestamp-ns
+		timestamp=$(( __shy_cmd_start / 1000 ))
 	fi
 
 	sessionfile="$XDG_CACHE_HOME/shy/sessions/$SHY_SESSION_PID.txt"
@@ -95,6 +101,9 @@ __shy_precmd() {
 	if [[ -n "$timestamp" ]]; then
 		shy_args+=("--timestamp" "$timestamp")
 	fi
+	if [[ -n "$__shy_cmd_start_ns" ]]; then
+		shy_args+=("--timestamp-ns" "$__shy_cmd_start_ns")
+	fi
 
 	# Add duration if calculated
 	if [[ -n "$duration" ]] && [[ "$duration" -ge 0 ]]; then
@@ -105,6 +114,14 @@ __shy_precmd() {
 	shy_args+=("--source-app" "zsh")
 	shy_args+=("--source-pid" "$$")
 
+	# Add the opted-in environment variables that are set
+	local env_name
+	for env_name in ${=SHY_CAPTURE_ENV}; do
+		if [[ -n "${(P)env_name}" ]]; then
+			shy_args+=("--env" "$env_name=${(P)env_name}")
+		fi
+	done
+
 	# Add custom database path if set
 	if [[ -n "$SHY_DB_PATH" ]]; then
 		shy_args+=("--db" "$SHY_DB_PATH")
@@ -131,6 +148,7 @@ __shy_precmd() {
 	__shy_cmd=""
 	__shy_cmd_dir=""
 	__shy_cmd_start=""
+	__shy_cmd_start_ns=""
 }
 
 # Hook called when shell exits
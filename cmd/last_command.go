@@ -5,8 +5,6 @@ import (
 	"os"
 
 	"github.com/spf13/cobra"
-
-	"github.com/chris/shy/internal/db"
 )
 
 var (
@@ -35,7 +33,7 @@ func runLastCommand(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
-	database, err := db.New(dbPath)
+	database, err := openDatabase()
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
 	}
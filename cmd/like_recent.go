@@ -7,11 +7,13 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/chris/shy/internal/db"
+	"github.com/chris/shy/internal/git"
 )
 
 var (
 	likeRecentPwd     bool
 	likeRecentSession bool
+	likeRecentBranch  bool
 	likeRecentExclude string
 	likeRecentLimit   int
 )
@@ -30,6 +32,7 @@ func init() {
 	// Add flags
 	likeRecentCmd.Flags().BoolVar(&likeRecentPwd, "pwd", false, "Only match commands from current directory")
 	likeRecentCmd.Flags().BoolVar(&likeRecentSession, "session", false, "Only match from current session (SHY_SESSION_PID)")
+	likeRecentCmd.Flags().BoolVar(&likeRecentBranch, "branch", false, "Prefer commands run on the current git branch")
 	likeRecentCmd.Flags().StringVar(&likeRecentExclude, "exclude", "", "Exclude commands matching pattern (glob)")
 	likeRecentCmd.Flags().IntVar(&likeRecentLimit, "limit", 1, "Number of suggestions")
 }
@@ -42,7 +45,7 @@ func runLikeRecent(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
-	database, err := db.New(dbPath)
+	database, err := openDatabase()
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
 	}
@@ -63,6 +66,18 @@ func runLikeRecent(cmd *cobra.Command, args []string) error {
 		opts.WorkingDir = cwd
 	}
 
+	// Add branch preference if requested; outside a repository there is none
+	if likeRecentBranch {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("failed to get current directory: %w", err)
+		}
+		if gitCtx, err := git.DetectGitContext(cwd); err == nil && gitCtx != nil {
+			opts.GitRepo = gitCtx.Repo
+			opts.GitBranch = gitCtx.Branch
+		}
+	}
+
 	// Add session filter if requested
 	if likeRecentSession {
 		sourceApp, sourcePid, detected, err := detectCurrentSession()
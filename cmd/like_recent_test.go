@@ -3,6 +3,7 @@ package cmd
 import (
 	"bytes"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"testing"
 
@@ -17,6 +18,7 @@ import (
 func resetLikeRecentFlags() {
 	likeRecentPwd = false
 	likeRecentSession = false
+	likeRecentBranch = false
 	likeRecentExclude = ""
 	likeRecentLimit = 1
 }
@@ -487,3 +489,61 @@ func TestLikeRecentWithLimitZero(t *testing.T) {
 	rootCmd.SetArgs(nil)
 	resetLikeRecentFlags()
 }
+
+// TestLikeRecentWithBranch tests that --branch prefers a command run on the
+// current git branch over a more recent match from the whole history
+func TestLikeRecentWithBranch(t *testing.T) {
+	tempDir := t.TempDir()
+	dbPath := filepath.Join(tempDir, "history.db")
+
+	repoDir := filepath.Join(tempDir, "repo")
+	require.NoError(t, os.Mkdir(repoDir, 0755))
+	for _, args := range [][]string{
+		{"init", "-b", "feature"},
+		{"remote", "add", "origin", "github.com/chris/shy"},
+		{"-c", "user.name=Test", "-c", "user.email=test@example.com", "commit", "--allow-empty", "-m", "init"},
+	} {
+		git := exec.Command("git", args...)
+		git.Dir = repoDir
+		require.NoError(t, git.Run(), "git %v", args)
+	}
+	t.Chdir(repoDir)
+
+	database, err := db.NewForTesting(dbPath)
+	require.NoError(t, err, "failed to create database")
+	defer database.Close()
+
+	repo := "github.com/chris/shy"
+	feature, main := "feature", "main"
+	commands := []struct {
+		text      string
+		timestamp int64
+		branch    *string
+	}{
+		{"go test ./internal/feature/...", 1704470400, &feature},
+		{"go test ./...", 1704470401, &main},
+	}
+	for _, c := range commands {
+		_, err := database.InsertCommand(&models.Command{
+			CommandText: c.text,
+			WorkingDir:  "/home/test",
+			Timestamp:   c.timestamp,
+			GitRepo:     &repo,
+			GitBranch:   c.branch,
+		})
+		require.NoError(t, err, "failed to insert command")
+	}
+
+	run := func(args ...string) string {
+		var buf bytes.Buffer
+		rootCmd.SetOut(&buf)
+		rootCmd.SetArgs(append([]string{"like-recent", "go test", "--db", dbPath}, args...))
+		require.NoError(t, rootCmd.Execute(), "like-recent should succeed")
+		rootCmd.SetArgs(nil)
+		resetLikeRecentFlags()
+		return buf.String()
+	}
+
+	assert.Equal(t, "go test ./...\n", run(), "without --branch the most recent match wins")
+	assert.Equal(t, "go test ./internal/feature/...\n", run("--branch"), "should prefer the current branch's command")
+}
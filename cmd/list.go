@@ -8,7 +8,6 @@ import (
 
 	"github.com/spf13/cobra"
 
-	"github.com/chris/shy/internal/db"
 	"github.com/chris/shy/pkg/models"
 )
 
@@ -106,7 +105,7 @@ func formatDurationMilliseconds(durationMs *int64) string {
 }
 
 func runList(cmd *cobra.Command, args []string) error {
-	database, err := db.New(dbPath)
+	database, err := openDatabase()
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
 	}
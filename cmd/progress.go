@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// stderrIsTerminal is injectable for testing, like stdinIsTerminal
+var stderrIsTerminal = stderrIsTerminalReal
+
+// stderrIsTerminalReal reports whether stderr is an interactive terminal
+func stderrIsTerminalReal() bool {
+	info, err := os.Stderr.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// progressInterval is the least time between two progress redraws
+const progressInterval = 100 * time.Millisecond
+
+// progress redraws a "label n/total" line in place on stderr while a bulk
+// import or export runs. It is silent unless stderr is a terminal, so piped
+// and redirected runs see no progress, and it never writes to stdout.
+type progress struct {
+	out   io.Writer // nil when silent
+	label string
+	total int // 0 when unknown: only n is shown
+	drawn time.Time
+}
+
+func newProgress(cmd *cobra.Command, label string, total int) *progress {
+	p := &progress{label: label, total: total}
+	if stderrIsTerminal() {
+		p.out = cmd.ErrOrStderr()
+	}
+	return p
+}
+
+// update shows that n rows have been processed. Redraws are throttled to
+// one per progressInterval, except for the last row.
+func (p *progress) update(n int) {
+	if p.out == nil {
+		return
+	}
+	now := time.Now()
+	if now.Sub(p.drawn) < progressInterval && n != p.total {
+		return
+	}
+	p.drawn = now
+	if p.total > 0 {
+		fmt.Fprintf(p.out, "\r%s %d/%d", p.label, n, p.total)
+	} else {
+		fmt.Fprintf(p.out, "\r%s %d", p.label, n)
+	}
+}
+
+// done clears the progress line so later output starts on a clean line
+func (p *progress) done() {
+	if p.out == nil || p.drawn.IsZero() {
+		return
+	}
+	fmt.Fprint(p.out, "\r\033[K")
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/chris/shy/internal/db"
+)
+
+var (
+	randomMatch string
+	randomSince string
+	randomUntil string
+)
+
+var randomCmd = &cobra.Command{
+	Use:   "random",
+	Short: "Print a random command from history",
+	Long: `Print one command picked at random from the history, with its time and
+directory, to rediscover commands run once and forgotten.
+
+-m keeps commands matching a glob pattern and --since/--until those run
+between two dates (YYYY-MM-DD, both inclusive). Every run is a candidate, so
+commands run often come up more often.`,
+	Args: cobra.NoArgs,
+	RunE: runRandom,
+}
+
+func init() {
+	rootCmd.AddCommand(randomCmd)
+	randomCmd.Flags().StringVarP(&randomMatch, "match", "m", "", "Only pick commands matching a glob pattern")
+	randomCmd.Flags().StringVar(&randomSince, "since", "", "Only pick commands on or after this date (YYYY-MM-DD)")
+	randomCmd.Flags().StringVar(&randomUntil, "until", "", "Only pick commands on or before this date (YYYY-MM-DD)")
+}
+
+func runRandom(cmd *cobra.Command, args []string) error {
+	var opts db.RandomOptions
+	if randomMatch != "" {
+		opts.Pattern = globToLike(randomMatch)
+	}
+	if randomSince != "" {
+		since, err := time.ParseInLocation("2006-01-02", randomSince, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid --since date %q: expected YYYY-MM-DD", randomSince)
+		}
+		opts.StartTime = since.Unix()
+	}
+	if randomUntil != "" {
+		until, err := time.ParseInLocation("2006-01-02", randomUntil, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid --until date %q: expected YYYY-MM-DD", randomUntil)
+		}
+		// --until is inclusive: stop at the start of the following day
+		opts.EndTime = until.AddDate(0, 0, 1).Unix()
+	}
+
+	database, err := openDatabase()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer database.Close()
+
+	c, err := database.GetRandomCommand(opts)
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		fmt.Fprintln(cmd.OutOrStdout(), "No commands found")
+		return nil
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "%s  %s  %s\n", time.Unix(c.Timestamp, 0).Format("2006-01-02 15:04:05"), c.WorkingDir, c.CommandText)
+	return nil
+}
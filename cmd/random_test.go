@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runRandomForTest(t *testing.T, dbPath string, args ...string) string {
+	t.Helper()
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	rootCmd.SetArgs(append([]string{"random", "--db", dbPath}, args...))
+	require.NoError(t, rootCmd.Execute())
+	rootCmd.SetOut(nil)
+	rootCmd.SetArgs(nil)
+	randomMatch, randomSince, randomUntil = "", "", ""
+	return out.String()
+}
+
+func TestRandomMatchesFilter(t *testing.T) {
+	// Commands are one per day from 2026-03-10: git status, make build,
+	// make test, git push, ls
+	dbPath := setupExportScenario(t)
+
+	for range 10 {
+		out := runRandomForTest(t, dbPath, "-m", "make*")
+		assert.Regexp(t, `^2026-03-1[12] 09:00:00  /home/user/shy  make (build|test)\n$`, out)
+
+		out = runRandomForTest(t, dbPath, "-m", "git*", "--since", "2026-03-11")
+		assert.Equal(t, "2026-03-13 09:00:00  /home/user/shy  git push\n", out)
+
+		out = runRandomForTest(t, dbPath, "--since", "2026-03-11", "--until", "2026-03-11")
+		assert.Equal(t, "2026-03-11 09:00:00  /home/user/shy  make build\n", out, "--until is inclusive")
+	}
+
+	assert.Equal(t, "No commands found\n", runRandomForTest(t, dbPath, "-m", "docker*"))
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/chris/shy/internal/db/migrations"
+)
+
+var reindexCmd = &cobra.Command{
+	Use:   "reindex",
+	Short: "Rebuild the database indexes and check integrity",
+	Long: `Drop and recreate every index the schema defines, then run SQLite's
+integrity check and report the result. Use it when queries slow down or a
+copied database is missing indexes (see shy doctor). Safe to run repeatedly.`,
+	Args: cobra.NoArgs,
+	RunE: runReindex,
+}
+
+func init() {
+	rootCmd.AddCommand(reindexCmd)
+}
+
+func runReindex(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+	out := cmd.OutOrStdout()
+
+	database, err := openDatabase()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer database.Close()
+
+	results, err := database.Reindex()
+	if err != nil {
+		return fmt.Errorf("failed to reindex: %w", err)
+	}
+	fmt.Fprintf(out, "Rebuilt %d indexes\n", len(migrations.Indexes()))
+
+	if len(results) == 1 && results[0] == "ok" {
+		fmt.Fprintln(out, "Integrity check: ok")
+		return nil
+	}
+	fmt.Fprintln(out, "Integrity check failed:")
+	for _, result := range results {
+		fmt.Fprintf(out, "  %s\n", result)
+	}
+	return fmt.Errorf("shy reindex: integrity check found %d problem(s)", len(results))
+}
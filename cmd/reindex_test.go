@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"bytes"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chris/shy/internal/db"
+	"github.com/chris/shy/pkg/models"
+)
+
+func TestReindexRestoresMissingIndexes(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	database, err := db.NewForTesting(dbPath)
+	require.NoError(t, err)
+	_, err = database.InsertCommand(models.NewCommand("ls", "/tmp", 0))
+	require.NoError(t, err)
+	database.Close()
+
+	conn, err := sql.Open("sqlite", dbPath)
+	require.NoError(t, err)
+	_, err = conn.Exec("DROP INDEX idx_timestamp_desc")
+	require.NoError(t, err)
+	_, err = conn.Exec("DROP INDEX idx_not_duplicate")
+	require.NoError(t, err)
+	conn.Close()
+	assert.Contains(t, runDoctorForTest(t, dbPath), "Indexes:        6/8 present")
+
+	// Running twice is harmless
+	for range 2 {
+		var buf bytes.Buffer
+		rootCmd.SetOut(&buf)
+		rootCmd.SetArgs([]string{"reindex", "--db", dbPath})
+		require.NoError(t, rootCmd.Execute())
+		rootCmd.SetOut(nil)
+		rootCmd.SetArgs(nil)
+
+		assert.Contains(t, buf.String(), "Rebuilt 8 indexes")
+		assert.Contains(t, buf.String(), "Integrity check: ok")
+	}
+
+	output := runDoctorForTest(t, dbPath)
+	assert.Contains(t, output, "Indexes:        8/8 present")
+	assert.Contains(t, output, "\nOK\n")
+}
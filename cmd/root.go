@@ -3,11 +3,16 @@ package cmd
 import (
 	"os"
 
+	"github.com/chris/shy/internal/db"
 	"github.com/chris/shy/internal/session"
 	"github.com/spf13/cobra"
 )
 
-var dbPath string
+var (
+	dbPath      string
+	dbReadOnly  bool
+	archivePath string
+)
 
 var rootCmd = &cobra.Command{
 	Use:     "shy",
@@ -37,15 +42,23 @@ var rootCmd = &cobra.Command{
 	},
 }
 
+// openDatabase opens the database at dbPath, read-only under --read-only and
+// with the --archive database attached
+func openDatabase() (*db.DB, error) {
+	return db.NewWithOptions(dbPath, db.Options{ReadOnly: dbReadOnly, ArchivePath: archivePath})
+}
+
 // Execute runs the root command
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }
 
 func init() {
 	rootCmd.PersistentFlags().StringVar(&dbPath, "db", "", "Database file path (default: ~/.local/share/shy/history.db)")
+	rootCmd.PersistentFlags().BoolVar(&dbReadOnly, "read-only", false, "Open the database read-only: no migrations, and writes fail")
+	rootCmd.PersistentFlags().StringVar(&archivePath, "archive", "", "Archive database whose commands summary, fc -l and search also show (read-only)")
 	// Version flag is automatically added by cobra when Version is set
 	rootCmd.SetVersionTemplate("shy version {{.Version}}\n")
 }
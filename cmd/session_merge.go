@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	sessionMergeSince string
+	sessionMergeYes   bool
+)
+
+var sessionCmd = &cobra.Command{
+	Use:   "session",
+	Short: "Manage recorded shell sessions",
+}
+
+var sessionMergeCmd = &cobra.Command{
+	Use:   "merge <from-pid> <to-pid>",
+	Short: "Move a session's commands to another session PID",
+	Long: `Reattribute the commands recorded under one session PID to another, to fix
+commands credited to the wrong session, e.g. after the OS reused a PID.
+
+--since limits the move to commands recorded on or after a date (YYYY-MM-DD)
+or Unix timestamp, so one session can be split off from another. The number
+of commands to move is shown and confirmed before anything changes.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runSessionMerge,
+}
+
+func init() {
+	rootCmd.AddCommand(sessionCmd)
+	sessionCmd.AddCommand(sessionMergeCmd)
+	sessionMergeCmd.Flags().StringVar(&sessionMergeSince, "since", "", "Only move commands on or after this date (YYYY-MM-DD) or Unix timestamp")
+	sessionMergeCmd.Flags().BoolVar(&sessionMergeYes, "yes", false, "Move without prompting for confirmation")
+}
+
+func runSessionMerge(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	pids := make([]int64, len(args))
+	for i, arg := range args {
+		pid, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil || pid <= 0 {
+			return fmt.Errorf("invalid session PID %q: must be a positive integer", arg)
+		}
+		pids[i] = pid
+	}
+	fromPid, toPid := pids[0], pids[1]
+	if fromPid == toPid {
+		return fmt.Errorf("shy session merge: from and to PIDs are the same")
+	}
+
+	since, err := parseSessionMergeSince(sessionMergeSince)
+	if err != nil {
+		return err
+	}
+
+	database, err := openDatabase()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer database.Close()
+
+	count, err := database.CountSessionCommands(fromPid, since)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		fmt.Fprintf(cmd.OutOrStdout(), "No commands to move from session %d\n", fromPid)
+		return nil
+	}
+
+	if !sessionMergeYes {
+		line := fmt.Sprintf("%d command(s) from session %d to session %d", count, fromPid, toPid)
+		ok, err := confirmPrompt(cmd.OutOrStdout(), "shy session merge", []string{line}, "move")
+		if err != nil || !ok {
+			return err
+		}
+	}
+
+	moved, err := database.ReassignSession(fromPid, toPid, since)
+	if err != nil {
+		return fmt.Errorf("failed to merge sessions: %w", err)
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Moved %d command(s) from session %d to session %d\n", moved, fromPid, toPid)
+	return nil
+}
+
+// parseSessionMergeSince parses --since as a local date or a Unix timestamp;
+// empty means the session's whole history
+func parseSessionMergeSince(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if ts, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return ts, nil
+	}
+	since, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return 0, fmt.Errorf("invalid --since %q: expected YYYY-MM-DD or a Unix timestamp", value)
+	}
+	return since.Unix(), nil
+}
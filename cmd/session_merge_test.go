@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chris/shy/internal/db"
+	"github.com/chris/shy/pkg/models"
+)
+
+func TestSessionMerge(t *testing.T) {
+	defer func() {
+		sessionMergeSince = ""
+		sessionMergeYes = false
+	}()
+
+	tempDir := t.TempDir()
+	dbPath := filepath.Join(tempDir, "test.db")
+
+	database, err := db.NewForTesting(dbPath)
+	require.NoError(t, err)
+	app := "zsh"
+	active := true
+	for i, pid := range []int64{100, 100, 100, 200} {
+		cmd := models.NewCommand("cmd", "/home/test", 0)
+		cmd.Timestamp = int64(1704470400 + i)
+		cmd.SourceApp = &app
+		cmd.SourcePid = &pid
+		cmd.SourceActive = &active
+		_, err := database.InsertCommand(cmd)
+		require.NoError(t, err)
+	}
+	database.Close()
+
+	run := func(answer string, args ...string) (string, error) {
+		defer setupConfirmInput(t, answer)()
+		var buf bytes.Buffer
+		rootCmd.SetOut(&buf)
+		rootCmd.SetArgs(append([]string{"session", "merge", "--db", dbPath}, args...))
+		err := rootCmd.Execute()
+		rootCmd.SetArgs(nil)
+		return buf.String(), err
+	}
+	sessionCount := func(pid int64) int64 {
+		database, err := db.New(dbPath)
+		require.NoError(t, err)
+		defer database.Close()
+		count, err := database.CountSessionCommands(pid, 0)
+		require.NoError(t, err)
+		return count
+	}
+
+	// Declining leaves everything in place
+	output, err := run("n\n", "100", "200", "--since", "1704470401")
+	require.NoError(t, err)
+	assert.Contains(t, output, "2 command(s) from session 100 to session 200")
+	assert.Contains(t, output, "shy session merge: aborted")
+	assert.Equal(t, int64(3), sessionCount(100))
+
+	output, err = run("y\n", "100", "200", "--since", "1704470401")
+	require.NoError(t, err)
+	assert.Contains(t, output, "Moved 2 command(s) from session 100 to session 200")
+	assert.Equal(t, int64(1), sessionCount(100))
+	assert.Equal(t, int64(3), sessionCount(200))
+
+	// --yes skips the prompt
+	sessionMergeSince = ""
+	output, err = run("", "200", "300", "--yes")
+	require.NoError(t, err)
+	assert.NotContains(t, output, "[y/N]")
+	assert.Contains(t, output, "Moved 3 command(s)")
+
+	output, err = run("", "200", "300", "--yes")
+	require.NoError(t, err)
+	assert.Contains(t, output, "No commands to move from session 200")
+
+	_, err = run("", "100", "100")
+	assert.Error(t, err)
+	_, err = run("", "100", "abc")
+	assert.Error(t, err)
+}
+
+func TestParseSessionMergeSince(t *testing.T) {
+	ts, err := parseSessionMergeSince("")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), ts)
+
+	ts, err = parseSessionMergeSince("1704470400")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1704470400), ts)
+
+	ts, err = parseSessionMergeSince("2026-01-02")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 1, 2, 0, 0, 0, 0, time.Local).Unix(), ts)
+
+	_, err = parseSessionMergeSince("yesterday")
+	assert.Error(t, err)
+}
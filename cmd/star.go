@@ -6,8 +6,6 @@ import (
 	"strconv"
 
 	"github.com/spf13/cobra"
-
-	"github.com/chris/shy/internal/db"
 )
 
 var starCmd = &cobra.Command{
@@ -36,7 +34,7 @@ var starAddCmd = &cobra.Command{
 			return fmt.Errorf("invalid event ID %q: must be a positive integer", args[0])
 		}
 
-		database, err := db.New(dbPath)
+		database, err := openDatabase()
 		if err != nil {
 			return fmt.Errorf("failed to open database: %w", err)
 		}
@@ -66,7 +64,7 @@ var starRemoveCmd = &cobra.Command{
 			return fmt.Errorf("invalid event ID %q: must be a positive integer", args[0])
 		}
 
-		database, err := db.New(dbPath)
+		database, err := openDatabase()
 		if err != nil {
 			return fmt.Errorf("failed to open database: %w", err)
 		}
@@ -108,7 +106,7 @@ func runStarBare(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("could not detect current session: SHY_SESSION_PID not set")
 	}
 
-	database, err := db.New(dbPath)
+	database, err := openDatabase()
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
 	}
@@ -166,7 +164,7 @@ func runStarList(cmd *cobra.Command, args []string) error {
 		cwd = wd
 	}
 
-	database, err := db.New(dbPath)
+	database, err := openDatabase()
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
 	}
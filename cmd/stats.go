@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/chris/shy/internal/db"
+	"github.com/chris/shy/pkg/models"
+)
+
+var (
+	statsJSON bool
+	statsTop  int
+)
+
+// statsNow is injectable for testing: it decides which day the current
+// streak runs up to
+var statsNow = time.Now
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show statistics about the command history",
+	Long: `Summarize the whole history: totals, the failure rate, the most-run
+commands and directories, the longest-running commands, the current and
+longest streaks of consecutive days with a command, and how many commands
+ran in each hour of the day.
+
+--json writes the same figures as one JSON object for dashboards. Keys are
+sorted and arrays are in a fixed order (ties broken alphabetically), so the
+output of two runs over the same history is identical.`,
+	Args: cobra.NoArgs,
+	RunE: runStats,
+}
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+	statsCmd.Flags().BoolVar(&statsJSON, "json", false, "Write the statistics as JSON")
+	statsCmd.Flags().IntVar(&statsTop, "top", 10, "Number of top commands and directories to show")
+}
+
+// statsReport is the JSON form of shy stats. Fields are declared in
+// alphabetical order of their keys, which is the order encoding/json writes.
+type statsReport struct {
+	BusiestHours   []int           `json:"busiest_hours"`
+	FailureRate    float64         `json:"failure_rate"`
+	Slowest        []statsSlow     `json:"slowest_commands"`
+	Streaks        statsStreaks    `json:"streaks"`
+	TopCommands    []statsCommand  `json:"top_commands"`
+	TopDirectories []statsDirEntry `json:"top_directories"`
+	Totals         statsTotals     `json:"totals"`
+}
+
+type statsCommand struct {
+	Command string `json:"command"`
+	Count   int    `json:"count"`
+}
+
+type statsSlow struct {
+	Command    string `json:"command"`
+	DurationMs int64  `json:"duration_ms"`
+	Timestamp  int64  `json:"timestamp"`
+}
+
+type statsStreaks struct {
+	CurrentDays int `json:"current_days"`
+	LongestDays int `json:"longest_days"`
+}
+
+type statsDirEntry struct {
+	Count     int    `json:"count"`
+	Directory string `json:"directory"`
+}
+
+type statsTotals struct {
+	Commands       int   `json:"commands"`
+	Directories    int   `json:"directories"`
+	Failed         int   `json:"failed"`
+	FirstTimestamp int64 `json:"first_timestamp"`
+	LastTimestamp  int64 `json:"last_timestamp"`
+	Sessions       int   `json:"sessions"`
+	UniqueCommands int   `json:"unique_commands"`
+}
+
+func runStats(cmd *cobra.Command, args []string) error {
+	if statsTop < 1 {
+		return fmt.Errorf("invalid --top %d: must be at least 1", statsTop)
+	}
+
+	database, err := openDatabase()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer database.Close()
+
+	stats, err := database.GetStats(statsTop)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if statsJSON {
+		data, err := json.MarshalIndent(newStatsReport(stats, statsNow()), "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode stats: %w", err)
+		}
+		fmt.Fprintln(out, string(data))
+		return nil
+	}
+	printStats(out, stats, statsNow())
+	return nil
+}
+
+// failureRate returns the fraction of commands that failed, 0 for no commands
+func failureRate(stats *db.Stats) float64 {
+	if stats.Commands == 0 {
+		return 0
+	}
+	return float64(stats.Failed) / float64(stats.Commands)
+}
+
+// newStatsReport builds the JSON report, with streaks running up to now
+func newStatsReport(stats *db.Stats, now time.Time) statsReport {
+	current, longest := db.ActivityStreaks(stats.Days, now)
+	report := statsReport{
+		BusiestHours:   stats.HourCounts[:],
+		FailureRate:    failureRate(stats),
+		Slowest:        []statsSlow{},
+		Streaks:        statsStreaks{CurrentDays: current, LongestDays: longest},
+		TopCommands:    []statsCommand{},
+		TopDirectories: []statsDirEntry{},
+		Totals: statsTotals{
+			Commands:       stats.Commands,
+			Directories:    stats.Directories,
+			Failed:         stats.Failed,
+			FirstTimestamp: stats.FirstTimestamp,
+			LastTimestamp:  stats.LastTimestamp,
+			Sessions:       stats.Sessions,
+			UniqueCommands: stats.UniqueCommands,
+		},
+	}
+	for _, c := range stats.TopCommands {
+		report.TopCommands = append(report.TopCommands, statsCommand{Command: c.Text, Count: c.Count})
+	}
+	for _, d := range stats.TopDirectories {
+		report.TopDirectories = append(report.TopDirectories, statsDirEntry{Count: d.Count, Directory: d.Text})
+	}
+	for _, c := range stats.Slowest {
+		report.Slowest = append(report.Slowest, statsSlow{Command: c.CommandText, DurationMs: *c.Duration, Timestamp: c.Timestamp})
+	}
+	return report
+}
+
+// statsBarWidth is the width of the longest bar in the busiest-hours chart
+const statsBarWidth = 40
+
+// printStats writes the human-readable report, with streaks running up to now
+func printStats(out io.Writer, stats *db.Stats, now time.Time) {
+	fmt.Fprintf(out, "Commands:     %d (%d unique)\n", stats.Commands, stats.UniqueCommands)
+	fmt.Fprintf(out, "Failed:       %d (%.1f%%)\n", stats.Failed, failureRate(stats)*100)
+	fmt.Fprintf(out, "Sessions:     %d\n", stats.Sessions)
+	fmt.Fprintf(out, "Directories:  %d\n", stats.Directories)
+	if stats.Commands == 0 {
+		return
+	}
+	fmt.Fprintf(out, "First:        %s\n", time.Unix(stats.FirstTimestamp, 0).Format("2006-01-02 15:04"))
+	fmt.Fprintf(out, "Last:         %s\n", time.Unix(stats.LastTimestamp, 0).Format("2006-01-02 15:04"))
+
+	current, longest := db.ActivityStreaks(stats.Days, now)
+	fmt.Fprintf(out, "\nCurrent streak: %s\n", formatDays(current))
+	fmt.Fprintf(out, "Longest streak: %s\n", formatDays(longest))
+
+	fmt.Fprintln(out, "\nTop commands:")
+	for _, c := range stats.TopCommands {
+		first, _, _ := strings.Cut(c.Text, "\n")
+		fmt.Fprintf(out, "  %6d  %s\n", c.Count, first)
+	}
+
+	fmt.Fprintln(out, "\nTop directories:")
+	for _, d := range stats.TopDirectories {
+		fmt.Fprintf(out, "  %6d  %s\n", d.Count, d.Text)
+	}
+
+	if len(stats.Slowest) > 0 {
+		fmt.Fprintln(out, "\nSlowest commands:")
+		for _, c := range stats.Slowest {
+			first, _, _ := strings.Cut(c.CommandText, "\n")
+			fmt.Fprintf(out, "  %8s  %s\n", models.FormatDurationTenths(*c.Duration), first)
+		}
+	}
+
+	busiest := 0
+	for _, n := range stats.HourCounts {
+		busiest = max(busiest, n)
+	}
+	fmt.Fprintln(out, "\nBusiest hours:")
+	for hour, n := range stats.HourCounts {
+		width := n * statsBarWidth / busiest
+		bar := strings.Repeat("█", width) + strings.Repeat(" ", statsBarWidth-width)
+		fmt.Fprintf(out, "  %02d  %s %d\n", hour, bar, n)
+	}
+}
+
+// formatDays formats a number of days ("1 day", "12 days")
+func formatDays(n int) string {
+	if n == 1 {
+		return "1 day"
+	}
+	return fmt.Sprintf("%d days", n)
+}
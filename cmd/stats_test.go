@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chris/shy/internal/db"
+	"github.com/chris/shy/pkg/models"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files in testdata")
+
+func runStatsForTest(t *testing.T, dbPath string, args ...string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	rootCmd.SetArgs(append([]string{"stats", "--db", dbPath}, args...))
+	err := rootCmd.Execute()
+	rootCmd.SetOut(nil)
+	rootCmd.SetArgs(nil)
+	statsJSON, statsTop = false, 10
+	return out.String(), err
+}
+
+// setupStatsScenario builds a fixed history: hours are read in UTC so the
+// busiest_hours array does not depend on the machine's zone, and streaks run
+// up to the day after it
+func setupStatsScenario(t *testing.T) string {
+	t.Helper()
+	local := time.Local
+	time.Local = time.UTC
+	statsNow = func() time.Time { return time.Date(2026, 3, 11, 12, 0, 0, 0, time.UTC) }
+	t.Cleanup(func() {
+		time.Local = local
+		statsNow = time.Now
+	})
+
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	database, err := db.NewForTesting(dbPath)
+	require.NoError(t, err)
+	defer database.Close()
+
+	day := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	zsh, bash := "zsh", "bash"
+	zshPid, bashPid := int64(100), int64(200)
+	for _, c := range []struct {
+		hour, minute int
+		text, dir    string
+		exit         int
+		app          *string
+		pid          *int64
+		durationMs   int64 // 0 when not captured
+	}{
+		{9, 0, "git status", "/home/user/shy", 0, &zsh, &zshPid, 40},
+		{9, 5, "make test", "/home/user/shy", 2, &zsh, &zshPid, 8250},
+		{9, 10, "make test", "/home/user/shy", 0, &zsh, &zshPid, 72000},
+		{9, 50, "git status", "/home/user/shy", 0, &zsh, &zshPid, 0},
+		{14, 0, "ls", "/tmp", 0, &bash, &bashPid, 0},
+		{14, 30, "cat notes.txt", "/tmp", 1, &bash, &bashPid, 0},
+		{23, 59, "git status", "/home/user/other", 0, nil, nil, 0},
+	} {
+		var duration *int64
+		if c.durationMs > 0 {
+			duration = &c.durationMs
+		}
+		_, err := database.InsertCommand(&models.Command{
+			CommandText: c.text,
+			WorkingDir:  c.dir,
+			ExitStatus:  c.exit,
+			Timestamp:   day.Add(time.Duration(c.hour)*time.Hour + time.Duration(c.minute)*time.Minute).Unix(),
+			SourceApp:   c.app,
+			SourcePid:   c.pid,
+			Duration:    duration,
+		})
+		require.NoError(t, err)
+	}
+	return dbPath
+}
+
+func TestStatsJSONGolden(t *testing.T) {
+	dbPath := setupStatsScenario(t)
+
+	out, err := runStatsForTest(t, dbPath, "--json", "--top", "3")
+	require.NoError(t, err)
+
+	golden := filepath.Join("testdata", "stats.json.golden")
+	if *updateGolden {
+		require.NoError(t, os.WriteFile(golden, []byte(out), 0644))
+	}
+	want, err := os.ReadFile(golden)
+	require.NoError(t, err)
+	assert.Equal(t, string(want), out)
+
+	// Stable: a second run writes the same bytes
+	again, err := runStatsForTest(t, dbPath, "--json", "--top", "3")
+	require.NoError(t, err)
+	assert.Equal(t, out, again)
+}
+
+func TestStatsHuman(t *testing.T) {
+	dbPath := setupStatsScenario(t)
+
+	out, err := runStatsForTest(t, dbPath)
+	require.NoError(t, err)
+	assert.Contains(t, out, "Commands:     7 (4 unique)\n")
+	assert.Contains(t, out, "Failed:       2 (28.6%)\n")
+	assert.Contains(t, out, "Sessions:     2\n")
+	assert.Contains(t, out, "\nCurrent streak: 1 day\nLongest streak: 1 day\n")
+	assert.Contains(t, out, "       3  git status\n")
+	assert.Contains(t, out, "       4  /home/user/shy\n")
+	assert.Contains(t, out, "  09  ████████████████████████████████████████ 4\n")
+	assert.Contains(t, out, "  14  ████████████████████                     2\n")
+	assert.Contains(t, out, "\nSlowest commands:\n     1m12s  make test\n      8.2s  make test\n      40ms  git status\n")
+}
+
+func TestStatsEmptyHistory(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	database, err := db.NewForTesting(dbPath)
+	require.NoError(t, err)
+	database.Close()
+
+	out, err := runStatsForTest(t, dbPath, "--json")
+	require.NoError(t, err)
+	assert.Contains(t, out, `"failure_rate": 0,`)
+	assert.Contains(t, out, `"top_commands": [],`)
+
+	out, err = runStatsForTest(t, dbPath)
+	require.NoError(t, err)
+	assert.Contains(t, out, "Commands:     0 (0 unique)\n")
+	assert.NotContains(t, out, "Busiest hours")
+
+	_, err = runStatsForTest(t, dbPath, "--top", "0")
+	assert.ErrorContains(t, err, "invalid --top 0")
+}
@@ -2,13 +2,44 @@ package cmd
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
 
 	tea "charm.land/bubbletea/v2"
 	"github.com/spf13/cobra"
 
 	"github.com/chris/shy/internal/summary/tui"
+	"github.com/chris/shy/pkg/models"
 )
 
+var (
+	summaryCompact        bool
+	summaryWatch          bool
+	summaryGroupWorktrees bool
+	summaryCollapseNonGit bool
+	summaryCd             bool
+	summaryWrap           bool
+	summaryConfirmQuit    bool
+	summaryDefaultBranch  []string
+	summaryDimNoise       bool
+	summaryHighlight      bool
+	summaryNoise          []string
+	summaryISOWeeks       bool
+	summaryTruncate       string
+	summaryHistorySize    bool
+	summaryIDs            bool
+	summaryRelativeLabels map[string]string
+	summaryPrintWindow    bool
+	summaryDate           string
+	summaryPeriod         string
+	summaryMode           string
+)
+
+// summaryWatchInterval is how often --watch reloads today's contexts
+const summaryWatchInterval = 2 * time.Second
+
 var summaryCmd = &cobra.Command{
 	Use:   "summary",
 	Short: "Interactive summary of shell command activity",
@@ -18,13 +49,107 @@ var summaryCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(summaryCmd)
+	summaryCmd.Flags().BoolVar(&summaryCompact, "compact", false, "Always use the compact layout (default: only on short terminals)")
+	summaryCmd.Flags().BoolVar(&summaryGroupWorktrees, "group-worktrees", false, "Group git worktrees of the same repo and branch into one context")
+	summaryCmd.Flags().BoolVar(&summaryCollapseNonGit, "collapse-non-git", false, "Collapse every directory outside a git repo into one \"(no repo)\" context")
+	summaryCmd.Flags().BoolVar(&summaryCd, "cd", false, "Enable o to quit and print a cd command for the selected context, for eval \"$(shy summary --cd)\"")
+	summaryCmd.Flags().BoolVar(&summaryWatch, "watch", false, "Refresh the current period as new commands arrive")
+	summaryCmd.Flags().BoolVar(&summaryWrap, "wrap", false, "Wrap j/k from the last item to the first and back")
+	summaryCmd.Flags().StringSliceVar(&summaryDefaultBranch, "default-branch", tui.DefaultBranches, "Branch names shown in the default-branch color (comma separated)")
+	summaryCmd.Flags().BoolVar(&summaryDimNoise, "dim-noise", false, "Dim successful runs of the --noise programs in the context detail list")
+	summaryCmd.Flags().StringSliceVar(&summaryNoise, "noise", tui.DefaultNoiseCommands, "Programs --dim-noise dims (comma separated)")
+	summaryCmd.Flags().BoolVar(&summaryHighlight, "highlight", false, "Color program names, flags and quoted strings of the listed commands")
+	summaryCmd.Flags().BoolVar(&summaryISOWeeks, "iso-weeks", false, "Label weeks by ISO week number (2026-W06) instead of start date")
+	summaryCmd.Flags().StringVar(&summaryTruncate, "truncate", "right", "Side to cut long context names and paths from: right, or left to keep the project name")
+	summaryCmd.Flags().BoolVar(&summaryHistorySize, "history-size", false, "Show the total number of stored commands in the footer")
+	summaryCmd.Flags().BoolVar(&summaryIDs, "ids", false, "Show each command's event id (for shy fc) in the context detail list")
+	summaryCmd.Flags().StringToStringVar(&summaryRelativeLabels, "relative-labels", nil, "Header markers for the current and previous period, e.g. today=now,yesterday=-1 (keys: today, yesterday, this-week, last-week, this-month, last-month)")
+	summaryCmd.Flags().BoolVar(&summaryPrintWindow, "print-window", false, "Print the start and end of the --date/--period window and exit, without the TUI")
+	summaryCmd.Flags().StringVar(&summaryDate, "date", "yesterday", "Date for --print-window: YYYY-MM-DD, today or yesterday")
+	summaryCmd.Flags().StringVar(&summaryPeriod, "period", "day", "Period to open in, and for --print-window: day, week or month; $SHY_SUMMARY_PERIOD overrides the default")
+	summaryCmd.Flags().StringVar(&summaryMode, "mode", "all", "Display mode to open in: all or unique; $SHY_SUMMARY_MODE overrides the default")
+	summaryCmd.Flags().BoolVar(&summaryConfirmQuit, "confirm-quit", false, "Ask before q quits while a filter or selection is active or in command detail")
 }
 
 func runSummary(cmd *cobra.Command, args []string) error {
-	model := tui.New(dbPath)
+	if summaryTruncate != "left" && summaryTruncate != "right" {
+		return fmt.Errorf("invalid --truncate %q: expected left or right", summaryTruncate)
+	}
+	labels, err := relativeLabels(summaryRelativeLabels)
+	if err != nil {
+		return err
+	}
+	periodName, startOpts, err := summaryStartOptions(cmd)
+	if err != nil {
+		return err
+	}
+	if summaryPrintWindow {
+		return printSummaryWindow(cmd.OutOrStdout(), time.Now(), periodName)
+	}
+	if cmd.Flags().Changed("date") {
+		return fmt.Errorf("--date is only used with --print-window")
+	}
+	opts := append([]tui.Option{tui.WithExporter(exportSummaryRange), tui.WithTruncateSide(summaryTruncate)}, startOpts...)
+	if summaryCompact {
+		opts = append(opts, tui.WithCompact())
+	}
+	if summaryGroupWorktrees {
+		opts = append(opts, tui.WithWorktreeGrouping())
+	}
+	if summaryCollapseNonGit {
+		opts = append(opts, tui.WithCollapseNonGit())
+	}
+	if summaryWatch {
+		opts = append(opts, tui.WithAutoRefresh(summaryWatchInterval))
+	}
+	if summaryWrap {
+		opts = append(opts, tui.WithWrapNavigation())
+	}
+	if summaryConfirmQuit {
+		opts = append(opts, tui.WithConfirmQuit())
+	}
+	if summaryHistorySize {
+		opts = append(opts, tui.WithHistorySize())
+	}
+	if summaryIDs {
+		opts = append(opts, tui.WithShowIDs())
+	}
+	if summaryISOWeeks {
+		opts = append(opts, tui.WithWeekLabelStyle(tui.ISOWeekLabels))
+	}
+	if cmd.Flags().Changed("relative-labels") {
+		opts = append(opts, tui.WithRelativeLabels(labels))
+	}
+	if cmd.Flags().Changed("default-branch") {
+		opts = append(opts, tui.WithDefaultBranches(summaryDefaultBranch))
+	}
+	if summaryHighlight {
+		opts = append(opts, tui.WithHighlight())
+	}
+	if summaryDimNoise {
+		opts = append(opts, tui.WithDimNoise(summaryNoise))
+	}
+	if dbReadOnly {
+		opts = append(opts, tui.WithReadOnly())
+	}
+	if archivePath != "" {
+		opts = append(opts, tui.WithArchivePath(archivePath))
+	}
+
+	var programOpts []tea.ProgramOption
+	if summaryCd {
+		opts = append(opts, tui.WithCdOnQuit())
+		// Draw on the terminal so stdout carries only the cd command
+		if tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0); err == nil {
+			defer tty.Close()
+			programOpts = append(programOpts, tea.WithInput(tty), tea.WithOutput(tty))
+		}
+	}
+
+	model := tui.New(dbPath, opts...)
 	defer model.Close()
 
-	p := tea.NewProgram(model)
+	p := tea.NewProgram(model, programOpts...)
 	if _, err := p.Run(); err != nil {
 		if err.Error() == "quit" {
 			return nil
@@ -32,5 +157,113 @@ func runSummary(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to run summary: %w", err)
 	}
 
+	if model.CdRequested() {
+		fmt.Fprintln(cmd.OutOrStdout(), cdCommand(model.CdTarget()))
+	}
+
 	return nil
 }
+
+// summaryPeriods and summaryModes map the --period and --mode names onto the
+// summary's periods and display modes
+var (
+	summaryPeriods = map[string]tui.Period{"day": tui.DayPeriod, "week": tui.WeekPeriod, "month": tui.MonthPeriod}
+	summaryModes   = map[string]tui.DisplayMode{"all": tui.AllMode, "unique": tui.UniqueMode}
+)
+
+// summaryStartOptions returns the period name the summary opens with and the
+// options that open it in that period and display mode
+func summaryStartOptions(cmd *cobra.Command) (string, []tui.Option, error) {
+	periodName, err := summaryStartValue(cmd, "period", "SHY_SUMMARY_PERIOD", summaryPeriods, "day, week or month")
+	if err != nil {
+		return "", nil, err
+	}
+	modeName, err := summaryStartValue(cmd, "mode", "SHY_SUMMARY_MODE", summaryModes, "all or unique")
+	if err != nil {
+		return "", nil, err
+	}
+	return periodName, []tui.Option{tui.WithPeriod(summaryPeriods[periodName]), tui.WithDisplayMode(summaryModes[modeName])}, nil
+}
+
+// summaryStartValue returns the --period or --mode value the summary opens
+// with: the flag when given, else the environment variable env, else the
+// flag's default. An invalid flag is an error; an invalid environment value
+// is ignored with a warning, so a typo in a profile does not stop the summary.
+func summaryStartValue[T any](cmd *cobra.Command, flag, env string, valid map[string]T, expected string) (string, error) {
+	f := cmd.Flags().Lookup(flag)
+	if f.Changed {
+		if _, ok := valid[f.Value.String()]; !ok {
+			return "", fmt.Errorf("invalid --%s %q: expected %s", flag, f.Value.String(), expected)
+		}
+		return f.Value.String(), nil
+	}
+	if value := os.Getenv(env); value != "" {
+		if _, ok := valid[value]; ok {
+			return value, nil
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "shy summary: warning: ignoring %s=%q: expected %s\n", env, value, expected)
+	}
+	return f.DefValue, nil
+}
+
+// relativeLabels applies --relative-labels key=label pairs over the default
+// markers; an empty label hides that marker
+func relativeLabels(pairs map[string]string) (tui.RelativeLabels, error) {
+	labels := tui.DefaultRelativeLabels
+	fields := map[string]*string{
+		"today":      &labels.Today,
+		"yesterday":  &labels.Yesterday,
+		"this-week":  &labels.ThisWeek,
+		"last-week":  &labels.LastWeek,
+		"this-month": &labels.ThisMonth,
+		"last-month": &labels.LastMonth,
+	}
+	for key, label := range pairs {
+		field, ok := fields[key]
+		if !ok {
+			return labels, fmt.Errorf("invalid --relative-labels key %q: expected today, yesterday, this-week, last-week, this-month or last-month", key)
+		}
+		*field = label
+	}
+	return labels, nil
+}
+
+// printSummaryWindow writes the window the summary would load for --date and
+// periodName, relative to now, as epoch seconds and local times
+func printSummaryWindow(out io.Writer, now time.Time, periodName string) error {
+	var date time.Time
+	switch summaryDate {
+	case "today":
+		date = now
+	case "yesterday":
+		date = now.AddDate(0, 0, -1)
+	default:
+		parsed, err := time.ParseInLocation("2006-01-02", summaryDate, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid --date %q: expected YYYY-MM-DD, today or yesterday", summaryDate)
+		}
+		date = parsed
+	}
+
+	start, end := tui.DateRange(date, summaryPeriods[periodName])
+	const layout = "Mon 2006-01-02 15:04:05 MST"
+	fmt.Fprintf(out, "Period: %s of %s\n", periodName, date.Format("2006-01-02"))
+	fmt.Fprintf(out, "Start:  %d  %s\n", start, time.Unix(start, 0).Format(layout))
+	fmt.Fprintf(out, "End:    %d  %s (exclusive)\n", end, time.Unix(end, 0).Format(layout))
+	return nil
+}
+
+// cdCommand returns a shell command that changes to dir, single-quoted for eval
+func cdCommand(dir string) string {
+	return "cd -- '" + strings.ReplaceAll(dir, "'", `'\''`) + "'"
+}
+
+// exportSummaryRange writes a summary day selection to the current directory
+// in zsh extended history format (the same format as fc -W)
+func exportSummaryRange(commands []models.Command, start, end time.Time) (string, error) {
+	fileName := fmt.Sprintf("shy-%s_%s.history", start.Format("2006-01-02"), end.Format("2006-01-02"))
+	if err := writeHistoryToFile(fileName, nil, commands); err != nil {
+		return "", err
+	}
+	return fileName, nil
+}
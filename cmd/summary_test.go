@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chris/shy/internal/summary/tui"
+)
+
+func TestCdCommand(t *testing.T) {
+	assert.Equal(t, "cd -- '/home/user/src/shy'", cdCommand("/home/user/src/shy"))
+	assert.Equal(t, `cd -- '/tmp/it'\''s here'`, cdCommand("/tmp/it's here"))
+	assert.Equal(t, "cd -- '/tmp/$(rm -rf x)'", cdCommand("/tmp/$(rm -rf x)"))
+}
+
+func runSummaryForTest(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	rootCmd.SetArgs(append([]string{"summary", "--db", filepath.Join(t.TempDir(), "history.db")}, args...))
+	err := rootCmd.Execute()
+	rootCmd.SetOut(nil)
+	rootCmd.SetArgs(nil)
+	summaryPrintWindow, summaryDate = false, "yesterday"
+	summaryCmd.Flags().Lookup("date").Changed = false
+	resetSummaryStartFlags()
+	summaryRelativeLabels = nil
+	summaryCmd.Flags().Lookup("relative-labels").Changed = false
+	return out.String(), err
+}
+
+func TestSummaryPrintWindow(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("EST", -5*60*60)
+	t.Cleanup(func() { time.Local = local })
+
+	// A Sunday belongs to the ISO week that started the Monday before
+	out, err := runSummaryForTest(t, "--print-window", "--date", "2026-02-08", "--period", "week")
+	require.NoError(t, err)
+	assert.Equal(t, "Period: week of 2026-02-08\n"+
+		"Start:  1770008400  Mon 2026-02-02 00:00:00 EST\n"+
+		"End:    1770613200  Mon 2026-02-09 00:00:00 EST (exclusive)\n", out)
+
+	out, err = runSummaryForTest(t, "--print-window", "--date", "2026-02-06", "--period", "month")
+	require.NoError(t, err)
+	assert.Contains(t, out, "Start:  1769922000  Sun 2026-02-01 00:00:00 EST\n")
+	assert.Contains(t, out, "End:    1772341200  Sun 2026-03-01 00:00:00 EST (exclusive)\n")
+
+	_, err = runSummaryForTest(t, "--print-window", "--period", "year")
+	assert.EqualError(t, err, `invalid --period "year": expected day, week or month`)
+
+	_, err = runSummaryForTest(t, "--print-window", "--date", "02/06/2026")
+	assert.ErrorContains(t, err, `invalid --date "02/06/2026"`)
+
+	_, err = runSummaryForTest(t, "--date", "2026-02-06")
+	assert.EqualError(t, err, "--date is only used with --print-window")
+
+	t.Setenv("SHY_SUMMARY_PERIOD", "month")
+	out, err = runSummaryForTest(t, "--print-window", "--date", "2026-02-06")
+	require.NoError(t, err)
+	assert.Contains(t, out, "Period: month of 2026-02-06\n")
+}
+
+func resetSummaryStartFlags() {
+	summaryPeriod, summaryMode = "day", "all"
+	summaryCmd.Flags().Lookup("period").Changed = false
+	summaryCmd.Flags().Lookup("mode").Changed = false
+}
+
+// startModel parses args as summary flags and returns a model built with
+// the start options, and what was warned
+func startModel(t *testing.T, args ...string) (*tui.Model, string, error) {
+	t.Helper()
+	defer resetSummaryStartFlags()
+	var stderr bytes.Buffer
+	summaryCmd.SetErr(&stderr)
+	defer summaryCmd.SetErr(nil)
+	require.NoError(t, summaryCmd.ParseFlags(args))
+	_, opts, err := summaryStartOptions(summaryCmd)
+	return tui.New("", opts...), stderr.String(), err
+}
+
+func TestSummaryStartPeriodAndMode(t *testing.T) {
+	model, _, err := startModel(t)
+	require.NoError(t, err)
+	assert.Equal(t, tui.DayPeriod, model.Period())
+	assert.Equal(t, tui.AllMode, model.DisplayMode())
+
+	model, _, err = startModel(t, "--period", "week", "--mode", "unique")
+	require.NoError(t, err)
+	assert.Equal(t, tui.WeekPeriod, model.Period())
+	assert.Equal(t, tui.UniqueMode, model.DisplayMode())
+
+	t.Setenv("SHY_SUMMARY_PERIOD", "month")
+	t.Setenv("SHY_SUMMARY_MODE", "unique")
+	model, _, err = startModel(t)
+	require.NoError(t, err)
+	assert.Equal(t, tui.MonthPeriod, model.Period())
+	assert.Equal(t, tui.UniqueMode, model.DisplayMode())
+
+	model, _, err = startModel(t, "--period", "week", "--mode", "all")
+	require.NoError(t, err)
+	assert.Equal(t, tui.WeekPeriod, model.Period(), "the flag wins over the environment")
+	assert.Equal(t, tui.AllMode, model.DisplayMode())
+
+	t.Setenv("SHY_SUMMARY_PERIOD", "fortnight")
+	model, warning, err := startModel(t)
+	require.NoError(t, err)
+	assert.Equal(t, tui.DayPeriod, model.Period(), "an invalid environment value falls back to the default")
+	assert.Equal(t, `shy summary: warning: ignoring SHY_SUMMARY_PERIOD="fortnight": expected day, week or month`+"\n", warning)
+
+	_, _, err = startModel(t, "--mode", "distinct")
+	assert.EqualError(t, err, `invalid --mode "distinct": expected all or unique`)
+}
+
+func TestSummaryRelativeLabels(t *testing.T) {
+	labels, err := relativeLabels(map[string]string{"today": "now", "this-week": "this wk", "yesterday": ""})
+	require.NoError(t, err)
+	assert.Equal(t, tui.RelativeLabels{Today: "now", ThisWeek: "this wk"}, labels)
+
+	_, err = runSummaryForTest(t, "--relative-labels", "tomorrow=soon")
+	assert.ErrorContains(t, err, `invalid --relative-labels key "tomorrow"`)
+}
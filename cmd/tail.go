@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/chris/shy/internal/db"
+)
+
+var (
+	tailMatch    string
+	tailDir      string
+	tailInterval time.Duration
+)
+
+var tailCmd = &cobra.Command{
+	Use:   "tail",
+	Short: "Print new commands as they are recorded",
+	Long: `Follow the history like tail -f: print each command recorded from now on,
+with its time and directory, as it arrives from any terminal.
+
+The database is polled every --interval for commands newer than the last
+one printed. -m keeps commands matching a glob pattern and --dir those run
+in one directory. Ctrl-C stops.`,
+	Args: cobra.NoArgs,
+	RunE: runTail,
+}
+
+func init() {
+	rootCmd.AddCommand(tailCmd)
+	tailCmd.Flags().StringVarP(&tailMatch, "match", "m", "", "Only print commands matching a glob pattern")
+	tailCmd.Flags().StringVar(&tailDir, "dir", "", "Only print commands run in this directory")
+	tailCmd.Flags().DurationVar(&tailInterval, "interval", time.Second, "How often to check for new commands")
+}
+
+func runTail(cmd *cobra.Command, args []string) error {
+	if tailInterval <= 0 {
+		return fmt.Errorf("invalid --interval %s: must be positive", tailInterval)
+	}
+
+	database, err := openDatabase()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer database.Close()
+
+	filter := db.RangeFilter{WorkingDir: tailDir, AllRuns: true}
+	if tailMatch != "" {
+		filter.Pattern = globToLike(tailMatch)
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+	defer stop()
+	return tailCommands(ctx, cmd.OutOrStdout(), database, filter, tailInterval)
+}
+
+// tailCommands prints the commands recorded after it starts that pass
+// filter, checking every interval, until ctx is done
+func tailCommands(ctx context.Context, out io.Writer, database *db.DB, filter db.RangeFilter, interval time.Duration) error {
+	last, err := database.GetMostRecentEventID()
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		newest, err := database.GetMostRecentEventID()
+		if err != nil {
+			return err
+		}
+		if newest <= last {
+			continue
+		}
+		commands, err := database.GetCommandsByRangeOrdered(last+1, newest, filter, db.Ascending)
+		if err != nil {
+			return err
+		}
+		for _, c := range commands {
+			fmt.Fprintf(out, "%s  %s  %s\n", time.Unix(c.Timestamp, 0).Format("2006-01-02 15:04:05"), c.WorkingDir, c.CommandText)
+		}
+		last = newest
+	}
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chris/shy/internal/db"
+	"github.com/chris/shy/pkg/models"
+)
+
+func TestTailPrintsNewCommands(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	database, err := db.NewForTesting(dbPath)
+	require.NoError(t, err)
+	defer database.Close()
+
+	insert := func(text, dir string) {
+		_, err := database.InsertCommand(&models.Command{
+			CommandText: text, WorkingDir: dir, Timestamp: time.Date(2026, 3, 10, 9, 0, 0, 0, time.Local).Unix(),
+		})
+		require.NoError(t, err)
+	}
+	insert("git log", "/home/user/shy")
+
+	for _, tt := range []struct {
+		name   string
+		filter db.RangeFilter
+		want   []string
+	}{
+		{"all", db.RangeFilter{AllRuns: true}, []string{
+			"2026-03-10 09:00:00  /home/user/shy  make test",
+			"2026-03-10 09:00:00  /tmp  ls",
+			"2026-03-10 09:00:00  /home/user/shy  make test",
+		}},
+		{"match and dir", db.RangeFilter{Pattern: globToLike("make*"), WorkingDir: "/home/user/shy", AllRuns: true}, []string{
+			"2026-03-10 09:00:00  /home/user/shy  make test",
+			"2026-03-10 09:00:00  /home/user/shy  make test",
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			var out bytes.Buffer
+			done := make(chan error)
+			go func() { done <- tailCommands(ctx, &out, database, tt.filter, 10*time.Millisecond) }()
+
+			time.Sleep(50 * time.Millisecond)
+			insert("make test", "/home/user/shy")
+			insert("ls", "/tmp")
+			insert("make test", "/home/user/shy")
+			time.Sleep(100 * time.Millisecond)
+			cancel()
+			require.NoError(t, <-done)
+
+			assert.Equal(t, tt.want, strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n"),
+				"only commands recorded after the start, repeats included")
+		})
+	}
+}
+
+func TestTailInvalidInterval(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	rootCmd.SetArgs([]string{"tail", "--db", dbPath, "--interval", "0s"})
+	err := rootCmd.Execute()
+	rootCmd.SetArgs(nil)
+	tailInterval = time.Second
+	tailCmd.Flags().Lookup("interval").Changed = false
+	assert.ErrorContains(t, err, "invalid --interval 0s")
+}
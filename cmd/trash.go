@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var trashListLimit int
+
+var trashCmd = &cobra.Command{
+	Use:   "trash",
+	Short: "List, restore or empty deleted commands",
+	Long: `Commands deleted in shy summary (D) go to the trash instead of being removed.
+Trashed commands are hidden from every listing until they are restored, and
+are only removed for good when the trash is emptied.`,
+}
+
+var trashListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List trashed commands, most recently trashed first",
+	Args:  cobra.NoArgs,
+	RunE:  runTrashList,
+}
+
+var trashRestoreCmd = &cobra.Command{
+	Use:   "restore <event-ids...>",
+	Short: "Move commands back out of the trash",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runTrashRestore,
+}
+
+var trashEmptyCmd = &cobra.Command{
+	Use:   "empty",
+	Short: "Permanently delete every trashed command",
+	Args:  cobra.NoArgs,
+	RunE:  runTrashEmpty,
+}
+
+func init() {
+	rootCmd.AddCommand(trashCmd)
+	trashCmd.AddCommand(trashListCmd, trashRestoreCmd, trashEmptyCmd)
+	trashListCmd.Flags().IntVar(&trashListLimit, "limit", 20, "Maximum number of commands to list (0 for all)")
+}
+
+func runTrashList(cmd *cobra.Command, args []string) error {
+	if trashListLimit < 0 {
+		return fmt.Errorf("invalid --limit %d: must not be negative", trashListLimit)
+	}
+
+	database, err := openDatabase()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer database.Close()
+
+	trashed, err := database.GetTrashedCommands(trashListLimit)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if len(trashed) == 0 {
+		fmt.Fprintln(out, "Trash is empty")
+		return nil
+	}
+	for _, c := range trashed {
+		first, _, _ := strings.Cut(c.CommandText, "\n")
+		fmt.Fprintf(out, "%6d  %s  %s\n", c.ID, time.Unix(c.DeletedAt, 0).Format("2006-01-02 15:04"), first)
+	}
+	return nil
+}
+
+func runTrashRestore(cmd *cobra.Command, args []string) error {
+	ids := make([]int64, len(args))
+	for i, arg := range args {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil || id <= 0 {
+			return fmt.Errorf("invalid event ID %q: must be a positive integer", arg)
+		}
+		ids[i] = id
+	}
+
+	database, err := openDatabase()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer database.Close()
+
+	restored := 0
+	for _, id := range ids {
+		ok, err := database.RestoreCommand(id)
+		if err != nil {
+			return fmt.Errorf("failed to restore command %d: %w", id, err)
+		}
+		if !ok {
+			fmt.Fprintf(cmd.ErrOrStderr(), "shy trash restore: command %d is not in the trash\n", id)
+			continue
+		}
+		restored++
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "Restored %d command(s)\n", restored)
+	return nil
+}
+
+func runTrashEmpty(cmd *cobra.Command, args []string) error {
+	database, err := openDatabase()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer database.Close()
+
+	count, err := database.EmptyTrash()
+	if err != nil {
+		return fmt.Errorf("failed to empty trash: %w", err)
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "Deleted %d command(s)\n", count)
+	return nil
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chris/shy/internal/db"
+	"github.com/chris/shy/pkg/models"
+)
+
+func runTrashForTest(t *testing.T, dbPath string, args ...string) (string, string, error) {
+	t.Helper()
+	var out, errOut bytes.Buffer
+	rootCmd.SetOut(&out)
+	rootCmd.SetErr(&errOut)
+	rootCmd.SetArgs(append(append([]string{"trash"}, args...), "--db", dbPath))
+	err := rootCmd.Execute()
+	rootCmd.SetOut(nil)
+	rootCmd.SetErr(nil)
+	rootCmd.SetArgs(nil)
+	trashListLimit = 20
+	return out.String(), errOut.String(), err
+}
+
+func TestTrash(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.NewForTesting(dbPath)
+	require.NoError(t, err)
+	for i, text := range []string{"git status", "rm -rf build\nmake", "ls"} {
+		cmd := models.NewCommand(text, "/home/test", 0)
+		cmd.Timestamp = int64(1704470400 + i)
+		_, err := database.InsertCommand(cmd)
+		require.NoError(t, err)
+	}
+	for _, id := range []int64{1, 2} {
+		_, err := database.SoftDeleteCommand(id)
+		require.NoError(t, err)
+	}
+	database.Close()
+
+	out, _, err := runTrashForTest(t, dbPath, "list")
+	require.NoError(t, err)
+	assert.Regexp(t, `^     2  \d{4}-\d\d-\d\d \d\d:\d\d  rm -rf build\n     1  .*git status\n$`, out)
+
+	out, errOut, err := runTrashForTest(t, dbPath, "restore", "1", "3")
+	require.NoError(t, err)
+	assert.Equal(t, "Restored 1 command(s)\n", out)
+	assert.Contains(t, errOut, "command 3 is not in the trash")
+
+	out, _, err = runTrashForTest(t, dbPath, "empty")
+	require.NoError(t, err)
+	assert.Equal(t, "Deleted 1 command(s)\n", out)
+
+	out, _, err = runTrashForTest(t, dbPath, "list")
+	require.NoError(t, err)
+	assert.Equal(t, "Trash is empty\n", out)
+
+	database, err = db.New(dbPath)
+	require.NoError(t, err)
+	defer database.Close()
+	count, err := database.CountCommands()
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
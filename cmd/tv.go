@@ -9,7 +9,6 @@ import (
 	"charm.land/lipgloss/v2"
 	"github.com/spf13/cobra"
 
-	"github.com/chris/shy/internal/db"
 	"github.com/chris/shy/pkg/models"
 )
 
@@ -65,7 +64,7 @@ Commands are deduplicated (only most recent occurrence shown) and output in reve
 		cmd.SilenceUsage = true
 
 		// Open database
-		database, err := db.New(dbPath)
+		database, err := openDatabase()
 		if err != nil {
 			if os.IsNotExist(err) {
 				return nil
@@ -102,7 +101,7 @@ This command is designed to be used as a preview command in television channel c
 		}
 
 		// Open database
-		database, err := db.New(dbPath)
+		database, err := openDatabase()
 		if err != nil {
 			if os.IsNotExist(err) {
 				return fmt.Errorf("database not found")
@@ -0,0 +1,129 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"os"
+	"strings"
+
+	"modernc.org/sqlite"
+
+	"github.com/chris/shy/internal/db/migrations"
+)
+
+// archiveSchema is the name the archive database is attached under
+const archiveSchema = "archive"
+
+// archiveFromJoins is commandFromJoins reading the attached archive
+const archiveFromJoins = `
+	FROM archive.commands c
+	JOIN archive.working_dirs w ON c.working_dir_id = w.id AND c.deleted_at IS NULL
+	LEFT JOIN archive.git_contexts g ON c.git_context_id = g.id
+	LEFT JOIN archive.sources s ON c.source_id = s.id
+`
+
+// archiveConnector opens primary connections with the archive attached.
+// ATTACH only lasts for the connection it runs on, so it is repeated by a
+// connection hook for every connection database/sql opens.
+type archiveConnector struct {
+	driver *sqlite.Driver
+	dsn    string
+}
+
+func (c archiveConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c archiveConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// openWithArchive opens dsn like sql.Open, attaching the database at
+// archivePath read-only as the archive schema on every connection
+func openWithArchive(dsn, archivePath string) (*sql.DB, error) {
+	archivePath, err := ResolvePath(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(archivePath); err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+
+	drv := &sqlite.Driver{}
+	drv.RegisterConnectionHook(func(conn sqlite.ExecQuerierContext, _ string) error {
+		_, err := conn.ExecContext(context.Background(), "ATTACH DATABASE ? AS "+archiveSchema,
+			[]driver.NamedValue{{Ordinal: 1, Value: ReadOnlyURI(archivePath)}})
+		return err
+	})
+	conn := sql.OpenDB(archiveConnector{driver: drv, dsn: dsn})
+
+	var version int
+	if err := conn.QueryRow("PRAGMA " + archiveSchema + ".user_version").Scan(&version); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to attach archive: %w", err)
+	}
+	if version != migrations.Latest() {
+		conn.Close()
+		return nil, fmt.Errorf("archive %s is at schema version %d, expected %d: run shy init-db --db %s",
+			archivePath, version, migrations.Latest(), archivePath)
+	}
+	return conn, nil
+}
+
+// commandSource is one database holding commands
+type commandSource struct {
+	from   string // FROM/JOIN clause with the c, w, g and s aliases
+	prefix string // schema prefix, for queries that name tables directly
+}
+
+// commandSources returns the databases holding commands: the primary, then
+// the archive when one is attached
+func (db *DB) commandSources() []commandSource {
+	sources := []commandSource{{from: commandFromJoins}}
+	if db.archive {
+		sources = append(sources, commandSource{from: archiveFromJoins, prefix: archiveSchema + "."})
+	}
+	return sources
+}
+
+// unionQuery builds one query over every command source. part returns the
+// SELECT for one source and args are its arguments, repeated for each
+// source. orderBy orders the combined rows by result column names
+// (timestamp ASC, id ASC), which each part must select under those names
+// (see commandSelectColumns); it may be "".
+//
+// Without an archive this is part(primary) ORDER BY orderBy, so queries are
+// unchanged. With one, the parts are joined by UNION ALL and the same order
+// applies to the union's columns. Archived rows are assumed to keep the ids
+// they had in the primary, so ids stay unique across both.
+func (db *DB) unionQuery(part func(src commandSource) string, args []any, orderBy string) (string, []any) {
+	sources := db.commandSources()
+	if len(sources) == 1 {
+		query := part(sources[0])
+		if orderBy != "" {
+			query += " ORDER BY " + orderBy
+		}
+		return query, args
+	}
+
+	parts := make([]string, len(sources))
+	var allArgs []any
+	for i, src := range sources {
+		parts[i] = part(src)
+		allArgs = append(allArgs, args...)
+	}
+	query := "SELECT * FROM (" + strings.Join(parts, " UNION ALL ") + ")"
+	if orderBy != "" {
+		query += " ORDER BY " + orderBy
+	}
+	return query, allArgs
+}
+
+// selectCommands returns a unionQuery selecting commandSelectColumns with where
+func (db *DB) selectCommands(where string, args []any, orderBy string) (string, []any) {
+	return db.unionQuery(func(src commandSource) string {
+		return "SELECT " + commandSelectColumns + src.from + where
+	}, args, orderBy)
+}
@@ -2,10 +2,16 @@ package db
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	_ "modernc.org/sqlite"
 
@@ -15,10 +21,16 @@ import (
 
 const defaultDBPath = "~/.local/share/shy/history.db"
 
+// ErrReadOnly is returned by write operations on a database opened with
+// Options.ReadOnly
+var ErrReadOnly = errors.New("database opened read-only")
+
 // DB wraps the SQLite database connection
 type DB struct {
-	conn *sql.DB
-	path string
+	conn     *sql.DB
+	path     string
+	readOnly bool
+	archive  bool // an archive is attached (Options.ArchivePath)
 }
 
 // Options configures database connection behavior
@@ -27,6 +39,17 @@ type Options struct {
 	// Use this for read-only/benchmark access to existing databases,
 	// or for init-db which runs migrations via InitSchema instead.
 	SkipSchemaCheck bool
+
+	// ReadOnly opens the database without creating or modifying anything:
+	// no directory creation, no migrations, no WAL switch. The file must exist.
+	// Write operations return ErrReadOnly.
+	ReadOnly bool
+
+	// ArchivePath attaches a second database, read-only, holding commands
+	// rotated out of this one. The summary, fc list and search read paths
+	// include its commands; everything else, and every write, sees only the
+	// primary. The archive must be at the current schema version.
+	ArchivePath string
 }
 
 // New creates a new database connection and initializes the schema
@@ -34,26 +57,41 @@ func New(dbPath string) (*DB, error) {
 	return NewWithOptions(dbPath, Options{})
 }
 
-// NewWithOptions creates a new database connection with configurable options
-func NewWithOptions(dbPath string, opts Options) (*DB, error) {
-	// Expand tilde in path or use default
+// ResolvePath expands a database path the way New does: an empty path (or the
+// default) resolves under XDG_DATA_HOME or ~/.local/share, and ~ is expanded.
+func ResolvePath(dbPath string) (string, error) {
 	if dbPath == "" || dbPath == defaultDBPath {
 		// Use XDG_DATA_HOME if set, otherwise fallback to ~/.local/share
 		dataDir := os.Getenv("XDG_DATA_HOME")
 		if dataDir == "" {
 			home, err := os.UserHomeDir()
 			if err != nil {
-				return nil, fmt.Errorf("failed to get user home directory: %w", err)
+				return "", fmt.Errorf("failed to get user home directory: %w", err)
 			}
 			dataDir = filepath.Join(home, ".local/share")
 		}
-		dbPath = filepath.Join(dataDir, "shy/history.db")
-	} else if dbPath[0] == '~' {
+		return filepath.Join(dataDir, "shy/history.db"), nil
+	}
+	if dbPath[0] == '~' {
 		home, err := os.UserHomeDir()
 		if err != nil {
-			return nil, fmt.Errorf("failed to get user home directory: %w", err)
+			return "", fmt.Errorf("failed to get user home directory: %w", err)
 		}
-		dbPath = filepath.Join(home, dbPath[1:])
+		return filepath.Join(home, dbPath[1:]), nil
+	}
+	return dbPath, nil
+}
+
+// NewWithOptions creates a new database connection with configurable options
+func NewWithOptions(dbPath string, opts Options) (*DB, error) {
+	// Expand tilde in path or use default
+	dbPath, err := ResolvePath(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.ReadOnly {
+		return openReadOnly(dbPath, opts.ArchivePath)
 	}
 
 	// Create directory if it doesn't exist
@@ -63,9 +101,9 @@ func NewWithOptions(dbPath string, opts Options) (*DB, error) {
 	}
 
 	// Open database connection
-	conn, err := sql.Open("sqlite", dbPath)
+	conn, err := openConn(dbPath, opts.ArchivePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+		return nil, err
 	}
 
 	// Set busy timeout first, before any other operations that might need write locks
@@ -95,13 +133,53 @@ func NewWithOptions(dbPath string, opts Options) (*DB, error) {
 	}
 
 	db := &DB{
-		conn: conn,
-		path: dbPath,
+		conn:    conn,
+		path:    dbPath,
+		archive: opts.ArchivePath != "",
 	}
 
 	return db, nil
 }
 
+// openConn opens the SQLite connection pool for dsn, with the archive at
+// archivePath attached when it is not ""
+func openConn(dsn, archivePath string) (*sql.DB, error) {
+	if archivePath != "" {
+		return openWithArchive(dsn, archivePath)
+	}
+	conn, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	return conn, nil
+}
+
+// ReadOnlyURI returns the SQLite URI opening the database file at path
+// read-only. The path is escaped, so a ? or # in it stays part of the name.
+func ReadOnlyURI(path string) string {
+	u := url.URL{Scheme: "file", Path: path, RawQuery: "mode=ro"}
+	return u.String()
+}
+
+// openReadOnly opens an existing database file in SQLite read-only mode
+func openReadOnly(dbPath, archivePath string) (*DB, error) {
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, err
+	}
+
+	conn, err := openConn(ReadOnlyURI(dbPath), archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := conn.Exec("PRAGMA busy_timeout=5000"); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to set busy timeout: %w", err)
+	}
+
+	return &DB{conn: conn, path: dbPath, readOnly: true, archive: archivePath != ""}, nil
+}
+
 // Close closes the database connection
 func (db *DB) Close() error {
 	return db.conn.Close()
@@ -112,6 +190,11 @@ func (db *DB) Path() string {
 	return db.path
 }
 
+// ReadOnly reports whether the database was opened with Options.ReadOnly
+func (db *DB) ReadOnly() bool {
+	return db.readOnly
+}
+
 // NewForTesting creates a new database with schema initialized.
 // This is a convenience function for tests.
 func NewForTesting(dbPath string) (*DB, error) {
@@ -122,6 +205,9 @@ func NewForTesting(dbPath string) (*DB, error) {
 // This should only be called by the init-db command.
 // Returns true if schema was created, false if it already existed.
 func (db *DB) InitSchema() (bool, error) {
+	if db.readOnly {
+		return false, ErrReadOnly
+	}
 	var version int
 	if err := db.conn.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
 		return false, fmt.Errorf("failed to check schema version: %w", err)
@@ -134,11 +220,17 @@ func (db *DB) InitSchema() (bool, error) {
 	return version == 0, nil
 }
 
+// execQuerier is the subset of *sql.DB and *sql.Tx used by the insert path
+type execQuerier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
 // getOrCreateWorkingDir returns the ID for a working directory, creating it if needed
-func (db *DB) getOrCreateWorkingDir(path string) (int64, error) {
+func getOrCreateWorkingDir(q execQuerier, path string) (int64, error) {
 	// Try to get existing
 	var id int64
-	err := db.conn.QueryRow("SELECT id FROM working_dirs WHERE path = ?", path).Scan(&id)
+	err := q.QueryRow("SELECT id FROM working_dirs WHERE path = ?", path).Scan(&id)
 	if err == nil {
 		return id, nil
 	}
@@ -147,10 +239,10 @@ func (db *DB) getOrCreateWorkingDir(path string) (int64, error) {
 	}
 
 	// Insert new
-	result, err := db.conn.Exec("INSERT INTO working_dirs (path) VALUES (?)", path)
+	result, err := q.Exec("INSERT INTO working_dirs (path) VALUES (?)", path)
 	if err != nil {
 		// Handle race condition - another connection may have inserted
-		err2 := db.conn.QueryRow("SELECT id FROM working_dirs WHERE path = ?", path).Scan(&id)
+		err2 := q.QueryRow("SELECT id FROM working_dirs WHERE path = ?", path).Scan(&id)
 		if err2 == nil {
 			return id, nil
 		}
@@ -162,14 +254,14 @@ func (db *DB) getOrCreateWorkingDir(path string) (int64, error) {
 
 // getOrCreateGitContext returns the ID for a git context, creating it if needed
 // Returns nil if both repo and branch are nil
-func (db *DB) getOrCreateGitContext(repo, branch *string) (*int64, error) {
+func getOrCreateGitContext(q execQuerier, repo, branch *string) (*int64, error) {
 	if repo == nil && branch == nil {
 		return nil, nil
 	}
 
 	// Try to get existing
 	var id int64
-	err := db.conn.QueryRow(
+	err := q.QueryRow(
 		"SELECT id FROM git_contexts WHERE repo IS ? AND branch IS ?",
 		repo, branch,
 	).Scan(&id)
@@ -181,13 +273,13 @@ func (db *DB) getOrCreateGitContext(repo, branch *string) (*int64, error) {
 	}
 
 	// Insert new
-	result, err := db.conn.Exec(
+	result, err := q.Exec(
 		"INSERT INTO git_contexts (repo, branch) VALUES (?, ?)",
 		repo, branch,
 	)
 	if err != nil {
 		// Handle race condition
-		err2 := db.conn.QueryRow(
+		err2 := q.QueryRow(
 			"SELECT id FROM git_contexts WHERE repo IS ? AND branch IS ?",
 			repo, branch,
 		).Scan(&id)
@@ -206,7 +298,7 @@ func (db *DB) getOrCreateGitContext(repo, branch *string) (*int64, error) {
 
 // getOrCreateSource returns the ID for a source, creating it if needed
 // Returns nil if both app and pid are nil
-func (db *DB) getOrCreateSource(app *string, pid *int64, active *bool) (*int64, error) {
+func getOrCreateSource(q execQuerier, app *string, pid *int64, active *bool) (*int64, error) {
 	if app == nil || pid == nil {
 		return nil, nil
 	}
@@ -219,7 +311,7 @@ func (db *DB) getOrCreateSource(app *string, pid *int64, active *bool) (*int64,
 
 	// Try to get existing
 	var id int64
-	err := db.conn.QueryRow(
+	err := q.QueryRow(
 		"SELECT id FROM sources WHERE app = ? AND pid = ? AND active = ?",
 		*app, *pid, activeInt,
 	).Scan(&id)
@@ -231,13 +323,13 @@ func (db *DB) getOrCreateSource(app *string, pid *int64, active *bool) (*int64,
 	}
 
 	// Insert new
-	result, err := db.conn.Exec(
+	result, err := q.Exec(
 		"INSERT INTO sources (app, pid, active) VALUES (?, ?, ?)",
 		*app, *pid, activeInt,
 	)
 	if err != nil {
 		// Handle race condition - another connection may have inserted
-		err2 := db.conn.QueryRow(
+		err2 := q.QueryRow(
 			"SELECT id FROM sources WHERE app = ? AND pid = ? AND active = ?",
 			*app, *pid, activeInt,
 		).Scan(&id)
@@ -256,6 +348,61 @@ func (db *DB) getOrCreateSource(app *string, pid *int64, active *bool) (*int64,
 
 // InsertCommand inserts a new command into the database
 func (db *DB) InsertCommand(cmd *models.Command) (int64, error) {
+	if db.readOnly {
+		return 0, ErrReadOnly
+	}
+	return insertCommand(db.conn, cmd)
+}
+
+// InsertCommands inserts a batch of commands in a single transaction.
+// Either all commands are inserted or none are. Returns the new IDs in order.
+func (db *DB) InsertCommands(cmds []*models.Command) ([]int64, error) {
+	if db.readOnly {
+		return nil, ErrReadOnly
+	}
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	ids := make([]int64, 0, len(cmds))
+	for _, cmd := range cmds {
+		id, err := insertCommand(tx, cmd)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return ids, nil
+}
+
+// HasCommand reports whether a command with this text, working directory
+// and timestamp is already stored. Imports use it to skip history that was
+// imported before.
+func (db *DB) HasCommand(timestamp int64, commandText, workingDir string) (bool, error) {
+	var exists bool
+	err := db.conn.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM commands c
+			JOIN working_dirs w ON c.working_dir_id = w.id
+			WHERE c.timestamp = ? AND c.command_text = ? AND w.path = ?
+		)`,
+		timestamp, commandText, workingDir,
+	).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up command: %w", err)
+	}
+	return exists, nil
+}
+
+// insertCommand inserts a command and its lookup rows using q, which may be
+// the connection or a transaction
+func insertCommand(q execQuerier, cmd *models.Command) (int64, error) {
 	// Convert nil duration to 0
 	duration := int64(0)
 	if cmd.Duration != nil {
@@ -263,24 +410,35 @@ func (db *DB) InsertCommand(cmd *models.Command) (int64, error) {
 	}
 
 	// Get or create lookup table records
-	workingDirID, err := db.getOrCreateWorkingDir(cmd.WorkingDir)
+	workingDirID, err := getOrCreateWorkingDir(q, cmd.WorkingDir)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get working_dir_id: %w", err)
 	}
 
-	gitContextID, err := db.getOrCreateGitContext(cmd.GitRepo, cmd.GitBranch)
+	gitContextID, err := getOrCreateGitContext(q, cmd.GitRepo, cmd.GitBranch)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get git_context_id: %w", err)
 	}
 
-	sourceID, err := db.getOrCreateSource(cmd.SourceApp, cmd.SourcePid, cmd.SourceActive)
+	sourceID, err := getOrCreateSource(q, cmd.SourceApp, cmd.SourcePid, cmd.SourceActive)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get source_id: %w", err)
 	}
 
-	result, err := db.conn.Exec(`
-		INSERT INTO commands (timestamp, exit_status, duration, command_text, working_dir_id, git_context_id, source_id)
-		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+	env, err := encodeEnv(cmd.Env)
+	if err != nil {
+		return 0, err
+	}
+
+	// Without a sub-second start time, the command started on its second
+	timestampNs := cmd.TimestampNs
+	if timestampNs == 0 {
+		timestampNs = cmd.Timestamp * int64(time.Second)
+	}
+
+	result, err := q.Exec(`
+		INSERT INTO commands (timestamp, exit_status, duration, command_text, working_dir_id, git_context_id, source_id, env, timestamp_ns)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		cmd.Timestamp,
 		cmd.ExitStatus,
 		duration,
@@ -288,6 +446,8 @@ func (db *DB) InsertCommand(cmd *models.Command) (int64, error) {
 		workingDirID,
 		gitContextID,
 		sourceID,
+		env,
+		timestampNs,
 	)
 	if err != nil {
 		return 0, fmt.Errorf("failed to insert command: %w", err)
@@ -299,7 +459,7 @@ func (db *DB) InsertCommand(cmd *models.Command) (int64, error) {
 	}
 
 	// Mark older commands with the same command_text as duplicates
-	_, err = db.conn.Exec(`
+	_, err = q.Exec(`
 		UPDATE commands SET is_duplicate = 1
 		WHERE command_text = ? AND id < ? AND is_duplicate = 0`,
 		cmd.CommandText, id,
@@ -314,16 +474,29 @@ func (db *DB) InsertCommand(cmd *models.Command) (int64, error) {
 
 // commandSelectColumns is the common SELECT clause for denormalized command queries
 const commandSelectColumns = `
-	c.id, c.timestamp, c.exit_status, c.duration, c.command_text,
+	c.id AS id, c.timestamp AS timestamp, c.exit_status AS exit_status,
+	c.duration AS duration, c.command_text AS command_text,
 	w.path,
 	g.repo, g.branch,
-	s.app, s.pid, s.active
+	s.app, s.pid, s.active,
+	c.env, c.timestamp_ns AS timestamp_ns
 `
 
-// commandFromJoins is the common FROM/JOIN clause for denormalized command queries
+// commandFromJoins is the common FROM/JOIN clause for denormalized command
+// queries. It leaves out trashed commands (see SoftDeleteCommand): the inner
+// join's ON filters rows exactly as a WHERE term would, so no query built on
+// it needs its own deleted_at condition.
 const commandFromJoins = `
 	FROM commands c
-	JOIN working_dirs w ON c.working_dir_id = w.id
+	JOIN working_dirs w ON c.working_dir_id = w.id AND c.deleted_at IS NULL
+	LEFT JOIN git_contexts g ON c.git_context_id = g.id
+	LEFT JOIN sources s ON c.source_id = s.id
+`
+
+// trashFromJoins is commandFromJoins for the trashed commands only
+const trashFromJoins = `
+	FROM commands c
+	JOIN working_dirs w ON c.working_dir_id = w.id AND c.deleted_at IS NOT NULL
 	LEFT JOIN git_contexts g ON c.git_context_id = g.id
 	LEFT JOIN sources s ON c.source_id = s.id
 `
@@ -332,6 +505,7 @@ const commandFromJoins = `
 func scanCommand(scanner interface{ Scan(...any) error }) (*models.Command, error) {
 	cmd := &models.Command{}
 	var sourceActive *int64
+	var env *string
 	err := scanner.Scan(
 		&cmd.ID,
 		&cmd.Timestamp,
@@ -344,6 +518,8 @@ func scanCommand(scanner interface{ Scan(...any) error }) (*models.Command, erro
 		&cmd.SourceApp,
 		&cmd.SourcePid,
 		&sourceActive,
+		&env,
+		&cmd.TimestampNs,
 	)
 	if err != nil {
 		return nil, err
@@ -355,185 +531,299 @@ func scanCommand(scanner interface{ Scan(...any) error }) (*models.Command, erro
 		cmd.SourceActive = &active
 	}
 
+	if env != nil {
+		if err := json.Unmarshal([]byte(*env), &cmd.Env); err != nil {
+			return nil, fmt.Errorf("failed to decode env of command %d: %w", cmd.ID, err)
+		}
+	}
+
 	return cmd, nil
 }
 
+// encodeEnv returns the JSON stored in the env column, or nil (NULL) when
+// no variables were captured
+func encodeEnv(env map[string]string) (*string, error) {
+	if len(env) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode env: %w", err)
+	}
+	text := string(data)
+	return &text, nil
+}
+
 // GetCommand retrieves a command by ID
 func (db *DB) GetCommand(id int64) (*models.Command, error) {
-	query := "SELECT " + commandSelectColumns + commandFromJoins + " WHERE c.id = ?"
-	cmd, err := scanCommand(db.conn.QueryRow(query, id))
+	query, args := db.selectCommands(" WHERE c.id = ?", []any{id}, "")
+	cmd, err := scanCommand(db.conn.QueryRow(query+" LIMIT 1", args...))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get command: %w", err)
 	}
 	return cmd, nil
 }
 
-// CountCommands returns the total number of commands in the database
+// CountCommands returns the total number of commands in the database,
+// not counting the trash
 func (db *DB) CountCommands() (int, error) {
+	ids, args := db.unionQuery(func(src commandSource) string {
+		return "SELECT id FROM " + src.prefix + "commands WHERE deleted_at IS NULL"
+	}, nil, "")
 	var count int
-	err := db.conn.QueryRow("SELECT COUNT(*) FROM commands").Scan(&count)
+	err := db.conn.QueryRow("SELECT COUNT(*) FROM ("+ids+")", args...).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("failed to count commands: %w", err)
 	}
 	return count, nil
 }
 
-// GetCommandsByDateRange retrieves commands within a Unix timestamp range (inclusive start, exclusive end)
-// Returns commands ordered by timestamp ascending
-func (db *DB) GetCommandsByDateRange(startTime, endTime int64, sourceApp *string) ([]models.Command, error) {
-	var query string
-	var args []any
+// Stats summarizes the whole history
+type Stats struct {
+	Commands       int
+	UniqueCommands int
+	Failed         int // commands with a non-zero exit status
+	Sessions       int // distinct shell sources
+	Directories    int
+	FirstTimestamp int64 // 0 when the history is empty
+	LastTimestamp  int64
+	TopCommands    []TextCount
+	TopDirectories []TextCount
+	HourCounts     [24]int          // commands per local hour of day
+	Slowest        []models.Command // the longest-running commands, longest first
+	Days           []DayCount       // commands per local day, oldest first
+}
 
-	if sourceApp != nil {
-		query = "SELECT " + commandSelectColumns + commandFromJoins + `
-			WHERE c.timestamp >= ? AND c.timestamp < ?
-			AND s.app = ?
-			ORDER BY c.timestamp ASC`
-		args = []any{startTime, endTime, *sourceApp}
-	} else {
-		query = "SELECT " + commandSelectColumns + commandFromJoins + `
-			WHERE c.timestamp >= ? AND c.timestamp < ?
-			ORDER BY c.timestamp ASC`
-		args = []any{startTime, endTime}
+// TextCount is a command text or directory with how often it occurs
+type TextCount struct {
+	Text  string
+	Count int
+}
+
+// statsBucketSeconds is the granularity of the hour-of-day query. Counting by
+// quarter hour in SQL and mapping to local hours in Go stays correct for
+// zones offset by 30 or 45 minutes, without relying on SQLite's localtime.
+const statsBucketSeconds = 15 * 60
+
+// GetStats returns totals, the top most-run commands and directories, the
+// slowest commands, and commands per hour of day and per day, over the
+// archive too when one is attached. Ties in the top lists are broken
+// alphabetically, so the result is deterministic.
+func (db *DB) GetStats(top int) (*Stats, error) {
+	var stats Stats
+	totals := db.statsRows(`c.command_text, c.exit_status, c.timestamp, w.path AS dir,
+		s.app || ':' || s.pid || ':' || s.active AS source`)
+	err := db.conn.QueryRow(`
+		SELECT COUNT(*), COUNT(DISTINCT command_text), COUNT(*) FILTER (WHERE exit_status != 0),
+			COUNT(DISTINCT source), COUNT(DISTINCT dir),
+			COALESCE(MIN(timestamp), 0), COALESCE(MAX(timestamp), 0)
+		FROM (`+totals+`)`).Scan(&stats.Commands, &stats.UniqueCommands, &stats.Failed,
+		&stats.Sessions, &stats.Directories, &stats.FirstTimestamp, &stats.LastTimestamp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stats totals: %w", err)
 	}
 
-	rows, err := db.conn.Query(query, args...)
+	stats.TopCommands, err = db.topCounts(`
+		SELECT command_text, COUNT(*) AS n FROM (`+db.statsRows("c.command_text")+`)
+		GROUP BY command_text ORDER BY n DESC, command_text ASC LIMIT ?`, top)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get commands by date range: %w", err)
+		return nil, fmt.Errorf("failed to get top commands: %w", err)
+	}
+	stats.TopDirectories, err = db.topCounts(`
+		SELECT dir, COUNT(*) AS n FROM (`+db.statsRows("w.path AS dir")+`)
+		GROUP BY dir ORDER BY n DESC, dir ASC LIMIT ?`, top)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top directories: %w", err)
 	}
-	defer rows.Close()
 
-	var commands []models.Command
+	stats.Slowest, err = db.GetSlowestCommands(0, math.MaxInt64, top)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.Days, err = db.GetCommandCountsByDay(time.Local)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.conn.Query("SELECT timestamp / ?, COUNT(*) FROM ("+db.statsRows("c.timestamp")+") GROUP BY timestamp / ?",
+		statsBucketSeconds, statsBucketSeconds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hourly counts: %w", err)
+	}
+	defer rows.Close()
 	for rows.Next() {
-		cmd, err := scanCommand(rows)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan command: %w", err)
+		var bucket int64
+		var n int
+		if err := rows.Scan(&bucket, &n); err != nil {
+			return nil, fmt.Errorf("failed to scan hourly count: %w", err)
 		}
-		commands = append(commands, *cmd)
+		stats.HourCounts[time.Unix(bucket*statsBucketSeconds, 0).Hour()] += n
 	}
-
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating commands: %w", err)
+		return nil, fmt.Errorf("error iterating hourly counts: %w", err)
 	}
-
-	return commands, nil
+	return &stats, nil
 }
 
-// TableExists checks if the commands table exists
-func (db *DB) TableExists() (bool, error) {
-	var name string
-	err := db.conn.QueryRow(`
-		SELECT name FROM sqlite_master
-		WHERE type='table' AND name='commands'`).Scan(&name)
+// statsRows selects columns of every command not in the trash, across the
+// command sources. Sessions and directories are compared by value, since
+// their ids differ between the primary and the archive.
+func (db *DB) statsRows(columns string) string {
+	query, _ := db.unionQuery(func(src commandSource) string {
+		return "SELECT " + columns + src.from
+	}, nil, "")
+	return query
+}
 
-	if err == sql.ErrNoRows {
-		return false, nil
-	}
+// topCounts runs a (text, count) query taking a LIMIT argument
+func (db *DB) topCounts(query string, limit int) ([]TextCount, error) {
+	rows, err := db.conn.Query(query, limit)
 	if err != nil {
-		return false, fmt.Errorf("failed to check table existence: %w", err)
+		return nil, err
 	}
-	return true, nil
+	defer rows.Close()
+
+	counts := []TextCount{}
+	for rows.Next() {
+		var tc TextCount
+		if err := rows.Scan(&tc.Text, &tc.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, tc)
+	}
+	return counts, rows.Err()
 }
 
-// GetTableSchema returns the schema of the commands table
-func (db *DB) GetTableSchema() ([]map[string]any, error) {
-	rows, err := db.conn.Query("PRAGMA table_info(commands)")
+// GetCommandCountsByHour counts the commands in each hour of a Unix timestamp
+// range (inclusive start, exclusive end). Element i counts the hour starting
+// at startTime + i*3600; hours with no commands are 0.
+func (db *DB) GetCommandCountsByHour(startTime, endTime int64) ([]int, error) {
+	counts := make([]int, max((endTime-startTime+3599)/3600, 0))
+	timestamps, args := db.unionQuery(func(src commandSource) string {
+		return "SELECT timestamp FROM " + src.prefix + "commands WHERE timestamp >= ? AND timestamp < ? AND deleted_at IS NULL"
+	}, []any{startTime, endTime}, "")
+	rows, err := db.conn.Query(`
+		SELECT (timestamp - ?) / 3600 AS hour, COUNT(*)
+		FROM (`+timestamps+`)
+		GROUP BY hour`,
+		append([]any{startTime}, args...)...,
+	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get table schema: %w", err)
+		return nil, fmt.Errorf("failed to count commands by hour: %w", err)
 	}
 	defer rows.Close()
 
-	var schema []map[string]any
 	for rows.Next() {
-		var name, colType string
-		var placeholder any
-		if err := rows.Scan(&placeholder, &name, &colType, &placeholder, &placeholder, &placeholder); err != nil {
-			return nil, fmt.Errorf("failed to scan schema row: %w", err)
+		var hour int64
+		var count int
+		if err := rows.Scan(&hour, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan hour count: %w", err)
 		}
-
-		schema = append(schema, map[string]any{
-			"name": name,
-			"type": colType,
-		})
+		counts[hour] = count
 	}
-
-	return schema, nil
+	return counts, rows.Err()
 }
 
-// ListCommands retrieves commands ordered by timestamp ascending (oldest first)
-// When a limit is applied, it returns the N most recent commands, but still ordered oldest-to-newest
-// If limit is 0, all commands are returned
-// If sourceApp and sourcePid are provided, only active commands from that session are returned
-func (db *DB) ListCommands(limit int, sourceApp string, sourcePid int64, cwd string) ([]models.Command, error) {
-	return db.ListCommandsInRange(0, 0, limit, sourceApp, sourcePid, cwd)
+// DayCount is the number of commands run on one day
+type DayCount struct {
+	Day   time.Time // midnight starting the day
+	Count int
 }
 
-// ListCommandsInRange retrieves commands within a timestamp range, ordered by timestamp ascending
-// If startTime is 0, no lower bound is applied
-// If endTime is 0, no upper bound is applied
-// If limit is 0, all matching commands are returned
-// If sourceApp and sourcePid are provided, only active commands from that session are returned
-func (db *DB) ListCommandsInRange(startTime, endTime int64, limit int, sourceApp string, sourcePid int64, cwd string) ([]models.Command, error) {
-	var query string
-	var whereClauses []string
-
-	// Build WHERE clause for time range
-	if startTime > 0 && endTime > 0 {
-		whereClauses = append(whereClauses, fmt.Sprintf("c.timestamp >= %d AND c.timestamp <= %d", startTime, endTime))
-	} else if startTime > 0 {
-		whereClauses = append(whereClauses, fmt.Sprintf("c.timestamp >= %d", startTime))
-	} else if endTime > 0 {
-		whereClauses = append(whereClauses, fmt.Sprintf("c.timestamp <= %d", endTime))
+// GetCommandCountsByDay counts the commands run on each day of the history,
+// with days starting at midnight in loc. Days without commands are left out;
+// the rest are oldest first. Like GetStats, it counts by quarter hour in SQL
+// and maps the buckets to days in Go.
+func (db *DB) GetCommandCountsByDay(loc *time.Location) ([]DayCount, error) {
+	timestamps, args := db.unionQuery(func(src commandSource) string {
+		return "SELECT timestamp FROM " + src.prefix + "commands WHERE deleted_at IS NULL"
+	}, nil, "")
+	rows, err := db.conn.Query(`
+		SELECT timestamp / ? AS bucket, COUNT(*)
+		FROM (`+timestamps+`)
+		GROUP BY bucket ORDER BY bucket`,
+		append([]any{statsBucketSeconds}, args...)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count commands by day: %w", err)
 	}
+	defer rows.Close()
 
-	if cwd != "" {
-		whereClauses = append(whereClauses, fmt.Sprintf("w.path = '%s'", cwd))
+	days := []DayCount{}
+	for rows.Next() {
+		var bucket int64
+		var n int
+		if err := rows.Scan(&bucket, &n); err != nil {
+			return nil, fmt.Errorf("failed to scan day count: %w", err)
+		}
+		t := time.Unix(bucket*statsBucketSeconds, 0).In(loc)
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		if len(days) > 0 && days[len(days)-1].Day.Equal(day) {
+			days[len(days)-1].Count += n
+			continue
+		}
+		days = append(days, DayCount{Day: day, Count: n})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating day counts: %w", err)
 	}
+	return days, nil
+}
 
-	// Add session filter if provided (app and/or pid)
-	if sourceApp != "" || sourcePid > 0 {
-		var sessionClauses []string
-		if sourceApp != "" {
-			sessionClauses = append(sessionClauses, fmt.Sprintf("s.app = '%s'",
-				strings.ReplaceAll(sourceApp, "'", "''")))
+// ActivityStreaks returns the current and longest runs of consecutive days
+// with at least one command, from days as GetCommandCountsByDay returns them.
+// The current run ends today, or yesterday while nothing has run yet today,
+// so a streak isn't broken before the day is over. today is any time on the
+// current day, in the location the days were counted in.
+func ActivityStreaks(days []DayCount, today time.Time) (current, longest int) {
+	run := 0
+	var prev time.Time
+	for _, d := range days {
+		if d.Count == 0 {
+			continue
 		}
-		if sourcePid > 0 {
-			sessionClauses = append(sessionClauses, fmt.Sprintf("s.pid = %d", sourcePid))
+		if run > 0 && nextDay(prev).Equal(d.Day) {
+			run++
+		} else {
+			run = 1
 		}
-		sessionClauses = append(sessionClauses, "s.active = 1")
-		whereClauses = append(whereClauses, strings.Join(sessionClauses, " AND "))
+		prev = d.Day
+		longest = max(longest, run)
 	}
 
-	// Combine WHERE clauses
-	var whereClause string
-	if len(whereClauses) > 0 {
-		whereClause = "WHERE " + strings.Join(whereClauses, " AND ")
+	if run == 0 {
+		return 0, 0
 	}
+	todayStart := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, prev.Location())
+	if prev.Equal(todayStart) || nextDay(prev).Equal(todayStart) {
+		current = run
+	}
+	return current, longest
+}
 
-	selectCols := commandSelectColumns
-	fromJoins := commandFromJoins
+// nextDay returns the midnight after day, which is not always 24 hours later
+// across a daylight saving change
+func nextDay(day time.Time) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, day.Location())
+}
 
-	if limit > 0 {
-		// Get the N most recent commands in the range, then order them oldest-to-newest
-		query = fmt.Sprintf(`
-			SELECT * FROM (
-				SELECT %s %s
-				%s
-				ORDER BY c.timestamp DESC
-				LIMIT %d
-			)
-			ORDER BY timestamp ASC`, selectCols, fromJoins, whereClause, limit)
-	} else {
-		// Get all commands in the range
-		query = fmt.Sprintf(`
-			SELECT %s %s
-			%s
-			ORDER BY c.timestamp ASC`, selectCols, fromJoins, whereClause)
+// GetCommandsByDateRange retrieves commands within a Unix timestamp range (inclusive start, exclusive end)
+// Returns commands ordered by start time ascending (see models.Command.RanBefore)
+func (db *DB) GetCommandsByDateRange(startTime, endTime int64, sourceApp *string) ([]models.Command, error) {
+	where := `
+		WHERE c.timestamp >= ? AND c.timestamp < ?`
+	args := []any{startTime, endTime}
+	if sourceApp != nil {
+		where += `
+		AND s.app = ?`
+		args = append(args, *sourceApp)
 	}
 
-	rows, err := db.conn.Query(query)
+	query, args := db.selectCommands(where, args, "timestamp ASC, timestamp_ns ASC, id ASC")
+	rows, err := db.conn.Query(query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list commands: %w", err)
+		return nil, fmt.Errorf("failed to get commands by date range: %w", err)
 	}
 	defer rows.Close()
 
@@ -553,24 +843,577 @@ func (db *DB) ListCommandsInRange(startTime, endTime int64, limit int, sourceApp
 	return commands, nil
 }
 
-// GetRecentCommandsWithoutConsecutiveDuplicates retrieves a single command at the given offset
-// Commands are gathered from session, working_dir, and full history contexts, sorted by context priority
-// (session=1, working_dir=2, history=3) then by timestamp DESC, and consecutive duplicates are removed using LAG
-// offset is 0-indexed: 0=most recent, 1=second most recent, etc.
-// Returns nil if no command exists at the given offset
-// Only populates CommandText field in the returned Command struct
-// sourceApp is required; sourcePid can be 0 to match all pids for that app
-func (db *DB) GetRecentCommandsWithoutConsecutiveDuplicates(offset int, sourceApp string, sourcePid int64, workingDir string) (*models.Command, error) {
-	if sourceApp == "" {
-		return nil, fmt.Errorf("sourceApp must be provided for session filtering")
+// GetSlowestCommands returns up to limit commands in [startTime, endTime)
+// with the longest durations, longest first, ties broken by id. Commands
+// without a captured duration (NULL or 0) are skipped.
+//
+// Durations are stored in milliseconds. Zsh extended history only records
+// whole seconds, so commands read from it (fc -R, import) were multiplied by
+// 1000 on the way in and are always a whole number of seconds.
+func (db *DB) GetSlowestCommands(startTime, endTime int64, limit int) ([]models.Command, error) {
+	query, args := db.selectCommands(`
+		WHERE c.timestamp >= ? AND c.timestamp < ? AND c.duration > 0`,
+		[]any{startTime, endTime}, "duration DESC, id ASC")
+	rows, err := db.conn.Query(query+" LIMIT ?", append(args, limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get slowest commands: %w", err)
 	}
+	defer rows.Close()
 
-	// Calculate fetch limit per priority bucket
-	// Use 3x multiplier with a minimum of 50 to handle duplicates
-	bucketLimit := (offset + 1) * 3
-	if bucketLimit < 50 {
-		bucketLimit = 50
-	}
+	return db.scanCommandRows(rows)
+}
+
+// contextMatchPredicate is the canonical WHERE fragment for selecting the commands
+// of one context. NULL and empty-string repo/branch values are treated as the same
+// context (matching summary.GroupByContext), so both sides are COALESCEd to the empty string.
+// Args: working dir path, git repo ("" for none), git branch ("" for none).
+const contextMatchPredicate = `w.path = ? AND COALESCE(g.repo, '') = ? AND COALESCE(g.branch, '') = ?`
+
+// ExportOptions selects a page of commands for export. StartTime and EndTime
+// bound the timestamps (EndTime exclusive, 0 means unbounded) and Limit caps
+// the page size (0 means no limit).
+type ExportOptions struct {
+	AfterID   int64 // cursor: only commands with a greater ID
+	StartTime int64
+	EndTime   int64
+	Limit     int
+}
+
+// ExportCommands returns commands in ID order after the AfterID cursor.
+// Paging by ID stays cheap and stable on large tables, unlike OFFSET: pass
+// the last ID of one page as AfterID of the next.
+func (db *DB) ExportCommands(opts ExportOptions) ([]models.Command, error) {
+	query := "SELECT " + commandSelectColumns + commandFromJoins + `
+		WHERE c.id > ? AND c.timestamp >= ?`
+	args := []any{opts.AfterID, opts.StartTime}
+	if opts.EndTime > 0 {
+		query += " AND c.timestamp < ?"
+		args = append(args, opts.EndTime)
+	}
+	query += " ORDER BY c.id ASC"
+	if opts.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, opts.Limit)
+	}
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export commands: %w", err)
+	}
+	defer rows.Close()
+
+	return db.scanCommandRows(rows)
+}
+
+// RandomOptions narrows GetRandomCommand. Zero fields do not filter: an
+// empty Pattern (a LIKE pattern) or a StartTime or EndTime of 0 (EndTime
+// exclusive).
+type RandomOptions struct {
+	Pattern   string
+	StartTime int64
+	EndTime   int64
+}
+
+// GetRandomCommand returns a command picked at random among those passing
+// opts, or nil when none does. Every run is a candidate, so a command run
+// often is picked more often than one run once.
+func (db *DB) GetRandomCommand(opts RandomOptions) (*models.Command, error) {
+	where := `
+		WHERE c.timestamp >= ?`
+	args := []any{opts.StartTime}
+	if opts.EndTime > 0 {
+		where += " AND c.timestamp < ?"
+		args = append(args, opts.EndTime)
+	}
+	if opts.Pattern != "" {
+		where += ` AND c.command_text LIKE ? ESCAPE '\'`
+		args = append(args, opts.Pattern)
+	}
+
+	query, args := db.selectCommands(where, args, "RANDOM()")
+	cmd, err := scanCommand(db.conn.QueryRow(query+" LIMIT 1", args...))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get random command: %w", err)
+	}
+	return cmd, nil
+}
+
+// GetCommandsForContext retrieves the commands of a single context within a Unix
+// timestamp range (inclusive start, exclusive end).
+// gitRepo and gitBranch use "" for non-git directories and branchless commands.
+// Returns commands ordered by timestamp ascending
+func (db *DB) GetCommandsForContext(startTime, endTime int64, workingDir, gitRepo, gitBranch string) ([]models.Command, error) {
+	query, args := db.selectCommands(`
+		WHERE c.timestamp >= ? AND c.timestamp < ?
+		AND `+contextMatchPredicate,
+		[]any{startTime, endTime, workingDir, gitRepo, gitBranch}, "timestamp ASC, timestamp_ns ASC, id ASC")
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commands for context: %w", err)
+	}
+	defer rows.Close()
+
+	return db.scanCommandRows(rows)
+}
+
+// ContextPageOptions selects one page of a context's commands for
+// GetCommandsForContextPaged. The range, context, mode, filter and exclude
+// mean what they do for PeekContextCount; AcrossWorktrees matches the repo
+// and branch in any directory, as PeekBranchCount does. AfterTimestamp,
+// AfterTimestampNs and AfterID are the last command of the previous page
+// (all 0 for the first page) and Limit caps the page size.
+type ContextPageOptions struct {
+	StartTime        int64
+	EndTime          int64
+	WorkingDir       string
+	GitRepo          string
+	GitBranch        string
+	AcrossWorktrees  bool
+	Mode             DisplayMode
+	Filter           string
+	Exclude          string
+	MinDuration      int64 // in milliseconds; 0 matches every duration
+	AfterTimestamp   int64
+	AfterTimestampNs int64
+	AfterID          int64
+	Limit            int
+}
+
+// GetCommandsForContextPaged retrieves one page of a context's commands,
+// ordered by timestamp, timestamp_ns then id. Pages are keyed on the last command returned,
+// so fetching the next page stays cheap however far into the context it is.
+// UniqueMode is decided over the whole range, not the page.
+func (db *DB) GetCommandsForContextPaged(opts ContextPageOptions) ([]models.Command, error) {
+	predicate := contextMatchPredicate
+	predicateArgs := []any{opts.WorkingDir, opts.GitRepo, opts.GitBranch}
+	if opts.AcrossWorktrees {
+		predicate = branchMatchPredicate
+		predicateArgs = []any{opts.GitRepo, opts.GitBranch}
+	}
+	textMatch, textArgs := textMatchClause(opts.Filter, opts.Exclude)
+
+	matchArgs := append([]any{opts.StartTime, opts.EndTime}, predicateArgs...)
+	matchArgs = append(matchArgs, textArgs...)
+	where := `
+		WHERE c.timestamp >= ? AND c.timestamp < ?
+		AND ` + predicate + `
+		AND ` + textMatch
+
+	pageWhere := where
+	args := append([]any{}, matchArgs...)
+	if opts.Mode == UniqueMode {
+		// Uniqueness counts the matching commands of every source together
+		matched, matchedArgs := db.unionQuery(func(src commandSource) string {
+			return "SELECT c.command_text" + src.from + where
+		}, matchArgs, "")
+		pageWhere += " AND c.command_text IN (SELECT command_text FROM (" + matched +
+			") GROUP BY command_text HAVING COUNT(*) = 1)"
+		args = append(args, matchedArgs...)
+	}
+	// Applied after UniqueMode, so uniqueness is still judged over every duration
+	if opts.MinDuration > 0 {
+		pageWhere += " AND c.duration >= ?"
+		args = append(args, opts.MinDuration)
+	}
+	if opts.AfterID > 0 {
+		pageWhere += ` AND (c.timestamp > ? OR (c.timestamp = ? AND
+			(c.timestamp_ns > ? OR (c.timestamp_ns = ? AND c.id > ?))))`
+		args = append(args, opts.AfterTimestamp, opts.AfterTimestamp,
+			opts.AfterTimestampNs, opts.AfterTimestampNs, opts.AfterID)
+	}
+	query, args := db.selectCommands(pageWhere, args, "timestamp ASC, timestamp_ns ASC, id ASC")
+	query += " LIMIT ?"
+	args = append(args, opts.Limit)
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get context page: %w", err)
+	}
+	defer rows.Close()
+
+	return db.scanCommandRows(rows)
+}
+
+// DisplayMode selects which of a context's commands are counted
+type DisplayMode int
+
+const (
+	// AllMode counts every command
+	AllMode DisplayMode = iota
+	// UniqueMode counts only commands whose text occurs once in the range
+	UniqueMode
+)
+
+// branchMatchPredicate selects the commands of a git branch in any directory,
+// so worktrees checking out the same branch count together.
+// Args: git repo, git branch ("" for none).
+const branchMatchPredicate = `COALESCE(g.repo, '') = ? AND COALESCE(g.branch, '') = ?`
+
+// ExactFilter reports whether a filter asks for an exact command text match,
+// which is written by wrapping the text in double quotes, and returns the text
+// to match. Any other filter is a case-sensitive substring.
+func ExactFilter(filter string) (string, bool) {
+	if len(filter) >= 2 && strings.HasPrefix(filter, `"`) && strings.HasSuffix(filter, `"`) {
+		return filter[1 : len(filter)-1], true
+	}
+	return filter, false
+}
+
+// ExcludePatterns splits an exclude string into its glob patterns, which are
+// separated by "|" (e.g. "ls*|cd *"). A pattern must match the whole command
+// text; * matches any run of characters and ? matches one character.
+func ExcludePatterns(exclude string) []string {
+	var patterns []string
+	for _, p := range strings.Split(exclude, "|") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// sqliteGlob escapes a pattern for the GLOB operator so that only * and ?
+// are wildcards, matching ExcludePatterns
+func sqliteGlob(pattern string) string {
+	return strings.ReplaceAll(pattern, "[", "[[]")
+}
+
+// PeekContextCount returns how many commands a context has within a Unix timestamp
+// range (inclusive start, exclusive end) under a display mode, text filter
+// (see ExactFilter) and exclude patterns (see ExcludePatterns). A nil branch
+// selects branchless commands. The filter and excludes are applied before
+// UniqueMode counts occurrences, so a command is unique when it appears once
+// among the remaining commands.
+func (db *DB) PeekContextCount(workingDir, gitRepo string, branch *string, startTime, endTime int64, mode DisplayMode, filter, exclude string) (int, error) {
+	return db.peekCount(contextMatchPredicate, []any{workingDir, gitRepo, branchValue(branch)}, startTime, endTime, mode, filter, exclude)
+}
+
+// PeekBranchCount is PeekContextCount for a git branch across all of the
+// directories (worktrees) it was used in.
+func (db *DB) PeekBranchCount(gitRepo string, branch *string, startTime, endTime int64, mode DisplayMode, filter, exclude string) (int, error) {
+	return db.peekCount(branchMatchPredicate, []any{gitRepo, branchValue(branch)}, startTime, endTime, mode, filter, exclude)
+}
+
+// FindPrevFailureDay returns the timestamp of the most recent failed command
+// of a context in [sinceTs, beforeTs); callers take its day. found is false
+// when the context has no failure in the window.
+func (db *DB) FindPrevFailureDay(workingDir, gitRepo string, branch *string, sinceTs, beforeTs int64) (ts int64, found bool, err error) {
+	query, args := db.unionQuery(func(src commandSource) string {
+		return `SELECT c.timestamp AS timestamp` + src.from + `
+		WHERE c.timestamp >= ? AND c.timestamp < ?
+		AND c.exit_status != 0
+		AND ` + contextMatchPredicate
+	}, []any{sinceTs, beforeTs, workingDir, gitRepo, branchValue(branch)}, "timestamp DESC")
+	err = db.conn.QueryRow(query+" LIMIT 1", args...).Scan(&ts)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to find previous failure: %w", err)
+	}
+	return ts, true, nil
+}
+
+// branchValue maps a nil branch to the empty string used by the match predicates
+func branchValue(branch *string) string {
+	if branch == nil {
+		return ""
+	}
+	return *branch
+}
+
+// textMatchClause returns the WHERE fragment and args applying a text filter
+// (see ExactFilter) and exclude patterns (see ExcludePatterns)
+func textMatchClause(filter, exclude string) (string, []any) {
+	text, exact := ExactFilter(filter)
+	textMatch := "instr(c.command_text, ?) > 0"
+	if exact {
+		textMatch = "c.command_text = ?"
+	}
+
+	args := []any{text}
+	for _, pattern := range ExcludePatterns(exclude) {
+		textMatch += " AND NOT c.command_text GLOB ?"
+		args = append(args, sqliteGlob(pattern))
+	}
+	return textMatch, args
+}
+
+// peekCount counts the commands in range matching predicate, mode and filter
+func (db *DB) peekCount(predicate string, predicateArgs []any, startTime, endTime int64, mode DisplayMode, filter, exclude string) (int, error) {
+	textMatch, textArgs := textMatchClause(filter, exclude)
+	args := append([]any{startTime, endTime}, predicateArgs...)
+	args = append(args, textArgs...)
+
+	matched, args := db.unionQuery(func(src commandSource) string {
+		return "SELECT c.command_text" + src.from + `
+		WHERE c.timestamp >= ? AND c.timestamp < ?
+		AND ` + predicate + `
+		AND ` + textMatch
+	}, args, "")
+
+	var query string
+	switch mode {
+	case UniqueMode:
+		query = "SELECT COUNT(*) FROM (SELECT command_text FROM (" + matched + ") GROUP BY command_text HAVING COUNT(*) = 1)"
+	default:
+		query = "SELECT COUNT(*) FROM (" + matched + ")"
+	}
+
+	var count int
+	err := db.conn.QueryRow(query, args...).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to peek context count: %w", err)
+	}
+	return count, nil
+}
+
+// TableExists checks if the commands table exists
+func (db *DB) TableExists() (bool, error) {
+	var name string
+	err := db.conn.QueryRow(`
+		SELECT name FROM sqlite_master
+		WHERE type='table' AND name='commands'`).Scan(&name)
+
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check table existence: %w", err)
+	}
+	return true, nil
+}
+
+// GetTableSchema returns the schema of the commands table
+func (db *DB) GetTableSchema() ([]map[string]any, error) {
+	rows, err := db.conn.Query("PRAGMA table_info(commands)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get table schema: %w", err)
+	}
+	defer rows.Close()
+
+	var schema []map[string]any
+	for rows.Next() {
+		var name, colType string
+		var placeholder any
+		if err := rows.Scan(&placeholder, &name, &colType, &placeholder, &placeholder, &placeholder); err != nil {
+			return nil, fmt.Errorf("failed to scan schema row: %w", err)
+		}
+
+		schema = append(schema, map[string]any{
+			"name": name,
+			"type": colType,
+		})
+	}
+
+	return schema, nil
+}
+
+// SchemaVersion returns the applied migration version (PRAGMA user_version)
+func (db *DB) SchemaVersion() (int, error) {
+	var version int
+	if err := db.conn.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, nil
+}
+
+// JournalMode returns the SQLite journal mode (e.g. "wal", "delete")
+func (db *DB) JournalMode() (string, error) {
+	var mode string
+	if err := db.conn.QueryRow("PRAGMA journal_mode").Scan(&mode); err != nil {
+		return "", fmt.Errorf("failed to read journal mode: %w", err)
+	}
+	return mode, nil
+}
+
+// ListIndexes returns the names of all user-defined indexes
+func (db *DB) ListIndexes() ([]string, error) {
+	rows, err := db.conn.Query(`
+		SELECT name FROM sqlite_master
+		WHERE type='index' AND name NOT LIKE 'sqlite_%'
+		ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list indexes: %w", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan index name: %w", err)
+		}
+		names = append(names, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating indexes: %w", err)
+	}
+	return names, nil
+}
+
+// Reindex drops and recreates every index defined by the migrations, in one
+// transaction so a failure leaves the old indexes in place, then runs
+// PRAGMA integrity_check. It returns the integrity check's rows, which are
+// just "ok" for a healthy database. Safe to run repeatedly.
+func (db *DB) Reindex() ([]string, error) {
+	if db.readOnly {
+		return nil, ErrReadOnly
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, idx := range migrations.Indexes() {
+		if _, err := tx.Exec("DROP INDEX IF EXISTS " + idx.Name); err != nil {
+			return nil, fmt.Errorf("failed to drop index %s: %w", idx.Name, err)
+		}
+		if _, err := tx.Exec(idx.SQL); err != nil {
+			return nil, fmt.Errorf("failed to create index %s: %w", idx.Name, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	rows, err := db.conn.Query("PRAGMA integrity_check")
+	if err != nil {
+		return nil, fmt.Errorf("failed to run integrity check: %w", err)
+	}
+	defer rows.Close()
+
+	var results []string
+	for rows.Next() {
+		var result string
+		if err := rows.Scan(&result); err != nil {
+			return nil, fmt.Errorf("failed to scan integrity check result: %w", err)
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating integrity check results: %w", err)
+	}
+	return results, nil
+}
+
+// ListCommands retrieves commands ordered by timestamp ascending (oldest first)
+// When a limit is applied, it returns the N most recent commands, but still ordered oldest-to-newest
+// If limit is 0, all commands are returned
+// If sourceApp and sourcePid are provided, only active commands from that session are returned
+func (db *DB) ListCommands(limit int, sourceApp string, sourcePid int64, cwd string) ([]models.Command, error) {
+	return db.ListCommandsInRange(0, 0, limit, sourceApp, sourcePid, cwd)
+}
+
+// ListCommandsInRange retrieves commands within a timestamp range, ordered by timestamp ascending
+// If startTime is 0, no lower bound is applied
+// If endTime is 0, no upper bound is applied
+// If limit is 0, all matching commands are returned
+// If sourceApp and sourcePid are provided, only active commands from that session are returned
+func (db *DB) ListCommandsInRange(startTime, endTime int64, limit int, sourceApp string, sourcePid int64, cwd string) ([]models.Command, error) {
+	var query string
+	var whereClauses []string
+
+	// Build WHERE clause for time range
+	if startTime > 0 && endTime > 0 {
+		whereClauses = append(whereClauses, fmt.Sprintf("c.timestamp >= %d AND c.timestamp <= %d", startTime, endTime))
+	} else if startTime > 0 {
+		whereClauses = append(whereClauses, fmt.Sprintf("c.timestamp >= %d", startTime))
+	} else if endTime > 0 {
+		whereClauses = append(whereClauses, fmt.Sprintf("c.timestamp <= %d", endTime))
+	}
+
+	if cwd != "" {
+		whereClauses = append(whereClauses, fmt.Sprintf("w.path = '%s'", cwd))
+	}
+
+	// Add session filter if provided (app and/or pid)
+	if sourceApp != "" || sourcePid > 0 {
+		var sessionClauses []string
+		if sourceApp != "" {
+			sessionClauses = append(sessionClauses, fmt.Sprintf("s.app = '%s'",
+				strings.ReplaceAll(sourceApp, "'", "''")))
+		}
+		if sourcePid > 0 {
+			sessionClauses = append(sessionClauses, fmt.Sprintf("s.pid = %d", sourcePid))
+		}
+		sessionClauses = append(sessionClauses, "s.active = 1")
+		whereClauses = append(whereClauses, strings.Join(sessionClauses, " AND "))
+	}
+
+	// Combine WHERE clauses
+	var whereClause string
+	if len(whereClauses) > 0 {
+		whereClause = "WHERE " + strings.Join(whereClauses, " AND ")
+	}
+
+	selectCols := commandSelectColumns
+	fromJoins := commandFromJoins
+
+	if limit > 0 {
+		// Get the N most recent commands in the range, then order them oldest-to-newest
+		query = fmt.Sprintf(`
+			SELECT * FROM (
+				SELECT %s %s
+				%s
+				ORDER BY c.timestamp DESC
+				LIMIT %d
+			)
+			ORDER BY timestamp ASC`, selectCols, fromJoins, whereClause, limit)
+	} else {
+		// Get all commands in the range
+		query = fmt.Sprintf(`
+			SELECT %s %s
+			%s
+			ORDER BY c.timestamp ASC`, selectCols, fromJoins, whereClause)
+	}
+
+	rows, err := db.conn.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list commands: %w", err)
+	}
+	defer rows.Close()
+
+	var commands []models.Command
+	for rows.Next() {
+		cmd, err := scanCommand(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan command: %w", err)
+		}
+		commands = append(commands, *cmd)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating commands: %w", err)
+	}
+
+	return commands, nil
+}
+
+// GetRecentCommandsWithoutConsecutiveDuplicates retrieves a single command at the given offset
+// Commands are gathered from session, working_dir, and full history contexts, sorted by context priority
+// (session=1, working_dir=2, history=3) then by timestamp DESC, and consecutive duplicates are removed using LAG
+// offset is 0-indexed: 0=most recent, 1=second most recent, etc.
+// Returns nil if no command exists at the given offset
+// Only populates CommandText field in the returned Command struct
+// sourceApp is required; sourcePid can be 0 to match all pids for that app
+func (db *DB) GetRecentCommandsWithoutConsecutiveDuplicates(offset int, sourceApp string, sourcePid int64, workingDir string) (*models.Command, error) {
+	if sourceApp == "" {
+		return nil, fmt.Errorf("sourceApp must be provided for session filtering")
+	}
+
+	// Calculate fetch limit per priority bucket
+	// Use 3x multiplier with a minimum of 50 to handle duplicates
+	bucketLimit := (offset + 1) * 3
+	if bucketLimit < 50 {
+		bucketLimit = 50
+	}
 
 	// Look up source IDs for this session
 	// If sourcePid is provided, look for exact match; otherwise match all pids for this app
@@ -650,7 +1493,7 @@ func (db *DB) GetRecentCommandsWithoutConsecutiveDuplicates(offset int, sourceAp
 			SELECT * FROM (
 				SELECT timestamp, command_text, 1 as priority
 				FROM commands
-				WHERE %s
+				WHERE %s AND deleted_at IS NULL
 				ORDER BY timestamp DESC
 				LIMIT ?
 			)
@@ -663,6 +1506,7 @@ func (db *DB) GetRecentCommandsWithoutConsecutiveDuplicates(offset int, sourceAp
 				FROM commands
 				WHERE working_dir_id = ?
 				  AND %s
+				  AND deleted_at IS NULL
 				ORDER BY timestamp DESC
 				LIMIT ?
 			)
@@ -675,6 +1519,7 @@ func (db *DB) GetRecentCommandsWithoutConsecutiveDuplicates(offset int, sourceAp
 				FROM commands
 				WHERE %s
 				  AND (working_dir_id IS NULL OR working_dir_id != ?)
+				  AND deleted_at IS NULL
 				ORDER BY timestamp DESC
 				LIMIT ?
 			)
@@ -717,7 +1562,7 @@ func (db *DB) GetRecentCommandsWithoutConsecutiveDuplicates(offset int, sourceAp
 // Returns 0 if no commands exist
 func (db *DB) GetMostRecentEventID() (int64, error) {
 	var id sql.NullInt64
-	err := db.conn.QueryRow("SELECT MAX(id) FROM commands").Scan(&id)
+	err := db.conn.QueryRow("SELECT MAX(id) FROM commands WHERE deleted_at IS NULL").Scan(&id)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get most recent event ID: %w", err)
 	}
@@ -728,117 +1573,31 @@ func (db *DB) GetMostRecentEventID() (int64, error) {
 	return id.Int64, nil
 }
 
-// GetCommandsByRange retrieves commands by event ID range (inclusive)
-// Returns commands ordered by ID ascending
-func (db *DB) GetCommandsByRange(first, last int64) ([]models.Command, error) {
-	// Handle invalid range
-	if first > last {
-		return []models.Command{}, nil
-	}
-
-	query := `SELECT id, command_text from commands
-		WHERE id >= ? AND id <= ? ORDER BY id ASC`
-
-	rows, err := db.conn.Query(query, first, last)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get commands by range: %w", err)
-	}
-	defer rows.Close()
-
-	var commands []models.Command
-	for rows.Next() {
-		var id int64
-		var cmdText string
-
-		if err := rows.Scan(&id, &cmdText); err != nil {
-			return nil, fmt.Errorf("failed to scan fzf entry: %w", err)
-		}
-		cmd := models.Command{ID: id, CommandText: cmdText}
-
-		commands = append(commands, cmd)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating commands: %w", err)
-	}
-
-	return commands, nil
-}
-
-// GetCommandsByRangeFull retrieves commands by event ID range (inclusive) with all columns
-// Returns commands ordered by ID ascending
-// Use this when you need full command data (timestamp, duration, working_dir, etc.)
-func (db *DB) GetCommandsByRangeFull(first, last int64) ([]models.Command, error) {
-	// Handle invalid range
-	if first > last {
-		return []models.Command{}, nil
-	}
-
-	query := `SELECT ` + commandSelectColumns + commandFromJoins + `
-		WHERE c.id >= ? AND c.id <= ?
-		ORDER BY c.id ASC`
-
-	rows, err := db.conn.Query(query, first, last)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get commands by range: %w", err)
-	}
-	defer rows.Close()
-
-	return db.scanCommandRows(rows)
-}
-
-// GetCommandsByRangeWithPattern retrieves commands by event ID range (inclusive) that match a pattern
-// Returns commands ordered by ID ascending
-// The pattern uses glob syntax (* for any chars, ? for single char) and is translated to SQL LIKE
-func (db *DB) GetCommandsByRangeWithPattern(first, last int64, pattern string) ([]models.Command, error) {
-	// Handle invalid range
-	if first > last {
-		return []models.Command{}, nil
-	}
-
-	query := `SELECT ` + commandSelectColumns + commandFromJoins + `
-		WHERE c.id IN (
-			SELECT max(id)
-			FROM commands
-			WHERE id >= ? AND id <= ?
-			AND command_text LIKE ? ESCAPE '\'
-			GROUP BY command_text
-		)
-		ORDER BY c.id ASC`
-
-	rows, err := db.conn.Query(query, first, last, pattern)
+// GetHistoryTimeRange returns the Unix timestamps of the oldest and newest
+// commands in the history. Both are 0 when the database is empty.
+func (db *DB) GetHistoryTimeRange() (first, last int64, err error) {
+	var minTs, maxTs sql.NullInt64
+	err = db.conn.QueryRow("SELECT MIN(timestamp), MAX(timestamp) FROM commands WHERE deleted_at IS NULL").Scan(&minTs, &maxTs)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get commands by range with pattern: %w", err)
+		return 0, 0, fmt.Errorf("failed to get history time range: %w", err)
 	}
-	defer rows.Close()
-
-	var commands []models.Command
-	for rows.Next() {
-		cmd, err := scanCommand(rows)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan command: %w", err)
-		}
-		commands = append(commands, *cmd)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating commands: %w", err)
+	if !minTs.Valid {
+		// No commands in database
+		return 0, 0, nil
 	}
-
-	return commands, nil
+	return minTs.Int64, maxTs.Int64, nil
 }
 
 // FindMostRecentMatching finds the most recent command that starts with the given prefix
 // Returns the event ID, or 0 if not found
 func (db *DB) FindMostRecentMatching(prefix string) (int64, error) {
 	var id int64
-	err := db.conn.QueryRow(`
-		SELECT id FROM commands
-		WHERE command_text LIKE ?
-		ORDER BY id DESC
-		LIMIT 1`,
-		prefix+"%",
-	).Scan(&id)
+	query, args := db.unionQuery(func(src commandSource) string {
+		return `
+		SELECT id FROM ` + src.prefix + `commands
+		WHERE command_text LIKE ? AND deleted_at IS NULL`
+	}, []any{prefix + "%"}, "id DESC")
+	err := db.conn.QueryRow(query+" LIMIT 1", args...).Scan(&id)
 
 	if err == sql.ErrNoRows {
 		return 0, nil
@@ -857,7 +1616,7 @@ func (db *DB) FindMostRecentMatchingBefore(prefix string, beforeID int64) (int64
 	var id int64
 	err := db.conn.QueryRow(`
 		SELECT id FROM commands
-		WHERE command_text LIKE ? AND id <= ?
+		WHERE command_text LIKE ? AND id <= ? AND deleted_at IS NULL
 		ORDER BY id DESC
 		LIMIT 1`,
 		prefix+"%",
@@ -874,97 +1633,137 @@ func (db *DB) FindMostRecentMatchingBefore(prefix string, beforeID int64) (int64
 	return id, nil
 }
 
-// GetCommandsByRangeInternal retrieves commands by event ID range (inclusive) filtered by session
-// Only returns commands from the active session with the given PID
-// Returns commands ordered by ID ascending
-func (db *DB) GetCommandsByRangeInternal(first, last, sessionPid int64) ([]models.Command, error) {
-	// Handle invalid range
-	if first > last {
-		return []models.Command{}, nil
-	}
+// SortOrder selects the event ID order of range queries
+type SortOrder int
 
-	query := `SELECT ` + commandSelectColumns + commandFromJoins + `
-		WHERE c.id IN (
-			SELECT max(c2.id)
-			FROM commands c2
-			JOIN sources s2 ON c2.source_id = s2.id
-			WHERE c2.id >= ? AND c2.id <= ?
-			AND s2.pid = ?
-			AND s2.active = 1
-			GROUP BY c2.command_text
-		)
-		ORDER BY c.id ASC`
+const (
+	Ascending SortOrder = iota
+	Descending
+)
 
-	rows, err := db.conn.Query(query, first, last, sessionPid)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get commands by range (internal): %w", err)
-	}
-	defer rows.Close()
+// RangeFilter narrows a range query. Zero fields do not filter: an empty
+// Pattern (a LIKE pattern), WorkingDir or SourceApp, a SessionPid of 0 or a
+// MinDuration of 0 (milliseconds, like the stored duration).
+type RangeFilter struct {
+	Pattern     string
+	WorkingDir  string
+	SourceApp   string
+	SessionPid  int64
+	MinDuration int64
+	AllRuns     bool // keep every matching command, not only the newest of each text
+}
 
-	var commands []models.Command
-	for rows.Next() {
-		cmd, err := scanCommand(rows)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan command: %w", err)
+// rangeIDSubquery builds a subquery selecting the command IDs in an event ID
+// range (inclusive) that pass filter. Filtered queries keep only the max(id)
+// per command text, unless filter.AllRuns is set; with an archive attached,
+// the max is taken over both databases.
+func (db *DB) rangeIDSubquery(first, last int64, filter RangeFilter) (string, []any) {
+	whereClauses := []string{"c2.id >= ?", "c2.id <= ?", "c2.deleted_at IS NULL"}
+	args := []any{first, last}
+	var joinDirs, joinSources bool
+
+	if filter.Pattern != "" {
+		whereClauses = append(whereClauses, `c2.command_text LIKE ? ESCAPE '\'`)
+		args = append(args, filter.Pattern)
+	}
+	if filter.WorkingDir != "" {
+		joinDirs = true
+		whereClauses = append(whereClauses, "w2.path = ?")
+		args = append(args, filter.WorkingDir)
+	}
+	if filter.SessionPid > 0 || filter.SourceApp != "" {
+		joinSources = true
+	}
+	if filter.SessionPid > 0 {
+		whereClauses = append(whereClauses, "s2.pid = ?", "s2.active = 1")
+		args = append(args, filter.SessionPid)
+	}
+	if filter.SourceApp != "" {
+		whereClauses = append(whereClauses, "s2.app = ?")
+		args = append(args, filter.SourceApp)
+	}
+	if filter.MinDuration > 0 {
+		whereClauses = append(whereClauses, "c2.duration >= ?")
+		args = append(args, filter.MinDuration)
+	}
+
+	// joins returns the joins of the database with the given schema prefix
+	joins := func(prefix string) string {
+		var joins string
+		if joinDirs {
+			joins += " JOIN " + prefix + "working_dirs w2 ON c2.working_dir_id = w2.id"
 		}
-		commands = append(commands, *cmd)
+		if joinSources {
+			joins += " JOIN " + prefix + "sources s2 ON c2.source_id = s2.id"
+		}
+		return joins
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating commands: %w", err)
+	where := " WHERE " + strings.Join(whereClauses, " AND ")
+	if filter == (RangeFilter{}) || filter.AllRuns {
+		return db.unionQuery(func(src commandSource) string {
+			return "SELECT c2.id FROM " + src.prefix + "commands c2" + joins(src.prefix) + where
+		}, args, "")
 	}
-
-	return commands, nil
+	if len(db.commandSources()) == 1 {
+		return "SELECT max(c2.id) FROM commands c2" + joins("") + where + " GROUP BY c2.command_text", args
+	}
+	matched, args := db.unionQuery(func(src commandSource) string {
+		return "SELECT c2.id, c2.command_text FROM " + src.prefix + "commands c2" + joins(src.prefix) + where
+	}, args, "")
+	return "SELECT max(id) FROM (" + matched + ") GROUP BY command_text", args
 }
 
-// GetCommandsByRangeWithPatternInternal retrieves commands by event ID range (inclusive) that match a pattern
-// and are from the active session with the given PID
-// Returns commands ordered by ID ascending
-// The pattern uses glob syntax (* for any chars, ? for single char) and is translated to SQL LIKE
-func (db *DB) GetCommandsByRangeWithPatternInternal(first, last, sessionPid int64, pattern string) ([]models.Command, error) {
+// GetCommandsByRangeOrdered retrieves commands by event ID range (inclusive)
+// that pass filter, returned in the given order so callers such as fc -r do
+// not need to reverse the results.
+func (db *DB) GetCommandsByRangeOrdered(first, last int64, filter RangeFilter, order SortOrder) ([]models.Command, error) {
 	// Handle invalid range
 	if first > last {
 		return []models.Command{}, nil
 	}
 
-	query := `SELECT ` + commandSelectColumns + commandFromJoins + `
-		WHERE c.id IN (
-			SELECT max(c2.id)
-			FROM commands c2
-			JOIN sources s2 ON c2.source_id = s2.id
-			WHERE c2.id >= ? AND c2.id <= ?
-			AND c2.command_text LIKE ? ESCAPE '\'
-			AND s2.pid = ?
-			AND s2.active = 1
-			GROUP BY c2.command_text
-		)
-		ORDER BY c.id ASC`
+	direction := "ASC"
+	if order == Descending {
+		direction = "DESC"
+	}
 
-	rows, err := db.conn.Query(query, first, last, pattern, sessionPid)
+	subquery, args := db.rangeIDSubquery(first, last, filter)
+	query, args := db.selectCommands(`
+		WHERE c.id IN (`+subquery+`)`, args, "id "+direction)
+
+	rows, err := db.conn.Query(query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get commands by range with pattern (internal): %w", err)
+		return nil, fmt.Errorf("failed to get commands by range: %w", err)
 	}
 	defer rows.Close()
 
-	var commands []models.Command
-	for rows.Next() {
-		cmd, err := scanCommand(rows)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan command: %w", err)
-		}
-		commands = append(commands, *cmd)
-	}
+	return db.scanCommandRows(rows)
+}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating commands: %w", err)
+// CountCommandsByRange counts the commands GetCommandsByRangeOrdered would
+// return, without materializing rows.
+func (db *DB) CountCommandsByRange(first, last int64, filter RangeFilter) (int, error) {
+	// Handle invalid range
+	if first > last {
+		return 0, nil
 	}
 
-	return commands, nil
+	subquery, args := db.rangeIDSubquery(first, last, filter)
+
+	var count int
+	if err := db.conn.QueryRow("SELECT COUNT(*) FROM ("+subquery+")", args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count commands by range: %w", err)
+	}
+	return count, nil
 }
 
 // CloseSession marks all active sources from a session as inactive
 // Returns the number of source records updated
 func (db *DB) CloseSession(sessionPid int64) (int64, error) {
+	if db.readOnly {
+		return 0, ErrReadOnly
+	}
 	result, err := db.conn.Exec(`
 		UPDATE sources
 		SET active = 0
@@ -990,11 +1789,13 @@ type LikeRecentOptions struct {
 	WorkingDir string
 	SourceApp  string
 	SourcePid  int64
+	GitRepo    string // repository GitBranch belongs to; empty for no remote
+	GitBranch  string
 }
 
 // LikeRecent finds commands matching a prefix with various filters
-// Runs three parallel queries (session, working dir, whole history) and returns first non-empty result
-// Query priority: session (with workingdir if provided) > working dir only > whole history
+// Runs the queries in parallel and returns the first non-empty result
+// Query priority: session on the branch > session > branch > working dir > whole history
 func (db *DB) LikeRecent(opts LikeRecentOptions) ([]string, error) {
 	type queryResult struct {
 		results []string
@@ -1025,15 +1826,27 @@ func (db *DB) LikeRecent(opts LikeRecentOptions) ([]string, error) {
 		}
 	}
 
+	// Look up git_context_id for the branch (if provided). Commands
+	// recorded outside a remote have a NULL repo.
+	var gitContextID sql.NullInt64
+	if opts.GitBranch != "" {
+		var repo *string
+		if opts.GitRepo != "" {
+			repo = &opts.GitRepo
+		}
+		err := db.conn.QueryRow(
+			"SELECT id FROM git_contexts WHERE repo IS ? AND branch = ?",
+			repo, opts.GitBranch,
+		).Scan(&gitContextID)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, fmt.Errorf("failed to query git_context ID: %w", err)
+		}
+	}
+
 	// Build base WHERE clause for common filters (prefix, IncludeShy)
-	baseWhere := "command_text LIKE ?"
+	baseWhere := "command_text LIKE ? AND deleted_at IS NULL"
 	baseArgs := []any{opts.Prefix + "%"}
 
-	// Create channels for results
-	sessionChan := make(chan queryResult, 1)
-	workingDirChan := make(chan queryResult, 1)
-	historyChan := make(chan queryResult, 1)
-
 	// Helper function to execute query
 	executeQuery := func(query string, args []any, ch chan<- queryResult) {
 		rows, err := db.conn.Query(query, args...)
@@ -1061,73 +1874,65 @@ func (db *DB) LikeRecent(opts LikeRecentOptions) ([]string, error) {
 		ch <- queryResult{results, nil}
 	}
 
-	// 1. Session query (if source_id found)
-	// Includes working dir filter if also provided
-	if sourceID.Valid {
-		go func() {
-			sessionWhere := baseWhere + " AND source_id = ?"
-			sessionArgs := append(append([]any{}, baseArgs...), sourceID.Int64)
-
-			query := `SELECT command_text FROM commands WHERE ` + sessionWhere + ` ORDER BY timestamp DESC LIMIT 1`
-			executeQuery(query, sessionArgs, sessionChan)
-		}()
-	} else {
-		sessionChan <- queryResult{nil, nil}
-	}
-
-	// 2. Working directory query (if working_dir_id found and no session filter)
-	// Only runs if session query didn't run
-	if workingDirID.Valid && !sourceID.Valid {
+	// run starts a query narrowed by extra WHERE conditions, or reports an
+	// empty result straight away when the query does not apply
+	run := func(enabled bool, where string, args ...any) <-chan queryResult {
+		ch := make(chan queryResult, 1)
+		if !enabled {
+			ch <- queryResult{nil, nil}
+			return ch
+		}
 		go func() {
-			workingDirWhere := baseWhere + " AND working_dir_id = ?"
-			workingDirArgs := append(append([]any{}, baseArgs...), workingDirID.Int64)
-			query := `SELECT command_text FROM commands WHERE ` + workingDirWhere + ` ORDER BY timestamp DESC LIMIT 1`
-			executeQuery(query, workingDirArgs, workingDirChan)
-		}()
-	} else {
-		workingDirChan <- queryResult{nil, nil}
-	}
-
-	// 3. Whole history query (always run)
-	go func() {
-		query := `SELECT command_text FROM commands WHERE ` + baseWhere + ` ORDER BY timestamp DESC LIMIT 1`
-		executeQuery(query, baseArgs, historyChan)
-	}()
-
-	// Check results in order: session, working dir, history
-	// Return first non-empty result
-	sessionResult := <-sessionChan
-	if sessionResult.err != nil {
-		return nil, sessionResult.err
-	}
-	if len(sessionResult.results) > 0 {
-		return sessionResult.results, nil
+			query := `SELECT command_text FROM commands WHERE ` + baseWhere + where + ` ORDER BY timestamp DESC LIMIT 1`
+			executeQuery(query, append(append([]any{}, baseArgs...), args...), ch)
+		}()
+		return ch
 	}
 
-	workingDirResult := <-workingDirChan
-	if workingDirResult.err != nil {
-		return nil, workingDirResult.err
-	}
-	if len(workingDirResult.results) > 0 {
-		return workingDirResult.results, nil
+	// In priority order. The working directory query only runs when there
+	// is no session filter.
+	chans := []<-chan queryResult{
+		// 1. Session on the current branch
+		run(sourceID.Valid && gitContextID.Valid, " AND source_id = ? AND git_context_id = ?", sourceID.Int64, gitContextID.Int64),
+		// 2. Session
+		run(sourceID.Valid, " AND source_id = ?", sourceID.Int64),
+		// 3. Branch, from any session
+		run(gitContextID.Valid, " AND git_context_id = ?", gitContextID.Int64),
+		// 4. Working directory
+		run(workingDirID.Valid && !sourceID.Valid, " AND working_dir_id = ?", workingDirID.Int64),
+		// 5. Whole history (always run)
+		run(true, ""),
 	}
 
-	historyResult := <-historyChan
-	if historyResult.err != nil {
-		return nil, historyResult.err
+	// Return first non-empty result
+	for _, ch := range chans {
+		result := <-ch
+		if result.err != nil {
+			return nil, result.err
+		}
+		if len(result.results) > 0 {
+			return result.results, nil
+		}
 	}
-
-	return historyResult.results, nil
+	return nil, nil
 }
 
 // GetCommandsForFzf retrieves commands using the is_duplicate column
 // This is the fastest approach as it uses a simple index scan with no deduplication logic
+// With an archive attached, archived commands whose text is also in the
+// primary are left out, so each text is still listed once.
 func (db *DB) GetCommandsForFzf(fn func(id int64, cmdText string) error) error {
-	query := `
+	query, _ := db.unionQuery(func(src commandSource) string {
+		part := `
 		SELECT id, command_text
-		FROM commands
-		WHERE is_duplicate = 0
-		ORDER BY id DESC`
+		FROM ` + src.prefix + `commands
+		WHERE is_duplicate = 0 AND deleted_at IS NULL`
+		if src.prefix != "" {
+			part += ` AND command_text NOT IN (
+			SELECT command_text FROM commands WHERE is_duplicate = 0 AND deleted_at IS NULL)`
+		}
+		return part
+	}, nil, "id DESC")
 
 	rows, err := db.conn.Query(query)
 	if err != nil {
@@ -1149,6 +1954,55 @@ func (db *DB) GetCommandsForFzf(fn func(id int64, cmdText string) error) error {
 	return rows.Err()
 }
 
+// Gap is an idle period between two consecutive commands of a session
+type Gap struct {
+	BeforeID int64 // last command before the gap
+	AfterID  int64 // first command after the gap
+	Start    int64 // Unix timestamp of BeforeID
+	End      int64 // Unix timestamp of AfterID
+}
+
+// Duration returns the length of the gap
+func (g Gap) Duration() time.Duration {
+	return time.Duration(g.End-g.Start) * time.Second
+}
+
+// GetSessionGaps returns the idle periods in a session: consecutive commands
+// (by timestamp) more than thresholdSec seconds apart, oldest first
+func (db *DB) GetSessionGaps(sessionPid int64, thresholdSec int64) ([]Gap, error) {
+	rows, err := db.conn.Query(`
+		SELECT prev_id, id, prev_timestamp, timestamp
+		FROM (
+			SELECT c.id, c.timestamp,
+				LAG(c.id) OVER (ORDER BY c.timestamp, c.id) AS prev_id,
+				LAG(c.timestamp) OVER (ORDER BY c.timestamp, c.id) AS prev_timestamp
+			FROM commands c
+			JOIN sources s ON c.source_id = s.id
+			WHERE s.pid = ? AND c.deleted_at IS NULL
+		)
+		WHERE prev_timestamp IS NOT NULL AND timestamp - prev_timestamp > ?
+		ORDER BY timestamp, id`,
+		sessionPid, thresholdSec,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query session gaps: %w", err)
+	}
+	defer rows.Close()
+
+	var gaps []Gap
+	for rows.Next() {
+		var g Gap
+		if err := rows.Scan(&g.BeforeID, &g.AfterID, &g.Start, &g.End); err != nil {
+			return nil, fmt.Errorf("failed to scan session gap: %w", err)
+		}
+		gaps = append(gaps, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating session gaps: %w", err)
+	}
+	return gaps, nil
+}
+
 // GetCommandWithContext returns a command along with surrounding commands from the same session
 // Returns (beforeCommands, targetCommand, afterCommands, error)
 // beforeCommands are in chronological order (oldest first)
@@ -1177,49 +2031,64 @@ func (db *DB) GetCommandWithContext(id int64, contextSize int) ([]models.Command
 		}
 	} else {
 		// Get commands from the same session
-		sessionPid := *targetCmd.SourcePid
-
-		// Get commands before (same session, ID < target, ordered by ID DESC, limit contextSize)
-		beforeQuery := `SELECT ` + commandSelectColumns + commandFromJoins + `
-			WHERE c.id < ? AND s.pid = ?
-			ORDER BY c.id DESC
-			LIMIT ?`
-
-		rows, err := db.conn.Query(beforeQuery, id, sessionPid, contextSize)
+		beforeCommands, afterCommands, err = db.getNeighborCommands(id, "s.pid = ?", *targetCmd.SourcePid, contextSize)
 		if err != nil {
-			return nil, nil, nil, fmt.Errorf("failed to query before commands: %w", err)
+			return nil, nil, nil, err
 		}
+	}
 
-		beforeCommands, err = db.scanCommandRows(rows)
-		rows.Close()
-		if err != nil {
-			return nil, nil, nil, fmt.Errorf("failed to scan before commands: %w", err)
-		}
+	return beforeCommands, targetCmd, afterCommands, nil
+}
 
-		// Reverse beforeCommands to get chronological order (oldest first)
-		for i, j := 0, len(beforeCommands)-1; i < j; i, j = i+1, j-1 {
-			beforeCommands[i], beforeCommands[j] = beforeCommands[j], beforeCommands[i]
-		}
+// GetCommandsWithContextAcrossSessions returns the commands around target
+// run in its working directory, from any session. It stands in for the
+// session neighbors of GetCommandWithContext when target has no source_pid,
+// as legacy imports don't: the directory is the best session proxy left,
+// where adjacent ids alone would mix in unrelated terminals.
+// Both slices are in chronological order (oldest first).
+func (db *DB) GetCommandsWithContextAcrossSessions(target *models.Command, contextSize int) ([]models.Command, []models.Command, error) {
+	return db.getNeighborCommands(target.ID, "w.path = ?", target.WorkingDir, contextSize)
+}
 
-		// Get commands after (same session, ID > target, ordered by ID ASC, limit contextSize)
-		afterQuery := `SELECT ` + commandSelectColumns + commandFromJoins + `
-			WHERE c.id > ? AND s.pid = ?
-			ORDER BY c.id ASC
-			LIMIT ?`
+// getNeighborCommands returns up to limit commands on each side of id that
+// match cond, a condition with one placeholder for arg, oldest first
+func (db *DB) getNeighborCommands(id int64, cond string, arg any, limit int) ([]models.Command, []models.Command, error) {
+	// Get commands before (ID < target, ordered by ID DESC, limit)
+	beforeQuery, args := db.selectCommands(`
+		WHERE c.id < ? AND `+cond, []any{id, arg}, "id DESC")
 
-		rows, err = db.conn.Query(afterQuery, id, sessionPid, contextSize)
-		if err != nil {
-			return nil, nil, nil, fmt.Errorf("failed to query after commands: %w", err)
-		}
+	rows, err := db.conn.Query(beforeQuery+" LIMIT ?", append(args, limit)...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query before commands: %w", err)
+	}
 
-		afterCommands, err = db.scanCommandRows(rows)
-		rows.Close()
-		if err != nil {
-			return nil, nil, nil, fmt.Errorf("failed to scan after commands: %w", err)
-		}
+	beforeCommands, err := db.scanCommandRows(rows)
+	rows.Close()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to scan before commands: %w", err)
 	}
 
-	return beforeCommands, targetCmd, afterCommands, nil
+	// Reverse beforeCommands to get chronological order (oldest first)
+	for i, j := 0, len(beforeCommands)-1; i < j; i, j = i+1, j-1 {
+		beforeCommands[i], beforeCommands[j] = beforeCommands[j], beforeCommands[i]
+	}
+
+	// Get commands after (ID > target, ordered by ID ASC, limit)
+	afterQuery, args := db.selectCommands(`
+		WHERE c.id > ? AND `+cond, []any{id, arg}, "id ASC")
+
+	rows, err = db.conn.Query(afterQuery+" LIMIT ?", append(args, limit)...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query after commands: %w", err)
+	}
+
+	afterCommands, err := db.scanCommandRows(rows)
+	rows.Close()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to scan after commands: %w", err)
+	}
+
+	return beforeCommands, afterCommands, nil
 }
 
 // getCommandsByIDRange gets commands within an ID range (inclusive)
@@ -1228,11 +2097,10 @@ func (db *DB) getCommandsByIDRange(startID, endID int64) ([]models.Command, erro
 		return []models.Command{}, nil
 	}
 
-	query := `SELECT ` + commandSelectColumns + commandFromJoins + `
-		WHERE c.id >= ? AND c.id <= ?
-		ORDER BY c.id ASC`
+	query, args := db.selectCommands(`
+		WHERE c.id >= ? AND c.id <= ?`, []any{startID, endID}, "id ASC")
 
-	rows, err := db.conn.Query(query, startID, endID)
+	rows, err := db.conn.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query commands by ID range: %w", err)
 	}
@@ -1268,133 +2136,510 @@ func (db *DB) GetUniqueSourceApps() ([]string, error) {
 	}
 	defer rows.Close()
 
-	var apps []string
-	for rows.Next() {
-		var app string
-		if err := rows.Scan(&app); err != nil {
-			return nil, fmt.Errorf("failed to scan source app: %w", err)
+	var apps []string
+	for rows.Next() {
+		var app string
+		if err := rows.Scan(&app); err != nil {
+			return nil, fmt.Errorf("failed to scan source app: %w", err)
+		}
+		apps = append(apps, app)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating source apps: %w", err)
+	}
+
+	return apps, nil
+}
+
+// StarCommand marks a command as starred
+func (db *DB) StarCommand(id int64) error {
+	if db.readOnly {
+		return ErrReadOnly
+	}
+	_, err := db.conn.Exec("INSERT OR IGNORE INTO starred_commands (command_id) VALUES (?)", id)
+	if err != nil {
+		return fmt.Errorf("failed to star command: %w", err)
+	}
+	return nil
+}
+
+// UnstarCommand removes a star from a command
+func (db *DB) UnstarCommand(id int64) error {
+	if db.readOnly {
+		return ErrReadOnly
+	}
+	_, err := db.conn.Exec("DELETE FROM starred_commands WHERE command_id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to unstar command: %w", err)
+	}
+	return nil
+}
+
+// ToggleStar toggles the starred status of a command.
+// Returns true if the command is now starred, false if unstarred.
+func (db *DB) ToggleStar(id int64) (bool, error) {
+	starred, err := db.IsStarred(id)
+	if err != nil {
+		return false, err
+	}
+	if starred {
+		return false, db.UnstarCommand(id)
+	}
+	return true, db.StarCommand(id)
+}
+
+// IsStarred returns whether a command is starred
+func (db *DB) IsStarred(id int64) (bool, error) {
+	var exists int
+	err := db.conn.QueryRow("SELECT 1 FROM starred_commands WHERE command_id = ?", id).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check starred status: %w", err)
+	}
+	return true, nil
+}
+
+// GetStarredIDs returns a set of all starred command IDs for O(1) lookup
+func (db *DB) GetStarredIDs() (map[int64]bool, error) {
+	rows, err := db.conn.Query("SELECT command_id FROM starred_commands")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query starred commands: %w", err)
+	}
+	defer rows.Close()
+
+	ids := make(map[int64]bool)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan starred command ID: %w", err)
+		}
+		ids[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating starred commands: %w", err)
+	}
+	return ids, nil
+}
+
+// ListStarredCommands returns all starred commands with optional filters.
+// If sourceApp is non-empty, filters to active commands from that app/pid.
+// If cwd is non-empty, filters to commands from that working directory.
+func (db *DB) ListStarredCommands(sourceApp string, sourcePid int64, cwd string) ([]models.Command, error) {
+	var whereClauses []string
+	whereClauses = append(whereClauses, "sc.command_id IS NOT NULL")
+
+	if sourceApp != "" || sourcePid > 0 {
+		var sessionClauses []string
+		if sourceApp != "" {
+			sessionClauses = append(sessionClauses, fmt.Sprintf("s.app = '%s'",
+				strings.ReplaceAll(sourceApp, "'", "''")))
+		}
+		if sourcePid > 0 {
+			sessionClauses = append(sessionClauses, fmt.Sprintf("s.pid = %d", sourcePid))
+		}
+		sessionClauses = append(sessionClauses, "s.active = 1")
+		whereClauses = append(whereClauses, strings.Join(sessionClauses, " AND "))
+	}
+
+	if cwd != "" {
+		whereClauses = append(whereClauses, fmt.Sprintf("w.path = '%s'",
+			strings.ReplaceAll(cwd, "'", "''")))
+	}
+
+	whereClause := "WHERE " + strings.Join(whereClauses, " AND ")
+
+	query := `SELECT ` + commandSelectColumns + commandFromJoins + `
+		JOIN starred_commands sc ON c.id = sc.command_id
+		` + whereClause + `
+		ORDER BY c.timestamp DESC`
+
+	rows, err := db.conn.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list starred commands: %w", err)
+	}
+	defer rows.Close()
+
+	return db.scanCommandRows(rows)
+}
+
+// ContextNoteKey identifies the context a note is attached to.
+// Empty GitRepo/GitBranch match commands with NULL or empty git columns.
+type ContextNoteKey struct {
+	WorkingDir string
+	GitRepo    string
+	GitBranch  string
+}
+
+// SetContextNote saves the note for a context, replacing any existing note.
+// An empty (or whitespace-only) note removes it.
+func (db *DB) SetContextNote(workingDir, gitRepo, gitBranch, note string) error {
+	if db.readOnly {
+		return ErrReadOnly
+	}
+	if strings.TrimSpace(note) == "" {
+		_, err := db.conn.Exec(
+			"DELETE FROM context_notes WHERE working_dir = ? AND git_repo = ? AND git_branch = ?",
+			workingDir, gitRepo, gitBranch)
+		if err != nil {
+			return fmt.Errorf("failed to delete context note: %w", err)
+		}
+		return nil
+	}
+
+	_, err := db.conn.Exec(`
+		INSERT INTO context_notes (working_dir, git_repo, git_branch, note, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (working_dir, git_repo, git_branch)
+		DO UPDATE SET note = excluded.note, updated_at = excluded.updated_at`,
+		workingDir, gitRepo, gitBranch, note, time.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("failed to set context note: %w", err)
+	}
+	return nil
+}
+
+// GetContextNote returns the note for a context, or "" if it has none
+func (db *DB) GetContextNote(workingDir, gitRepo, gitBranch string) (string, error) {
+	var note string
+	err := db.conn.QueryRow(
+		"SELECT note FROM context_notes WHERE working_dir = ? AND git_repo = ? AND git_branch = ?",
+		workingDir, gitRepo, gitBranch).Scan(&note)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get context note: %w", err)
+	}
+	return note, nil
+}
+
+// GetContextNotes returns all context notes keyed by context for O(1) lookup
+func (db *DB) GetContextNotes() (map[ContextNoteKey]string, error) {
+	rows, err := db.conn.Query("SELECT working_dir, git_repo, git_branch, note FROM context_notes")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query context notes: %w", err)
+	}
+	defer rows.Close()
+
+	notes := make(map[ContextNoteKey]string)
+	for rows.Next() {
+		var key ContextNoteKey
+		var note string
+		if err := rows.Scan(&key.WorkingDir, &key.GitRepo, &key.GitBranch, &note); err != nil {
+			return nil, fmt.Errorf("failed to scan context note: %w", err)
+		}
+		notes[key] = note
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating context notes: %w", err)
+	}
+	return notes, nil
+}
+
+// contextTagTarget selects the ids of one context's commands in [startTs, endTs).
+// Args: start, end, then contextMatchPredicate's.
+const contextTagTarget = `SELECT c.id` + commandFromJoins + `
+	WHERE c.timestamp >= ? AND c.timestamp < ? AND ` + contextMatchPredicate
+
+// TagContext tags every command of a context in [startTs, endTs) in one
+// statement. It returns how many commands were newly tagged; commands that
+// already had the tag are left alone and not counted.
+func (db *DB) TagContext(workingDir, gitRepo, gitBranch string, startTs, endTs int64, tag string) (int64, error) {
+	if db.readOnly {
+		return 0, ErrReadOnly
+	}
+	res, err := db.conn.Exec(
+		"INSERT OR IGNORE INTO command_tags (command_id, tag) SELECT id, ? FROM ("+contextTagTarget+")",
+		tag, startTs, endTs, workingDir, gitRepo, gitBranch)
+	if err != nil {
+		return 0, fmt.Errorf("failed to tag context: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count tagged commands: %w", err)
+	}
+	return n, nil
+}
+
+// UntagContext removes a tag from every command of a context in
+// [startTs, endTs), returning how many commands lost it
+func (db *DB) UntagContext(workingDir, gitRepo, gitBranch string, startTs, endTs int64, tag string) (int64, error) {
+	if db.readOnly {
+		return 0, ErrReadOnly
+	}
+	res, err := db.conn.Exec(
+		"DELETE FROM command_tags WHERE tag = ? AND command_id IN ("+contextTagTarget+")",
+		tag, startTs, endTs, workingDir, gitRepo, gitBranch)
+	if err != nil {
+		return 0, fmt.Errorf("failed to untag context: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count untagged commands: %w", err)
+	}
+	return n, nil
+}
+
+// GetCommandTags returns a command's tags in alphabetical order
+func (db *DB) GetCommandTags(id int64) ([]string, error) {
+	rows, err := db.conn.Query("SELECT tag FROM command_tags WHERE command_id = ? ORDER BY tag", id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query command tags: %w", err)
+	}
+	defer rows.Close()
+
+	var tags []string
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, fmt.Errorf("failed to scan command tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating command tags: %w", err)
+	}
+	return tags, nil
+}
+
+// UpdateCommandText replaces the stored text of a command, keeping the
+// is_duplicate flags consistent for both the old and the new text
+func (db *DB) UpdateCommandText(id int64, text string) error {
+	if db.readOnly {
+		return ErrReadOnly
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var oldText string
+	var wasCanonical bool
+	err = tx.QueryRow("SELECT command_text, is_duplicate = 0 FROM commands WHERE id = ?", id).Scan(&oldText, &wasCanonical)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("command %d not found", id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get command: %w", err)
+	}
+	if oldText == text {
+		return nil
+	}
+
+	// The row is canonical for its new text unless a newer row already has it
+	_, err = tx.Exec(`
+		UPDATE commands SET command_text = ?,
+			is_duplicate = EXISTS (SELECT 1 FROM commands WHERE command_text = ? AND id > ? AND deleted_at IS NULL)
+		WHERE id = ?`,
+		text, text, id, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update command text: %w", err)
+	}
+	_, err = tx.Exec(`
+		UPDATE commands SET is_duplicate = 1
+		WHERE command_text = ? AND id < ? AND is_duplicate = 0`,
+		text, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to mark duplicates: %w", err)
+	}
+
+	// Promote the highest remaining ID to canonical for the old text
+	if wasCanonical {
+		_, err = tx.Exec(`
+			UPDATE commands SET is_duplicate = 0
+			WHERE id = (SELECT MAX(id) FROM commands WHERE command_text = ? AND deleted_at IS NULL)
+			AND is_duplicate = 1`,
+			oldText,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to promote canonical entry: %w", err)
 		}
-		apps = append(apps, app)
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating source apps: %w", err)
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
+	return nil
+}
 
-	return apps, nil
+// NearDuplicateText is one stored text of a near-duplicate cluster and the
+// number of live commands with it
+type NearDuplicateText struct {
+	Text  string
+	Count int
 }
 
-// StarCommand marks a command as starred
-func (db *DB) StarCommand(id int64) error {
-	_, err := db.conn.Exec("INSERT OR IGNORE INTO starred_commands (command_id) VALUES (?)", id)
-	if err != nil {
-		return fmt.Errorf("failed to star command: %w", err)
-	}
-	return nil
+// NearDuplicates is a cluster of distinct command texts that differ only in
+// whitespace outside quotes, such as "git status " and "git  status", with
+// the normalized text they share
+type NearDuplicates struct {
+	Normalized string
+	Texts      []NearDuplicateText // most used first
 }
 
-// UnstarCommand removes a star from a command
-func (db *DB) UnstarCommand(id int64) error {
-	_, err := db.conn.Exec("DELETE FROM starred_commands WHERE command_id = ?", id)
-	if err != nil {
-		return fmt.Errorf("failed to unstar command: %w", err)
+// Total returns the number of commands in the cluster
+func (n NearDuplicates) Total() int {
+	total := 0
+	for _, t := range n.Texts {
+		total += t.Count
 	}
-	return nil
+	return total
 }
 
-// ToggleStar toggles the starred status of a command.
-// Returns true if the command is now starred, false if unstarred.
-func (db *DB) ToggleStar(id int64) (bool, error) {
-	starred, err := db.IsStarred(id)
-	if err != nil {
-		return false, err
-	}
-	if starred {
-		return false, db.UnstarCommand(id)
+// Rewrites returns the number of commands whose text differs from the
+// normalized one
+func (n NearDuplicates) Rewrites() int {
+	count := 0
+	for _, t := range n.Texts {
+		if t.Text != n.Normalized {
+			count += t.Count
+		}
 	}
-	return true, db.StarCommand(id)
+	return count
 }
 
-// IsStarred returns whether a command is starred
-func (db *DB) IsStarred(id int64) (bool, error) {
-	var exists int
-	err := db.conn.QueryRow("SELECT 1 FROM starred_commands WHERE command_id = ?", id).Scan(&exists)
-	if err == sql.ErrNoRows {
-		return false, nil
-	}
-	if err != nil {
-		return false, fmt.Errorf("failed to check starred status: %w", err)
+// normalizeCommandText trims text and collapses each run of spaces and tabs
+// outside quotes to one space. Quoted whitespace is kept, since it is part
+// of an argument.
+func normalizeCommandText(text string) string {
+	var b strings.Builder
+	var quote rune
+	escaped, space := false, false
+	for _, r := range strings.TrimSpace(text) {
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0:
+			if r == '\\' && quote == '"' {
+				escaped = true
+			} else if r == quote {
+				quote = 0
+			}
+		case r == ' ' || r == '\t':
+			space = true
+			continue
+		case r == '\\':
+			escaped = true
+		case r == '\'' || r == '"':
+			quote = r
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
 	}
-	return true, nil
+	return b.String()
 }
 
-// GetStarredIDs returns a set of all starred command IDs for O(1) lookup
-func (db *DB) GetStarredIDs() (map[int64]bool, error) {
-	rows, err := db.conn.Query("SELECT command_id FROM starred_commands")
+// FindNearDuplicates groups the live command texts by their normalized form
+// (see normalizeCommandText) and returns the groups with more than one
+// text, largest first
+func (db *DB) FindNearDuplicates() ([]NearDuplicates, error) {
+	rows, err := db.conn.Query("SELECT command_text, COUNT(*) FROM commands WHERE deleted_at IS NULL GROUP BY command_text")
 	if err != nil {
-		return nil, fmt.Errorf("failed to query starred commands: %w", err)
+		return nil, fmt.Errorf("failed to query command texts: %w", err)
 	}
 	defer rows.Close()
 
-	ids := make(map[int64]bool)
+	groups := make(map[string][]NearDuplicateText)
 	for rows.Next() {
-		var id int64
-		if err := rows.Scan(&id); err != nil {
-			return nil, fmt.Errorf("failed to scan starred command ID: %w", err)
+		var t NearDuplicateText
+		if err := rows.Scan(&t.Text, &t.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan command text: %w", err)
 		}
-		ids[id] = true
+		normalized := normalizeCommandText(t.Text)
+		groups[normalized] = append(groups[normalized], t)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating starred commands: %w", err)
+		return nil, fmt.Errorf("error iterating command texts: %w", err)
 	}
-	return ids, nil
-}
-
-// ListStarredCommands returns all starred commands with optional filters.
-// If sourceApp is non-empty, filters to active commands from that app/pid.
-// If cwd is non-empty, filters to commands from that working directory.
-func (db *DB) ListStarredCommands(sourceApp string, sourcePid int64, cwd string) ([]models.Command, error) {
-	var whereClauses []string
-	whereClauses = append(whereClauses, "sc.command_id IS NOT NULL")
 
-	if sourceApp != "" || sourcePid > 0 {
-		var sessionClauses []string
-		if sourceApp != "" {
-			sessionClauses = append(sessionClauses, fmt.Sprintf("s.app = '%s'",
-				strings.ReplaceAll(sourceApp, "'", "''")))
-		}
-		if sourcePid > 0 {
-			sessionClauses = append(sessionClauses, fmt.Sprintf("s.pid = %d", sourcePid))
+	var clusters []NearDuplicates
+	for normalized, texts := range groups {
+		if len(texts) < 2 {
+			continue
 		}
-		sessionClauses = append(sessionClauses, "s.active = 1")
-		whereClauses = append(whereClauses, strings.Join(sessionClauses, " AND "))
+		sort.Slice(texts, func(i, j int) bool {
+			if texts[i].Count != texts[j].Count {
+				return texts[i].Count > texts[j].Count
+			}
+			return texts[i].Text < texts[j].Text
+		})
+		clusters = append(clusters, NearDuplicates{Normalized: normalized, Texts: texts})
 	}
+	sort.Slice(clusters, func(i, j int) bool {
+		if ti, tj := clusters[i].Total(), clusters[j].Total(); ti != tj {
+			return ti > tj
+		}
+		return clusters[i].Normalized < clusters[j].Normalized
+	})
+	return clusters, nil
+}
 
-	if cwd != "" {
-		whereClauses = append(whereClauses, fmt.Sprintf("w.path = '%s'",
-			strings.ReplaceAll(cwd, "'", "''")))
+// NormalizeNearDuplicates rewrites the live commands of each cluster to its
+// normalized text and makes the newest of them canonical for it, in one
+// transaction. Trashed commands keep their text. Returns the number of
+// rewritten rows.
+func (db *DB) NormalizeNearDuplicates(clusters []NearDuplicates) (int64, error) {
+	if db.readOnly {
+		return 0, ErrReadOnly
 	}
 
-	whereClause := "WHERE " + strings.Join(whereClauses, " AND ")
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
 
-	query := `SELECT ` + commandSelectColumns + commandFromJoins + `
-		JOIN starred_commands sc ON c.id = sc.command_id
-		` + whereClause + `
-		ORDER BY c.timestamp DESC`
+	var rewritten int64
+	for _, cluster := range clusters {
+		for _, t := range cluster.Texts {
+			if t.Text == cluster.Normalized {
+				continue
+			}
+			result, err := tx.Exec("UPDATE commands SET command_text = ? WHERE command_text = ? AND deleted_at IS NULL",
+				cluster.Normalized, t.Text)
+			if err != nil {
+				return 0, fmt.Errorf("failed to rewrite command text: %w", err)
+			}
+			n, err := result.RowsAffected()
+			if err != nil {
+				return 0, fmt.Errorf("failed to get rows affected: %w", err)
+			}
+			rewritten += n
+		}
 
-	rows, err := db.conn.Query(query)
-	if err != nil {
-		return nil, fmt.Errorf("failed to list starred commands: %w", err)
+		_, err = tx.Exec(`
+			UPDATE commands SET is_duplicate = id <> (SELECT MAX(id) FROM commands WHERE command_text = ? AND deleted_at IS NULL)
+			WHERE command_text = ? AND deleted_at IS NULL`,
+			cluster.Normalized, cluster.Normalized,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("failed to mark duplicates: %w", err)
+		}
 	}
-	defer rows.Close()
 
-	return db.scanCommandRows(rows)
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return rewritten, nil
 }
 
 // DeleteCommands deletes commands by their IDs.
 // It recalculates is_duplicate flags for affected command texts and cleans up
 // orphaned lookup table rows. Returns the number of deleted rows.
 func (db *DB) DeleteCommands(ids []int64) (int64, error) {
+	if db.readOnly {
+		return 0, ErrReadOnly
+	}
 	if len(ids) == 0 {
 		return 0, nil
 	}
@@ -1453,7 +2698,7 @@ func (db *DB) DeleteCommands(ids []int64) (int64, error) {
 	for _, text := range affectedTexts {
 		_, err := tx.Exec(`
 			UPDATE commands SET is_duplicate = 0
-			WHERE id = (SELECT MAX(id) FROM commands WHERE command_text = ?)
+			WHERE id = (SELECT MAX(id) FROM commands WHERE command_text = ? AND deleted_at IS NULL)
 			AND is_duplicate = 1`,
 			text,
 		)
@@ -1482,3 +2727,263 @@ func (db *DB) DeleteCommands(ids []int64) (int64, error) {
 
 	return count, nil
 }
+
+// TrashedCommand is a command in the trash with the time it was trashed
+type TrashedCommand struct {
+	models.Command
+	DeletedAt int64
+}
+
+// SoftDeleteCommand moves a live command to the trash. Trashed commands are
+// left out of the standard queries and are never canonical for their text,
+// so de-duplicated listings fall back to the newest live copy. Returns false
+// when there is no live command with the id.
+func (db *DB) SoftDeleteCommand(id int64) (bool, error) {
+	if db.readOnly {
+		return false, ErrReadOnly
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var text string
+	var wasCanonical bool
+	err = tx.QueryRow("SELECT command_text, is_duplicate = 0 FROM commands WHERE id = ? AND deleted_at IS NULL", id).Scan(&text, &wasCanonical)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get command: %w", err)
+	}
+
+	_, err = tx.Exec("UPDATE commands SET deleted_at = ?, is_duplicate = 1 WHERE id = ?", time.Now().Unix(), id)
+	if err != nil {
+		return false, fmt.Errorf("failed to trash command: %w", err)
+	}
+
+	// Promote the highest remaining live ID to canonical
+	if wasCanonical {
+		_, err = tx.Exec(`
+			UPDATE commands SET is_duplicate = 0
+			WHERE id = (SELECT MAX(id) FROM commands WHERE command_text = ? AND deleted_at IS NULL)`,
+			text,
+		)
+		if err != nil {
+			return false, fmt.Errorf("failed to promote canonical entry: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
+
+// RestoreCommand takes a command out of the trash. It becomes canonical for
+// its text again when no newer live command has the same text. Returns false
+// when there is no trashed command with the id.
+func (db *DB) RestoreCommand(id int64) (bool, error) {
+	if db.readOnly {
+		return false, ErrReadOnly
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var text string
+	err = tx.QueryRow("SELECT command_text FROM commands WHERE id = ? AND deleted_at IS NOT NULL", id).Scan(&text)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get command: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		UPDATE commands SET deleted_at = NULL,
+			is_duplicate = EXISTS (SELECT 1 FROM commands WHERE command_text = ? AND id > ? AND deleted_at IS NULL)
+		WHERE id = ?`,
+		text, id, id,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to restore command: %w", err)
+	}
+	_, err = tx.Exec(`
+		UPDATE commands SET is_duplicate = 1
+		WHERE command_text = ? AND id < ? AND is_duplicate = 0`,
+		text, id,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to mark duplicates: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
+
+// GetTrashedCommands returns the trashed commands, most recently trashed
+// first, at most limit of them (0 means no limit)
+func (db *DB) GetTrashedCommands(limit int) ([]TrashedCommand, error) {
+	query := `SELECT ` + commandSelectColumns + `, c.deleted_at` + trashFromJoins + `
+		ORDER BY c.deleted_at DESC, c.id DESC`
+	var args []any
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trashed commands: %w", err)
+	}
+	defer rows.Close()
+
+	var trashed []TrashedCommand
+	for rows.Next() {
+		var deletedAt int64
+		cmd, err := scanCommand(trailingScanner{rows, []any{&deletedAt}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan command: %w", err)
+		}
+		trashed = append(trashed, TrashedCommand{Command: *cmd, DeletedAt: deletedAt})
+	}
+	return trashed, rows.Err()
+}
+
+// trailingScanner scans a row into scanCommand's destinations followed by
+// extra ones, for queries selecting columns after commandSelectColumns
+type trailingScanner struct {
+	rows  *sql.Rows
+	extra []any
+}
+
+func (t trailingScanner) Scan(dest ...any) error {
+	return t.rows.Scan(append(dest, t.extra...)...)
+}
+
+// EmptyTrash hard-deletes every trashed command (see DeleteCommands) and
+// returns how many were deleted
+func (db *DB) EmptyTrash() (int64, error) {
+	if db.readOnly {
+		return 0, ErrReadOnly
+	}
+
+	rows, err := db.conn.Query("SELECT id FROM commands WHERE deleted_at IS NOT NULL")
+	if err != nil {
+		return 0, fmt.Errorf("failed to get trashed commands: %w", err)
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan trashed id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating trashed ids: %w", err)
+	}
+
+	return db.DeleteCommands(ids)
+}
+
+// CountSessionCommands returns how many commands recorded under pid at or
+// after sinceTs ReassignSession would move
+func (db *DB) CountSessionCommands(pid, sinceTs int64) (int64, error) {
+	var count int64
+	err := db.conn.QueryRow(`
+		SELECT COUNT(*) FROM commands c
+		JOIN sources s ON c.source_id = s.id
+		WHERE s.pid = ? AND c.timestamp >= ?`,
+		pid, sinceTs,
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count session commands: %w", err)
+	}
+	return count, nil
+}
+
+// ReassignSession moves the commands recorded under fromPid at or after
+// sinceTs to toPid, to correct commands attributed to the wrong session after
+// a PID was reused. Each command keeps its source's app and active flag.
+// fromPid's sources left without commands are removed. Returns the number of moved rows.
+func (db *DB) ReassignSession(fromPid, toPid, sinceTs int64) (int64, error) {
+	if db.readOnly {
+		return 0, ErrReadOnly
+	}
+	if fromPid == toPid {
+		return 0, nil
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	type source struct {
+		id     int64
+		app    string
+		active bool
+	}
+	rows, err := tx.Query("SELECT id, app, active FROM sources WHERE pid = ?", fromPid)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query sources: %w", err)
+	}
+	var sources []source
+	for rows.Next() {
+		var s source
+		if err := rows.Scan(&s.id, &s.app, &s.active); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan source: %w", err)
+		}
+		sources = append(sources, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating sources: %w", err)
+	}
+
+	var moved int64
+	for _, s := range sources {
+		target, err := getOrCreateSource(tx, &s.app, &toPid, &s.active)
+		if err != nil {
+			return 0, err
+		}
+		result, err := tx.Exec(
+			"UPDATE commands SET source_id = ? WHERE source_id = ? AND timestamp >= ?",
+			*target, s.id, sinceTs,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("failed to reassign commands: %w", err)
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		moved += n
+	}
+
+	_, err = tx.Exec(
+		"DELETE FROM sources WHERE pid = ? AND id NOT IN (SELECT DISTINCT source_id FROM commands WHERE source_id IS NOT NULL)",
+		fromPid,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to clean orphaned sources: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return moved, nil
+}
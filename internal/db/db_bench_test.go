@@ -5,6 +5,8 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/chris/shy/pkg/models"
 )
 
 // BenchmarkDBSize defines a database size for benchmarking
@@ -165,7 +167,7 @@ func BenchmarkGetCommandsByRange(b *testing.B) {
 				b.ResetTimer()
 				for i := 0; i < b.N; i++ {
 					database := OpenDB(b, dbPath)
-					_, err := database.GetCommandsByRange(r.first, r.last)
+					_, err := database.GetCommandsByRangeOrdered(r.first, r.last, RangeFilter{}, Ascending)
 					if err != nil {
 						b.Fatalf("failed to get commands: %v", err)
 					}
@@ -196,7 +198,7 @@ func BenchmarkGetAllHistory(b *testing.B) {
 					b.Fatalf("failed to get most recent event id: %v", err)
 				}
 
-				_, err = database.GetCommandsByRange(1, mostRecent)
+				_, err = database.GetCommandsByRangeOrdered(1, mostRecent, RangeFilter{}, Ascending)
 				if err != nil {
 					b.Fatalf("failed to get commands: %v", err)
 				}
@@ -205,3 +207,53 @@ func BenchmarkGetAllHistory(b *testing.B) {
 		})
 	}
 }
+
+// populateWorkingDirBenchDB fills a temporary database with commands spread
+// across many working directories
+func populateWorkingDirBenchDB(b *testing.B, dirs, perDir int) *DB {
+	b.Helper()
+	database, err := NewForTesting(filepath.Join(b.TempDir(), "history.db"))
+	if err != nil {
+		b.Fatalf("failed to create database: %v", err)
+	}
+
+	cmds := make([]*models.Command, 0, dirs*perDir)
+	for i := 0; i < dirs*perDir; i++ {
+		cmd := models.NewCommand(fmt.Sprintf("make target-%d", i%97), fmt.Sprintf("/home/user/projects/p%d", i%dirs), 0)
+		cmd.Timestamp = int64(1700000000 + i)
+		cmds = append(cmds, cmd)
+	}
+	if _, err := database.InsertCommands(cmds); err != nil {
+		b.Fatalf("failed to insert commands: %v", err)
+	}
+	return database
+}
+
+// BenchmarkWorkingDirIndex measures a working_dir-filtered lookup with and
+// without idx_working_dir_timestamp
+func BenchmarkWorkingDirIndex(b *testing.B) {
+	for _, withIndex := range []bool{true, false} {
+		name := "with-index"
+		if !withIndex {
+			name = "without-index"
+		}
+		b.Run(name, func(b *testing.B) {
+			database := populateWorkingDirBenchDB(b, 200, 500)
+			defer database.Close()
+			if !withIndex {
+				if _, err := database.conn.Exec("DROP INDEX idx_working_dir_timestamp"); err != nil {
+					b.Fatalf("failed to drop index: %v", err)
+				}
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				// No session matches pid 0, so the lookup falls through to the working_dir branch
+				_, err := database.GetRecentCommandsWithoutConsecutiveDuplicates(0, "zsh", 0, "/home/user/projects/p7")
+				if err != nil {
+					b.Fatalf("failed to get commands: %v", err)
+				}
+			}
+		})
+	}
+}
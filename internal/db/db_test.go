@@ -5,10 +5,12 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/chris/shy/internal/db/migrations"
 	"github.com/chris/shy/pkg/models"
 )
 
@@ -59,6 +61,9 @@ func TestScenario1_DatabaseInitializationOnFirstInsert(t *testing.T) {
 		{"git_context_id", "INTEGER"},
 		{"source_id", "INTEGER"},
 		{"is_duplicate", "INTEGER"},
+		{"env", "TEXT"},
+		{"deleted_at", "INTEGER"},
+		{"timestamp_ns", "INTEGER"},
 	}
 
 	require.Len(t, schema, len(expectedColumns), "should have correct number of columns")
@@ -348,6 +353,132 @@ func TestGetCommandsByDateRange(t *testing.T) {
 }
 
 // TestGetCommandsByDateRange_EmptyResult tests querying when no commands exist in range
+func TestGetSlowestCommands(t *testing.T) {
+	database, err := NewForTesting(filepath.Join(t.TempDir(), "history.db"))
+	require.NoError(t, err)
+	defer database.Close()
+
+	start := int64(1736841600)
+	for i, c := range []struct {
+		text     string
+		duration *int64
+	}{
+		{"ls", int64Ptr(40)},
+		{"make test", int64Ptr(8200)},
+		{"vim", nil},
+		{"true", int64Ptr(0)},
+		{"go build", int64Ptr(72000)},
+		{"make lint", int64Ptr(8200)},
+	} {
+		cmd := models.NewCommand(c.text, "/home/user/projects/shy", 0)
+		cmd.Timestamp = start + int64(i)*60
+		cmd.Duration = c.duration
+		_, err := database.InsertCommand(cmd)
+		require.NoError(t, err)
+	}
+	outside := models.NewCommand("sleep 600", "/home/user/projects/shy", 0)
+	outside.Timestamp = start + 86400
+	outside.Duration = int64Ptr(600000)
+	_, err = database.InsertCommand(outside)
+	require.NoError(t, err)
+
+	commands, err := database.GetSlowestCommands(start, start+86400, 10)
+	require.NoError(t, err)
+	var texts []string
+	for _, c := range commands {
+		texts = append(texts, c.CommandText)
+	}
+	assert.Equal(t, []string{"go build", "make test", "make lint", "ls"}, texts,
+		"longest first, ties by id, no NULL or zero durations, nothing outside the range")
+	assert.Equal(t, int64(72000), *commands[0].Duration)
+
+	commands, err = database.GetSlowestCommands(start, start+86400, 2)
+	require.NoError(t, err)
+	assert.Len(t, commands, 2)
+}
+
+func TestGetRandomCommand(t *testing.T) {
+	database, err := NewForTesting(filepath.Join(t.TempDir(), "history.db"))
+	require.NoError(t, err)
+	defer database.Close()
+
+	start := int64(1736841600)
+	for i, text := range []string{"ls", "git status", "make test", "git push", "vim"} {
+		cmd := models.NewCommand(text, "/home/user/projects/shy", 0)
+		cmd.Timestamp = start + int64(i)*3600
+		_, err := database.InsertCommand(cmd)
+		require.NoError(t, err)
+	}
+
+	// Repeat the picks so a filter ignored by chance would show
+	for range 20 {
+		cmd, err := database.GetRandomCommand(RandomOptions{Pattern: "git %"})
+		require.NoError(t, err)
+		require.NotNil(t, cmd)
+		assert.Contains(t, []string{"git status", "git push"}, cmd.CommandText)
+
+		cmd, err = database.GetRandomCommand(RandomOptions{StartTime: start + 3600, EndTime: start + 3*3600})
+		require.NoError(t, err)
+		require.NotNil(t, cmd)
+		assert.Contains(t, []string{"git status", "make test"}, cmd.CommandText, "EndTime is exclusive")
+
+		cmd, err = database.GetRandomCommand(RandomOptions{Pattern: "git %", StartTime: start + 2*3600})
+		require.NoError(t, err)
+		require.NotNil(t, cmd)
+		assert.Equal(t, "git push", cmd.CommandText)
+	}
+
+	cmd, err := database.GetRandomCommand(RandomOptions{Pattern: "docker%"})
+	require.NoError(t, err)
+	assert.Nil(t, cmd, "nothing matches")
+}
+
+func TestGetCommandCountsByDay(t *testing.T) {
+	database, err := NewForTesting(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer database.Close()
+
+	loc := time.FixedZone("UTC-5", -5*3600)
+	day := func(month time.Month, d int) time.Time { return time.Date(2026, month, d, 0, 0, 0, 0, loc) }
+	for _, ts := range []time.Time{
+		day(3, 1).Add(23*time.Hour + 50*time.Minute), // 04:50 UTC on the 2nd
+		day(3, 2).Add(9 * time.Hour),
+		day(3, 2).Add(10 * time.Hour),
+		day(3, 5).Add(8 * time.Hour),
+	} {
+		_, err := database.InsertCommand(&models.Command{CommandText: "ls", WorkingDir: "/shy", Timestamp: ts.Unix()})
+		require.NoError(t, err)
+	}
+
+	days, err := database.GetCommandCountsByDay(loc)
+	require.NoError(t, err)
+	require.Len(t, days, 3)
+	assert.True(t, days[0].Day.Equal(day(3, 1)), "days start at midnight in loc")
+	assert.Equal(t, []int{1, 2, 1}, []int{days[0].Count, days[1].Count, days[2].Count})
+	assert.True(t, days[2].Day.Equal(day(3, 5)))
+}
+
+func TestActivityStreaks(t *testing.T) {
+	day := func(d int) DayCount { return DayCount{Day: time.Date(2026, 3, d, 0, 0, 0, 0, time.UTC), Count: 3} }
+	// Four days, a gap on the 5th, then two days
+	days := []DayCount{day(1), day(2), day(3), day(4), day(6), day(7)}
+	at := func(d, hour int) time.Time { return time.Date(2026, 3, d, hour, 0, 0, 0, time.UTC) }
+
+	current, longest := ActivityStreaks(days, at(7, 18))
+	assert.Equal(t, 2, current)
+	assert.Equal(t, 4, longest)
+
+	current, _ = ActivityStreaks(days, at(8, 9))
+	assert.Equal(t, 2, current, "a streak lasts until a whole day passes without a command")
+	current, longest = ActivityStreaks(days, at(9, 9))
+	assert.Equal(t, 0, current)
+	assert.Equal(t, 4, longest)
+
+	current, longest = ActivityStreaks(nil, at(9, 9))
+	assert.Zero(t, current)
+	assert.Zero(t, longest)
+}
+
 func TestGetCommandsByDateRange_EmptyResult(t *testing.T) {
 	// Given: the shy database exists with no commands
 	tempDir := t.TempDir()
@@ -415,8 +546,8 @@ func boolPtr(b bool) *bool {
 	return &b
 }
 
-// TestGetCommandsByRange tests that GetCommandsByRange returns all commands in range
-func TestGetCommandsByRange(t *testing.T) {
+// TestGetCommandsByRangeUnfiltered tests that an empty filter returns all commands in range
+func TestGetCommandsByRangeUnfiltered(t *testing.T) {
 	t.Run("returns all commands including duplicates", func(t *testing.T) {
 		// Given: database with duplicate commands
 		tempDir := t.TempDir()
@@ -441,8 +572,8 @@ func TestGetCommandsByRange(t *testing.T) {
 			require.NoError(t, err)
 		}
 
-		// When: GetCommandsByRange is called
-		results, err := database.GetCommandsByRange(1, 6)
+		// When: GetCommandsByRangeOrdered is called without a filter
+		results, err := database.GetCommandsByRangeOrdered(1, 6, RangeFilter{}, Ascending)
 		require.NoError(t, err)
 
 		// Then: should return all 6 commands (no deduplication)
@@ -462,7 +593,7 @@ func TestGetCommandsByRange(t *testing.T) {
 		defer database.Close()
 
 		// When: called with invalid range (first > last)
-		results, err := database.GetCommandsByRange(10, 5)
+		results, err := database.GetCommandsByRangeOrdered(10, 5, RangeFilter{}, Ascending)
 		require.NoError(t, err)
 
 		// Then: should return empty slice
@@ -483,8 +614,8 @@ func TestGetCommandsByRange(t *testing.T) {
 			require.NoError(t, err)
 		}
 
-		// When: GetCommandsByRange is called
-		results, err := database.GetCommandsByRange(1, 5)
+		// When: GetCommandsByRangeOrdered is called without a filter
+		results, err := database.GetCommandsByRangeOrdered(1, 5, RangeFilter{}, Ascending)
 		require.NoError(t, err)
 
 		// Then: should return all 5 commands
@@ -492,8 +623,8 @@ func TestGetCommandsByRange(t *testing.T) {
 	})
 }
 
-// TestGetCommandsByRangeWithPattern tests pattern matching with deduplication
-func TestGetCommandsByRangeWithPattern(t *testing.T) {
+// TestGetCommandsByRangePatternFilter tests pattern matching with deduplication
+func TestGetCommandsByRangePatternFilter(t *testing.T) {
 	t.Run("returns unique commands matching pattern", func(t *testing.T) {
 		// Given: database with duplicate commands
 		tempDir := t.TempDir()
@@ -518,8 +649,8 @@ func TestGetCommandsByRangeWithPattern(t *testing.T) {
 			require.NoError(t, err)
 		}
 
-		// When: GetCommandsByRangeWithPattern is called with pattern "git%"
-		results, err := database.GetCommandsByRangeWithPattern(1, 6, "git%")
+		// When: GetCommandsByRangeOrdered is called with pattern "git%"
+		results, err := database.GetCommandsByRangeOrdered(1, 6, RangeFilter{Pattern: "git%"}, Ascending)
 		require.NoError(t, err)
 
 		// Then: should return only unique git commands
@@ -554,7 +685,7 @@ func TestGetCommandsByRangeWithPattern(t *testing.T) {
 		require.NoError(t, err)
 
 		// When: pattern doesn't match any commands
-		results, err := database.GetCommandsByRangeWithPattern(1, 1, "git%")
+		results, err := database.GetCommandsByRangeOrdered(1, 1, RangeFilter{Pattern: "git%"}, Ascending)
 		require.NoError(t, err)
 
 		// Then: should return empty slice
@@ -562,8 +693,8 @@ func TestGetCommandsByRangeWithPattern(t *testing.T) {
 	})
 }
 
-// TestGetCommandsByRangeInternal tests session filtering with deduplication
-func TestGetCommandsByRangeInternal(t *testing.T) {
+// TestGetCommandsByRangeSessionFilter tests session filtering with deduplication
+func TestGetCommandsByRangeSessionFilter(t *testing.T) {
 	t.Run("returns unique commands for specific session", func(t *testing.T) {
 		// Given: database with commands from multiple sessions
 		tempDir := t.TempDir()
@@ -592,8 +723,8 @@ func TestGetCommandsByRangeInternal(t *testing.T) {
 			require.NoError(t, err)
 		}
 
-		// When: GetCommandsByRangeInternal is called for session 1000
-		results, err := database.GetCommandsByRangeInternal(1, 6, 1000)
+		// When: GetCommandsByRangeOrdered is called for session 1000
+		results, err := database.GetCommandsByRangeOrdered(1, 6, RangeFilter{SessionPid: 1000}, Ascending)
 		require.NoError(t, err)
 
 		// Then: should return only unique commands from session 1000
@@ -632,8 +763,8 @@ func TestGetCommandsByRangeInternal(t *testing.T) {
 		_, err = database.InsertCommand(cmd2)
 		require.NoError(t, err)
 
-		// When: GetCommandsByRangeInternal is called
-		results, err := database.GetCommandsByRangeInternal(1, 2, 1000)
+		// When: GetCommandsByRangeOrdered is called for the session
+		results, err := database.GetCommandsByRangeOrdered(1, 2, RangeFilter{SessionPid: 1000}, Ascending)
 		require.NoError(t, err)
 
 		// Then: should only return active command
@@ -642,8 +773,8 @@ func TestGetCommandsByRangeInternal(t *testing.T) {
 	})
 }
 
-// TestGetCommandsByRangeWithPatternInternal tests pattern + session filtering with deduplication
-func TestGetCommandsByRangeWithPatternInternal(t *testing.T) {
+// TestGetCommandsByRangePatternAndSessionFilter tests pattern + session filtering with deduplication
+func TestGetCommandsByRangePatternAndSessionFilter(t *testing.T) {
 	t.Run("returns unique commands matching pattern for session", func(t *testing.T) {
 		// Given: database with commands from multiple sessions
 		tempDir := t.TempDir()
@@ -670,8 +801,8 @@ func TestGetCommandsByRangeWithPatternInternal(t *testing.T) {
 		_, err = database.InsertCommand(cmd)
 		require.NoError(t, err)
 
-		// When: GetCommandsByRangeWithPatternInternal is called for session 1000 with pattern "git%"
-		results, err := database.GetCommandsByRangeWithPatternInternal(1, 5, 1000, "git%")
+		// When: GetCommandsByRangeOrdered is called for session 1000 with pattern "git%"
+		results, err := database.GetCommandsByRangeOrdered(1, 5, RangeFilter{SessionPid: 1000, Pattern: "git%"}, Ascending)
 		require.NoError(t, err)
 
 		// Then: should return unique git commands from session 1000 only
@@ -1406,22 +1537,24 @@ func TestMigrateSchemaV1ToV2(t *testing.T) {
 	_, err = db1.conn.Exec("PRAGMA user_version = 1")
 	require.NoError(t, err)
 
-	// Insert a command at v1
-	cmd := models.NewCommand("echo test", "/home/test", 0)
-	_, err = db1.InsertCommand(cmd)
+	// Insert a command at v1 (raw SQL: InsertCommand writes the v4 env column)
+	_, err = db1.conn.Exec("INSERT INTO working_dirs (path) VALUES ('/home/test')")
+	require.NoError(t, err)
+	_, err = db1.conn.Exec(`INSERT INTO commands (timestamp, exit_status, duration, command_text, working_dir_id)
+		VALUES (1000, 0, 0, 'echo test', 1)`)
 	require.NoError(t, err)
 	db1.Close()
 
-	// Reopen — should detect PRAGMA user_version=1, run migration 2
+	// Reopen — should detect PRAGMA user_version=1, run migrations 2 to 7
 	db2, err := New(dbPath)
 	require.NoError(t, err)
 	defer db2.Close()
 
-	// Verify PRAGMA user_version is now 2
+	// Verify PRAGMA user_version is now the latest version
 	var version int
 	err = db2.conn.QueryRow("PRAGMA user_version").Scan(&version)
 	require.NoError(t, err)
-	assert.Equal(t, 2, version)
+	assert.Equal(t, 7, version)
 
 	// Verify starred_commands table exists
 	var tableName string
@@ -1437,3 +1570,1459 @@ func TestMigrateSchemaV1ToV2(t *testing.T) {
 	require.NoError(t, err)
 	assert.True(t, starred)
 }
+
+// TestMigrateCommandEnv verifies that migration 4 adds the env column while
+// keeping commands, stars and the id sequence, and that it can be rerun
+func TestMigrateCommandEnv(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	database, err := New(dbPath)
+	require.NoError(t, err)
+	for _, text := range []string{"one", "two", "three"} {
+		_, err := database.InsertCommand(models.NewCommand(text, "/home/test", 0))
+		require.NoError(t, err)
+	}
+	require.NoError(t, database.StarCommand(2))
+	_, err = database.DeleteCommands([]int64{3})
+	require.NoError(t, err)
+
+	// Roll back to a version 3 schema
+	_, err = database.conn.Exec("ALTER TABLE commands DROP COLUMN env")
+	require.NoError(t, err)
+	_, err = database.conn.Exec("PRAGMA user_version = 3")
+	require.NoError(t, err)
+	database.Close()
+
+	for run := 1; run <= 2; run++ {
+		database, err = New(dbPath)
+		require.NoError(t, err, "migration run %d", run)
+
+		count, err := database.CountCommands()
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+		starred, err := database.IsStarred(2)
+		require.NoError(t, err)
+		assert.True(t, starred, "stars survive the table rebuild")
+		cmd, err := database.GetCommand(1)
+		require.NoError(t, err)
+		assert.Nil(t, cmd.Env, "existing rows have no env")
+
+		if run == 1 {
+			_, err = database.conn.Exec("PRAGMA user_version = 3")
+			require.NoError(t, err)
+		}
+		database.Close()
+	}
+
+	database, err = New(dbPath)
+	require.NoError(t, err)
+	defer database.Close()
+
+	// The deleted id is not reused, and env round-trips
+	cmd := models.NewCommand("kubectl get pods", "/home/test", 0)
+	cmd.Env = map[string]string{"AWS_PROFILE": "staging", "KUBECONFIG": "/home/test/.kube/staging"}
+	id, err := database.InsertCommand(cmd)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), id)
+
+	got, err := database.GetCommand(id)
+	require.NoError(t, err)
+	assert.Equal(t, cmd.Env, got.Env)
+}
+
+// TestMigrateCommandTimestampNs verifies that migration 7 adds timestamp_ns,
+// filled with each existing row's second, while keeping commands, stars,
+// tags, the trash and the id sequence, and that it can be rerun
+func TestMigrateCommandTimestampNs(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	database, err := New(dbPath)
+	require.NoError(t, err)
+	for i, text := range []string{"one", "two", "three", "four"} {
+		cmd := models.NewCommand(text, "/home/test", 0)
+		cmd.Timestamp = 1000 + int64(i)
+		_, err := database.InsertCommand(cmd)
+		require.NoError(t, err)
+	}
+	require.NoError(t, database.StarCommand(2))
+	_, err = database.TagContext("/home/test", "", "", 0, 2000, "work")
+	require.NoError(t, err)
+	trashed, err := database.SoftDeleteCommand(3)
+	require.NoError(t, err)
+	require.True(t, trashed)
+	_, err = database.DeleteCommands([]int64{4})
+	require.NoError(t, err)
+
+	// Roll back to a version 6 schema
+	_, err = database.conn.Exec("ALTER TABLE commands DROP COLUMN timestamp_ns")
+	require.NoError(t, err)
+	_, err = database.conn.Exec("PRAGMA user_version = 6")
+	require.NoError(t, err)
+	database.Close()
+
+	for run := 1; run <= 2; run++ {
+		database, err = New(dbPath)
+		require.NoError(t, err, "migration run %d", run)
+
+		count, err := database.CountCommands()
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+		starred, err := database.IsStarred(2)
+		require.NoError(t, err)
+		assert.True(t, starred, "stars survive the table rebuild")
+		tags, err := database.GetCommandTags(1)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"work"}, tags, "tags survive the table rebuild")
+		inTrash, err := database.GetTrashedCommands(10)
+		require.NoError(t, err)
+		require.Len(t, inTrash, 1, "the trash survives the table rebuild")
+		cmd, err := database.GetCommand(2)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1001)*int64(time.Second), cmd.TimestampNs, "existing rows start on their second")
+
+		if run == 1 {
+			_, err = database.conn.Exec("PRAGMA user_version = 6")
+			require.NoError(t, err)
+		}
+		database.Close()
+	}
+
+	database, err = New(dbPath)
+	require.NoError(t, err)
+	defer database.Close()
+
+	// The deleted id is not reused
+	id, err := database.InsertCommand(models.NewCommand("five", "/home/test", 0))
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), id)
+}
+
+// TestCommandsOrderWithinSecond verifies that commands of the same second
+// come back in timestamp_ns order, whatever order they were inserted in
+func TestCommandsOrderWithinSecond(t *testing.T) {
+	database, err := NewForTesting(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer database.Close()
+
+	second := int64(1736841600)
+	for _, c := range []struct {
+		text string
+		ns   int64
+	}{
+		{"late", second*int64(time.Second) + 900_000_000},
+		{"whole", 0},
+		{"early", second*int64(time.Second) + 100_000_000},
+	} {
+		cmd := models.NewCommand(c.text, "/home/test", 0)
+		cmd.Timestamp = second
+		cmd.TimestampNs = c.ns
+		_, err := database.InsertCommand(cmd)
+		require.NoError(t, err)
+	}
+
+	commands, err := database.GetCommandsByDateRange(second, second+1, nil)
+	require.NoError(t, err)
+	var texts []string
+	for _, c := range commands {
+		texts = append(texts, c.CommandText)
+	}
+	assert.Equal(t, []string{"whole", "early", "late"}, texts)
+	assert.Equal(t, second*int64(time.Second), commands[0].TimestampNs, "no sub-second time means the whole second")
+
+	forContext, err := database.GetCommandsForContext(second, second+1, "/home/test", "", "")
+	require.NoError(t, err)
+	require.Len(t, forContext, 3)
+	assert.Equal(t, "early", forContext[1].CommandText)
+}
+
+// TestGetCommandsForContext_NullAndEmptyBranchMatch verifies that NULL and empty-string
+// git branches are treated as the same context when loading detail commands
+func TestGetCommandsForContext_NullAndEmptyBranchMatch(t *testing.T) {
+	database, err := NewForTesting(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer database.Close()
+
+	repo := "github.com/chris/shy"
+	empty := ""
+	main := "main"
+	commands := []*models.Command{
+		{CommandText: "null branch 1", WorkingDir: "/home/test/shy", GitRepo: &repo, Timestamp: 1000},
+		{CommandText: "empty branch 1", WorkingDir: "/home/test/shy", GitRepo: &repo, GitBranch: &empty, Timestamp: 1001},
+		{CommandText: "null branch 2", WorkingDir: "/home/test/shy", GitRepo: &repo, Timestamp: 1002},
+		{CommandText: "empty branch 2", WorkingDir: "/home/test/shy", GitRepo: &repo, GitBranch: &empty, Timestamp: 1003},
+		{CommandText: "on main", WorkingDir: "/home/test/shy", GitRepo: &repo, GitBranch: &main, Timestamp: 1004},
+		{CommandText: "other dir", WorkingDir: "/home/test/other", GitRepo: &repo, Timestamp: 1005},
+	}
+	for _, cmd := range commands {
+		_, err := database.InsertCommand(cmd)
+		require.NoError(t, err)
+	}
+
+	noBranch, err := database.GetCommandsForContext(0, 2000, "/home/test/shy", repo, "")
+	require.NoError(t, err)
+	require.Len(t, noBranch, 4)
+	assert.Equal(t, "null branch 1", noBranch[0].CommandText)
+	assert.Equal(t, "empty branch 1", noBranch[1].CommandText)
+	assert.Equal(t, "null branch 2", noBranch[2].CommandText)
+	assert.Equal(t, "empty branch 2", noBranch[3].CommandText)
+
+	onMain, err := database.GetCommandsForContext(0, 2000, "/home/test/shy", repo, "main")
+	require.NoError(t, err)
+	require.Len(t, onMain, 1)
+	assert.Equal(t, "on main", onMain[0].CommandText)
+
+	// The repo is part of the context: a different repo value does not match
+	otherRepo, err := database.GetCommandsForContext(0, 2000, "/home/test/other", "", "")
+	require.NoError(t, err)
+	assert.Len(t, otherRepo, 0)
+}
+
+// TestGetCommandsForContextPaged verifies that pages walk a context in
+// timestamp order, including ties, and that mode and filter span all pages
+func TestGetCommandsForContextPaged(t *testing.T) {
+	database, err := NewForTesting(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer database.Close()
+
+	repo := "github.com/chris/shy"
+	main := "main"
+	var commands []*models.Command
+	for i := 0; i < 7; i++ {
+		// Pairs share a timestamp so pages split between equal timestamps
+		duration := int64(i * 1000)
+		commands = append(commands, &models.Command{CommandText: fmt.Sprintf("cmd %d", i), WorkingDir: "/home/test/shy", GitRepo: &repo, GitBranch: &main, Timestamp: int64(1000 + i/2), Duration: &duration})
+	}
+	commands = append(commands,
+		&models.Command{CommandText: "cmd 0", WorkingDir: "/home/test/shy", GitRepo: &repo, GitBranch: &main, Timestamp: 1010},
+		&models.Command{CommandText: "worktree", WorkingDir: "/home/test/shy-wt", GitRepo: &repo, GitBranch: &main, Timestamp: 1011},
+	)
+	_, err = database.InsertCommands(commands)
+	require.NoError(t, err)
+
+	pageAll := func(opts ContextPageOptions) []string {
+		var texts []string
+		for {
+			page, err := database.GetCommandsForContextPaged(opts)
+			require.NoError(t, err)
+			for _, c := range page {
+				texts = append(texts, c.CommandText)
+			}
+			if len(page) < opts.Limit {
+				return texts
+			}
+			last := page[len(page)-1]
+			opts.AfterTimestamp, opts.AfterTimestampNs, opts.AfterID = last.Timestamp, last.TimestampNs, last.ID
+		}
+	}
+	opts := ContextPageOptions{StartTime: 0, EndTime: 2000, WorkingDir: "/home/test/shy", GitRepo: repo, GitBranch: "main", Limit: 3}
+
+	assert.Equal(t, []string{"cmd 0", "cmd 1", "cmd 2", "cmd 3", "cmd 4", "cmd 5", "cmd 6", "cmd 0"}, pageAll(opts))
+
+	first, err := database.GetCommandsForContextPaged(opts)
+	require.NoError(t, err)
+	assert.Len(t, first, 3)
+
+	// Unique mode drops cmd 0 even though its repeat is on a later page
+	unique := opts
+	unique.Mode = UniqueMode
+	assert.Equal(t, []string{"cmd 1", "cmd 2", "cmd 3", "cmd 4", "cmd 5", "cmd 6"}, pageAll(unique))
+
+	filtered := opts
+	filtered.Filter = "cmd 0"
+	filtered.Exclude = "worktree"
+	assert.Equal(t, []string{"cmd 0", "cmd 0"}, pageAll(filtered))
+
+	// Durations are in milliseconds; the repeat of cmd 0 has none and is dropped
+	slow := opts
+	slow.MinDuration = 3000
+	assert.Equal(t, []string{"cmd 3", "cmd 4", "cmd 5", "cmd 6"}, pageAll(slow))
+
+	// Uniqueness is still judged over every duration
+	slow.Mode = UniqueMode
+	slow.MinDuration = 0
+	assert.NotContains(t, pageAll(slow), "cmd 0")
+	slow.MinDuration = 1
+	assert.Equal(t, []string{"cmd 1", "cmd 2", "cmd 3", "cmd 4", "cmd 5", "cmd 6"}, pageAll(slow))
+
+	across := opts
+	across.AcrossWorktrees = true
+	assert.Contains(t, pageAll(across), "worktree")
+}
+
+// TestPeekContextCount verifies the context count under each display mode and filter
+func TestPeekContextCount(t *testing.T) {
+	database, err := NewForTesting(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer database.Close()
+
+	repo := "github.com/chris/shy"
+	main := "main"
+	commands := []*models.Command{
+		{CommandText: "git status", WorkingDir: "/home/test/shy", GitRepo: &repo, GitBranch: &main, Timestamp: 1000},
+		{CommandText: "git status", WorkingDir: "/home/test/shy", GitRepo: &repo, GitBranch: &main, Timestamp: 1001},
+		{CommandText: "git push", WorkingDir: "/home/test/shy", GitRepo: &repo, GitBranch: &main, Timestamp: 1002},
+		{CommandText: "make build", WorkingDir: "/home/test/shy", GitRepo: &repo, GitBranch: &main, Timestamp: 1003},
+		{CommandText: "Git log", WorkingDir: "/home/test/shy", GitRepo: &repo, GitBranch: &main, Timestamp: 1004},
+		{CommandText: "git outside", WorkingDir: "/home/test/shy", GitRepo: &repo, GitBranch: &main, Timestamp: 3000},
+		{CommandText: "git branchless", WorkingDir: "/home/test/shy", GitRepo: &repo, Timestamp: 1005},
+		{CommandText: "git other dir", WorkingDir: "/home/test/other", GitRepo: &repo, GitBranch: &main, Timestamp: 1006},
+	}
+	for _, cmd := range commands {
+		_, err := database.InsertCommand(cmd)
+		require.NoError(t, err)
+	}
+
+	tests := []struct {
+		name   string
+		branch *string
+		mode   DisplayMode
+		filter string
+		want   int
+	}{
+		{"all commands", &main, AllMode, "", 5},
+		{"unique commands", &main, UniqueMode, "", 3},
+		{"filter is case-sensitive", &main, AllMode, "git", 3},
+		{"unique after filter", &main, UniqueMode, "git", 1},
+		{"no match", &main, AllMode, "cargo", 0},
+		{"quoted filter is exact", &main, AllMode, `"git"`, 0},
+		{"quoted filter matches whole text", &main, AllMode, `"git status"`, 2},
+		{"nil branch selects branchless", nil, AllMode, "", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count, err := database.PeekContextCount("/home/test/shy", repo, tt.branch, 0, 2000, tt.mode, tt.filter, "")
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, count)
+		})
+	}
+}
+
+// TestPeekContextCountExclude verifies that exclude globs remove commands before counting
+func TestPeekContextCountExclude(t *testing.T) {
+	database, err := NewForTesting(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer database.Close()
+
+	for i, text := range []string{"ls -la", "ls", "cd src", "git status", "git status", "echo [x]", "lsof"} {
+		_, err := database.InsertCommand(&models.Command{CommandText: text, WorkingDir: "/home/test", Timestamp: int64(1000 + i)})
+		require.NoError(t, err)
+	}
+
+	tests := []struct {
+		name    string
+		mode    DisplayMode
+		filter  string
+		exclude string
+		want    int
+	}{
+		{"no exclude", AllMode, "", "", 7},
+		{"prefix glob", AllMode, "", "ls*", 4},
+		{"whole-text match", AllMode, "", "ls", 6},
+		{"multiple patterns", AllMode, "", "ls*|cd *", 3},
+		{"brackets are literal", AllMode, "", "echo [x]", 6},
+		{"question mark", AllMode, "", "l?of", 6},
+		{"composes with filter", AllMode, "s", "ls*", 3},
+		{"unique after exclude", UniqueMode, "", "ls*|cd*", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count, err := database.PeekContextCount("/home/test", "", nil, 0, 2000, tt.mode, tt.filter, tt.exclude)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, count)
+		})
+	}
+}
+
+// TestPeekBranchCount verifies that a branch counts across its worktree directories
+func TestPeekBranchCount(t *testing.T) {
+	database, err := NewForTesting(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer database.Close()
+
+	repo := "github.com/chris/shy"
+	main := "main"
+	feature := "feature"
+	commands := []*models.Command{
+		{CommandText: "make build", WorkingDir: "/home/test/shy", GitRepo: &repo, GitBranch: &main, Timestamp: 1000},
+		{CommandText: "make build", WorkingDir: "/home/test/shy-wt", GitRepo: &repo, GitBranch: &main, Timestamp: 1001},
+		{CommandText: "make test", WorkingDir: "/home/test/shy-wt", GitRepo: &repo, GitBranch: &main, Timestamp: 1002},
+		{CommandText: "git push", WorkingDir: "/home/test/shy-wt", GitRepo: &repo, GitBranch: &feature, Timestamp: 1003},
+	}
+	for _, cmd := range commands {
+		_, err := database.InsertCommand(cmd)
+		require.NoError(t, err)
+	}
+
+	count, err := database.PeekBranchCount(repo, &main, 0, 2000, AllMode, "", "")
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	count, err = database.PeekBranchCount(repo, &main, 0, 2000, UniqueMode, "", "")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	count, err = database.PeekContextCount("/home/test/shy", repo, &main, 0, 2000, AllMode, "", "")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestInsertCommands(t *testing.T) {
+	database, err := NewForTesting(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer database.Close()
+
+	repo := "github.com/chris/shy"
+	commands := []*models.Command{
+		{CommandText: "make build", WorkingDir: "/home/test/shy", GitRepo: &repo, Timestamp: 1000},
+		{CommandText: "make build", WorkingDir: "/home/test/shy", GitRepo: &repo, Timestamp: 1001},
+		{CommandText: "ls", WorkingDir: "/tmp", Timestamp: 1002},
+	}
+
+	ids, err := database.InsertCommands(commands)
+	require.NoError(t, err)
+	require.Len(t, ids, 3)
+	assert.Less(t, ids[0], ids[1])
+	assert.Less(t, ids[1], ids[2])
+
+	stored, err := database.GetCommandsByDateRange(0, 2000, nil)
+	require.NoError(t, err)
+	require.Len(t, stored, 3)
+	assert.Equal(t, "/home/test/shy", stored[0].WorkingDir)
+	require.NotNil(t, stored[0].GitRepo)
+	assert.Equal(t, repo, *stored[0].GitRepo)
+
+	// The older duplicate is marked, as with InsertCommand
+	var isDuplicate int
+	require.NoError(t, database.conn.QueryRow("SELECT is_duplicate FROM commands WHERE id = ?", ids[0]).Scan(&isDuplicate))
+	assert.Equal(t, 1, isDuplicate)
+}
+
+func TestGetHistoryTimeRange(t *testing.T) {
+	database, err := NewForTesting(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer database.Close()
+
+	first, last, err := database.GetHistoryTimeRange()
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), first)
+	assert.Equal(t, int64(0), last)
+
+	// Insert out of timestamp order; the range comes from timestamps, not IDs
+	for _, ts := range []int64{1704470500, 1704470400, 1704470900, 1704470600} {
+		_, err := database.InsertCommand(&models.Command{CommandText: "ls", WorkingDir: "/home/test", Timestamp: ts})
+		require.NoError(t, err)
+	}
+
+	first, last, err = database.GetHistoryTimeRange()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1704470400), first)
+	assert.Equal(t, int64(1704470900), last)
+}
+
+func TestGetSessionGaps(t *testing.T) {
+	database, err := NewForTesting(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer database.Close()
+
+	app := "zsh"
+	pid, otherPid := int64(12345), int64(67890)
+	insert := func(ts int64, sessionPid *int64) int64 {
+		id, err := database.InsertCommand(&models.Command{
+			CommandText: "ls", WorkingDir: "/home/test", Timestamp: ts, SourceApp: &app, SourcePid: sessionPid,
+		})
+		require.NoError(t, err)
+		return id
+	}
+
+	base := int64(1704470400)
+	first := insert(base, &pid)
+	second := insert(base+60, &pid)
+	insert(base+1000, &otherPid) // another session doesn't split the gap
+	third := insert(base+60+45*60, &pid)
+	insert(base+60+45*60+600, &pid) // 10m: under the threshold
+
+	gaps, err := database.GetSessionGaps(pid, 15*60)
+	require.NoError(t, err)
+	require.Len(t, gaps, 1)
+	assert.Equal(t, Gap{BeforeID: second, AfterID: third, Start: base + 60, End: base + 60 + 45*60}, gaps[0])
+	assert.Equal(t, 45*time.Minute, gaps[0].Duration())
+
+	gaps, err = database.GetSessionGaps(pid, 30)
+	require.NoError(t, err)
+	require.Len(t, gaps, 3)
+	assert.Equal(t, first, gaps[0].BeforeID)
+}
+
+func TestGetCommandsWithContextAcrossSessions(t *testing.T) {
+	database, err := NewForTesting(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer database.Close()
+
+	// Legacy commands without a pid, from two terminals interleaved by id
+	var ids []int64
+	for i, dir := range []string{"/shy", "/blog", "/shy", "/blog", "/shy", "/blog", "/shy"} {
+		id, err := database.InsertCommand(&models.Command{
+			CommandText: fmt.Sprintf("cmd %d", i), WorkingDir: dir, Timestamp: int64(1000 + i),
+		})
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+
+	target, err := database.GetCommand(ids[4])
+	require.NoError(t, err)
+	require.Nil(t, target.SourcePid)
+
+	before, after, err := database.GetCommandsWithContextAcrossSessions(target, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{ids[0], ids[2]}, commandIDs(before))
+	assert.Equal(t, []int64{ids[6]}, commandIDs(after))
+	for _, c := range append(before, after...) {
+		assert.Equal(t, "/shy", c.WorkingDir, "neighbors don't cross directories")
+	}
+
+	target, err = database.GetCommand(ids[1])
+	require.NoError(t, err)
+	before, after, err = database.GetCommandsWithContextAcrossSessions(target, 5)
+	require.NoError(t, err)
+	assert.Empty(t, before)
+	assert.Equal(t, []int64{ids[3], ids[5]}, commandIDs(after))
+}
+
+func TestUpdateCommandText(t *testing.T) {
+	database, err := NewForTesting(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer database.Close()
+
+	var ids []int64
+	for i, text := range []string{"gti status", "git status", "gti status"} {
+		id, err := database.InsertCommand(&models.Command{CommandText: text, WorkingDir: "/home/test", Timestamp: int64(1704470400 + i)})
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+
+	require.NoError(t, database.UpdateCommandText(ids[2], "git status"))
+
+	cmd, err := database.GetCommand(ids[2])
+	require.NoError(t, err)
+	assert.Equal(t, "git status", cmd.CommandText)
+	assert.Equal(t, "/home/test", cmd.WorkingDir)
+
+	duplicates := map[int64]bool{}
+	rows, err := database.conn.Query("SELECT id, is_duplicate FROM commands")
+	require.NoError(t, err)
+	for rows.Next() {
+		var id int64
+		var dup bool
+		require.NoError(t, rows.Scan(&id, &dup))
+		duplicates[id] = dup
+	}
+	require.NoError(t, rows.Close())
+	assert.False(t, duplicates[ids[0]], "remaining typo row is promoted to canonical")
+	assert.True(t, duplicates[ids[1]], "older row with the new text becomes a duplicate")
+	assert.False(t, duplicates[ids[2]], "updated row is canonical for its new text")
+
+	assert.ErrorContains(t, database.UpdateCommandText(999, "ls"), "command 999 not found")
+}
+
+func TestNormalizeCommandText(t *testing.T) {
+	assert.Equal(t, "git status", normalizeCommandText("  git   status \t"))
+	assert.Equal(t, `echo "a  b" 'c  d'`, normalizeCommandText(`echo  "a  b"   'c  d'`), "quoted whitespace is kept")
+	assert.Equal(t, `echo "\"  x"`, normalizeCommandText(`echo "\"  x"`), "an escaped quote does not end the string")
+	assert.Equal(t, `touch a\  b`, normalizeCommandText(`touch a\   b`), "an escaped space is kept")
+}
+
+func TestFindNearDuplicates(t *testing.T) {
+	database, err := NewForTesting(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer database.Close()
+
+	var ids []int64
+	for i, text := range []string{"git status", "git  status", "ls", "git status ", "git  status", "ls -la", `echo "a  b"`, `echo "a b"`} {
+		id, err := database.InsertCommand(&models.Command{CommandText: text, WorkingDir: "/home/test", Timestamp: int64(1704470400 + i)})
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+
+	clusters, err := database.FindNearDuplicates()
+	require.NoError(t, err)
+	require.Len(t, clusters, 1, "quoted whitespace is not a near-duplicate")
+	cluster := clusters[0]
+	assert.Equal(t, "git status", cluster.Normalized)
+	assert.Equal(t, []NearDuplicateText{{"git  status", 2}, {"git status", 1}, {"git status ", 1}}, cluster.Texts)
+	assert.Equal(t, 4, cluster.Total())
+	assert.Equal(t, 3, cluster.Rewrites())
+
+	rewritten, err := database.NormalizeNearDuplicates(clusters)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), rewritten)
+
+	clusters, err = database.FindNearDuplicates()
+	require.NoError(t, err)
+	assert.Empty(t, clusters)
+
+	var canonical []int64
+	rows, err := database.conn.Query("SELECT id FROM commands WHERE command_text = 'git status' AND is_duplicate = 0")
+	require.NoError(t, err)
+	for rows.Next() {
+		var id int64
+		require.NoError(t, rows.Scan(&id))
+		canonical = append(canonical, id)
+	}
+	require.NoError(t, rows.Close())
+	assert.Equal(t, []int64{ids[4]}, canonical, "the newest rewritten row is the one canonical")
+}
+
+func TestReadOnlyURI(t *testing.T) {
+	assert.Equal(t, "file:///data/history.db?mode=ro", ReadOnlyURI("/data/history.db"))
+	assert.Equal(t, "file:///data/a%3Fb%23c.db?mode=ro", ReadOnlyURI("/data/a?b#c.db"),
+		"? and # are part of the file name, not the query")
+
+	// Archives and read-only databases open at such paths
+	primaryPath, archivePath := setupArchivePair(t)
+	oddPath := filepath.Join(filepath.Dir(archivePath), "archive?v#1.db")
+	require.NoError(t, os.Rename(archivePath, oddPath))
+	database, err := NewWithOptions(primaryPath, Options{ArchivePath: oddPath})
+	require.NoError(t, err)
+	defer database.Close()
+	count, err := database.CountCommands()
+	require.NoError(t, err)
+	assert.Equal(t, 6, count)
+
+	readOnly, err := NewWithOptions(oddPath, Options{ReadOnly: true})
+	require.NoError(t, err)
+	defer readOnly.Close()
+	count, err = readOnly.CountCommands()
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+}
+
+func TestReadOnlyRejectsWrites(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := NewForTesting(dbPath)
+	require.NoError(t, err)
+	id, err := database.InsertCommand(&models.Command{CommandText: "ls", WorkingDir: "/home/test", Timestamp: 1704470400})
+	require.NoError(t, err)
+	database.Close()
+
+	readOnly, err := NewWithOptions(dbPath, Options{ReadOnly: true})
+	require.NoError(t, err)
+	defer readOnly.Close()
+	assert.True(t, readOnly.ReadOnly())
+
+	commands, err := readOnly.GetCommandsByDateRange(1704470000, 1704471000, nil)
+	require.NoError(t, err)
+	require.Len(t, commands, 1)
+	assert.Equal(t, "ls", commands[0].CommandText)
+
+	_, err = readOnly.InsertCommand(&models.Command{CommandText: "pwd", WorkingDir: "/home/test", Timestamp: 1704470500})
+	assert.ErrorIs(t, err, ErrReadOnly)
+	_, err = readOnly.DeleteCommands([]int64{id})
+	assert.ErrorIs(t, err, ErrReadOnly)
+	_, err = readOnly.ToggleStar(id)
+	assert.ErrorIs(t, err, ErrReadOnly)
+	assert.ErrorIs(t, readOnly.SetContextNote("/home/test", "", "", "note"), ErrReadOnly)
+	assert.ErrorIs(t, readOnly.UpdateCommandText(id, "pwd"), ErrReadOnly)
+
+	count, err := readOnly.CountCommands()
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestContextNote(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := NewForTesting(dbPath)
+	require.NoError(t, err)
+	defer database.Close()
+
+	// No note yet
+	note, err := database.GetContextNote("/home/user/shy", "github.com/chris/shy", "main")
+	require.NoError(t, err)
+	assert.Equal(t, "", note)
+
+	// Set, then replace
+	require.NoError(t, database.SetContextNote("/home/user/shy", "github.com/chris/shy", "main", "bump version"))
+	require.NoError(t, database.SetContextNote("/home/user/shy", "github.com/chris/shy", "main", "bump version before release"))
+
+	note, err = database.GetContextNote("/home/user/shy", "github.com/chris/shy", "main")
+	require.NoError(t, err)
+	assert.Equal(t, "bump version before release", note)
+
+	// Other branches of the same repo are separate contexts
+	note, err = database.GetContextNote("/home/user/shy", "github.com/chris/shy", "feature")
+	require.NoError(t, err)
+	assert.Equal(t, "", note)
+
+	// Empty note removes it
+	require.NoError(t, database.SetContextNote("/home/user/shy", "github.com/chris/shy", "main", "  "))
+	note, err = database.GetContextNote("/home/user/shy", "github.com/chris/shy", "main")
+	require.NoError(t, err)
+	assert.Equal(t, "", note)
+}
+
+func TestGetContextNotes(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := NewForTesting(dbPath)
+	require.NoError(t, err)
+	defer database.Close()
+
+	require.NoError(t, database.SetContextNote("/home/user/shy", "github.com/chris/shy", "main", "release notes"))
+	require.NoError(t, database.SetContextNote("/tmp", "", "", "scratch space"))
+
+	notes, err := database.GetContextNotes()
+	require.NoError(t, err)
+	assert.Equal(t, map[ContextNoteKey]string{
+		{WorkingDir: "/home/user/shy", GitRepo: "github.com/chris/shy", GitBranch: "main"}: "release notes",
+		{WorkingDir: "/tmp"}: "scratch space",
+	}, notes)
+}
+
+func TestTagContext(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := NewForTesting(dbPath)
+	require.NoError(t, err)
+	defer database.Close()
+
+	repo := "github.com/chris/shy"
+	main := "main"
+	feature := "feature"
+	var ids []int64
+	for _, c := range []*models.Command{
+		{CommandText: "make", WorkingDir: "/home/test/shy", GitRepo: &repo, GitBranch: &main, Timestamp: 1000},
+		{CommandText: "make test", WorkingDir: "/home/test/shy", GitRepo: &repo, GitBranch: &main, Timestamp: 1001},
+		{CommandText: "git push", WorkingDir: "/home/test/shy", GitRepo: &repo, GitBranch: &feature, Timestamp: 1002},
+		{CommandText: "make", WorkingDir: "/home/test/shy", GitRepo: &repo, GitBranch: &main, Timestamp: 3000},
+		{CommandText: "ls", WorkingDir: "/tmp", Timestamp: 1003},
+	} {
+		id, err := database.InsertCommand(c)
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+	tags := func(id int64) []string {
+		got, err := database.GetCommandTags(id)
+		require.NoError(t, err)
+		return got
+	}
+
+	n, err := database.TagContext("/home/test/shy", repo, "main", 0, 2000, "release-prep")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	assert.Equal(t, []string{"release-prep"}, tags(ids[0]))
+	assert.Equal(t, []string{"release-prep"}, tags(ids[1]))
+	assert.Empty(t, tags(ids[2]), "other branch")
+	assert.Empty(t, tags(ids[3]), "outside the range")
+
+	// Tagging again adds nothing; a second tag sorts alongside the first
+	n, err = database.TagContext("/home/test/shy", repo, "main", 0, 2000, "release-prep")
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	_, err = database.TagContext("/home/test/shy", repo, "main", 0, 1001, "build")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"build", "release-prep"}, tags(ids[0]))
+
+	// A context without git matches on empty repo and branch
+	n, err = database.TagContext("/tmp", "", "", 0, 2000, "scratch")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	n, err = database.UntagContext("/home/test/shy", repo, "main", 0, 2000, "release-prep")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	assert.Equal(t, []string{"build"}, tags(ids[0]))
+	assert.Empty(t, tags(ids[1]))
+
+	// Deleting a command drops its tags
+	_, err = database.DeleteCommands([]int64{ids[0]})
+	require.NoError(t, err)
+	assert.Empty(t, tags(ids[0]))
+}
+
+func TestCountCommandsByRange(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := NewForTesting(dbPath)
+	require.NoError(t, err)
+	defer database.Close()
+
+	app := "zsh"
+	pid := int64(111)
+	otherPid := int64(222)
+	active := true
+	for i, c := range []struct {
+		text     string
+		pid      *int64
+		duration int64
+	}{
+		{"git status", &pid, 6000},
+		{"git status", &pid, 500},
+		{"make", &otherPid, 12000},
+		{"git log", &otherPid, 0},
+	} {
+		_, err := database.InsertCommand(&models.Command{
+			CommandText:  c.text,
+			WorkingDir:   "/home/test",
+			Timestamp:    int64(1000 + i),
+			Duration:     &c.duration,
+			SourceApp:    &app,
+			SourcePid:    c.pid,
+			SourceActive: &active,
+		})
+		require.NoError(t, err)
+	}
+
+	cases := []struct {
+		name        string
+		first, last int64
+		pattern     string
+		dir         string
+		pid         int64
+		minDuration int64
+		want        int
+	}{
+		{"unfiltered", 1, 4, "", "", 0, 0, 4},
+		{"pattern is deduplicated", 1, 4, "git%", "", 0, 0, 2},
+		{"session", 1, 4, "", "", pid, 0, 1},
+		{"session and pattern", 1, 4, "git%", "", otherPid, 0, 1},
+		{"range", 2, 3, "", "", 0, 0, 2},
+		{"inverted range", 3, 2, "", "", 0, 0, 0},
+		{"min duration", 1, 4, "", "", 0, 5000, 2},
+		{"min duration is inclusive", 1, 4, "", "", 0, 12000, 1},
+		{"min duration and pattern", 1, 4, "git%", "", 0, 1000, 1},
+		{"directory is deduplicated", 1, 4, "", "/home/test", 0, 0, 3},
+		{"other directory", 1, 4, "", "/tmp", 0, 0, 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			count, err := database.CountCommandsByRange(tc.first, tc.last, RangeFilter{
+				Pattern:     tc.pattern,
+				WorkingDir:  tc.dir,
+				SessionPid:  tc.pid,
+				MinDuration: tc.minDuration,
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, count)
+		})
+	}
+
+	count, err := database.CountCommandsByRange(1, 4, RangeFilter{SourceApp: app})
+	require.NoError(t, err)
+	assert.Equal(t, 3, count, "source app is deduplicated")
+	count, err = database.CountCommandsByRange(1, 4, RangeFilter{SourceApp: app, SessionPid: otherPid})
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	count, err = database.CountCommandsByRange(1, 4, RangeFilter{SourceApp: "bash"})
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func TestGetCommandsByRangeOrdered(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := NewForTesting(dbPath)
+	require.NoError(t, err)
+	defer database.Close()
+
+	for i, c := range []struct{ text, dir string }{
+		{"git status", "/home/test"},
+		{"make", "/home/test"},
+		{"git status", "/home/test"},
+		{"git log", "/tmp"},
+	} {
+		_, err := database.InsertCommand(&models.Command{
+			CommandText: c.text,
+			WorkingDir:  c.dir,
+			Timestamp:   int64(1000 + i),
+		})
+		require.NoError(t, err)
+	}
+
+	ids := func(cmds []models.Command) []int64 {
+		var out []int64
+		for _, c := range cmds {
+			out = append(out, c.ID)
+		}
+		return out
+	}
+
+	asc, err := database.GetCommandsByRangeOrdered(1, 4, RangeFilter{}, Ascending)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 3, 4}, ids(asc))
+
+	desc, err := database.GetCommandsByRangeOrdered(1, 4, RangeFilter{}, Descending)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{4, 3, 2, 1}, ids(desc))
+
+	// Pattern queries keep max(id) per command text in either order
+	desc, err = database.GetCommandsByRangeOrdered(1, 4, RangeFilter{Pattern: "git%"}, Descending)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{4, 3}, ids(desc))
+
+	// A directory composes with the pattern
+	local, err := database.GetCommandsByRangeOrdered(1, 4, RangeFilter{Pattern: "git%", WorkingDir: "/home/test"}, Ascending)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{3}, ids(local))
+}
+
+func TestReassignSession(t *testing.T) {
+	tempDir := t.TempDir()
+	dbPath := filepath.Join(tempDir, "test.db")
+	database, err := NewForTesting(dbPath)
+	require.NoError(t, err)
+	defer database.Close()
+
+	zsh, bash := "zsh", "bash"
+	active, closed := true, false
+	insert := func(text string, app *string, pid int64, isActive *bool, ts int64) int64 {
+		cmd := models.NewCommand(text, "/home/test", 0)
+		cmd.Timestamp = ts
+		cmd.SourceApp = app
+		cmd.SourcePid = &pid
+		cmd.SourceActive = isActive
+		id, err := database.InsertCommand(cmd)
+		require.NoError(t, err)
+		return id
+	}
+	old := insert("old session", &zsh, 100, &closed, 1000)
+	reusedZsh := insert("reused zsh", &zsh, 100, &active, 2000)
+	reusedBash := insert("reused bash", &bash, 100, &active, 2500)
+	other := insert("other session", &zsh, 200, &active, 3000)
+
+	count, err := database.CountSessionCommands(100, 2000)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	moved, err := database.ReassignSession(100, 300, 2000)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), moved)
+
+	pidOf := func(id int64) int64 {
+		cmd, err := database.GetCommand(id)
+		require.NoError(t, err)
+		require.NotNil(t, cmd.SourcePid)
+		return *cmd.SourcePid
+	}
+	assert.Equal(t, int64(100), pidOf(old), "commands before --since stay")
+	assert.Equal(t, int64(300), pidOf(reusedZsh))
+	assert.Equal(t, int64(300), pidOf(reusedBash))
+	assert.Equal(t, int64(200), pidOf(other), "other sessions are untouched")
+
+	// App and active flag follow each command
+	cmd, err := database.GetCommand(reusedBash)
+	require.NoError(t, err)
+	assert.Equal(t, "bash", *cmd.SourceApp)
+	assert.True(t, *cmd.SourceActive)
+
+	// The emptied (zsh, 100, active) and (bash, 100, active) sources are gone
+	var sources int
+	require.NoError(t, database.conn.QueryRow("SELECT COUNT(*) FROM sources WHERE pid = 100").Scan(&sources))
+	assert.Equal(t, 1, sources)
+
+	moved, err = database.ReassignSession(100, 300, 2000)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), moved)
+}
+
+func TestReindex(t *testing.T) {
+	tempDir := t.TempDir()
+	dbPath := filepath.Join(tempDir, "test.db")
+	database, err := NewForTesting(dbPath)
+	require.NoError(t, err)
+	defer database.Close()
+
+	_, err = database.InsertCommand(models.NewCommand("ls", "/home/test", 0))
+	require.NoError(t, err)
+	_, err = database.conn.Exec("DROP INDEX idx_command_text_id")
+	require.NoError(t, err)
+
+	results, err := database.Reindex()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ok"}, results)
+
+	indexes, err := database.ListIndexes()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, migrations.IndexNames(), indexes)
+
+	// The partial index keeps its WHERE clause
+	var indexSQL string
+	require.NoError(t, database.conn.QueryRow("SELECT sql FROM sqlite_master WHERE name = 'idx_not_duplicate'").Scan(&indexSQL))
+	assert.Contains(t, indexSQL, "WHERE is_duplicate = 0")
+
+	count, err := database.CountCommands()
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestReindexReadOnly(t *testing.T) {
+	tempDir := t.TempDir()
+	dbPath := filepath.Join(tempDir, "test.db")
+	database, err := NewForTesting(dbPath)
+	require.NoError(t, err)
+	database.Close()
+
+	database, err = NewWithOptions(dbPath, Options{ReadOnly: true})
+	require.NoError(t, err)
+	defer database.Close()
+	_, err = database.Reindex()
+	assert.ErrorIs(t, err, ErrReadOnly)
+}
+
+func TestGetStats(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := NewForTesting(dbPath)
+	require.NoError(t, err)
+	defer database.Close()
+
+	stats, err := database.GetStats(5)
+	require.NoError(t, err)
+	assert.Equal(t, 0, stats.Commands)
+	assert.NotNil(t, stats.TopCommands)
+	assert.NotNil(t, stats.TopDirectories)
+
+	app := "zsh"
+	pid := int64(111)
+	for i, c := range []struct {
+		text, dir string
+		exit      int
+	}{
+		{"make", "/b", 1},
+		{"git status", "/a", 0},
+		{"make", "/a", 0},
+		{"ls", "/b", 0},
+	} {
+		_, err := database.InsertCommand(&models.Command{
+			CommandText: c.text,
+			WorkingDir:  c.dir,
+			ExitStatus:  c.exit,
+			Timestamp:   int64(1000 + i),
+			SourceApp:   &app,
+			SourcePid:   &pid,
+		})
+		require.NoError(t, err)
+	}
+
+	stats, err = database.GetStats(2)
+	require.NoError(t, err)
+	assert.Equal(t, 4, stats.Commands)
+	assert.Equal(t, 3, stats.UniqueCommands)
+	assert.Equal(t, 1, stats.Failed)
+	assert.Equal(t, 1, stats.Sessions)
+	assert.Equal(t, 2, stats.Directories)
+	assert.Equal(t, int64(1000), stats.FirstTimestamp)
+	assert.Equal(t, int64(1003), stats.LastTimestamp)
+	// Ties are broken alphabetically and the lists stop at the limit
+	assert.Equal(t, []TextCount{{"make", 2}, {"git status", 1}}, stats.TopCommands)
+	assert.Equal(t, []TextCount{{"/a", 2}, {"/b", 2}}, stats.TopDirectories)
+	total := 0
+	for _, n := range stats.HourCounts {
+		total += n
+	}
+	assert.Equal(t, 4, total)
+}
+
+func TestLikeRecentPrefersBranch(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := NewForTesting(dbPath)
+	require.NoError(t, err)
+	defer database.Close()
+
+	repo := "github.com/chris/shy"
+	feature, main := "feature", "main"
+	app := "zsh"
+	mine, other := int64(111), int64(222)
+	active := true
+	for i, c := range []struct {
+		text   string
+		branch *string
+		pid    int64
+	}{
+		{"make mine-main", &main, mine},
+		{"make other-main", &main, other},
+		{"make mine-feature", &feature, mine},
+		{"make other-nobranch", nil, other},
+	} {
+		_, err := database.InsertCommand(&models.Command{
+			CommandText:  c.text,
+			WorkingDir:   "/home/test",
+			Timestamp:    int64(1000 + i),
+			GitRepo:      &repo,
+			GitBranch:    c.branch,
+			SourceApp:    &app,
+			SourcePid:    &c.pid,
+			SourceActive: &active,
+		})
+		require.NoError(t, err)
+	}
+
+	cases := []struct {
+		name string
+		opts LikeRecentOptions
+		want string
+	}{
+		{"whole history", LikeRecentOptions{}, "make other-nobranch"},
+		{"branch beats history", LikeRecentOptions{GitRepo: repo, GitBranch: main}, "make other-main"},
+		{"session on branch beats session", LikeRecentOptions{GitRepo: repo, GitBranch: main, SourceApp: app, SourcePid: mine}, "make mine-main"},
+		{"session beats branch", LikeRecentOptions{GitRepo: repo, GitBranch: "gone", SourceApp: app, SourcePid: mine}, "make mine-feature"},
+		{"branch of another repo", LikeRecentOptions{GitRepo: "github.com/other/repo", GitBranch: main}, "make other-nobranch"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.opts.Prefix = "make"
+			got, err := database.LikeRecent(tc.opts)
+			require.NoError(t, err)
+			assert.Equal(t, []string{tc.want}, got)
+		})
+	}
+}
+
+func TestHasCommand(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := NewForTesting(dbPath)
+	require.NoError(t, err)
+	defer database.Close()
+
+	c := models.NewCommand("git status", "/a", 0)
+	c.Timestamp = 1000
+	_, err = database.InsertCommand(c)
+	require.NoError(t, err)
+
+	exists, err := database.HasCommand(1000, "git status", "/a")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	for _, other := range []struct {
+		timestamp int64
+		text, dir string
+	}{
+		{1001, "git status", "/a"},
+		{1000, "git diff", "/a"},
+		{1000, "git status", "/b"},
+	} {
+		exists, err := database.HasCommand(other.timestamp, other.text, other.dir)
+		require.NoError(t, err)
+		assert.False(t, exists, "%+v", other)
+	}
+}
+
+func TestGetCommandCountsByHour(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := NewForTesting(dbPath)
+	require.NoError(t, err)
+	defer database.Close()
+
+	start := int64(1770000000)
+	for _, ts := range []int64{start - 1, start, start + 59, start + 3600, start + 3*3600 + 1, start + 4*3600} {
+		c := models.NewCommand("ls", "/a", 0)
+		c.Timestamp = ts
+		_, err := database.InsertCommand(c)
+		require.NoError(t, err)
+	}
+
+	// The range is four hours; commands before it or at its end are left out
+	counts, err := database.GetCommandCountsByHour(start, start+4*3600)
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 1, 0, 1}, counts)
+}
+
+func TestFindPrevFailureDay(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := NewForTesting(dbPath)
+	require.NoError(t, err)
+	defer database.Close()
+
+	repo, main := "github.com/chris/shy", "main"
+	for _, c := range []struct {
+		ts   int64
+		dir  string
+		exit int
+	}{
+		{1000, "/shy", 1},
+		{2000, "/shy", 2},
+		{3000, "/shy", 0},
+		{3500, "/other", 1},
+		{4000, "/shy", 1},
+	} {
+		_, err := database.InsertCommand(&models.Command{
+			CommandText: "make", WorkingDir: c.dir, GitRepo: &repo, GitBranch: &main,
+			ExitStatus: c.exit, Timestamp: c.ts,
+		})
+		require.NoError(t, err)
+	}
+
+	ts, found, err := database.FindPrevFailureDay("/shy", repo, &main, 0, 4000)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, int64(2000), ts, "the newest failure before the bound, in this context")
+
+	_, found, err = database.FindPrevFailureDay("/shy", repo, &main, 1500, 2000)
+	require.NoError(t, err)
+	assert.False(t, found, "failures before the window are not found")
+
+	other := "other"
+	_, found, err = database.FindPrevFailureDay("/shy", repo, &other, 0, 5000)
+	require.NoError(t, err)
+	assert.False(t, found, "another branch is another context")
+}
+
+func TestSoftDeleteAndRestore(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := NewForTesting(dbPath)
+	require.NoError(t, err)
+	defer database.Close()
+
+	for i, text := range []string{"make", "ls", "make"} {
+		c := models.NewCommand(text, "/a", 0)
+		c.Timestamp = int64(1000 + i)
+		_, err := database.InsertCommand(c)
+		require.NoError(t, err)
+	}
+	fzfTexts := func() map[int64]string {
+		got := map[int64]string{}
+		require.NoError(t, database.GetCommandsForFzf(func(id int64, text string) error {
+			got[id] = text
+			return nil
+		}))
+		return got
+	}
+
+	// Trashing the canonical "make" falls back to the older copy
+	trashed, err := database.SoftDeleteCommand(3)
+	require.NoError(t, err)
+	assert.True(t, trashed)
+	count, err := database.CountCommands()
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	_, err = database.GetCommand(3)
+	assert.Error(t, err, "trashed commands are hidden")
+	assert.Equal(t, map[int64]string{1: "make", 2: "ls"}, fzfTexts())
+
+	trashed, err = database.SoftDeleteCommand(3)
+	require.NoError(t, err)
+	assert.False(t, trashed, "already in the trash")
+
+	list, err := database.GetTrashedCommands(0)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, int64(3), list[0].ID)
+	assert.Equal(t, "make", list[0].CommandText)
+	assert.NotZero(t, list[0].DeletedAt)
+
+	// Restoring makes it canonical again
+	restored, err := database.RestoreCommand(3)
+	require.NoError(t, err)
+	assert.True(t, restored)
+	assert.Equal(t, map[int64]string{2: "ls", 3: "make"}, fzfTexts())
+	restored, err = database.RestoreCommand(3)
+	require.NoError(t, err)
+	assert.False(t, restored, "not in the trash")
+
+	// Emptying removes trashed commands for good
+	_, err = database.SoftDeleteCommand(2)
+	require.NoError(t, err)
+	deleted, err := database.EmptyTrash()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	list, err = database.GetTrashedCommands(0)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+	restored, err = database.RestoreCommand(2)
+	require.NoError(t, err)
+	assert.False(t, restored)
+	count, err = database.CountCommands()
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
+
+// setupArchivePair rotates a history into an archive: ids 1-3 are moved to
+// the archive and ids 4-5 stay in the primary, which then gets id 6
+func setupArchivePair(t *testing.T) (primaryPath, archivePath string) {
+	t.Helper()
+	dir := t.TempDir()
+	primaryPath = filepath.Join(dir, "history.db")
+	archivePath = filepath.Join(dir, "archive.db")
+
+	database, err := NewForTesting(primaryPath)
+	require.NoError(t, err)
+	for _, c := range []struct {
+		ts   int64
+		text string
+		dir  string
+		exit int
+	}{
+		{1000, "make", "/shy", 1},
+		{2000, "git status", "/shy", 0},
+		{3000, "ls", "/tmp", 0},
+		{4000, "git status", "/shy", 0},
+		{5000, "go test", "/shy", 0},
+	} {
+		_, err := database.InsertCommand(&models.Command{
+			CommandText: c.text, WorkingDir: c.dir, ExitStatus: c.exit, Timestamp: c.ts,
+		})
+		require.NoError(t, err)
+	}
+	require.NoError(t, database.Close())
+
+	data, err := os.ReadFile(primaryPath)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(archivePath, data, 0644))
+
+	archive, err := New(archivePath)
+	require.NoError(t, err)
+	_, err = archive.conn.Exec("DELETE FROM commands WHERE id > 3")
+	require.NoError(t, err)
+	require.NoError(t, archive.Close())
+
+	database, err = New(primaryPath)
+	require.NoError(t, err)
+	_, err = database.conn.Exec("DELETE FROM commands WHERE id <= 3")
+	require.NoError(t, err)
+	_, err = database.InsertCommand(&models.Command{CommandText: "ls", WorkingDir: "/tmp", Timestamp: 6000})
+	require.NoError(t, err)
+	require.NoError(t, database.Close())
+	return primaryPath, archivePath
+}
+
+func commandIDs(commands []models.Command) []int64 {
+	ids := []int64{}
+	for _, c := range commands {
+		ids = append(ids, c.ID)
+	}
+	return ids
+}
+
+func TestArchiveReads(t *testing.T) {
+	primaryPath, archivePath := setupArchivePair(t)
+	database, err := NewWithOptions(primaryPath, Options{ArchivePath: archivePath})
+	require.NoError(t, err)
+	defer database.Close()
+
+	commands, err := database.GetCommandsByDateRange(0, 10000, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 3, 4, 5, 6}, commandIDs(commands))
+
+	cmd, err := database.GetCommand(2)
+	require.NoError(t, err)
+	assert.Equal(t, "git status", cmd.CommandText, "archived commands are found by id")
+
+	count, err := database.PeekContextCount("/shy", "", nil, 0, 10000, AllMode, "", "")
+	require.NoError(t, err)
+	assert.Equal(t, 4, count)
+	count, err = database.PeekContextCount("/shy", "", nil, 0, 10000, UniqueMode, "", "")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count, "git status is in both databases, so it is not unique")
+
+	page, err := database.GetCommandsForContextPaged(ContextPageOptions{
+		StartTime: 0, EndTime: 10000, WorkingDir: "/shy", Mode: UniqueMode, Limit: 10,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 5}, commandIDs(page))
+
+	hours, err := database.GetCommandCountsByHour(0, 7200)
+	require.NoError(t, err)
+	assert.Equal(t, []int{3, 3}, hours)
+
+	total, err := database.CountCommands()
+	require.NoError(t, err)
+	assert.Equal(t, 6, total, "the archive's commands are counted too")
+
+	slowest, err := database.GetSlowestCommands(0, 10000, 5)
+	require.NoError(t, err)
+	assert.Empty(t, slowest, "no command has a duration")
+
+	ts, found, err := database.FindPrevFailureDay("/shy", "", nil, 0, 10000)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, int64(1000), ts)
+
+	ranged, err := database.GetCommandsByRangeOrdered(1, 6, RangeFilter{}, Descending)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{6, 5, 4, 3, 2, 1}, commandIDs(ranged))
+	ranged, err = database.GetCommandsByRangeOrdered(1, 6, RangeFilter{Pattern: "git%"}, Ascending)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{4}, commandIDs(ranged), "a filtered range keeps the newest of a text across both")
+	count, err = database.CountCommandsByRange(1, 6, RangeFilter{Pattern: "%"})
+	require.NoError(t, err)
+	assert.Equal(t, 4, count)
+
+	id, err := database.FindMostRecentMatching("ma")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), id)
+
+	before, target, after, err := database.GetCommandWithContext(2, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), target.ID)
+	assert.Equal(t, []int64{1}, commandIDs(before))
+	assert.Equal(t, []int64{3}, commandIDs(after))
+
+	var fzf []int64
+	require.NoError(t, database.GetCommandsForFzf(func(id int64, _ string) error {
+		fzf = append(fzf, id)
+		return nil
+	}))
+	assert.Equal(t, []int64{6, 5, 4, 1}, fzf, "the archived ls is listed by the primary's")
+}
+
+func TestArchiveStats(t *testing.T) {
+	primaryPath, archivePath := setupArchivePair(t)
+	database, err := NewWithOptions(primaryPath, Options{ArchivePath: archivePath})
+	require.NoError(t, err)
+	defer database.Close()
+
+	stats, err := database.GetStats(2)
+	require.NoError(t, err)
+	assert.Equal(t, 6, stats.Commands)
+	assert.Equal(t, 4, stats.UniqueCommands)
+	assert.Equal(t, 1, stats.Failed, "the failed make is archived")
+	assert.Equal(t, 0, stats.Sessions)
+	assert.Equal(t, 2, stats.Directories)
+	assert.Equal(t, int64(1000), stats.FirstTimestamp)
+	assert.Equal(t, int64(6000), stats.LastTimestamp)
+	assert.Equal(t, []TextCount{{"git status", 2}, {"ls", 2}}, stats.TopCommands)
+	assert.Equal(t, []TextCount{{"/shy", 4}, {"/tmp", 2}}, stats.TopDirectories)
+	hours := 0
+	for _, n := range stats.HourCounts {
+		hours += n
+	}
+	assert.Equal(t, 6, hours)
+	days := 0
+	for _, d := range stats.Days {
+		days += d.Count
+	}
+	assert.Equal(t, 6, days)
+}
+
+func TestArchiveWritesGoToPrimary(t *testing.T) {
+	primaryPath, archivePath := setupArchivePair(t)
+	database, err := NewWithOptions(primaryPath, Options{ArchivePath: archivePath})
+	require.NoError(t, err)
+	_, err = database.InsertCommand(&models.Command{CommandText: "make", WorkingDir: "/shy", Timestamp: 7000})
+	require.NoError(t, err)
+	trashed, err := database.SoftDeleteCommand(1)
+	require.NoError(t, err)
+	assert.False(t, trashed, "archived commands cannot be changed")
+
+	count, err := database.CountCommands()
+	require.NoError(t, err)
+	assert.Equal(t, 7, count, "four in the primary, with the new one, and three archived")
+	require.NoError(t, database.Close())
+
+	archive, err := NewWithOptions(archivePath, Options{ReadOnly: true})
+	require.NoError(t, err)
+	defer archive.Close()
+	commands, err := archive.GetCommandsByDateRange(0, 10000, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 3}, commandIDs(commands), "the archive is unchanged")
+}
+
+func TestArchiveSchemaVersion(t *testing.T) {
+	primaryPath, archivePath := setupArchivePair(t)
+	archive, err := New(archivePath)
+	require.NoError(t, err)
+	_, err = archive.conn.Exec("PRAGMA user_version = 1")
+	require.NoError(t, err)
+	require.NoError(t, archive.Close())
+
+	_, err = NewWithOptions(primaryPath, Options{ArchivePath: archivePath})
+	assert.ErrorContains(t, err, "is at schema version 1, expected")
+
+	_, err = NewWithOptions(primaryPath, Options{ArchivePath: filepath.Join(t.TempDir(), "missing.db")})
+	assert.ErrorContains(t, err, "failed to open archive")
+}
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS context_notes (
+	working_dir TEXT NOT NULL,
+	git_repo TEXT NOT NULL DEFAULT '',
+	git_branch TEXT NOT NULL DEFAULT '',
+	note TEXT NOT NULL,
+	updated_at INTEGER NOT NULL,
+	PRIMARY KEY (working_dir, git_repo, git_branch)
+);
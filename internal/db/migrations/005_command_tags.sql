@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS command_tags (
+	command_id INTEGER NOT NULL REFERENCES commands(id) ON DELETE CASCADE,
+	tag TEXT NOT NULL,
+	PRIMARY KEY (command_id, tag)
+);
+CREATE INDEX IF NOT EXISTS idx_command_tags_tag ON command_tags (tag);
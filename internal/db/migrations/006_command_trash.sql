@@ -0,0 +1,4 @@
+-- Add a nullable deleted_at column to commands: the Unix time a command was
+-- moved to the trash, NULL for live commands. Trashed rows stay until they
+-- are restored or the trash is emptied, so the column is added in place.
+ALTER TABLE commands ADD COLUMN deleted_at INTEGER;
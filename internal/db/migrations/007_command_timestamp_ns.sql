@@ -0,0 +1,53 @@
+-- Add a timestamp_ns column to commands: the Unix time the command started,
+-- in nanoseconds, for ordering and showing commands within one second. The
+-- shell hook records it; every existing row gets its whole second.
+--
+-- As in migration 4, the table is recreated rather than altered so the
+-- migration can be rerun after a partial failure, setting stars, tags and
+-- the AUTOINCREMENT high-water mark aside across the rebuild: dropping
+-- commands cascades to starred_commands and command_tags.
+DROP TABLE IF EXISTS commands_rebuild;
+DROP TABLE IF EXISTS starred_rebuild;
+DROP TABLE IF EXISTS tags_rebuild;
+DROP TABLE IF EXISTS sequence_rebuild;
+
+CREATE TABLE commands_rebuild (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	timestamp INTEGER NOT NULL,
+	exit_status INTEGER NOT NULL,
+	duration INTEGER NOT NULL,
+	command_text TEXT NOT NULL,
+	working_dir_id INTEGER NOT NULL REFERENCES working_dirs(id),
+	git_context_id INTEGER REFERENCES git_contexts(id),
+	source_id INTEGER REFERENCES sources(id),
+	is_duplicate INTEGER DEFAULT 0,
+	env TEXT,
+	deleted_at INTEGER,
+	timestamp_ns INTEGER NOT NULL
+);
+
+INSERT INTO commands_rebuild (id, timestamp, exit_status, duration, command_text, working_dir_id, git_context_id, source_id, is_duplicate, env, deleted_at, timestamp_ns)
+	SELECT id, timestamp, exit_status, duration, command_text, working_dir_id, git_context_id, source_id, is_duplicate, env, deleted_at, timestamp * 1000000000
+	FROM commands;
+
+CREATE TABLE starred_rebuild AS SELECT command_id FROM starred_commands;
+CREATE TABLE tags_rebuild AS SELECT command_id, tag FROM command_tags;
+CREATE TABLE sequence_rebuild AS SELECT seq FROM sqlite_sequence WHERE name = 'commands';
+
+DROP TABLE commands;
+ALTER TABLE commands_rebuild RENAME TO commands;
+
+INSERT OR IGNORE INTO starred_commands (command_id) SELECT command_id FROM starred_rebuild;
+INSERT OR IGNORE INTO command_tags (command_id, tag) SELECT command_id, tag FROM tags_rebuild;
+UPDATE sqlite_sequence SET seq = (SELECT seq FROM sequence_rebuild)
+	WHERE name = 'commands' AND seq < (SELECT seq FROM sequence_rebuild);
+
+DROP TABLE starred_rebuild;
+DROP TABLE tags_rebuild;
+DROP TABLE sequence_rebuild;
+
+CREATE INDEX IF NOT EXISTS idx_source_timestamp ON commands (source_id, timestamp DESC);
+CREATE INDEX IF NOT EXISTS idx_working_dir_timestamp ON commands (working_dir_id, timestamp DESC);
+CREATE INDEX IF NOT EXISTS idx_timestamp_desc ON commands (timestamp DESC);
+CREATE INDEX IF NOT EXISTS idx_command_text_id ON commands (command_text, id DESC);
+CREATE INDEX IF NOT EXISTS idx_not_duplicate ON commands (id DESC) WHERE is_duplicate = 0;
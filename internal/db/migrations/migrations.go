@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	_ "embed"
 	"fmt"
+	"regexp"
+	"slices"
 )
 
 //go:embed 001_initial_schema.sql
@@ -12,10 +14,71 @@ var initialSchemaSQL string
 //go:embed 002_starred_commands.sql
 var starredCommandsSQL string
 
+//go:embed 003_context_notes.sql
+var contextNotesSQL string
+
+//go:embed 004_command_env.sql
+var commandEnvSQL string
+
+//go:embed 005_command_tags.sql
+var commandTagsSQL string
+
+//go:embed 006_command_trash.sql
+var commandTrashSQL string
+
+//go:embed 007_command_timestamp_ns.sql
+var commandTimestampNsSQL string
+
 // All contains all migrations in order. Each migration's index+1 is its version number.
 var All = []string{
-	initialSchemaSQL,   // version 1
-	starredCommandsSQL, // version 2
+	initialSchemaSQL,      // version 1
+	starredCommandsSQL,    // version 2
+	contextNotesSQL,       // version 3
+	commandEnvSQL,         // version 4
+	commandTagsSQL,        // version 5
+	commandTrashSQL,       // version 6
+	commandTimestampNsSQL, // version 7
+}
+
+// Latest returns the schema version after all migrations have run
+func Latest() int {
+	return len(All)
+}
+
+var createIndexRe = regexp.MustCompile(`(?is)CREATE\s+INDEX\s+(?:IF\s+NOT\s+EXISTS\s+)?(\w+)[^;]*`)
+
+// Index is an index created by the migrations
+type Index struct {
+	Name string
+	SQL  string // the CREATE INDEX statement, without the trailing semicolon
+}
+
+// Indexes returns the indexes created by the migrations, in the order they
+// first appear. An index recreated by a later migration is listed once, with
+// its latest definition.
+func Indexes() []Index {
+	var indexes []Index
+	for _, m := range All {
+		for _, match := range createIndexRe.FindAllStringSubmatch(m, -1) {
+			idx := Index{Name: match[1], SQL: match[0]}
+			if i := slices.IndexFunc(indexes, func(e Index) bool { return e.Name == idx.Name }); i >= 0 {
+				indexes[i] = idx
+			} else {
+				indexes = append(indexes, idx)
+			}
+		}
+	}
+	return indexes
+}
+
+// IndexNames returns the names of all indexes created by the migrations, in
+// order. An index recreated by a later migration is listed once.
+func IndexNames() []string {
+	var names []string
+	for _, idx := range Indexes() {
+		names = append(names, idx.Name)
+	}
+	return names
 }
 
 // Migrate runs all pending migrations on the database.
@@ -0,0 +1,182 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chris/shy/pkg/models"
+)
+
+// CSVFormats lists the --from values ReadCSV accepts: comma and tab separated
+var CSVFormats = []string{"csv", "tsv"}
+
+// CSVFields lists the command fields a CSV column can be mapped onto
+var CSVFields = []string{"timestamp", "command", "dir", "exit", "duration", "repo", "branch", "session"}
+
+// CSVMapping maps command fields onto 1-indexed CSV columns
+type CSVMapping map[string]int
+
+// ParseCSVMapping parses a --map value such as "timestamp=1,command=3,dir=2".
+// Every field must be one of CSVFields, at most once, and command is required.
+func ParseCSVMapping(spec string) (CSVMapping, error) {
+	mapping := make(CSVMapping)
+	for _, pair := range strings.Split(spec, ",") {
+		field, col, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid mapping %q: expected field=column", pair)
+		}
+		field = strings.TrimSpace(field)
+		if !slices.Contains(CSVFields, field) {
+			return nil, fmt.Errorf("unknown field %q: expected %s", field, strings.Join(CSVFields, ", "))
+		}
+		if _, dup := mapping[field]; dup {
+			return nil, fmt.Errorf("field %q is mapped twice", field)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(col))
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid column %q for %s: expected a number from 1", col, field)
+		}
+		mapping[field] = n
+	}
+	if _, ok := mapping["command"]; !ok {
+		return nil, errors.New("the mapping needs a command column")
+	}
+	return mapping, nil
+}
+
+// width returns the number of columns a row needs for every mapped field
+func (cm CSVMapping) width() int {
+	n := 0
+	for _, col := range cm {
+		n = max(n, col)
+	}
+	return n
+}
+
+// csvTimeLayouts are the timestamp formats accepted besides Unix seconds;
+// those without a zone are read as local time
+var csvTimeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02 15:04"}
+
+// ReadCSV reads a comma separated ("csv") or tab separated ("tsv") file,
+// mapping columns onto command fields:
+//
+//	timestamp  → Timestamp: Unix seconds, RFC 3339 or "2006-01-02 15:04:05"
+//	command    → CommandText
+//	dir        → WorkingDir
+//	exit       → ExitStatus
+//	duration   → Duration (ms)
+//	repo       → GitRepo
+//	branch     → GitBranch
+//	session    → SourceApp "csv", SourcePid from the session id
+//
+// Unmapped fields, and mapped ones whose cell is empty, are left empty. A
+// row without a timestamp is dated now, the time of the import, shared by
+// every such row. Imports are de-duplicated by time, so re-importing a file
+// without timestamps adds its rows again. hasHeader skips the first row.
+func ReadCSV(format, path string, mapping CSVMapping, hasHeader bool, now time.Time) ([]*models.Command, error) {
+	if !slices.Contains(CSVFormats, format) {
+		return nil, fmt.Errorf("unknown format %q: expected %s", format, strings.Join(CSVFormats, ", "))
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %w", path, err)
+	}
+	defer file.Close()
+
+	r := csv.NewReader(file)
+	if format == "tsv" {
+		r.Comma = '\t'
+		r.LazyQuotes = true
+	}
+	r.FieldsPerRecord = -1
+
+	var commands []*models.Command
+	for row := 1; ; row++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", format, err)
+		}
+		if row == 1 && hasHeader {
+			continue
+		}
+		if len(record) < mapping.width() {
+			return nil, fmt.Errorf("row %d has %d columns, the mapping needs %d", row, len(record), mapping.width())
+		}
+		c, err := csvCommand(record, mapping, now.Unix())
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", row, err)
+		}
+		commands = appendCommand(commands, c)
+	}
+
+	sort.SliceStable(commands, func(i, j int) bool {
+		return commands[i].Timestamp < commands[j].Timestamp
+	})
+	return commands, nil
+}
+
+// csvCommand builds the command of one CSV record
+func csvCommand(record []string, mapping CSVMapping, now int64) (*models.Command, error) {
+	cell := func(field string) string {
+		if col, ok := mapping[field]; ok {
+			return strings.TrimSpace(record[col-1])
+		}
+		return ""
+	}
+
+	timestamp := now
+	if value := cell("timestamp"); value != "" {
+		ts, err := parseCSVTime(value)
+		if err != nil {
+			return nil, err
+		}
+		timestamp = ts
+	}
+	c := newCommand(record[mapping["command"]-1], cell("dir"), timestamp, "csv", cell("session"))
+
+	if value := cell("exit"); value != "" {
+		exit, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exit status %q", value)
+		}
+		c.ExitStatus = exit
+	}
+	if value := cell("duration"); value != "" {
+		duration, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration %q", value)
+		}
+		c.Duration = &duration
+	}
+	if value := cell("repo"); value != "" {
+		c.GitRepo = &value
+	}
+	if value := cell("branch"); value != "" {
+		c.GitBranch = &value
+	}
+	return c, nil
+}
+
+// parseCSVTime parses a timestamp cell into Unix seconds
+func parseCSVTime(value string) (int64, error) {
+	if ts, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return ts, nil
+	}
+	for _, layout := range csvTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t.Unix(), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid timestamp %q", value)
+}
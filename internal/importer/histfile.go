@@ -0,0 +1,139 @@
+package importer
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/chris/shy/pkg/models"
+)
+
+// readFish reads fish's history file, a YAML-like list with one entry per
+// command:
+//
+//	# ~/.local/share/fish/fish_history
+//	- cmd: git commit -m "two\nlines"
+//	  when: 1770215400
+//	  paths:
+//	    - ./notes.txt
+//
+// mapped as
+//
+//	cmd   → CommandText, with fish's \n and \\ escapes undone
+//	when  → Timestamp (s)
+//
+// fish records no directory, duration, exit status or session, so those are
+// left empty. paths and any other field are ignored, as are entries without a
+// when, which fish always writes.
+func readFish(path string) ([]*models.Command, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var commands []*models.Command
+	var text string
+	var when int64
+	inEntry, hasWhen := false, false
+	flush := func() {
+		if inEntry && hasWhen {
+			commands = appendCommand(commands, newCommand(text, "", when, "fish", ""))
+		}
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if value, ok := strings.CutPrefix(line, "- cmd:"); ok {
+			flush()
+			text = unescapeFish(strings.TrimPrefix(value, " "))
+			inEntry, hasWhen = true, false
+			continue
+		}
+		// Fields of the entry are indented by two spaces, paths items by four
+		value, ok := strings.CutPrefix(line, "  when:")
+		if !inEntry || !ok {
+			continue
+		}
+		if ts, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64); err == nil {
+			when, hasWhen = ts, true
+		}
+	}
+	flush()
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read fish history: %w", err)
+	}
+
+	// fish appends entries as sessions exit, so it is nearly but not
+	// entirely in time order
+	sort.SliceStable(commands, func(i, j int) bool {
+		return commands[i].Timestamp < commands[j].Timestamp
+	})
+	return commands, nil
+}
+
+// unescapeFish undoes the escapes fish writes in a history cmd: \n for a
+// newline and \\ for a backslash. Any other backslash is kept as is.
+func unescapeFish(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			switch s[i+1] {
+			case 'n':
+				b.WriteByte('\n')
+				i++
+				continue
+			case '\\':
+				b.WriteByte('\\')
+				i++
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// readSh reads a plain history file with one command per line, as POSIX sh,
+// BusyBox ash and dash write it (~/.ash_history, $HISTFILE).
+//
+//	line  → CommandText
+//
+// These files carry no times, so the last command is given the file's
+// modification time and each earlier one a second less: the order and any
+// repeats survive, and re-importing an unchanged file matches the first
+// import. Once the file has grown, the times shift and its old lines are
+// imported again. Blank lines are skipped.
+func readSh(path string) ([]*models.Command, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %w", path, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sh history: %w", err)
+	}
+
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	last := info.ModTime().Unix()
+	var commands []*models.Command
+	for i, line := range lines {
+		timestamp := last - int64(len(lines)-1-i)
+		commands = appendCommand(commands, newCommand(line, "", timestamp, "sh", ""))
+	}
+	return commands, nil
+}
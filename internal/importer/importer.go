@@ -0,0 +1,177 @@
+// Package importer reads command history from other shell history tools'
+// SQLite databases, from shells' own history files, and from CSV exports,
+// and maps it onto shy's command model
+package importer
+
+import (
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"strings"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/chris/shy/internal/db"
+	"github.com/chris/shy/pkg/models"
+)
+
+// Formats lists the --from values Read accepts
+var Formats = []string{"atuin", "mcfly", "fish", "sh"}
+
+// Read reads every command from the history database or file at path,
+// written by the tool or shell named by format, oldest first
+func Read(format, path string) ([]*models.Command, error) {
+	switch format {
+	case "atuin":
+		return readAtuin(path)
+	case "mcfly":
+		return readMcFly(path)
+	case "fish":
+		return readFish(path)
+	case "sh":
+		return readSh(path)
+	default:
+		return nil, fmt.Errorf("unknown format %q: expected %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+// open opens a foreign history database read-only, so a failed import never
+// touches the other tool's data
+func open(path string) (*sql.DB, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("cannot read %s: %w", path, err)
+	}
+	conn, err := sql.Open("sqlite", db.ReadOnlyURI(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	return conn, nil
+}
+
+// readAtuin reads Atuin's history table. Atuin stores times in nanoseconds
+// and uses -1 for an unknown duration or exit status.
+//
+//	command    → CommandText
+//	cwd        → WorkingDir
+//	timestamp  → Timestamp (ns → s)
+//	duration   → Duration (ns → ms), NULL when -1
+//	exit       → ExitStatus, 0 when -1
+//	session    → SourceApp "atuin", SourcePid from the session id
+//
+// Rows with deleted_at set were deleted in Atuin and are skipped. hostname
+// has no counterpart and is dropped.
+func readAtuin(path string) ([]*models.Command, error) {
+	conn, err := open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	rows, err := conn.Query(`
+		SELECT command, cwd, timestamp, duration, exit, session
+		FROM history
+		WHERE deleted_at IS NULL
+		ORDER BY timestamp, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read atuin history: %w", err)
+	}
+	defer rows.Close()
+
+	var commands []*models.Command
+	for rows.Next() {
+		var text, cwd, session string
+		var timestampNs, durationNs, exit int64
+		if err := rows.Scan(&text, &cwd, &timestampNs, &durationNs, &exit, &session); err != nil {
+			return nil, fmt.Errorf("failed to scan atuin history: %w", err)
+		}
+		c := newCommand(text, cwd, timestampNs/1e9, "atuin", session)
+		if durationNs >= 0 {
+			duration := durationNs / 1e6
+			c.Duration = &duration
+		}
+		if exit >= 0 {
+			c.ExitStatus = int(exit)
+		}
+		commands = appendCommand(commands, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating atuin history: %w", err)
+	}
+	return commands, nil
+}
+
+// readMcFly reads McFly's commands table. McFly records no duration.
+//
+//	cmd         → CommandText
+//	dir         → WorkingDir, "" when NULL
+//	when_run    → Timestamp (s)
+//	exit_code   → ExitStatus
+//	session_id  → SourceApp "mcfly", SourcePid from the session id
+//
+// cmd_tpl, selected and old_dir are McFly's own ranking data and are dropped.
+func readMcFly(path string) ([]*models.Command, error) {
+	conn, err := open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	rows, err := conn.Query(`
+		SELECT cmd, COALESCE(dir, ''), when_run, exit_code, session_id
+		FROM commands
+		ORDER BY when_run, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mcfly history: %w", err)
+	}
+	defer rows.Close()
+
+	var commands []*models.Command
+	for rows.Next() {
+		var text, dir, session string
+		var whenRun int64
+		var exit int
+		if err := rows.Scan(&text, &dir, &whenRun, &exit, &session); err != nil {
+			return nil, fmt.Errorf("failed to scan mcfly history: %w", err)
+		}
+		c := newCommand(text, dir, whenRun, "mcfly", session)
+		c.ExitStatus = exit
+		commands = appendCommand(commands, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating mcfly history: %w", err)
+	}
+	return commands, nil
+}
+
+// newCommand builds an imported command. The other tools identify sessions
+// by a string id, so it is hashed into a stable pseudo pid: importing the
+// same history twice maps a session to the same shy session. Imported
+// sessions are closed.
+func newCommand(text, dir string, timestamp int64, app, session string) *models.Command {
+	c := models.NewCommand(text, dir, 0)
+	c.Timestamp = timestamp
+	if session != "" {
+		h := fnv.New64a()
+		h.Write([]byte(session))
+		pid := int64(h.Sum64() >> 1)
+		active := false
+		c.SourceApp = &app
+		c.SourcePid = &pid
+		c.SourceActive = &active
+	}
+	return c
+}
+
+// appendCommand appends c with its text trimmed, unless it starts with a
+// space, which shy leaves out of history, or is blank
+func appendCommand(commands []*models.Command, c *models.Command) []*models.Command {
+	if c.CommandText == "" || c.CommandText[0] == ' ' {
+		return commands
+	}
+	c.TrimCommandText()
+	if c.CommandText == "" {
+		return commands
+	}
+	return append(commands, c)
+}
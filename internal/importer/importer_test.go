@@ -0,0 +1,214 @@
+package importer
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildFixture loads testdata/<name>.sql into a fresh SQLite file and
+// returns its path
+func buildFixture(t *testing.T, name string) string {
+	t.Helper()
+	schema, err := os.ReadFile(filepath.Join("testdata", name+".sql"))
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), name+".db")
+	conn, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Exec(string(schema))
+	require.NoError(t, err)
+	return path
+}
+
+func TestReadAtuin(t *testing.T) {
+	commands, err := Read("atuin", buildFixture(t, "atuin"))
+	require.NoError(t, err)
+
+	// The deleted row and the space-prefixed row are skipped
+	require.Len(t, commands, 2)
+
+	first := commands[0]
+	assert.Equal(t, "git status", first.CommandText)
+	assert.Equal(t, "/home/test/proj", first.WorkingDir)
+	assert.Equal(t, int64(1770215400), first.Timestamp)
+	require.NotNil(t, first.Duration)
+	assert.Equal(t, int64(1500), *first.Duration)
+	assert.Equal(t, 0, first.ExitStatus)
+	require.NotNil(t, first.SourceApp)
+	assert.Equal(t, "atuin", *first.SourceApp)
+	require.NotNil(t, first.SourceActive)
+	assert.False(t, *first.SourceActive)
+
+	second := commands[1]
+	assert.Equal(t, "make build", second.CommandText, "text should be trimmed")
+	assert.Nil(t, second.Duration, "a -1 duration is unknown")
+	assert.Equal(t, 0, second.ExitStatus, "a -1 exit status maps to 0")
+	require.NotNil(t, second.SourcePid)
+	assert.Equal(t, *first.SourcePid, *second.SourcePid, "one session maps to one pid")
+}
+
+func TestReadMcFly(t *testing.T) {
+	commands, err := Read("mcfly", buildFixture(t, "mcfly"))
+	require.NoError(t, err)
+
+	// The blank command is skipped
+	require.Len(t, commands, 2)
+
+	assert.Equal(t, "ls -la", commands[0].CommandText)
+	assert.Equal(t, "/home/test", commands[0].WorkingDir)
+	assert.Equal(t, int64(1770215400), commands[0].Timestamp)
+	assert.Nil(t, commands[0].Duration, "mcfly records no duration")
+	require.NotNil(t, commands[0].SourceApp)
+	assert.Equal(t, "mcfly", *commands[0].SourceApp)
+
+	assert.Equal(t, "false", commands[1].CommandText)
+	assert.Equal(t, "", commands[1].WorkingDir, "a NULL dir maps to empty")
+	assert.Equal(t, 1, commands[1].ExitStatus)
+}
+
+func TestReadFish(t *testing.T) {
+	commands, err := Read("fish", filepath.Join("testdata", "fish_history"))
+	require.NoError(t, err)
+
+	// The space-prefixed entry and the one without a when are skipped
+	require.Len(t, commands, 3)
+
+	assert.Equal(t, "git status", commands[0].CommandText)
+	assert.Equal(t, int64(1770215400), commands[0].Timestamp)
+	assert.Equal(t, "", commands[0].WorkingDir, "fish records no directory")
+	assert.Nil(t, commands[0].Duration)
+	assert.Nil(t, commands[0].SourceApp, "fish records no session")
+
+	assert.Equal(t, "make build", commands[1].CommandText, "entries are sorted by time")
+	assert.Equal(t, 0, commands[1].ExitStatus, "unknown fields are ignored")
+
+	assert.Equal(t, "git commit -m \"first\nsecond\" && echo C:\\temp", commands[2].CommandText,
+		"escaped newlines and backslashes are undone")
+	assert.Equal(t, int64(1770215460), commands[2].Timestamp, "a when inside paths is not the entry's")
+}
+
+func TestUnescapeFish(t *testing.T) {
+	assert.Equal(t, "plain", unescapeFish("plain"))
+	assert.Equal(t, `a\nb`, unescapeFish(`a\\nb`), "an escaped backslash before n is not a newline")
+	assert.Equal(t, "a\nb", unescapeFish(`a\nb`))
+	assert.Equal(t, `\t and trailing \`, unescapeFish(`\t and trailing \`))
+}
+
+func TestReadSh(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ash_history")
+	data, err := os.ReadFile(filepath.Join("testdata", "sh_history"))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0644))
+	mtime := time.Unix(1770215400, 0)
+	require.NoError(t, os.Chtimes(path, mtime, mtime))
+
+	commands, err := Read("sh", path)
+	require.NoError(t, err)
+
+	// The blank line and the space-prefixed line are skipped; the repeat is kept
+	require.Len(t, commands, 3)
+	assert.Equal(t, []string{"ls -la", "cd /tmp", "ls -la"},
+		[]string{commands[0].CommandText, commands[1].CommandText, commands[2].CommandText})
+	assert.Equal(t, int64(1770215400-3), commands[0].Timestamp, "one second per line before the file's time")
+	assert.Equal(t, int64(1770215400-1), commands[2].Timestamp)
+
+	again, err := Read("sh", path)
+	require.NoError(t, err)
+	assert.Equal(t, commands[2].Timestamp, again[2].Timestamp, "an unchanged file reads the same times")
+}
+
+func TestReadErrors(t *testing.T) {
+	_, err := Read("nushell", buildFixture(t, "mcfly"))
+	assert.ErrorContains(t, err, `unknown format "nushell"`)
+
+	_, err = Read("fish", filepath.Join(t.TempDir(), "missing"))
+	assert.ErrorContains(t, err, "cannot read")
+
+	_, err = Read("atuin", filepath.Join(t.TempDir(), "missing.db"))
+	assert.ErrorContains(t, err, "cannot read")
+
+	// A McFly database is not an Atuin one
+	_, err = Read("atuin", buildFixture(t, "mcfly"))
+	assert.ErrorContains(t, err, "failed to read atuin history")
+}
+
+func TestReadCSV(t *testing.T) {
+	mapping, err := ParseCSVMapping("timestamp=1,command=3,dir=2,exit=4")
+	require.NoError(t, err)
+	commands, err := ReadCSV("csv", filepath.Join("testdata", "history.csv"), mapping, true, time.Now())
+	require.NoError(t, err)
+
+	// The header and the row without a command are skipped
+	require.Len(t, commands, 3)
+
+	assert.Equal(t, "git status", commands[0].CommandText, "rows are sorted by time")
+	assert.Equal(t, "/home/test/proj", commands[0].WorkingDir)
+	assert.Equal(t, int64(1770215400), commands[0].Timestamp)
+	assert.Equal(t, 0, commands[0].ExitStatus, "an empty exit cell maps to 0")
+	assert.Nil(t, commands[0].Duration, "duration is unmapped")
+	assert.Nil(t, commands[0].SourceApp, "session is unmapped")
+
+	assert.Equal(t, `git commit -m "a, b"`, commands[1].CommandText, "quoted cells keep their commas")
+
+	assert.Equal(t, "false", commands[2].CommandText)
+	assert.Equal(t, int64(1770215520), commands[2].Timestamp, "RFC 3339 timestamps are parsed")
+	assert.Equal(t, 1, commands[2].ExitStatus)
+
+	// Without --has-header the header row is data, with a bad timestamp
+	_, err = ReadCSV("csv", filepath.Join("testdata", "history.csv"), mapping, false, time.Now())
+	assert.ErrorContains(t, err, `row 1: invalid timestamp "when"`)
+}
+
+func TestReadTSVDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.tsv")
+	require.NoError(t, os.WriteFile(path, []byte("main\tmake \"all\"\ts1\n"), 0644))
+
+	mapping, err := ParseCSVMapping("command=2,branch=1,session=3")
+	require.NoError(t, err)
+	now := time.Unix(1770215400, 0)
+	commands, err := ReadCSV("tsv", path, mapping, false, now)
+	require.NoError(t, err)
+	require.Len(t, commands, 1)
+
+	c := commands[0]
+	assert.Equal(t, `make "all"`, c.CommandText, "tsv cells are not quoted")
+	assert.Equal(t, now.Unix(), c.Timestamp, "an unmapped timestamp is the import time")
+	assert.Equal(t, "", c.WorkingDir)
+	require.NotNil(t, c.GitBranch)
+	assert.Equal(t, "main", *c.GitBranch)
+	assert.Nil(t, c.GitRepo)
+	require.NotNil(t, c.SourceApp)
+	assert.Equal(t, "csv", *c.SourceApp)
+
+	// A row too short for the mapping is an error
+	short, err := ParseCSVMapping("command=4")
+	require.NoError(t, err)
+	_, err = ReadCSV("tsv", path, short, false, time.Now())
+	assert.ErrorContains(t, err, "row 1 has 3 columns, the mapping needs 4")
+}
+
+func TestParseCSVMapping(t *testing.T) {
+	mapping, err := ParseCSVMapping("timestamp=1, command=3 ,dir=2")
+	require.NoError(t, err)
+	assert.Equal(t, CSVMapping{"timestamp": 1, "command": 3, "dir": 2}, mapping)
+
+	for spec, want := range map[string]string{
+		"timestamp=1":           "needs a command column",
+		"command":               `invalid mapping "command"`,
+		"command=0":             `invalid column "0" for command`,
+		"command=x":             `invalid column "x" for command`,
+		"command=1,host=2":      `unknown field "host"`,
+		"command=1,command=2":   `field "command" is mapped twice`,
+		"command=1,,timestamp=": `invalid mapping ""`,
+	} {
+		_, err := ParseCSVMapping(spec)
+		assert.ErrorContains(t, err, want, spec)
+	}
+}
@@ -0,0 +1,17 @@
+-- Trimmed Atuin history schema with one row per mapping case
+CREATE TABLE history (
+	id text PRIMARY KEY,
+	timestamp integer NOT NULL,
+	duration integer NOT NULL,
+	exit integer NOT NULL,
+	command text NOT NULL,
+	cwd text NOT NULL,
+	session text NOT NULL,
+	hostname text NOT NULL,
+	deleted_at integer
+);
+
+INSERT INTO history VALUES ('01a', 1770215400000000000, 1500000000, 0, 'git status', '/home/test/proj', 'sess-a', 'host:test', NULL);
+INSERT INTO history VALUES ('01b', 1770215460000000000, -1, -1, 'make build  ', '/home/test/proj', 'sess-a', 'host:test', NULL);
+INSERT INTO history VALUES ('01c', 1770215520000000000, 20000000, 1, 'rm secrets.txt', '/home/test', 'sess-b', 'host:test', 1770215600000000000);
+INSERT INTO history VALUES ('01d', 1770215580000000000, 10000000, 0, ' export TOKEN=x', '/home/test', 'sess-b', 'host:test', NULL);
@@ -0,0 +1,16 @@
+-- Trimmed McFly commands schema with one row per mapping case
+CREATE TABLE commands (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	cmd TEXT NOT NULL,
+	cmd_tpl TEXT,
+	session_id TEXT NOT NULL,
+	when_run INTEGER NOT NULL,
+	exit_code INTEGER NOT NULL,
+	selected INTEGER NOT NULL,
+	dir TEXT,
+	old_dir TEXT
+);
+
+INSERT INTO commands (cmd, cmd_tpl, session_id, when_run, exit_code, selected, dir) VALUES ('ls -la', 'ls -la', 'abc', 1770215400, 0, 0, '/home/test');
+INSERT INTO commands (cmd, cmd_tpl, session_id, when_run, exit_code, selected, dir) VALUES ('false', 'false', 'abc', 1770215460, 1, 0, NULL);
+INSERT INTO commands (cmd, cmd_tpl, session_id, when_run, exit_code, selected, dir) VALUES ('   ', '', 'abc', 1770215520, 0, 0, '/home/test');
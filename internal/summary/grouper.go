@@ -20,6 +20,24 @@ type BranchKey string
 
 const NoBranch BranchKey = "No branch"
 
+// BranchKeyFor returns the branch key for a command's git branch.
+// NULL and empty branches are the same context and both map to NoBranch.
+func BranchKeyFor(branch *string) BranchKey {
+	if branch == nil || *branch == "" {
+		return NoBranch
+	}
+	return BranchKey(*branch)
+}
+
+// DBValue returns the branch as stored in the database, with NoBranch
+// mapping to the empty string (matching the COALESCE in db context queries).
+func (b BranchKey) DBValue() string {
+	if b == NoBranch {
+		return ""
+	}
+	return string(b)
+}
+
 // GroupedCommands represents commands grouped by context and branch
 type GroupedCommands struct {
 	Contexts map[ContextKey]map[BranchKey][]models.Command
@@ -49,12 +67,7 @@ func GroupByContext(commands []models.Command) *GroupedCommands {
 		}
 
 		// Determine branch key
-		var branchKey BranchKey
-		if cmd.GitBranch != nil && *cmd.GitBranch != "" {
-			branchKey = BranchKey(*cmd.GitBranch)
-		} else {
-			branchKey = NoBranch
-		}
+		branchKey := BranchKeyFor(cmd.GitBranch)
 
 		// Append command to the appropriate context and branch
 		grouped.Contexts[contextKey][branchKey] = append(
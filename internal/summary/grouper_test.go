@@ -304,3 +304,33 @@ func TestGroupByContext_EmptyBranch(t *testing.T) {
 	assert.Len(t, noBranchCommands, 1)
 	assert.Equal(t, "git status", noBranchCommands[0].CommandText)
 }
+
+// TestGroupByContext_NullAndEmptyBranchSameContext tests that NULL and empty branches group together
+func TestGroupByContext_NullAndEmptyBranchSameContext(t *testing.T) {
+	repo := "github.com/chris/shy"
+	empty := ""
+	commands := []models.Command{
+		{ID: 1, CommandText: "ls", WorkingDir: "/home/user/shy", GitRepo: &repo, GitBranch: nil},
+		{ID: 2, CommandText: "pwd", WorkingDir: "/home/user/shy", GitRepo: &repo, GitBranch: &empty},
+	}
+
+	grouped := GroupByContext(commands)
+
+	require.Len(t, grouped.Contexts, 1)
+	branches := grouped.Contexts[ContextKey{WorkingDir: "/home/user/shy", GitRepo: repo}]
+	require.Len(t, branches, 1)
+	assert.Len(t, branches[NoBranch], 2)
+}
+
+// TestBranchKeyFor tests branch key normalization and its database value
+func TestBranchKeyFor(t *testing.T) {
+	empty := ""
+	main := "main"
+
+	assert.Equal(t, NoBranch, BranchKeyFor(nil))
+	assert.Equal(t, NoBranch, BranchKeyFor(&empty))
+	assert.Equal(t, BranchKey("main"), BranchKeyFor(&main))
+
+	assert.Equal(t, "", NoBranch.DBValue())
+	assert.Equal(t, "main", BranchKey("main").DBValue())
+}
@@ -2,16 +2,22 @@ package tui
 
 import (
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 
 	tea "charm.land/bubbletea/v2"
+
+	"github.com/chris/shy/pkg/models"
 )
 
 // yankResultMsg is sent after a yank attempt completes.
 type yankResultMsg struct {
-	err error
+	err    error
+	path   bool // a context directory was copied rather than a command
+	table  bool // the summary was copied as a markdown table
+	record bool // a command's full record was copied as JSON
 }
 
 // oscClipboard writes an OSC 52 escape sequence to set the system clipboard.
@@ -47,3 +53,39 @@ func yankToClipboard(text string) tea.Cmd {
 		return yankResultMsg{err: err}
 	})
 }
+
+// yankPathToClipboard copies a context's working directory via OSC 52. The
+// stored absolute path is copied, never the ~ form shown on screen.
+func yankPathToClipboard(dir string) tea.Cmd {
+	return tea.Exec(&oscClipboard{text: dir}, func(err error) tea.Msg {
+		return yankResultMsg{err: err, path: true}
+	})
+}
+
+// yankTableToClipboard copies the summary's markdown table via OSC 52
+func yankTableToClipboard(table string) tea.Cmd {
+	return tea.Exec(&oscClipboard{text: table}, func(err error) tea.Msg {
+		return yankResultMsg{err: err, table: true}
+	})
+}
+
+// commandRecord renders every field of cmd as indented JSON, for pasting
+// into a bug report or note
+func commandRecord(cmd models.Command) (string, error) {
+	data, err := json.MarshalIndent(cmd, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode command: %w", err)
+	}
+	return string(data), nil
+}
+
+// yankRecordToClipboard copies cmd's full record as JSON via OSC 52
+func yankRecordToClipboard(cmd models.Command) tea.Cmd {
+	text, err := commandRecord(cmd)
+	if err != nil {
+		return func() tea.Msg { return yankResultMsg{err: err, record: true} }
+	}
+	return tea.Exec(&oscClipboard{text: text}, func(err error) tea.Msg {
+		return yankResultMsg{err: err, record: true}
+	})
+}
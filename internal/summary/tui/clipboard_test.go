@@ -3,10 +3,16 @@ package tui
 import (
 	"bytes"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"testing"
+	"time"
 
+	tea "charm.land/bubbletea/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/chris/shy/pkg/models"
 )
 
 func TestOscClipboard(t *testing.T) {
@@ -58,3 +64,112 @@ func TestOscClipboard(t *testing.T) {
 		assert.Equal(t, want, buf.String())
 	})
 }
+
+// TestYankContextPath tests that Y copies the selected context's real
+// directory, not the ~ form shown, from both the summary and the detail view
+func TestYankContextPath(t *testing.T) {
+	t.Setenv("HOME", "/home/user")
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+
+	dbPath := setupTestDB(t, []models.Command{
+		makeCommand(yesterday, 9, "/home/user/projects/shy", strPtr("github.com/chris/shy"), strPtr("main")),
+	})
+	model := initModel(t, dbPath, today)
+	model.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	require.Contains(t, model.renderView(), "~/projects/shy")
+
+	_, cmd := model.handleKey(tea.KeyPressMsg{Code: 'Y', Text: "Y"})
+	assert.NotNil(t, cmd)
+	assert.Equal(t, "/home/user/projects/shy", model.contextDir())
+
+	pressEnter(model)
+	require.Equal(t, ContextDetailView, model.ViewState())
+	_, cmd = model.handleKey(tea.KeyPressMsg{Code: 'Y', Text: "Y"})
+	assert.NotNil(t, cmd)
+	assert.Equal(t, "/home/user/projects/shy", model.contextDir())
+
+	model.Update(yankResultMsg{path: true})
+	assert.Equal(t, "Copied path", model.StatusMsg())
+	model.Update(yankResultMsg{path: true, err: errors.New("no tty")})
+	assert.Equal(t, "Copy failed", model.StatusMsg())
+	model.Update(yankResultMsg{})
+	assert.Equal(t, "Yanked!", model.StatusMsg())
+}
+
+// TestMarkdownSummaryTable tests M's table of the summary contexts, which
+// follows the filter and display mode
+func TestMarkdownSummaryTable(t *testing.T) {
+	t.Setenv("HOME", "/home/user")
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+	ms := func(n int64) *int64 { return &n }
+	shy, main := strPtr("github.com/chris/shy"), strPtr("main")
+
+	dbPath := setupTestDB(t, []models.Command{
+		makeCommandFull(yesterday, 9, 0, "make test", "/home/user/projects/shy", shy, main, 0, ms(90_000), nil),
+		makeCommandFull(yesterday, 9, 5, "make test", "/home/user/projects/shy", shy, main, 0, ms(30_000), nil),
+		makeCommandFull(yesterday, 9, 10, "git push", "/home/user/projects/shy", shy, main, 0, ms(1_500), nil),
+		makeCommandFull(yesterday, 10, 0, "curl -O example.com", "/tmp/a|b", nil, nil, 0, nil, nil),
+	})
+	model := initModel(t, dbPath, today)
+
+	assert.Equal(t, ""+
+		"| Context             | Commands | Duration |\n"+
+		"| ------------------- | -------: | -------: |\n"+
+		"| ~/projects/shy:main |        3 |    2m 1s |\n"+
+		"| /tmp/a\\|b           |        1 |      0ms |\n",
+		model.markdownSummaryTable())
+
+	// Unique mode keeps git push only; the filter drops /tmp/a|b
+	model.displayMode = UniqueMode
+	model.filterText = "git"
+	assert.Equal(t, ""+
+		"| Context             | Commands | Duration |\n"+
+		"| ------------------- | -------: | -------: |\n"+
+		"| ~/projects/shy:main |        1 |       1s |\n",
+		model.markdownSummaryTable())
+
+	_, cmd := model.handleKey(tea.KeyPressMsg{Code: 'M', Text: "M"})
+	assert.NotNil(t, cmd)
+	model.Update(yankResultMsg{table: true})
+	assert.Equal(t, "Copied table", model.StatusMsg())
+	model.Update(yankResultMsg{table: true, err: errors.New("no tty")})
+	assert.Equal(t, "Copy failed", model.StatusMsg())
+}
+
+// TestYankCommandRecord tests that Y in command detail copies every field of
+// the selected command as JSON, nulls included
+func TestYankCommandRecord(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+
+	cmds := phase4aCommands(yesterday)
+	cmds[1].Env = map[string]string{"AWS_PROFILE": "staging"}
+	dbPath := setupTestDB(t, cmds)
+	model := initModel(t, dbPath, today)
+	model.Update(model.loadCommandContext(2)())
+	require.Equal(t, CommandDetailView, model.ViewState())
+
+	target := model.CmdDetailTarget()
+	require.NotNil(t, target)
+	record, err := commandRecord(*target)
+	require.NoError(t, err)
+	var fields map[string]any
+	require.NoError(t, json.Unmarshal([]byte(record), &fields))
+	assert.Equal(t, float64(2), fields["id"])
+	assert.Equal(t, target.CommandText, fields["command_text"])
+	assert.Equal(t, target.WorkingDir, fields["working_dir"])
+	assert.Equal(t, map[string]any{"AWS_PROFILE": "staging"}, fields["env"])
+	for _, key := range []string{"timestamp", "exit_status", "git_repo", "git_branch", "duration", "source_app", "source_pid", "source_active"} {
+		assert.Contains(t, fields, key)
+	}
+	assert.Contains(t, record, "\n  \"id\": 2,", "the record is indented")
+
+	_, cmd := model.handleKey(tea.KeyPressMsg{Code: 'Y', Text: "Y"})
+	assert.NotNil(t, cmd)
+	model.Update(yankResultMsg{record: true})
+	assert.Equal(t, "Copied record", model.StatusMsg())
+	model.Update(yankResultMsg{record: true, err: errors.New("no tty")})
+	assert.Equal(t, "Copy failed", model.StatusMsg())
+}
@@ -0,0 +1,95 @@
+package tui
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/chris/shy/pkg/models"
+)
+
+// commitSegment describes the commands leading up to a git commit, shown as a
+// divider under the commit in the detail view (g to toggle)
+type commitSegment struct {
+	number   int    // 1-based position of the commit in the detail list
+	commands int    // commands since the previous commit, the commit included
+	message  string // the -m message, or "" when there was none
+}
+
+// label is the divider text for the segment
+func (s commitSegment) label() string {
+	label := fmt.Sprintf("commit %d", s.number)
+	if s.message != "" {
+		label += " · " + singleLine(s.message)
+	}
+	noun := "commands"
+	if s.commands == 1 {
+		noun = "command"
+	}
+	return label + fmt.Sprintf(" · %d %s", s.commands, noun)
+}
+
+// commitSegments splits commands, oldest first, at each successful git commit
+// and returns the segments keyed by the ID of the commit that ends them.
+// Commands after the last commit belong to no segment.
+func commitSegments(commands []models.Command) map[int64]commitSegment {
+	segments := make(map[int64]commitSegment)
+	since := 0
+	for _, c := range commands {
+		since++
+		if c.ExitStatus != 0 {
+			continue
+		}
+		message, ok := gitCommitMessage(c.CommandText)
+		if !ok {
+			continue
+		}
+		segments[c.ID] = commitSegment{number: len(segments) + 1, commands: since, message: message}
+		since = 0
+	}
+	return segments
+}
+
+// gitCommitMessage reports whether text runs git commit, in any part of a
+// command list, and returns the commit's -m message when it has one
+func gitCommitMessage(text string) (message string, ok bool) {
+	for _, args := range shellCommands(text) {
+		if i := gitSubcommand(args); i >= 0 && args[i] == "commit" {
+			return commitMessageArg(args[i+1:]), true
+		}
+	}
+	return "", false
+}
+
+// gitSubcommand returns the index of git's subcommand in args, skipping
+// global options such as -C <dir>, or -1 when args does not run git
+func gitSubcommand(args []string) int {
+	if len(args) == 0 || path.Base(args[0]) != "git" {
+		return -1
+	}
+	for i := 1; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "-C" || arg == "-c":
+			i++ // the option's value
+		case strings.HasPrefix(arg, "-"):
+		default:
+			return i
+		}
+	}
+	return -1
+}
+
+// commitMessageArg returns the first -m/--message value in git commit's args
+func commitMessageArg(args []string) string {
+	for i, arg := range args {
+		switch {
+		case (arg == "-m" || arg == "--message") && i+1 < len(args):
+			return args[i+1]
+		case strings.HasPrefix(arg, "--message="):
+			return strings.TrimPrefix(arg, "--message=")
+		case strings.HasPrefix(arg, "-m") && len(arg) > 2:
+			return arg[2:]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,292 @@
+package tui
+
+import (
+	"strings"
+	"time"
+
+	tea "charm.land/bubbletea/v2"
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/chris/shy/internal/summary"
+	"github.com/chris/shy/pkg/models"
+)
+
+// comparePaneMinWidth is the narrowest a side-by-side compare pane may be;
+// narrower terminals stack the panes instead
+const comparePaneMinWidth = 40
+
+// comparePaneGap separates side-by-side compare panes
+const comparePaneGap = " │ "
+
+// compareMark is the context marked with c to be compared with another
+type compareMark struct {
+	key    summary.ContextKey
+	branch summary.BranchKey
+}
+
+// comparePane is one side of the compare view: a context's commands for the
+// period, as the detail view would list them, with its own cursor and scroll
+type comparePane struct {
+	key      summary.ContextKey
+	branch   summary.BranchKey
+	commands []models.Command
+	cmdIdx   int
+	scroll   int
+}
+
+// toggleCompareMark marks the selected context for comparison, or clears
+// the mark when it is already on it
+func (m *Model) toggleCompareMark() {
+	if len(m.contexts) == 0 {
+		return
+	}
+	ctx := m.contexts[m.selectedIdx]
+	if m.isCompareMarked(ctx) {
+		m.compareMark = nil
+		return
+	}
+	m.compareMark = &compareMark{key: ctx.Key, branch: ctx.Branch}
+}
+
+// isCompareMarked reports whether ctx is the context marked with c
+func (m *Model) isCompareMarked(ctx ContextItem) bool {
+	return m.compareMark != nil && m.compareMark.key == ctx.Key && m.compareMark.branch == ctx.Branch
+}
+
+// compareMarkSuffix returns the marker drawn after the marked context's
+// name in the summary
+func (m *Model) compareMarkSuffix(ctx ContextItem) string {
+	if m.isCompareMarked(ctx) {
+		return " ◆"
+	}
+	return ""
+}
+
+// enterCompareView opens the marked context and the selected one side by
+// side, or flashes why it cannot
+func (m *Model) enterCompareView() tea.Cmd {
+	flash := func(text string) tea.Cmd {
+		m.statusMsg = text
+		return tea.Tick(2*time.Second, func(time.Time) tea.Msg { return clearStatusMsg{} })
+	}
+	if m.compareMark == nil {
+		return flash("Mark a context with c first")
+	}
+	if len(m.contexts) == 0 {
+		return nil
+	}
+	marked := -1
+	for i, ctx := range m.contexts {
+		if m.isCompareMarked(ctx) {
+			marked = i
+			break
+		}
+	}
+	switch marked {
+	case -1:
+		return flash("The marked context has no commands in this period")
+	case m.selectedIdx:
+		return flash("Select another context to compare with")
+	}
+
+	for i, idx := range []int{marked, m.selectedIdx} {
+		ctx := m.contexts[idx]
+		m.comparePanes[i] = comparePane{
+			key:      ctx.Key,
+			branch:   ctx.Branch,
+			commands: filterMinDuration(visibleCommands(ctx.Commands, m.displayMode, m.filterText, m.excludeText), m.minDuration),
+		}
+	}
+	m.compareFocus = 0
+	m.viewState = CompareView
+	return nil
+}
+
+func (m *Model) handleCompareKey(msg tea.KeyPressMsg) (*Model, tea.Cmd) {
+	pane := &m.comparePanes[m.compareFocus]
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return m, tea.Quit
+
+	case "j", "down":
+		pane.cmdIdx = m.stepIndex(pane.cmdIdx, 1, len(pane.commands))
+		m.ensureCompareCmdVisible(pane)
+		return m, nil
+
+	case "k", "up":
+		pane.cmdIdx = m.stepIndex(pane.cmdIdx, -1, len(pane.commands))
+		m.ensureCompareCmdVisible(pane)
+		return m, nil
+
+	case "tab":
+		m.compareFocus = 1 - m.compareFocus
+		return m, nil
+
+	case "y":
+		if len(pane.commands) > 0 {
+			return m, yankToClipboard(pane.commands[pane.cmdIdx].CommandText)
+		}
+		return m, nil
+
+	case "-", "esc":
+		m.viewState = SummaryView
+		return m, nil
+
+	case "?":
+		m.helpPreviousView = m.viewState
+		m.viewState = HelpView
+		return m, nil
+	}
+	return m, nil
+}
+
+// compareSideBySide reports whether the panes fit next to each other
+func (m *Model) compareSideBySide() bool {
+	return m.width-2*marginX >= 2*comparePaneMinWidth+ansi.StringWidth(comparePaneGap)
+}
+
+// comparePaneHeight returns the lines available to pane i, its title and
+// rule included, or 0 when the height is unknown and panes are not clipped
+func (m *Model) comparePaneHeight(i int) int {
+	if m.height == 0 {
+		return 0
+	}
+	// headerBar(1) + footerBar(1)
+	avail := max(m.height-2, 2)
+	if m.compareSideBySide() {
+		return avail
+	}
+	// Stacked: the first pane takes the top half
+	if i == 0 {
+		return avail / 2
+	}
+	return avail - avail/2
+}
+
+// ensureCompareCmdVisible scrolls pane to keep its selected command in view
+func (m *Model) ensureCompareCmdVisible(pane *comparePane) {
+	i := 0
+	if pane == &m.comparePanes[1] {
+		i = 1
+	}
+	height := m.comparePaneHeight(i)
+	if height == 0 {
+		return
+	}
+	rows := max(height-2, 1)
+	if pane.cmdIdx < pane.scroll {
+		pane.scroll = pane.cmdIdx
+	}
+	if pane.cmdIdx >= pane.scroll+rows {
+		pane.scroll = pane.cmdIdx - rows + 1
+	}
+}
+
+// compareTimeLabel formats a compare row's time: the time of day, with the
+// weekday or date when the period spans several days
+func (m *Model) compareTimeLabel(timestamp int64) string {
+	t := time.Unix(timestamp, 0)
+	switch m.period {
+	case WeekPeriod:
+		return t.Format("Mon 3:04pm")
+	case MonthPeriod:
+		return t.Format("Jan _2 3:04pm")
+	default:
+		return t.Format("3:04pm")
+	}
+}
+
+// renderComparePane renders pane i as lines of exactly width columns: a
+// title naming the context, a rule, then its commands. A nonzero height
+// clips the commands to fit and pads short lists.
+func (m *Model) renderComparePane(i, width, height int) []string {
+	pane := &m.comparePanes[i]
+	focused := i == m.compareFocus
+	pad := func(line string) string {
+		line = ansi.Truncate(line, width, "…")
+		return line + strings.Repeat(" ", max(width-ansi.StringWidth(line), 0))
+	}
+
+	name := formatContextName(pane.key, pane.branch)
+	titleStyle := countStyle
+	if focused {
+		titleStyle = bucketLabelStyle
+	}
+	lines := []string{
+		pad(titleStyle.Render(name) + countStyle.Render(" ("+formatThousands(len(pane.commands))+")")),
+		separatorStyle.Render(strings.Repeat("─", width)),
+	}
+
+	if len(pane.commands) == 0 {
+		lines = append(lines, pad(countStyle.Render("  No commands")))
+	}
+	end := len(pane.commands)
+	if height > 0 {
+		end = min(end, pane.scroll+max(height-2, 1))
+	}
+	for idx := pane.scroll; idx < end; idx++ {
+		cmd := pane.commands[idx]
+		first, _ := firstLine(cmd.CommandText)
+		label := countStyle.Render(m.compareTimeLabel(cmd.Timestamp) + "  ")
+		switch {
+		case idx == pane.cmdIdx && focused:
+			lines = append(lines, pad(selectedStyle.Render("▶ ")+label+selectedStyle.Render(first)))
+		case idx == pane.cmdIdx:
+			lines = append(lines, pad(countStyle.Render("▷ ")+label+normalStyle.Render(first)))
+		default:
+			lines = append(lines, pad("  "+label+normalStyle.Render(first)))
+		}
+	}
+
+	for height > 0 && len(lines) < height {
+		lines = append(lines, strings.Repeat(" ", width))
+	}
+	return lines
+}
+
+func (m *Model) renderCompareView() string {
+	var b strings.Builder
+	margin := strings.Repeat(" ", marginX)
+	contentWidth := max(m.width-2*marginX, 20)
+
+	b.WriteString(m.renderHeaderBar())
+	b.WriteString("\n")
+
+	var body []string
+	if m.compareSideBySide() {
+		paneWidth := (contentWidth - ansi.StringWidth(comparePaneGap)) / 2
+		left := m.renderComparePane(0, paneWidth, m.comparePaneHeight(0))
+		right := m.renderComparePane(1, paneWidth, m.comparePaneHeight(1))
+		blank := strings.Repeat(" ", paneWidth)
+		for i := range max(len(left), len(right)) {
+			l, r := blank, blank
+			if i < len(left) {
+				l = left[i]
+			}
+			if i < len(right) {
+				r = right[i]
+			}
+			body = append(body, margin+l+separatorStyle.Render(comparePaneGap)+r)
+		}
+	} else {
+		for i := range m.comparePanes {
+			for _, line := range m.renderComparePane(i, contentWidth, m.comparePaneHeight(i)) {
+				body = append(body, margin+line)
+			}
+		}
+	}
+
+	for _, line := range body {
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	// Pad to push footer to bottom
+	if m.height > 0 {
+		for i := len(body); i < m.height-2; i++ {
+			b.WriteString("\n")
+		}
+	}
+
+	b.WriteString(m.renderFooterBar())
+	return b.String()
+}
@@ -0,0 +1,99 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// contextsQuery records the database query behind a context load, for the
+// debug overlay: the query run once per window (one window for the period,
+// or one per day with a weekday filter) and the rows it returned in all
+type contextsQuery struct {
+	name    string
+	windows [][2]int64
+	rows    int
+}
+
+// debugOverlayLines returns the debug overlay content: the model state that
+// decides which contexts and commands are shown
+func (m *Model) debugOverlayLines() []string {
+	start, end := m.dateRange()
+	commands, visible := 0, 0
+	for _, ctx := range m.contexts {
+		commands += len(ctx.Commands)
+		visible += filteredCommandCount(ctx.Commands, m.displayMode, m.filterText, m.excludeText)
+	}
+
+	lines := []string{
+		fmt.Sprintf("Period:     %s %s", m.periodName(), m.currentDate.Format("2006-01-02")),
+		fmt.Sprintf("Range:      %d – %d", start, end),
+		fmt.Sprintf("            %s – %s", debugTime(start), debugTime(end)),
+		fmt.Sprintf("Weekday:    %s", m.weekdayName()),
+		fmt.Sprintf("Mode:       %s", m.activeModeName()),
+		fmt.Sprintf("Filter:     %q", m.filterText),
+		fmt.Sprintf("Exclude:    %q", m.excludeText),
+		fmt.Sprintf("Contexts:   %d", len(m.contexts)),
+		fmt.Sprintf("Commands:   %d loaded, %d visible", commands, visible),
+	}
+	return append(lines, m.lastQueryLines()...)
+}
+
+// lastQueryLines describes the last context load's query: the call itself
+// for a single window, or the call's count and each window's range
+func (m *Model) lastQueryLines() []string {
+	q := m.lastQuery
+	switch {
+	case q == nil:
+		return []string{"Last query: none"}
+	case len(q.windows) == 1:
+		return []string{fmt.Sprintf("Last query: %s(%d, %d) → %d rows", q.name, q.windows[0][0], q.windows[0][1], q.rows)}
+	}
+	lines := []string{fmt.Sprintf("Last query: %s × %d windows → %d rows", q.name, len(q.windows), q.rows)}
+	for _, w := range q.windows {
+		lines = append(lines, fmt.Sprintf("            %d – %d  %s", w[0], w[1], time.Unix(w[0], 0).Format("Mon 2006-01-02")))
+	}
+	return lines
+}
+
+// debugTime formats a Unix timestamp for the debug overlay
+func debugTime(ts int64) string {
+	return time.Unix(ts, 0).Format("2006-01-02 15:04:05 MST")
+}
+
+// renderDebugOverlay draws the debug box over the middle rows of base
+func (m *Model) renderDebugOverlay(base string) string {
+	content := m.debugOverlayLines()
+	inner := 0
+	for _, line := range content {
+		inner = max(inner, ansi.StringWidth(line))
+	}
+
+	title := " Debug (ctrl+g to close) "
+	inner = max(inner, ansi.StringWidth(title)-2)
+	inner = min(inner, max(m.width-6, 10))
+	box := []string{"┌" + title + strings.Repeat("─", max(inner+2-ansi.StringWidth(title), 0)) + "┐"}
+	for _, line := range content {
+		line = ansi.Truncate(line, inner, "…")
+		box = append(box, "│ "+line+strings.Repeat(" ", inner-ansi.StringWidth(line))+" │")
+	}
+	box = append(box, "└"+strings.Repeat("─", inner+2)+"┘")
+	return m.overlayBox(base, box)
+}
+
+// overlayBox draws box centered over the middle rows of base
+func (m *Model) overlayBox(base string, box []string) string {
+	lines := strings.Split(base, "\n")
+	top := max((len(lines)-len(box))/2, 0)
+	left := strings.Repeat(" ", max((m.width-ansi.StringWidth(box[0]))/2, 0))
+	for i, row := range box {
+		if top+i < len(lines) {
+			lines[top+i] = left + normalStyle.Render(row)
+		} else {
+			lines = append(lines, left+normalStyle.Render(row))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
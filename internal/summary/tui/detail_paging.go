@@ -0,0 +1,102 @@
+package tui
+
+import (
+	tea "charm.land/bubbletea/v2"
+
+	"github.com/chris/shy/internal/db"
+	"github.com/chris/shy/pkg/models"
+)
+
+// detailPageSize is the number of commands fetched per page for a context
+// detail view. Contexts with more commands than this are loaded in pages as
+// the cursor nears the end of what has been loaded.
+const detailPageSize = 500
+
+// detailPageLoadedMsg carries one page of the detail view's commands
+type detailPageLoadedMsg struct {
+	gen      int
+	commands []models.Command
+	err      error
+}
+
+// shouldPageDetail reports whether the context is large enough to page. A
+// weekday filter is left to the in-memory path, since it is not one range,
+// and so is newest-first order, since pages are fetched oldest first, and
+// latest-unique, since a later page may hold a later run, and the busiest
+// session, which needs every command to count. The collapsed
+// non-git context is not one directory the database can page through.
+func (m *Model) shouldPageDetail(ctx ContextItem) bool {
+	return m.db != nil && m.weekdayFilter == 0 && !m.detailNewestFirst && !m.detailLatestUnique && !m.detailMainSession &&
+		!isNoRepoContext(ctx.Key) && ctx.CommandCount > detailPageSize
+}
+
+// loadNextDetailPage requests the page after the last loaded command, unless
+// one is already in flight or the context has been fully loaded
+func (m *Model) loadNextDetailPage() tea.Cmd {
+	if m.detailPageLoading || m.detailExhausted {
+		return nil
+	}
+	m.detailPageLoading = true
+
+	start, end := m.dateRange()
+	opts := db.ContextPageOptions{
+		StartTime:       start,
+		EndTime:         end,
+		WorkingDir:      m.detailContextKey.WorkingDir,
+		GitRepo:         m.detailContextKey.GitRepo,
+		GitBranch:       m.detailContextBranch.DBValue(),
+		AcrossWorktrees: m.groupWorktrees && isWorktreeGroupable(m.detailContextKey, m.detailContextBranch),
+		Mode:            m.displayMode,
+		Filter:          m.filterText,
+		Exclude:         m.excludeText,
+		MinDuration:     m.minDuration.Milliseconds(),
+		Limit:           detailPageSize,
+	}
+	if n := len(m.detailLoaded); n > 0 {
+		opts.AfterTimestamp = m.detailLoaded[n-1].Timestamp
+		opts.AfterTimestampNs = m.detailLoaded[n-1].TimestampNs
+		opts.AfterID = m.detailLoaded[n-1].ID
+	}
+
+	database := m.db
+	gen := m.detailPageGen
+	return func() tea.Msg {
+		commands, err := database.GetCommandsForContextPaged(opts)
+		return detailPageLoadedMsg{gen: gen, commands: commands, err: err}
+	}
+}
+
+// loadDetailPageIfNeeded requests the next page once the rows below the
+// cursor no longer fill a screen
+func (m *Model) loadDetailPageIfNeeded() tea.Cmd {
+	if !m.detailPaged || len(m.detailCommands)-m.detailCmdIdx > m.height {
+		return nil
+	}
+	return m.loadNextDetailPage()
+}
+
+// appendDetailPage adds a loaded page to the detail view and rebuilds its
+// buckets, keeping the cursor where it is
+func (m *Model) appendDetailPage(msg detailPageLoadedMsg) tea.Cmd {
+	if msg.gen != m.detailPageGen || !m.detailPaged {
+		return nil // the detail view was re-entered while loading
+	}
+	m.detailPageLoading = false
+	if msg.err != nil {
+		// Stop paging rather than retrying on every keypress
+		m.detailExhausted = true
+		return nil
+	}
+
+	first := len(m.detailLoaded) == 0
+	m.detailLoaded = append(m.detailLoaded, msg.commands...)
+	m.detailExhausted = len(msg.commands) < detailPageSize
+	m.buildDetail(m.detailLoaded)
+	if first {
+		if cmd := m.detailBuilt(); cmd != nil {
+			return cmd
+		}
+	}
+	// Collapsed repeats can leave a page short of a screen; keep loading
+	return m.loadDetailPageIfNeeded()
+}
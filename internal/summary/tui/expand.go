@@ -0,0 +1,92 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "charm.land/bubbletea/v2"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// expandPopupChrome is the number of rows around the popup's text: the top
+// and bottom borders plus a row of the view above and below
+const expandPopupChrome = 4
+
+// openExpandPopup shows the selected detail command's full text, newlines
+// and all, in a popup over the detail list
+func (m *Model) openExpandPopup() {
+	if len(m.detailCommands) == 0 {
+		return
+	}
+	target := m.detailCommands[m.detailCmdIdx]
+	m.expandTarget = &target
+	m.expandScroll = 0
+}
+
+func (m *Model) handleExpandKey(msg tea.KeyPressMsg) (*Model, tea.Cmd) {
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return m, tea.Quit
+	case "esc", "x", "enter":
+		m.expandTarget = nil
+	case "j", "down":
+		maxOffset := max(len(m.expandLines())-m.expandAvail(), 0)
+		if m.expandScroll < maxOffset {
+			m.expandScroll++
+		}
+	case "k", "up":
+		if m.expandScroll > 0 {
+			m.expandScroll--
+		}
+	case "y":
+		return m, yankToClipboard(m.expandTarget.CommandText)
+	}
+	return m, nil
+}
+
+// expandInner returns the popup's text width inside its border
+func (m *Model) expandInner() int {
+	return max(m.width-2*marginX-4, 10)
+}
+
+// expandAvail returns how many text rows fit in the popup
+func (m *Model) expandAvail() int {
+	if m.height == 0 {
+		return len(m.expandLines())
+	}
+	return max(m.height-expandPopupChrome, 1)
+}
+
+// expandLines returns the popup's numbered lines, wrapped to fit
+func (m *Model) expandLines() []string {
+	text := m.expandTarget.CommandText
+	lineNumWidth := len(fmt.Sprintf("%d", strings.Count(text, "\n")+1))
+	var lines []string
+	for _, vl := range buildCmdTextLines(text, lineNumWidth, max(m.expandInner()-lineNumWidth-2, 1)) {
+		numStr := strings.Repeat(" ", lineNumWidth)
+		if !vl.isContinue {
+			numStr = fmt.Sprintf("%*d", lineNumWidth, vl.lineNum)
+		}
+		lines = append(lines, numStr+"  "+vl.text)
+	}
+	return lines
+}
+
+// renderExpandOverlay draws the full command text popup over base
+func (m *Model) renderExpandOverlay(base string) string {
+	lines := m.expandLines()
+	start := min(m.expandScroll, len(lines))
+	end := min(start+m.expandAvail(), len(lines))
+
+	inner := m.expandInner()
+	title := " Command (esc to close) "
+	if len(lines) > end-start {
+		title = fmt.Sprintf(" Command %d–%d of %d (esc to close) ", start+1, end, len(lines))
+	}
+	box := []string{"┌" + title + strings.Repeat("─", max(inner+2-ansi.StringWidth(title), 0)) + "┐"}
+	for _, line := range lines[start:end] {
+		box = append(box, "│ "+line+strings.Repeat(" ", max(inner-ansi.StringWidth(line), 0))+" │")
+	}
+	box = append(box, "└"+strings.Repeat("─", inner+2)+"┘")
+	return m.overlayBox(base, box)
+}
@@ -0,0 +1,145 @@
+package tui
+
+import (
+	"strings"
+
+	"charm.land/lipgloss/v2"
+)
+
+var (
+	highlightProgramStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("14")).Bold(true)
+	highlightFlagStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("3"))
+	highlightStringStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+	highlightOperatorStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("13"))
+)
+
+// renderCommandLine renders one line of a command in style, highlighted
+// when WithHighlight is set
+func (m *Model) renderCommandLine(line string, style lipgloss.Style) string {
+	if m.highlight {
+		return highlightCommand(line, style)
+	}
+	return style.Render(line)
+}
+
+// highlightCommand renders one line of a command with its program names,
+// flags (words starting with -), quoted strings and list operators (|, &&,
+// ;, …) colored, and everything else in base. Like shellSegments it is a
+// heuristic for reading command lines: a word after an operator is taken to
+// name a program, and leading VAR=value assignments are skipped.
+func highlightCommand(line string, base lipgloss.Style) string {
+	var b strings.Builder
+	runes := []rune(line)
+	program := true // the next word names a program
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case r == ' ' || r == '\t':
+			j := i
+			for j < len(runes) && (runes[j] == ' ' || runes[j] == '\t') {
+				j++
+			}
+			b.WriteString(string(runes[i:j]))
+			i = j
+		case isOperatorAt(runes, i):
+			j := i
+			for j < len(runes) && isOperatorAt(runes, j) {
+				j++
+			}
+			b.WriteString(highlightOperatorStyle.Render(string(runes[i:j])))
+			i = j
+			program = true
+		default:
+			j := wordEnd(runes, i)
+			word := string(runes[i:j])
+			style := base
+			switch {
+			case program && !isShellAssignment(word):
+				style = highlightProgramStyle
+				program = false
+			case strings.HasPrefix(word, "-"):
+				style = highlightFlagStyle
+			}
+			b.WriteString(renderWord(word, style))
+			i = j
+		}
+	}
+	return b.String()
+}
+
+// wordEnd returns the index after the word starting at runes[start]: the
+// next unquoted, unescaped space or operator, or the end of the line
+func wordEnd(runes []rune, start int) int {
+	var quote rune
+	for i := start; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote != 0:
+			if r == '\\' && quote == '"' {
+				i++
+			} else if r == quote {
+				quote = 0
+			}
+		case r == '\\':
+			i++
+		case r == '\'' || r == '"':
+			quote = r
+		case r == ' ' || r == '\t' || isOperatorAt(runes, i):
+			return i
+		}
+	}
+	return len(runes)
+}
+
+// isOperatorAt reports whether runes[i] is part of a list operator or pipe,
+// and not the & of a redirection such as 2>&1 or &>log
+func isOperatorAt(runes []rune, i int) bool {
+	switch runes[i] {
+	case '|', ';', '(', ')':
+		return true
+	case '&':
+		redirect := i > 0 && (runes[i-1] == '>' || runes[i-1] == '<') ||
+			i+1 < len(runes) && runes[i+1] == '>'
+		return !redirect
+	}
+	return false
+}
+
+// renderWord renders word in style, with its quoted parts (quotes included)
+// in the string style
+func renderWord(word string, style lipgloss.Style) string {
+	if !strings.ContainsAny(word, `'"`) {
+		return style.Render(word)
+	}
+	var b strings.Builder
+	runes := []rune(word)
+	start := 0
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r == '\\' {
+			i++
+			continue
+		}
+		if r != '\'' && r != '"' {
+			continue
+		}
+		if start < i {
+			b.WriteString(style.Render(string(runes[start:i])))
+		}
+		j := i + 1
+		for j < len(runes) && runes[j] != r {
+			if runes[j] == '\\' && r == '"' {
+				j++
+			}
+			j++
+		}
+		j = min(j+1, len(runes))
+		b.WriteString(highlightStringStyle.Render(string(runes[i:j])))
+		start = j
+		i = j - 1
+	}
+	if start < len(runes) {
+		b.WriteString(style.Render(string(runes[start:])))
+	}
+	return b.String()
+}
@@ -1,5 +1,114 @@
 package tui
 
+import (
+	"slices"
+	"strings"
+
+	tea "charm.land/bubbletea/v2"
+)
+
+// KeyMap maps actions to the keys that trigger them, as key strings such as
+// "k", "up" or "ctrl+p". A key bound to an action replaces its default keys,
+// which then do nothing; actions left nil keep their defaults (see
+// DefaultKeyMap). Keys not covered here, and ctrl+c, are fixed.
+type KeyMap struct {
+	Up         []string
+	Down       []string
+	PrevPeriod []string
+	NextPeriod []string
+	UniqueMode []string
+	AllMode    []string
+	Filter     []string
+	Help       []string
+	Quit       []string
+}
+
+// DefaultKeyMap returns the built-in bindings
+func DefaultKeyMap() KeyMap {
+	return KeyMap{
+		Up:         []string{"k", "up"},
+		Down:       []string{"j", "down"},
+		PrevPeriod: []string{"h"},
+		NextPeriod: []string{"l"},
+		UniqueMode: []string{"u"},
+		AllMode:    []string{"a"},
+		Filter:     []string{"/"},
+		Help:       []string{"?"},
+		Quit:       []string{"q"},
+	}
+}
+
+// keyAction is one remappable action: the key the handlers match it by, and
+// its configured and default keys
+type keyAction struct {
+	canonical string
+	keys      []string
+	defaults  []string
+}
+
+// actions lists the key map's actions, filling nil fields from the defaults
+func (km KeyMap) actions() []keyAction {
+	def := DefaultKeyMap()
+	pairs := []struct {
+		keys, defaults []string
+	}{
+		{km.Up, def.Up},
+		{km.Down, def.Down},
+		{km.PrevPeriod, def.PrevPeriod},
+		{km.NextPeriod, def.NextPeriod},
+		{km.UniqueMode, def.UniqueMode},
+		{km.AllMode, def.AllMode},
+		{km.Filter, def.Filter},
+		{km.Help, def.Help},
+		{km.Quit, def.Quit},
+	}
+	actions := make([]keyAction, len(pairs))
+	for i, p := range pairs {
+		keys := p.keys
+		if keys == nil {
+			keys = p.defaults
+		}
+		actions[i] = keyAction{canonical: p.defaults[0], keys: keys, defaults: p.defaults}
+	}
+	return actions
+}
+
+// translate maps a pressed key to the default key the handlers match. ok is
+// false for a default key whose action has been bound elsewhere.
+func (km KeyMap) translate(msg tea.KeyPressMsg) (tea.KeyPressMsg, bool) {
+	key := msg.String()
+	actions := km.actions()
+	for _, a := range actions {
+		if slices.Contains(a.keys, key) {
+			if key == a.canonical {
+				return msg, true
+			}
+			r := []rune(a.canonical)[0]
+			return tea.KeyPressMsg{Code: r, Text: a.canonical}, true
+		}
+	}
+	for _, a := range actions {
+		if slices.Contains(a.defaults, key) {
+			return msg, false
+		}
+	}
+	return msg, true
+}
+
+// label rewrites the keys of help entries for remapped actions to the keys
+// that now trigger them
+func (km KeyMap) label(bindings []helpBinding) []helpBinding {
+	out := slices.Clone(bindings)
+	for i, b := range out {
+		for _, a := range km.actions() {
+			if b.key == a.canonical && !slices.Equal(a.keys, a.defaults) {
+				out[i].key = strings.Join(a.keys, "/")
+			}
+		}
+	}
+	return out
+}
+
 // helpBinding represents a single keybinding entry for the help view.
 type helpBinding struct {
 	key  string
@@ -15,6 +124,10 @@ func bindingsForView(vs ViewState) []helpBinding {
 		return commandDetailBindings()
 	case CommandTextView:
 		return commandTextBindings()
+	case CompareView:
+		return compareBindings()
+	case RepoListView:
+		return repoListBindings()
 	default:
 		return summaryBindings()
 	}
@@ -25,16 +138,45 @@ func summaryBindings() []helpBinding {
 		{"j", "Navigate down"},
 		{"k", "Navigate up"},
 		{"enter", "Open context"},
+		{"0", "Flat list of every command (0 again to leave)"},
+		{"g", "Collapse to repos"},
+		{"-", "Back to repos (after enter on a repo)"},
+		{"c", "Mark context to compare"},
+		{"C", "Compare marked context with this one"},
+		{"A", "Sort contexts by count / name"},
+		{"'", "Mark period to compare totals with (again to clear)"},
+		{"v", "Select days / export selection"},
+		{"#", "Tag context's commands (-tag to untag)"},
+		{"H", "Same weekday, previous week"},
+		{"L", "Same weekday, next week"},
 		{"h", "Previous period"},
 		{"l", "Next period"},
+		{"<", "Same weekday, previous week"},
+		{">", "Same weekday, next week"},
+		{"w", "Start of week"},
+		{"W", "Toggle week dates / ISO week numbers"},
 		{"t", "Today"},
 		{"e", "Yesterday"},
 		{"u", "Unique mode"},
 		{"a", "All mode"},
-		{"/", "Filter"},
-		{"esc", "Clear filter"},
+		{"tab", "Next display mode (shift+tab: previous)"},
+		{"m", "Cycle count / duration / last used / active / total vs unique"},
+		{"M", "Copy summary as a markdown table"},
+		{"d", "Cycle weekday filter (Mon–Sun, all)"},
+		{"T", "Toggle activity timeline"},
+		{"s", "Toggle slowest commands"},
+		{"p", "Cycle preview: first / last command / none"},
+		{"F", "Previous day with a failure in this context"},
+		{"~", "Show a random command of the period"},
+		{"/", "Filter (up/down recall previous filters)"},
+		{"!", "Exclude (globs, | separated)"},
+		{"esc", "Clear filter, then exclude, then directory scope"},
 		{"]", "Cycle period up"},
 		{"[", "Cycle period down"},
+		{"R", "Reload from the database"},
+		{"Y", "Copy context directory path"},
+		{"o", "Quit and cd to context (--cd)"},
+		{"ctrl+g", "Debug overlay"},
 		{"?", "Help"},
 		{"q", "Quit"},
 	}
@@ -45,22 +187,49 @@ func contextDetailBindings() []helpBinding {
 		{"j", "Navigate down"},
 		{"k", "Navigate up"},
 		{"enter", "View command detail"},
+		{"x", "Expand full multi-line command"},
 		{"y", "Yank command"},
 		{"S", "Star command"},
-		{"D", "Delete command"},
+		{"D", "Delete command (to trash)"},
+		{"=", "Filter to this exact command"},
+		{"@", "Scope summary to this command's directory"},
+		{"n", "Edit context note"},
+		{"c", "Collapse repeated commands"},
+		{"U", "Each command once, at its latest run"},
+		{"s", "Only the session with the most commands"},
+		{"i", "Toggle timestamp / id order"},
+		{"r", "Toggle oldest / newest first"},
+		{"N", "Toggle event ids (for shy fc)"},
+		{"g", "Divide at git commits"},
+		{"p", "Pipelines as program names only"},
+		{"z", "Expand hour into minutes (day view)"},
+		{"T", "Cycle minimum duration (1s, 5s, 30s, 1m, 5m)"},
+		{"F", "Previous day with a failure in this context"},
 		{"-", "Back to summary"},
 		{"H", "Previous context"},
 		{"L", "Next context"},
+		{"A", "Sort contexts by count / name (H/L follow)"},
+		{"'", "Mark period to compare totals with (again to clear)"},
 		{"h", "Previous period"},
 		{"l", "Next period"},
+		{"<", "Same weekday, previous week"},
+		{">", "Same weekday, next week"},
+		{"w", "Start of week"},
+		{"W", "Toggle week dates / ISO week numbers"},
 		{"t", "Today"},
 		{"e", "Yesterday"},
 		{"u", "Unique mode"},
 		{"a", "All mode"},
-		{"/", "Filter"},
-		{"esc", "Clear filter"},
+		{"tab", "Next display mode (shift+tab: previous)"},
+		{"/", "Filter (up/down recall previous filters)"},
+		{"!", "Exclude (globs, | separated)"},
+		{"esc", "Clear filter, then exclude, then directory scope"},
 		{"]", "Cycle period up"},
 		{"[", "Cycle period down"},
+		{"R", "Reload from the database"},
+		{"Y", "Copy context directory path"},
+		{"o", "Quit and cd to context (--cd)"},
+		{"ctrl+g", "Debug overlay"},
 		{"?", "Help"},
 		{"q", "Quit"},
 	}
@@ -77,13 +246,50 @@ func commandTextBindings() []helpBinding {
 	}
 }
 
+func compareBindings() []helpBinding {
+	return []helpBinding{
+		{"j", "Navigate down"},
+		{"k", "Navigate up"},
+		{"tab", "Switch pane"},
+		{"y", "Yank command"},
+		{"-", "Back to summary"},
+		{"?", "Help"},
+		{"q", "Quit"},
+	}
+}
+
+func repoListBindings() []helpBinding {
+	return []helpBinding{
+		{"j", "Navigate down"},
+		{"k", "Navigate up"},
+		{"enter", "Open repo's branches"},
+		{"A", "Sort repos by count / name"},
+		{"h", "Previous period"},
+		{"l", "Next period"},
+		{"t", "Today"},
+		{"e", "Yesterday"},
+		{"]", "Cycle period up"},
+		{"[", "Cycle period down"},
+		{"g", "Back to summary"},
+		{"R", "Reload from the database"},
+		{"?", "Help"},
+		{"q", "Quit"},
+	}
+}
+
 func commandDetailBindings() []helpBinding {
 	return []helpBinding{
 		{"j", "Navigate down"},
 		{"k", "Navigate up"},
+		{"ctrl+n", "Scroll session context down (ctrl+p: up)"},
+		{"z", "Re-center session context on the command"},
 		{"y", "Yank command"},
+		{"Y", "Yank full record as JSON"},
 		{"S", "Star command"},
-		{"D", "Delete command"},
+		{"D", "Delete command (to trash)"},
+		{"b", "Toggle branch switch dividers"},
+		{"@", "Scope summary to this command's directory"},
+		{"U", "Toggle local time / UTC"},
 		{"-", "Back to context"},
 		{"?", "Help"},
 		{"q", "Quit"},
@@ -0,0 +1,92 @@
+package tui
+
+import (
+	"fmt"
+	"time"
+
+	tea "charm.land/bubbletea/v2"
+)
+
+// periodMark is the reference period marked with ': the headers of other
+// periods of the same length show their command total against its total,
+// while the filters that narrow the total are the ones it was marked under
+type periodMark struct {
+	date    time.Time
+	period  Period
+	total   int
+	filters totalFilters
+}
+
+// totalFilters is the filter state that decides which commands a period's
+// total counts: the weekday (d), directory (@) and repo drilled into
+type totalFilters struct {
+	weekday    int
+	dir        string
+	repoScoped bool
+	repoScope  string
+}
+
+// totalFilters returns the filters the listed contexts were loaded under
+func (m *Model) totalFilters() totalFilters {
+	return totalFilters{weekday: m.weekdayFilter, dir: m.dirFilter, repoScoped: m.repoScoped, repoScope: m.repoScope}
+}
+
+// periodTotal returns the number of commands across the listed contexts
+func (m *Model) periodTotal() int {
+	total := 0
+	for _, ctx := range m.contexts {
+		total += ctx.CommandCount
+	}
+	return total
+}
+
+// isMarkedPeriod reports whether the displayed period is the marked one
+func (m *Model) isMarkedPeriod() bool {
+	if m.mark == nil || m.mark.period != m.period {
+		return false
+	}
+	markStart, _ := dateRangeForPeriod(m.mark.date, m.mark.period)
+	start, _ := dateRangeForPeriod(m.currentDate, m.period)
+	return markStart == start
+}
+
+// toggleMark marks the displayed period as the reference, keeping its
+// current total and the filters behind it, or clears the mark when the
+// period is already marked
+func (m *Model) toggleMark() tea.Cmd {
+	if m.isMarkedPeriod() {
+		m.mark = nil
+		m.statusMsg = "Mark cleared"
+	} else {
+		m.mark = &periodMark{date: m.currentDate, period: m.period, total: m.periodTotal(), filters: m.totalFilters()}
+		m.statusMsg = "Marked " + m.markLabel()
+	}
+	return tea.Tick(2*time.Second, func(time.Time) tea.Msg {
+		return clearStatusMsg{}
+	})
+}
+
+// markLabel names the marked period, e.g. "Feb 1" or "Week of Feb 2"
+func (m *Model) markLabel() string {
+	if m.mark.period == DayPeriod {
+		return formatShortDate(m.mark.date, m.now().Year())
+	}
+	return periodDateLabel(m.mark.date, m.mark.period, m.weekLabels, m.now)
+}
+
+// renderMarkDelta renders the header segment comparing the displayed
+// period's total with the marked one's, e.g. " +8 commands vs Feb 1 ". It
+// is empty with no mark, when the mark is a period of another length, or
+// when the totals would be counted under different filters.
+func (m *Model) renderMarkDelta() string {
+	if m.mark == nil || m.mark.period != m.period {
+		return ""
+	}
+	if m.isMarkedPeriod() {
+		return barDimStyle.Render(" ◆ marked ")
+	}
+	if m.mark.filters != m.totalFilters() {
+		return ""
+	}
+	return barDimStyle.Render(fmt.Sprintf(" %+d commands vs %s ", m.periodTotal()-m.mark.total, m.markLabel()))
+}
@@ -0,0 +1,47 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+)
+
+// markdownSummaryTable renders the summary's contexts as a GitHub-flavored
+// markdown table for pasting into chat. Counts and durations cover the
+// commands the filter, exclude and mode leave visible; contexts left with
+// none are dropped. Columns are padded so the table also reads as text.
+func (m *Model) markdownSummaryTable() string {
+	rows := [][3]string{{"Context", "Commands", "Duration"}}
+	for _, ctx := range m.contexts {
+		commands := visibleCommands(ctx.Commands, m.displayMode, m.filterText, m.excludeText)
+		if len(commands) == 0 {
+			continue
+		}
+		var total int64
+		for _, cmd := range commands {
+			if cmd.Duration != nil {
+				total += *cmd.Duration
+			}
+		}
+		name := strings.ReplaceAll(formatContextName(ctx.Key, ctx.Branch)+worktreeSuffix(ctx), "|", `\|`)
+		rows = append(rows, [3]string{name, fmt.Sprint(len(commands)), formatDurationHuman(&total)})
+	}
+
+	var widths [3]int
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], len(cell))
+		}
+	}
+
+	var b strings.Builder
+	writeRow := func(row [3]string) {
+		fmt.Fprintf(&b, "| %-*s | %*s | %*s |\n", widths[0], row[0], widths[1], row[1], widths[2], row[2])
+	}
+	writeRow(rows[0])
+	fmt.Fprintf(&b, "| %s | %s: | %s: |\n",
+		strings.Repeat("-", widths[0]), strings.Repeat("-", widths[1]-1), strings.Repeat("-", widths[2]-1))
+	for _, row := range rows[1:] {
+		writeRow(row)
+	}
+	return b.String()
+}
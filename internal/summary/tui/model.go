@@ -1,7 +1,10 @@
 package tui
 
 import (
+	"errors"
 	"fmt"
+	"regexp"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -22,16 +25,21 @@ const (
 	CommandDetailView
 	CommandTextView
 	HelpView
+	CompareView
+	RepoListView
 )
 
 // DisplayMode controls which commands are shown based on frequency
-type DisplayMode int
+type DisplayMode = db.DisplayMode
 
 const (
-	AllMode DisplayMode = iota
-	UniqueMode
+	AllMode    = db.AllMode
+	UniqueMode = db.UniqueMode
 )
 
+// displayModes lists the display modes in the order tab cycles through them
+var displayModes = []DisplayMode{AllMode, UniqueMode}
+
 // Period represents the time granularity for the view
 type Period int
 
@@ -41,6 +49,42 @@ const (
 	MonthPeriod
 )
 
+// Metric selects what the summary's right column shows for each context
+type Metric int
+
+const (
+	CountMetric    Metric = iota // number of commands
+	DurationMetric               // total recorded command duration
+	LastUsedMetric               // time since the context's last command
+	ActiveMetric                 // wall time between prompts, ignoring long breaks
+	RatioMetric                  // total commands against those run only once
+)
+
+// WeekLabelStyle selects how weeks are labeled in the week header and the
+// month view's week buckets
+type WeekLabelStyle int
+
+const (
+	DateWeekLabels WeekLabelStyle = iota // "Week of Feb 2"
+	ISOWeekLabels                        // "2026-W06", buckets "W06"
+)
+
+// RelativeLabels are the markers the header shows ahead of the date when
+// the displayed period is the current or previous one. An empty label shows
+// nothing.
+type RelativeLabels struct {
+	Today, Yesterday     string
+	ThisWeek, LastWeek   string
+	ThisMonth, LastMonth string
+}
+
+// DefaultRelativeLabels mark today and yesterday only
+var DefaultRelativeLabels = RelativeLabels{Today: "TODAY", Yesterday: "YESTERDAY"}
+
+// activeGapCap caps each gap between consecutive commands counted by the
+// active metric, so a lunch break adds no more than a short pause would
+const activeGapCap = 5 * time.Minute
+
 // periodPeekData holds a label and command count for an adjacent period
 type periodPeekData struct {
 	dateLabel string
@@ -54,6 +98,16 @@ func (p *periodPeekData) Count() int        { return p.count }
 type DetailBucket struct {
 	Label    string
 	Commands []models.Command
+	// Minute marks a minute of an hour expanded with z. It follows its
+	// hour's header, which then holds no commands of its own.
+	Minute bool
+}
+
+// repeatRun describes a detail row folded from consecutive identical commands.
+// The row itself is the most recent occurrence.
+type repeatRun struct {
+	count          int
+	firstTimestamp int64
 }
 
 // ContextItem represents a context with its command count
@@ -62,6 +116,7 @@ type ContextItem struct {
 	Branch       summary.BranchKey
 	CommandCount int
 	Commands     []models.Command
+	WorkingDirs  []string // directories of a merged worktree context; nil otherwise
 }
 
 // Model represents the TUI state
@@ -83,9 +138,17 @@ type Model struct {
 	detailScrollOffset   int
 	detailContextKey     summary.ContextKey
 	detailContextBranch  summary.BranchKey
+	detailWorkingDirs    []string // set when the detail context merges worktrees
 	pendingDetailReentry bool
 	pendingDeletedID     int64 // after delete, position cursor near this ID
 
+	// Paged detail view for large contexts (see detail_paging.go)
+	detailPaged       bool             // detailCommands is built from pages loaded so far
+	detailLoaded      []models.Command // filtered commands of the pages loaded so far
+	detailExhausted   bool             // the last page has been loaded
+	detailPageLoading bool             // a page request is in flight
+	detailPageGen     int              // bumped on every detail entry to drop stale pages
+
 	// Empty state peek data (adjacent period hints)
 	emptyPrevPeriod *periodPeekData
 	emptyNextPeriod *periodPeekData
@@ -94,6 +157,20 @@ type Model struct {
 	cmdDetailAll      []models.Command // full session context: [before..., target, after...]
 	cmdDetailIdx      int              // index of currently selected command
 	cmdDetailStartIdx int              // index of the original target in cmdDetailAll
+	cmdDetailGaps     map[int64]db.Gap // session idle gaps, keyed by the command after the gap
+	cmdDetailTags     []string         // tags of the command in view
+
+	// The context pane scrolls (ctrl+n/ctrl+p) without changing the target:
+	// cmdDetailScroll shifts its window from centered on the target, and
+	// cmdDetailExtra neighbors are loaded on each side beyond what fits, to
+	// scroll into. cmdDetailMoreBefore/After report that the session has
+	// commands beyond those loaded.
+	cmdDetailScroll     int
+	cmdDetailExtra      int
+	cmdDetailMoreBefore bool
+	cmdDetailMoreAfter  bool
+	hideBranchSwitch    bool // hide "switched to <branch>" dividers (b to toggle)
+	utcTimes            bool // show the timestamp in UTC instead of local time (U to toggle)
 
 	// Command text view (full multi-line command text)
 	cmdTextScrollOffset int
@@ -104,12 +181,101 @@ type Model struct {
 
 	// Filter
 	filterText     string // currently active filter (persists across views)
+	dirFilter      string // working directory the summary is scoped to (@), or ""
 	filterActive   bool   // whether the filter input bar is open
 	filterPrevText string // saved before opening bar, for Esc cancel
 
+	// Filter history: up/down in the filter bar recall applied filters
+	filterHistory    []string // oldest first, consecutive duplicates dropped
+	filterHistoryIdx int      // entry being recalled; len(filterHistory) when editing a new filter
+	filterDraft      string   // text typed before recalling, restored by down
+
+	// Exclude: hides commands matching any of its globs (see db.ExcludePatterns)
+	excludeText    string // persists across views, composes with the filter
+	editingExclude bool   // whether the input bar is editing the exclude
+
 	// Display mode
 	displayMode DisplayMode
 
+	// Right-column metric in the summary list (m to cycle)
+	metric Metric
+
+	// Activity timeline under the summary header (T to toggle): commands
+	// per hour across the whole period, loaded only while it is shown
+	showTimeline bool
+	activity     []int
+
+	// Slowest commands of the period, under the summary list (s to toggle),
+	// loaded only while the panel is shown
+	showSlowest bool
+	slowest     []models.Command
+
+	// Command shown after each summary row's name (p to cycle)
+	contextPreview contextPreview
+
+	// Order of the summary's contexts (A to toggle)
+	contextOrder contextOrder
+
+	// Reference period (' to mark): headers show the change in command
+	// total against it
+	mark *periodMark
+
+	// Weekday filter (d to cycle): only that weekday's commands within the
+	// period are grouped into contexts; 0 shows every day, 1–7 is Mon–Sun
+	weekdayFilter int
+
+	// Collapse consecutive identical commands in the detail view (c to toggle)
+	collapseRepeats bool
+	detailRepeats   map[int64]repeatRun // keyed by the ID of the row kept
+
+	// Show each distinct detail command once, at its latest run (U to
+	// toggle), so it lands in the bucket of the last time it ran
+	detailLatestUnique bool
+	detailRunCounts    map[int64]int // keyed by the ID of the run kept
+
+	// Show only the detail commands of the context's busiest session (s to
+	// toggle): the source pid with the most commands, 0 when none has one
+	detailMainSession    bool
+	detailMainSessionPid int64
+
+	// Order detail commands by id (insertion order) instead of timestamp
+	// (i to toggle). Timestamps collide within a minute; ids never do.
+	detailIDOrder bool
+
+	// List the detail view newest first (r to toggle): buckets and the
+	// commands within them are both reversed
+	detailNewestFirst bool
+
+	// Show each detail command's event id, as used by shy fc (N to toggle)
+	showIDs bool
+
+	// Shorten pipelines and command lists in the detail view to their
+	// program names (p to toggle); the selected row stays in full
+	pipelineHeads bool
+
+	// Divide the detail list at each git commit (g to toggle), labelling the
+	// commands that led up to it
+	commitDividers       bool
+	detailCommitSegments map[int64]commitSegment // keyed by the commit's ID
+
+	// Two contexts side by side (c marks one, C compares it with the
+	// selected one); tab moves the focus between the panes
+	compareMark  *compareMark
+	comparePanes [2]comparePane
+	compareFocus int
+
+	// Hours of the day view expanded into a minute-by-minute timeline (z to
+	// toggle), keyed by the Unix time the hour starts
+	expandedHours map[int64]bool
+
+	// Flat view (0 to toggle): the detail view lists every command of the
+	// period in time order, with no context grouping
+	flatView bool
+
+	// Minimum duration a detail command must have run to be shown (T to
+	// cycle through durationThresholds); 0 shows every command
+	minDuration time.Duration
+
 	// Selection
 	selectedIdx int
 
@@ -117,15 +283,106 @@ type Model struct {
 	width  int
 	height int
 
+	// Compact layout (recomputed on resize; always on when forceCompact)
+	forceCompact  bool
+	compactLayout bool
+
 	// Focus
 	focused bool
 
 	// Starred commands (loaded once, updated on toggle)
 	starredIDs map[int64]bool
 
+	// Context notes (loaded with contexts, updated on save)
+	contextNotes map[db.ContextNoteKey]string
+	noteActive   bool   // whether the note input bar is open
+	noteText     string // note being edited
+
+	// Bulk tag input for the selected context (# to open; "-tag" untags)
+	tagActive bool
+	tagText   string
+
+	// Help search (/ in the help view), kept apart from the command filter
+	helpFilterActive bool   // whether the search input is open
+	helpFilterText   string // substring the listed descriptions must contain
+
 	// Status flash message (e.g. "Yanked!")
 	statusMsg string
 
+	// Multi-day selection for export (v to start, v again to export)
+	selectActive bool
+	selectAnchor time.Time
+	exporter     ExportFunc
+
+	// Group git worktrees of the same repo and branch into one context
+	groupWorktrees bool
+
+	// Collapse every non-git directory into one "(no repo)" context
+	collapseNonGit bool
+
+	// Repo list (g to open): the summary collapsed to its git repos, where
+	// enter scopes the summary to the selected repo's branches
+	repoIdx    int
+	repoScoped bool   // the summary lists only repoScope's contexts
+	repoScope  string // git repo drilled into, "" for non-git directories
+
+	// cd-on-quit: o quits and records the context's directory for the caller
+	cdOnQuit    bool
+	cdRequested bool
+	cdTarget    string
+
+	// Auto-refresh of the current period (0 disables)
+	refreshInterval time.Duration
+
+	// Open the database read-only; star, note and delete report it instead
+	readOnly bool
+
+	// Archive database attached alongside the primary ("" for none)
+	archivePath string
+
+	// j/k wrap from the last item to the first and back instead of stopping
+	wrapNavigation bool
+
+	// Key bindings of the remappable actions (see WithKeyMap)
+	keyMap KeyMap
+
+	// Ask before q quits while a filter or selection is active or in command
+	// detail; quitPromptActive means the "Quit? y/n" footer has the keys
+	confirmQuit      bool
+	quitPromptActive bool
+
+	// Debug overlay (ctrl+g): shows the state and query behind the view
+	debugOverlay bool
+	lastQuery    *contextsQuery // the query of the last context load
+
+	// Full-text popup for a detail command (x to open, esc to close)
+	expandTarget *models.Command
+	expandScroll int
+
+	// Branch names rendered as default branches rather than feature branches
+	defaultBranches []string
+
+	// Week labels: start dates or ISO week numbers (W to toggle)
+	weekLabels WeekLabelStyle
+
+	// Header markers for the current and previous day, week and month
+	relativeLabels RelativeLabels
+
+	// Truncate long context names and paths from the left, keeping the tail
+	truncateLeft bool
+
+	// Color program names, flags and quoted strings of the detail and command
+	// detail commands (see WithHighlight)
+	highlight bool
+
+	// Programs whose successful runs are dimmed in the detail list, rather
+	// than removed like excluded ones; empty for none (see WithDimNoise)
+	noiseCommands []string
+
+	// Total history size for the footer, counted once on Init
+	showHistorySize bool
+	historySize     int // -1 until counted
+
 	// For testing - allows injecting "today"
 	now func() time.Time
 }
@@ -133,6 +390,184 @@ type Model struct {
 // Option is a functional option for configuring the Model
 type Option func(*Model)
 
+// ExportFunc writes the commands of a selected day range somewhere (typically
+// a history file) and returns a description of where they went.
+type ExportFunc func(commands []models.Command, start, end time.Time) (string, error)
+
+// WithExporter sets the function used to export a multi-day selection
+func WithExporter(fn ExportFunc) Option {
+	return func(m *Model) {
+		m.exporter = fn
+	}
+}
+
+// WithAutoRefresh reloads the contexts every interval while the current
+// period (the one containing today) is displayed, so new commands show up
+// without navigating. Historical periods are never refreshed.
+func WithAutoRefresh(interval time.Duration) Option {
+	return func(m *Model) {
+		m.refreshInterval = interval
+	}
+}
+
+// WithWorktreeGrouping groups contexts by git repo and branch, so worktrees
+// checking out the same branch in different directories show as one context
+func WithWorktreeGrouping() Option {
+	return func(m *Model) {
+		m.groupWorktrees = true
+	}
+}
+
+// WithCollapseNonGit collapses the contexts of every directory outside a
+// git repo into one "(no repo)" context, whose detail view names each
+// command's directory
+func WithCollapseNonGit() Option {
+	return func(m *Model) {
+		m.collapseNonGit = true
+	}
+}
+
+// WithCdOnQuit enables o, which quits and records the selected context's
+// working directory so the caller can print a cd command for the shell to eval
+func WithCdOnQuit() Option {
+	return func(m *Model) {
+		m.cdOnQuit = true
+	}
+}
+
+// WithReadOnly opens the database read-only (see db.Options.ReadOnly)
+func WithReadOnly() Option {
+	return func(m *Model) {
+		m.readOnly = true
+	}
+}
+
+// WithArchivePath includes the commands of an archive database alongside
+// the primary's (see db.Options.ArchivePath)
+func WithArchivePath(path string) Option {
+	return func(m *Model) {
+		m.archivePath = path
+	}
+}
+
+// WithWrapNavigation makes j/k in the summary and context detail lists wrap
+// around at the ends instead of stopping
+func WithWrapNavigation() Option {
+	return func(m *Model) {
+		m.wrapNavigation = true
+	}
+}
+
+// WithShowIDs starts the context detail list with its event id column shown
+func WithShowIDs() Option {
+	return func(m *Model) {
+		m.showIDs = true
+	}
+}
+
+// WithKeyMap replaces the key bindings of the actions set in km (see KeyMap)
+func WithKeyMap(km KeyMap) Option {
+	return func(m *Model) {
+		m.keyMap = km
+	}
+}
+
+// WithConfirmQuit makes q ask "Quit? y/n" before quitting when there is
+// state worth keeping (see quitNeedsConfirm). ctrl+c always quits.
+func WithConfirmQuit() Option {
+	return func(m *Model) {
+		m.confirmQuit = true
+	}
+}
+
+// WithDimNoise renders the successful runs of the named programs (see
+// DefaultNoiseCommands) faint in the context detail list, so the commands
+// around them stand out. Unlike the exclude filter, nothing is hidden.
+func WithDimNoise(names []string) Option {
+	return func(m *Model) {
+		m.noiseCommands = names
+	}
+}
+
+// WithHighlight colors the program names, flags, quoted strings and
+// operators of the commands listed in the detail and command detail views
+// (see highlightCommand). The selected row keeps its own color.
+func WithHighlight() Option {
+	return func(m *Model) {
+		m.highlight = true
+	}
+}
+
+// DefaultBranches are the branch names WithDefaultBranches replaces
+var DefaultBranches = []string{"main", "master"}
+
+// WithDefaultBranches sets the branch names rendered in the default-branch
+// color, so work on feature branches stands out
+func WithDefaultBranches(names []string) Option {
+	return func(m *Model) {
+		m.defaultBranches = names
+	}
+}
+
+// WithPeriod sets the period the summary opens in; [ and ] change it
+func WithPeriod(period Period) Option {
+	return func(m *Model) {
+		m.period = period
+	}
+}
+
+// WithDisplayMode sets the display mode the summary opens in; u, a and tab
+// change it
+func WithDisplayMode(mode DisplayMode) Option {
+	return func(m *Model) {
+		m.displayMode = mode
+	}
+}
+
+// WithWeekLabelStyle sets how weeks are labeled initially; W toggles it
+func WithWeekLabelStyle(style WeekLabelStyle) Option {
+	return func(m *Model) {
+		m.weekLabels = style
+	}
+}
+
+// WithRelativeLabels replaces the header's relative date markers, for
+// words or glyphs that suit the terminal's font
+func WithRelativeLabels(labels RelativeLabels) Option {
+	return func(m *Model) {
+		m.relativeLabels = labels
+	}
+}
+
+// WithHistorySize shows the total number of stored commands in the footer,
+// for a sense of scale. It is counted once when the model starts.
+func WithHistorySize() Option {
+	return func(m *Model) {
+		m.showHistorySize = true
+	}
+}
+
+// WithTruncateSide sets which end of a long context name or path is cut:
+// "left" keeps the tail ("…/nested/my-project:main"), anything else keeps the
+// head, the default
+func WithTruncateSide(side string) Option {
+	return func(m *Model) {
+		m.truncateLeft = side == "left"
+	}
+}
+
+// compactHeightThreshold is the terminal height below which the summary
+// switches to the compact layout automatically.
+const compactHeightThreshold = 16
+
+// WithCompact forces the compact summary layout regardless of terminal height
+func WithCompact() Option {
+	return func(m *Model) {
+		m.forceCompact = true
+		m.compactLayout = true
+	}
+}
+
 // New creates a new Model
 func New(dbPath string, opts ...Option) *Model {
 	m := &Model{
@@ -142,6 +577,11 @@ func New(dbPath string, opts ...Option) *Model {
 		focused:     true,
 		now:         time.Now,
 		width:       80,
+
+		defaultBranches: DefaultBranches,
+		relativeLabels:  DefaultRelativeLabels,
+		historySize:     -1,
+		keyMap:          DefaultKeyMap(),
 	}
 
 	for _, opt := range opts {
@@ -150,18 +590,107 @@ func New(dbPath string, opts ...Option) *Model {
 
 	// Recalculate yesterday based on now function
 	m.currentDate = m.now().AddDate(0, 0, -1)
+	if m.period != DayPeriod {
+		// [ back to the day view returns to yesterday
+		m.anchorDate = m.currentDate
+	}
 
 	return m
 }
 
 // Init implements tea.Model
 func (m *Model) Init() tea.Cmd {
-	database, err := db.New(m.dbPath)
+	database, err := db.NewWithOptions(m.dbPath, db.Options{ReadOnly: m.readOnly, ArchivePath: m.archivePath})
 	if err != nil {
 		return func() tea.Msg { return errMsg{err} }
 	}
 	m.db = database
-	return m.loadContexts
+	cmds := []tea.Cmd{m.loadContexts}
+	if m.refreshInterval > 0 {
+		cmds = append(cmds, m.scheduleRefresh())
+	}
+	if m.showHistorySize {
+		cmds = append(cmds, m.loadHistorySize)
+	}
+	if len(cmds) == 1 {
+		return m.loadContexts // unbatched, so callers can run it directly
+	}
+	return tea.Batch(cmds...)
+}
+
+// loadHistorySize counts every stored command for the footer
+func (m *Model) loadHistorySize() tea.Msg {
+	count, err := m.db.CountCommands()
+	return historySizeMsg{count: count, err: err}
+}
+
+// scheduleRefresh returns a tick that fires the next auto-refresh
+func (m *Model) scheduleRefresh() tea.Cmd {
+	return tea.Tick(m.refreshInterval, func(time.Time) tea.Msg {
+		return refreshTickMsg{}
+	})
+}
+
+// refreshContexts reloads the contexts for the displayed period, tagging the
+// result with the date and period it was loaded for.
+func (m *Model) refreshContexts() tea.Cmd {
+	date := m.currentDate
+	period := m.period
+	return func() tea.Msg {
+		loaded, ok := m.loadContexts().(contextsLoadedMsg)
+		if !ok {
+			return nil
+		}
+		return contextsRefreshedMsg{loaded: loaded, date: date, period: period}
+	}
+}
+
+// toggleContextOrder switches the contexts between count and name order,
+// keeping the selected context selected, and flashes the new order
+func (m *Model) toggleContextOrder() tea.Cmd {
+	m.contextOrder = (m.contextOrder + 1) % (nameOrder + 1)
+	status := "Sorted by count"
+	if m.contextOrder == nameOrder {
+		status = "Sorted by name"
+	}
+
+	var selected *ContextItem
+	if m.selectedIdx < len(m.contexts) {
+		ctx := m.contexts[m.selectedIdx]
+		selected = &ctx
+	}
+	prevRepo, hadRepo := m.selectedRepo()
+	sortContextItems(m.contexts, m.contextOrder)
+	if m.viewState == RepoListView {
+		m.restoreRepoSelection(prevRepo, hadRepo)
+	}
+	if selected != nil {
+		for i, ctx := range m.contexts {
+			if m.matchesContext(ctx, selected.Key, selected.Branch) {
+				m.selectedIdx = i
+				break
+			}
+		}
+	}
+
+	m.statusMsg = status
+	return tea.Tick(2*time.Second, func(time.Time) tea.Msg {
+		return clearStatusMsg{}
+	})
+}
+
+// reloadContexts is refreshContexts on demand, as for R: once loaded, an
+// open detail view is rebuilt too and status flashes
+func (m *Model) reloadContexts(status string) tea.Cmd {
+	refresh := m.refreshContexts()
+	return func() tea.Msg {
+		msg, ok := refresh().(contextsRefreshedMsg)
+		if !ok {
+			return nil
+		}
+		msg.status = status
+		return msg
+	}
 }
 
 // Close releases the persistent database connection.
@@ -175,9 +704,21 @@ func (m *Model) Close() error {
 // loadContexts loads contexts for the current date
 func (m *Model) loadContexts() tea.Msg {
 	startTime, endTime := m.dateRange()
-	commands, err := m.db.GetCommandsByDateRange(startTime, endTime, nil)
-	if err != nil {
-		return errMsg{err}
+	windows := [][2]int64{{startTime, endTime}}
+	if wd, ok := m.filteredWeekday(); ok {
+		windows = weekdayWindows(startTime, endTime, wd)
+	}
+	var commands []models.Command
+	for _, w := range windows {
+		cmds, err := m.db.GetCommandsByDateRange(w[0], w[1], nil)
+		if err != nil {
+			return errMsg{err}
+		}
+		commands = append(commands, cmds...)
+	}
+	query := contextsQuery{name: "GetCommandsByDateRange", windows: windows, rows: len(commands)}
+	if m.dirFilter != "" {
+		commands = filterByDir(commands, m.dirFilter)
 	}
 
 	// Group by context
@@ -196,8 +737,35 @@ func (m *Model) loadContexts() tea.Msg {
 		}
 	}
 
-	// Sort contexts alphabetically by working dir, then branch
-	sortContextItems(items)
+	if m.groupWorktrees {
+		items = mergeWorktreeContexts(items)
+	}
+	if m.collapseNonGit {
+		items = collapseNonGitContexts(items)
+	}
+	if m.repoScoped {
+		items = filterByRepo(items, m.repoScope)
+	}
+
+	sortContextItems(items, m.contextOrder)
+
+	var activity []int
+	if m.showTimeline {
+		var err error
+		activity, err = m.db.GetCommandCountsByHour(startTime, endTime)
+		if err != nil {
+			return errMsg{err}
+		}
+	}
+
+	var slowest []models.Command
+	if m.showSlowest {
+		var err error
+		slowest, err = m.db.GetSlowestCommands(startTime, endTime, slowestPanelSize)
+		if err != nil {
+			return errMsg{err}
+		}
+	}
 
 	// Load starred IDs
 	starredIDs, err := m.db.GetStarredIDs()
@@ -205,7 +773,43 @@ func (m *Model) loadContexts() tea.Msg {
 		return errMsg{err}
 	}
 
-	return contextsLoadedMsg{contexts: items, starredIDs: starredIDs}
+	// Load context notes
+	notes, err := m.db.GetContextNotes()
+	if err != nil {
+		return errMsg{err}
+	}
+
+	return contextsLoadedMsg{
+		contexts:   items,
+		starredIDs: starredIDs,
+		notes:      notes,
+		activity:   activity,
+		slowest:    slowest,
+		query:      query,
+	}
+}
+
+// noteKeyFor returns the notes-store key for a context and branch
+func noteKeyFor(key summary.ContextKey, branch summary.BranchKey) db.ContextNoteKey {
+	return db.ContextNoteKey{
+		WorkingDir: key.WorkingDir,
+		GitRepo:    key.GitRepo,
+		GitBranch:  branch.DBValue(),
+	}
+}
+
+// detailNote returns the note attached to the detail view's context
+func (m *Model) detailNote() string {
+	return m.contextNotes[noteKeyFor(m.detailContextKey, m.detailContextBranch)]
+}
+
+// saveNote stores the note for a context asynchronously
+func (m *Model) saveNote(key db.ContextNoteKey, note string) tea.Cmd {
+	database := m.db
+	return func() tea.Msg {
+		err := database.SetContextNote(key.WorkingDir, key.GitRepo, key.GitBranch, note)
+		return noteSavedMsg{key: key, note: note, err: err}
+	}
 }
 
 // mondayOfWeek returns the Monday (00:00 local) of the ISO week containing date.
@@ -242,6 +846,13 @@ func dateRangeForPeriod(date time.Time, period Period) (int64, int64) {
 	}
 }
 
+// DateRange returns the window the summary loads for date and period: Unix
+// timestamps from the period's start, inclusive, to its end, exclusive, in
+// local time. shy summary --print-window prints it.
+func DateRange(date time.Time, period Period) (start, end int64) {
+	return dateRangeForPeriod(date, period)
+}
+
 // dateRange returns the start and end timestamps for the current period
 func (m *Model) dateRange() (int64, int64) {
 	return dateRangeForPeriod(m.currentDate, m.period)
@@ -300,6 +911,66 @@ func (m *Model) navigateForward() {
 	}
 }
 
+// filteredWeekday returns the weekday the period is restricted to, if any
+func (m *Model) filteredWeekday() (time.Weekday, bool) {
+	if m.weekdayFilter == 0 {
+		return 0, false
+	}
+	// 1–7 is Monday–Sunday; time.Weekday counts from Sunday
+	return time.Weekday(m.weekdayFilter % 7), true
+}
+
+// weekdayWindows returns the day windows within [start, end) that fall on
+// weekday, oldest first. A month yields four or five windows; a day yields
+// one or none.
+func weekdayWindows(start, end int64, weekday time.Weekday) [][2]int64 {
+	var windows [][2]int64
+	day := time.Unix(start, 0)
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.Local)
+	for day.Unix() < end {
+		next := day.AddDate(0, 0, 1)
+		if day.Weekday() == weekday {
+			windows = append(windows, [2]int64{max(day.Unix(), start), min(next.Unix(), end)})
+		}
+		day = next
+	}
+	return windows
+}
+
+// jumpSameWeekday moves the day view ±7 days to the previous/next occurrence
+// of the same weekday. Forward jumps are clamped to today. Returns false if
+// the date did not change (not in day period, or already on today).
+func (m *Model) jumpSameWeekday(direction int) bool {
+	if m.period != DayPeriod {
+		return false
+	}
+	if direction > 0 {
+		if m.isCurrentPeriod() {
+			return false
+		}
+		now := m.now()
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+		target := m.currentDate.AddDate(0, 0, 7)
+		if !target.Before(today) {
+			target = now
+		}
+		m.currentDate = target
+		return true
+	}
+	m.currentDate = m.currentDate.AddDate(0, 0, -7)
+	return true
+}
+
+// jumpToWeekStart moves the day view to the Monday of the displayed week.
+// Returns false if not in day period or already on Monday.
+func (m *Model) jumpToWeekStart() bool {
+	if m.period != DayPeriod || m.currentDate.Weekday() == time.Monday {
+		return false
+	}
+	m.currentDate = mondayOfWeek(m.currentDate)
+	return true
+}
+
 // cyclePeriodUp moves Day→Week→Month
 func (m *Model) cyclePeriodUp() bool {
 	switch m.period {
@@ -346,6 +1017,62 @@ func (m *Model) cmdDetailTotalContext() int {
 	return avail
 }
 
+// cmdDetailWindow returns the index in cmdDetailAll of the first context
+// row shown and the number of rows shown: as many as fit the terminal,
+// centered on the target as far as the loaded commands allow, then shifted
+// by the scroll offset
+func (m *Model) cmdDetailWindow() (int, int) {
+	n := len(m.cmdDetailAll)
+	size := m.cmdDetailTotalContext() + 1
+	if n <= size {
+		return 0, n
+	}
+	centered := min(max(m.cmdDetailIdx-size/2, 0), n-size)
+	return min(max(centered+m.cmdDetailScroll, 0), n-size), size
+}
+
+// clampCmdDetailScroll limits the scroll offset to the windows the loaded
+// commands can fill
+func (m *Model) clampCmdDetailScroll() {
+	n := len(m.cmdDetailAll)
+	size := m.cmdDetailTotalContext() + 1
+	if n <= size {
+		m.cmdDetailScroll = 0
+		return
+	}
+	centered := min(max(m.cmdDetailIdx-size/2, 0), n-size)
+	m.cmdDetailScroll = min(max(m.cmdDetailScroll, -centered), n-size-centered)
+}
+
+// scrollCmdDetail moves the context window step rows down (up when
+// negative), keeping the target. At the end of the loaded commands it loads
+// a window's worth more on each side when the session has them.
+func (m *Model) scrollCmdDetail(step int) tea.Cmd {
+	start, _ := m.cmdDetailWindow()
+	m.cmdDetailScroll += step
+	if moved, _ := m.cmdDetailWindow(); moved != start {
+		return nil
+	}
+	m.cmdDetailScroll -= step
+
+	target := m.CmdDetailTarget()
+	more := step > 0 && m.cmdDetailMoreAfter || step < 0 && m.cmdDetailMoreBefore
+	if target == nil || !more {
+		return nil
+	}
+	m.cmdDetailScroll += step
+	m.cmdDetailExtra += m.cmdDetailTotalContext() + 1
+	return m.loadCommandContext(target.ID)
+}
+
+// showCommandContext opens the command detail of id with its context
+// centered on it, dropping the previous command's scroll
+func (m *Model) showCommandContext(id int64) tea.Cmd {
+	m.cmdDetailScroll = 0
+	m.cmdDetailExtra = 0
+	return m.loadCommandContext(id)
+}
+
 // balanceContext trims before/after slices so their combined length fits within
 // total, while maximizing the number of commands shown. When one side is short,
 // the surplus goes to the other.
@@ -375,53 +1102,137 @@ func balanceContext(before, after []models.Command, total int) ([]models.Command
 	return before, after
 }
 
+// sessionGapThreshold is the idle time (seconds) between two session commands
+// that the command detail context marks with a gap divider
+const sessionGapThreshold = 15 * 60
+
 // loadCommandContext loads session context for a specific command
 func (m *Model) loadCommandContext(cmdID int64) tea.Cmd {
 	database := m.db
+	total := m.cmdDetailTotalContext() + 2*m.cmdDetailExtra
 	return func() tea.Msg {
-		total := m.cmdDetailTotalContext()
 		before, target, after, err := database.GetCommandWithContext(cmdID, total)
 		if err != nil {
 			return errMsg{err}
 		}
+		if target.SourcePid == nil {
+			// Without a session, neighbors by id may come from any terminal:
+			// keep to the command's directory instead
+			before, after, err = database.GetCommandsWithContextAcrossSessions(target, total)
+			if err != nil {
+				return errMsg{err}
+			}
+		}
 
+		loadedBefore, loadedAfter := len(before), len(after)
 		before, after = balanceContext(before, after, total)
+		moreBefore := len(before) < loadedBefore || loadedBefore == total
+		moreAfter := len(after) < loadedAfter || loadedAfter == total
+
+		var gaps map[int64]db.Gap
+		if target.SourcePid != nil {
+			sessionGaps, err := database.GetSessionGaps(*target.SourcePid, sessionGapThreshold)
+			if err != nil {
+				return errMsg{err}
+			}
+			gaps = make(map[int64]db.Gap, len(sessionGaps))
+			for _, g := range sessionGaps {
+				gaps[g.AfterID] = g
+			}
+		}
+
+		tags, err := database.GetCommandTags(target.ID)
+		if err != nil {
+			return errMsg{err}
+		}
 
 		return commandContextLoadedMsg{
-			before: before,
-			target: target,
-			after:  after,
+			before:     before,
+			target:     target,
+			after:      after,
+			moreBefore: moreBefore,
+			moreAfter:  moreAfter,
+			gaps:       gaps,
+			tags:       tags,
 		}
 	}
 }
 
-// deleteCommand deletes a command by ID asynchronously
-func (m *Model) deleteCommand(id int64) tea.Cmd {
-	database := m.db
-	return func() tea.Msg {
-		count, err := database.DeleteCommands([]int64{id})
-		return deleteResultMsg{id: id, count: count, err: err}
+// selectionRange returns the selected days in chronological order
+func (m *Model) selectionRange() (time.Time, time.Time) {
+	if m.currentDate.Before(m.selectAnchor) {
+		return m.currentDate, m.selectAnchor
 	}
+	return m.selectAnchor, m.currentDate
 }
 
-// toggleStar toggles the starred status of a command
-func (m *Model) toggleStar(id int64) tea.Cmd {
+// exportSelection exports every command in the selected days, honoring the
+// active filter and display mode, via the configured exporter
+func (m *Model) exportSelection() tea.Cmd {
 	database := m.db
+	exporter := m.exporter
+	start, end := m.selectionRange()
+	mode := m.displayMode
+	filter := m.filterText
+	exclude := m.excludeText
+
 	return func() tea.Msg {
-		starred, err := database.ToggleStar(id)
-		return starToggleResultMsg{id: id, starred: starred, err: err}
+		if exporter == nil {
+			return exportResultMsg{err: fmt.Errorf("no exporter configured")}
+		}
+		startTime, _ := dateRangeForPeriod(start, DayPeriod)
+		_, endTime := dateRangeForPeriod(end, DayPeriod)
+		cmds, err := database.GetCommandsByDateRange(startTime, endTime, nil)
+		if err != nil {
+			return exportResultMsg{err: err}
+		}
+		cmds = filterByMode(filterCommands(cmds, filter, exclude), mode)
+		dest, err := exporter(cmds, start, end)
+		return exportResultMsg{dest: dest, count: len(cmds), err: err}
 	}
 }
 
-// Update implements tea.Model
-func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	switch msg := msg.(type) {
+// deleteCommand moves a command to the trash asynchronously; shy trash
+// restore brings it back
+func (m *Model) deleteCommand(id int64) tea.Cmd {
+	database := m.db
+	return func() tea.Msg {
+		trashed, err := database.SoftDeleteCommand(id)
+		var count int64
+		if trashed {
+			count = 1
+		}
+		return deleteResultMsg{id: id, count: count, err: err}
+	}
+}
+
+// writeFailedStatus returns the status flash for a failed write
+func writeFailedStatus(action string, err error) string {
+	if errors.Is(err, db.ErrReadOnly) {
+		return "Read-only database"
+	}
+	return action + " failed"
+}
+
+// toggleStar toggles the starred status of a command
+func (m *Model) toggleStar(id int64) tea.Cmd {
+	database := m.db
+	return func() tea.Msg {
+		starred, err := database.ToggleStar(id)
+		return starToggleResultMsg{id: id, starred: starred, err: err}
+	}
+}
+
+// Update implements tea.Model
+func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
 	case tea.KeyPressMsg:
 		return m.handleKey(msg)
 
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
+		m.compactLayout = m.forceCompact || msg.Height < compactHeightThreshold
 		if m.viewState == CommandDetailView && m.cmdDetailIdx < len(m.cmdDetailAll) {
 			return m, m.loadCommandContext(m.cmdDetailAll[m.cmdDetailIdx].ID)
 		}
@@ -436,14 +1247,25 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case contextsLoadedMsg:
+		prevRepo, hadRepo := m.selectedRepo()
 		m.contexts = msg.contexts
 		m.starredIDs = msg.starredIDs
+		m.contextNotes = msg.notes
+		m.activity = msg.activity
+		m.slowest = msg.slowest
+		m.lastQuery = &msg.query
 		m.selectedIdx = 0
+		if m.viewState == RepoListView {
+			m.restoreRepoSelection(prevRepo, hadRepo)
+		}
 		if m.pendingDetailReentry {
 			m.pendingDetailReentry = false
+			if m.flatView {
+				return m, m.enterDetailView()
+			}
 			found := false
 			for i, ctx := range m.contexts {
-				if ctx.Key == m.detailContextKey && ctx.Branch == m.detailContextBranch {
+				if m.matchesContext(ctx, m.detailContextKey, m.detailContextBranch) {
 					m.selectedIdx = i
 					return m, m.enterDetailView()
 				}
@@ -462,6 +1284,15 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
+	case detailPageLoadedMsg:
+		return m, m.appendDetailPage(msg)
+
+	case historySizeMsg:
+		if msg.err == nil {
+			m.historySize = msg.count
+		}
+		return m, nil
+
 	case commandContextLoadedMsg:
 		var all []models.Command
 		all = append(all, msg.before...)
@@ -470,22 +1301,81 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		all = append(all, msg.after...)
 		m.cmdDetailAll = all
+		m.cmdDetailGaps = msg.gaps
+		m.cmdDetailTags = msg.tags
 		m.cmdDetailIdx = len(msg.before) // point at target
+		m.cmdDetailMoreBefore = msg.moreBefore
+		m.cmdDetailMoreAfter = msg.moreAfter
+		m.clampCmdDetailScroll()
 		if m.viewState != CommandDetailView {
 			m.cmdDetailStartIdx = m.cmdDetailIdx
 		}
 		m.viewState = CommandDetailView
 		return m, nil
 
+	case refreshTickMsg:
+		if m.isCurrentPeriod() {
+			return m, tea.Batch(m.refreshContexts(), m.scheduleRefresh())
+		}
+		return m, m.scheduleRefresh()
+
+	case contextsRefreshedMsg:
+		if !msg.date.Equal(m.currentDate) || msg.period != m.period {
+			return m, nil // navigated away while loading
+		}
+		var selected *ContextItem
+		if m.selectedIdx < len(m.contexts) {
+			selected = &m.contexts[m.selectedIdx]
+		}
+		prevRepo, hadRepo := m.selectedRepo()
+		m.contexts = msg.loaded.contexts
+		m.starredIDs = msg.loaded.starredIDs
+		m.contextNotes = msg.loaded.notes
+		m.activity = msg.loaded.activity
+		m.slowest = msg.loaded.slowest
+		m.lastQuery = &msg.loaded.query
+		m.selectedIdx = 0
+		if selected != nil {
+			for i, ctx := range m.contexts {
+				if m.matchesContext(ctx, selected.Key, selected.Branch) {
+					m.selectedIdx = i
+					break
+				}
+			}
+		}
+		if m.viewState == RepoListView {
+			m.restoreRepoSelection(prevRepo, hadRepo)
+		}
+		if msg.status == "" {
+			return m, nil
+		}
+		var detailCmd tea.Cmd
+		if m.viewState == ContextDetailView {
+			detailCmd = m.rebuildDetailView()
+		}
+		m.statusMsg = msg.status
+		return m, tea.Batch(detailCmd, tea.Tick(2*time.Second, func(time.Time) tea.Msg {
+			return clearStatusMsg{}
+		}))
+
 	case emptyStatePeeksMsg:
 		m.emptyPrevPeriod = msg.prev
 		m.emptyNextPeriod = msg.next
 		return m, nil
 
 	case yankResultMsg:
-		if msg.err != nil {
+		switch {
+		case msg.err != nil && (msg.path || msg.table || msg.record):
+			m.statusMsg = "Copy failed"
+		case msg.err != nil:
 			m.statusMsg = "Yank failed"
-		} else {
+		case msg.path:
+			m.statusMsg = "Copied path"
+		case msg.table:
+			m.statusMsg = "Copied table"
+		case msg.record:
+			m.statusMsg = "Copied record"
+		default:
 			m.statusMsg = "Yanked!"
 		}
 		return m, tea.Tick(2*time.Second, func(time.Time) tea.Msg {
@@ -494,7 +1384,7 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case starToggleResultMsg:
 		if msg.err != nil {
-			m.statusMsg = "Star failed"
+			m.statusMsg = writeFailedStatus("Star", msg.err)
 		} else {
 			if msg.starred {
 				if m.starredIDs == nil {
@@ -511,13 +1401,55 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return clearStatusMsg{}
 		})
 
+	case noteSavedMsg:
+		if msg.err != nil {
+			m.statusMsg = writeFailedStatus("Note", msg.err)
+		} else {
+			if m.contextNotes == nil {
+				m.contextNotes = make(map[db.ContextNoteKey]string)
+			}
+			if strings.TrimSpace(msg.note) == "" {
+				delete(m.contextNotes, msg.key)
+				m.statusMsg = "Note cleared"
+			} else {
+				m.contextNotes[msg.key] = msg.note
+				m.statusMsg = "Note saved"
+			}
+		}
+		return m, tea.Tick(2*time.Second, func(time.Time) tea.Msg {
+			return clearStatusMsg{}
+		})
+
+	case tagResultMsg:
+		switch {
+		case msg.err != nil:
+			m.statusMsg = writeFailedStatus("Tag", msg.err)
+		case msg.untag:
+			m.statusMsg = fmt.Sprintf("Untagged %d from %s", msg.count, msg.tag)
+		default:
+			m.statusMsg = fmt.Sprintf("Tagged %d as %s", msg.count, msg.tag)
+		}
+		return m, tea.Tick(2*time.Second, func(time.Time) tea.Msg {
+			return clearStatusMsg{}
+		})
+
+	case exportResultMsg:
+		if msg.err != nil {
+			m.statusMsg = "Export failed"
+		} else {
+			m.statusMsg = fmt.Sprintf("Exported %d to %s", msg.count, msg.dest)
+		}
+		return m, tea.Tick(2*time.Second, func(time.Time) tea.Msg {
+			return clearStatusMsg{}
+		})
+
 	case deleteResultMsg:
 		if msg.err != nil {
-			m.statusMsg = "Delete failed"
+			m.statusMsg = writeFailedStatus("Delete", msg.err)
 		} else if msg.count == 0 {
 			m.statusMsg = "Not found"
 		} else {
-			m.statusMsg = fmt.Sprintf("Deleted #%d", msg.id)
+			m.statusMsg = fmt.Sprintf("Trashed #%d", msg.id)
 			m.pendingDetailReentry = true
 			m.pendingDeletedID = msg.id
 			m.viewState = ContextDetailView
@@ -525,6 +1457,45 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
+	case failureDayMsg:
+		if !msg.date.Equal(m.currentDate) || msg.period != m.period {
+			return m, nil // navigated away while searching
+		}
+		clearLater := tea.Tick(2*time.Second, func(time.Time) tea.Msg {
+			return clearStatusMsg{}
+		})
+		switch {
+		case msg.err != nil:
+			m.statusMsg = "Search failed"
+			return m, clearLater
+		case !msg.found:
+			m.statusMsg = fmt.Sprintf("No failures in the last %d days", failureSearchDays)
+			return m, clearLater
+		}
+		m.currentDate = dateOnly(time.Unix(msg.ts, 0))
+		m.period = DayPeriod
+		m.statusMsg = "Failure on " + m.currentDate.Format("Mon ") + formatShortDate(m.currentDate, m.now().Year())
+		if m.viewState == ContextDetailView {
+			m.pendingDetailReentry = true
+			return m, tea.Batch(m.loadContexts, clearLater)
+		}
+		// Refreshing keeps the selected context selected on the new day
+		return m, tea.Batch(m.refreshContexts(), clearLater)
+
+	case randomCommandMsg:
+		switch {
+		case msg.err != nil:
+			m.statusMsg = "Random command failed"
+		case msg.cmd == nil:
+			m.statusMsg = "No commands"
+		default:
+			first, _ := firstLine(msg.cmd.CommandText)
+			m.statusMsg = "Random: " + first
+		}
+		return m, tea.Tick(2*time.Second, func(time.Time) tea.Msg {
+			return clearStatusMsg{}
+		})
+
 	case clearStatusMsg:
 		m.statusMsg = ""
 		return m, nil
@@ -538,28 +1509,86 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m *Model) handleKey(msg tea.KeyPressMsg) (*Model, tea.Cmd) {
+	if m.quitPromptActive {
+		return m.handleQuitPromptKey(msg)
+	}
 	if m.filterActive {
 		return m.handleFilterKey(msg)
 	}
+	if m.noteActive {
+		return m.handleNoteKey(msg)
+	}
+	if m.tagActive {
+		return m.handleTagKey(msg)
+	}
+	if m.helpFilterActive {
+		return m.handleHelpFilterKey(msg)
+	}
+
+	// Outside the text inputs, remapped keys stand in for their defaults
+	msg, bound := m.keyMap.translate(msg)
+	if !bound {
+		return m, nil
+	}
+	if msg.String() == "q" && m.confirmQuit && m.quitNeedsConfirm() {
+		m.quitPromptActive = true
+		return m, nil
+	}
+	if m.expandTarget != nil {
+		return m.handleExpandKey(msg)
+	}
+
+	// ctrl+g toggles the debug overlay; esc also dismisses it
+	if msg.String() == "ctrl+g" {
+		m.debugOverlay = !m.debugOverlay
+		return m, nil
+	}
+	if msg.String() == "esc" && m.debugOverlay {
+		m.debugOverlay = false
+		return m, nil
+	}
 
-	// ESC clears filter when one is active (in any view)
-	if msg.String() == "esc" && m.filterText != "" {
-		m.filterText = ""
+	// The help view handles its own esc, leaving the filters below alone
+	if m.viewState == HelpView {
+		return m.handleHelpKey(msg)
+	}
+
+	// ESC cancels a multi-day selection before anything else
+	if msg.String() == "esc" && m.selectActive {
+		m.selectActive = false
+		return m, nil
+	}
+
+	// ESC clears filter when one is active (in any view), then the exclude
+	if msg.String() == "esc" && (m.filterText != "" || m.excludeText != "") {
+		if m.filterText != "" {
+			m.filterText = ""
+		} else {
+			m.excludeText = ""
+		}
 		if m.viewState == ContextDetailView {
 			return m, m.refreshDetailView()
 		}
 		return m, nil
 	}
 
+	// ESC then lifts the directory scope, reloading the period without it
+	if msg.String() == "esc" && m.dirFilter != "" && (m.viewState == SummaryView || m.viewState == ContextDetailView) {
+		m.dirFilter = ""
+		return m, m.reloadContexts("Directory scope cleared")
+	}
+
 	switch m.viewState {
-	case HelpView:
-		return m.handleHelpKey(msg)
+	case CompareView:
+		return m.handleCompareKey(msg)
 	case CommandTextView:
 		return m.handleCommandTextKey(msg)
 	case CommandDetailView:
 		return m.handleCommandDetailKey(msg)
 	case ContextDetailView:
 		return m.handleDetailKey(msg)
+	case RepoListView:
+		return m.handleRepoListKey(msg)
 	default:
 		return m.handleSummaryKey(msg)
 	}
@@ -584,6 +1613,7 @@ func (m *Model) jumpToDate(date time.Time) (*Model, tea.Cmd) {
 	m.selectedIdx = 0
 	if m.viewState == ContextDetailView {
 		m.viewState = SummaryView
+		m.flatView = false
 	}
 	return m, m.loadContexts
 }
@@ -597,6 +1627,14 @@ func (m *Model) setDisplayMode(mode DisplayMode) (*Model, tea.Cmd) {
 	return m, nil
 }
 
+// cycleDisplayMode steps the display mode by step (1 or -1) through
+// displayModes, wrapping at either end
+func (m *Model) cycleDisplayMode(step int) (*Model, tea.Cmd) {
+	i := slices.Index(displayModes, m.displayMode)
+	n := len(displayModes)
+	return m.setDisplayMode(displayModes[((i+step)%n+n)%n])
+}
+
 // handleSharedKey handles keys shared between summary and detail views.
 // Returns handled=true if the key was consumed.
 func (m *Model) handleSharedKey(msg tea.KeyPressMsg) (model *Model, cmd tea.Cmd, handled bool) {
@@ -604,6 +1642,26 @@ func (m *Model) handleSharedKey(msg tea.KeyPressMsg) (model *Model, cmd tea.Cmd,
 	case "q", "ctrl+c":
 		return m, tea.Quit, true
 
+	case "o":
+		if !m.cdOnQuit {
+			return m, nil, false
+		}
+		if target := m.contextDir(); target != "" {
+			m.cdRequested = true
+			m.cdTarget = target
+			return m, tea.Quit, true
+		}
+		return m, nil, true
+
+	case "R":
+		return m, m.reloadContexts("Refreshed"), true
+
+	case "Y":
+		if dir := m.contextDir(); dir != "" {
+			return m, yankPathToClipboard(dir), true
+		}
+		return m, nil, true
+
 	case "h":
 		m.navigateBack()
 		model, cmd = m.navigateAndReload()
@@ -633,9 +1691,46 @@ func (m *Model) handleSharedKey(msg tea.KeyPressMsg) (model *Model, cmd tea.Cmd,
 		model, cmd = m.setDisplayMode(AllMode)
 		return model, cmd, true
 
+	case "tab":
+		model, cmd = m.cycleDisplayMode(1)
+		return model, cmd, true
+
+	case "shift+tab":
+		model, cmd = m.cycleDisplayMode(-1)
+		return model, cmd, true
+
 	case "/":
 		m.filterActive = true
+		m.editingExclude = false
 		m.filterPrevText = m.filterText
+		m.filterHistoryIdx = len(m.filterHistory)
+		return m, nil, true
+
+	case "!":
+		m.filterActive = true
+		m.editingExclude = true
+		m.filterPrevText = m.excludeText
+		return m, nil, true
+
+	case "<":
+		if m.jumpSameWeekday(-1) {
+			model, cmd = m.navigateAndReload()
+			return model, cmd, true
+		}
+		return m, nil, true
+
+	case ">":
+		if m.jumpSameWeekday(1) {
+			model, cmd = m.navigateAndReload()
+			return model, cmd, true
+		}
+		return m, nil, true
+
+	case "w":
+		if m.jumpToWeekStart() {
+			model, cmd = m.navigateAndReload()
+			return model, cmd, true
+		}
 		return m, nil, true
 
 	case "]":
@@ -652,6 +1747,20 @@ func (m *Model) handleSharedKey(msg tea.KeyPressMsg) (model *Model, cmd tea.Cmd,
 		}
 		return m, nil, true
 
+	case "A":
+		return m, m.toggleContextOrder(), true
+
+	case "'":
+		return m, m.toggleMark(), true
+
+	case "W":
+		m.weekLabels = (m.weekLabels + 1) % (ISOWeekLabels + 1)
+		m.relabelDetailBuckets()
+		return m, nil, true
+
+	case "F":
+		return m, m.findPrevFailureDay(), true
+
 	case "?":
 		m.helpPreviousView = m.viewState
 		m.viewState = HelpView
@@ -668,15 +1777,11 @@ func (m *Model) handleSummaryKey(msg tea.KeyPressMsg) (*Model, tea.Cmd) {
 
 	switch msg.String() {
 	case "j", "down":
-		if m.selectedIdx < len(m.contexts)-1 {
-			m.selectedIdx++
-		}
+		m.selectedIdx = m.stepIndex(m.selectedIdx, 1, len(m.contexts))
 		return m, nil
 
 	case "k", "up":
-		if m.selectedIdx > 0 {
-			m.selectedIdx--
-		}
+		m.selectedIdx = m.stepIndex(m.selectedIdx, -1, len(m.contexts))
 		return m, nil
 
 	case "enter":
@@ -684,6 +1789,94 @@ func (m *Model) handleSummaryKey(msg tea.KeyPressMsg) (*Model, tea.Cmd) {
 			return m, m.enterDetailView()
 		}
 		return m, nil
+
+	case "0":
+		m.flatView = true
+		return m, m.enterDetailView()
+
+	case "c":
+		m.toggleCompareMark()
+		return m, nil
+
+	case "C":
+		return m, m.enterCompareView()
+
+	case "#":
+		if len(m.contexts) > 0 {
+			m.tagActive = true
+			m.tagText = ""
+		}
+		return m, nil
+
+	case "v":
+		if m.selectActive {
+			m.selectActive = false
+			return m, m.exportSelection()
+		}
+		if m.period == DayPeriod {
+			m.selectActive = true
+			m.selectAnchor = m.currentDate
+		}
+		return m, nil
+
+	case "m":
+		m.metric = (m.metric + 1) % (RatioMetric + 1)
+		return m, nil
+
+	case "M":
+		if len(m.contexts) == 0 {
+			return m, nil
+		}
+		return m, yankTableToClipboard(m.markdownSummaryTable())
+
+	case "d":
+		m.weekdayFilter = (m.weekdayFilter + 1) % 8
+		return m.navigateAndReload()
+
+	case "T":
+		m.showTimeline = !m.showTimeline
+		if m.showTimeline {
+			return m, m.refreshContexts()
+		}
+		m.activity = nil
+		return m, nil
+
+	case "s":
+		m.showSlowest = !m.showSlowest
+		if m.showSlowest {
+			return m, m.refreshContexts()
+		}
+		m.slowest = nil
+		return m, nil
+
+	case "p":
+		m.contextPreview = (m.contextPreview + 1) % (lastPreview + 1)
+		return m, nil
+
+	case "~":
+		return m, m.randomCommand()
+
+	case "g":
+		return m, m.enterRepoList()
+
+	case "-":
+		if m.repoScoped {
+			return m, m.enterRepoList()
+		}
+		return m, nil
+
+	// H/L switch contexts in the detail view; in the summary they jump a week
+	case "H":
+		if m.jumpSameWeekday(-1) {
+			return m.navigateAndReload()
+		}
+		return m, nil
+
+	case "L":
+		if m.jumpSameWeekday(1) {
+			return m.navigateAndReload()
+		}
+		return m, nil
 	}
 
 	return m, nil
@@ -696,23 +1889,23 @@ func (m *Model) handleDetailKey(msg tea.KeyPressMsg) (*Model, tea.Cmd) {
 
 	switch msg.String() {
 	case "j", "down":
-		if m.detailCmdIdx < len(m.detailCommands)-1 {
-			m.detailCmdIdx++
-			m.ensureDetailCmdVisible()
+		// At the end of the loaded pages, wait for the next one instead of wrapping
+		if m.detailPaged && !m.detailExhausted && m.detailCmdIdx == len(m.detailCommands)-1 {
+			return m, m.loadNextDetailPage()
 		}
-		return m, nil
+		m.detailCmdIdx = m.stepIndex(m.detailCmdIdx, 1, len(m.detailCommands))
+		m.ensureDetailCmdVisible()
+		return m, m.loadDetailPageIfNeeded()
 
 	case "k", "up":
-		if m.detailCmdIdx > 0 {
-			m.detailCmdIdx--
-			m.ensureDetailCmdVisible()
-		}
-		return m, nil
+		m.detailCmdIdx = m.stepIndex(m.detailCmdIdx, -1, len(m.detailCommands))
+		m.ensureDetailCmdVisible()
+		return m, m.loadDetailPageIfNeeded()
 
 	case "enter":
 		if len(m.detailCommands) > 0 {
 			cmd := m.detailCommands[m.detailCmdIdx]
-			return m, m.loadCommandContext(cmd.ID)
+			return m, m.showCommandContext(cmd.ID)
 		}
 		return m, nil
 
@@ -734,11 +1927,96 @@ func (m *Model) handleDetailKey(msg tea.KeyPressMsg) (*Model, tea.Cmd) {
 		}
 		return m, nil
 
+	case "c":
+		m.collapseRepeats = !m.collapseRepeats
+		return m, m.refreshDetailView()
+
+	case "i":
+		m.detailIDOrder = !m.detailIDOrder
+		return m, m.refreshDetailView()
+
+	case "U":
+		m.detailLatestUnique = !m.detailLatestUnique
+		return m, m.refreshDetailView()
+
+	case "s":
+		m.detailMainSession = !m.detailMainSession
+		return m, m.refreshDetailView()
+
+	case "N":
+		m.showIDs = !m.showIDs
+		return m, nil
+
+	case "g":
+		m.commitDividers = !m.commitDividers
+		m.ensureDetailCmdVisible()
+		return m, nil
+
+	case "p":
+		m.pipelineHeads = !m.pipelineHeads
+		return m, nil
+
+	case "@":
+		if len(m.detailCommands) > 0 {
+			return m, m.scopeToDir(m.detailCommands[m.detailCmdIdx].WorkingDir)
+		}
+		return m, nil
+
+	case "r":
+		m.detailNewestFirst = !m.detailNewestFirst
+		return m, m.refreshDetailView()
+
+	case "z":
+		return m, m.toggleMinuteTimeline()
+
+	case "x":
+		m.openExpandPopup()
+		return m, nil
+
+	case "T":
+		m.minDuration = nextDurationThreshold(m.minDuration)
+		return m, m.refreshDetailView()
+
+	case "=":
+		if len(m.detailCommands) > 0 {
+			selectedID := m.detailCommands[m.detailCmdIdx].ID
+			m.filterText = `"` + m.detailCommands[m.detailCmdIdx].CommandText + `"`
+			cmd := m.refreshDetailView()
+			for i, c := range m.detailCommands {
+				if c.ID == selectedID {
+					m.detailCmdIdx = i
+					m.ensureDetailCmdVisible()
+					break
+				}
+			}
+			return m, cmd
+		}
+		return m, nil
+
+	case "n":
+		if m.detailContextKey.WorkingDir == "" {
+			return m, nil
+		}
+		m.noteActive = true
+		m.noteText = m.detailNote()
+		return m, nil
+
 	case "-":
 		m.viewState = SummaryView
+		m.flatView = false
+		return m, nil
+
+	case "0":
+		if m.flatView {
+			m.viewState = SummaryView
+			m.flatView = false
+		}
 		return m, nil
 
 	case "H":
+		if m.flatView {
+			return m, nil
+		}
 		if m.detailContextOrphaned() {
 			if len(m.contexts) > 0 {
 				m.selectedIdx = len(m.contexts) - 1
@@ -751,6 +2029,9 @@ func (m *Model) handleDetailKey(msg tea.KeyPressMsg) (*Model, tea.Cmd) {
 		return m, nil
 
 	case "L":
+		if m.flatView {
+			return m, nil
+		}
 		if m.detailContextOrphaned() {
 			if len(m.contexts) > 0 {
 				m.selectedIdx = 0
@@ -766,9 +2047,11 @@ func (m *Model) handleDetailKey(msg tea.KeyPressMsg) (*Model, tea.Cmd) {
 	return m, nil
 }
 
-// cmdDetailAllCommands returns the full session context list
-func (m *Model) cmdDetailAllCommands() []models.Command {
-	return m.cmdDetailAll
+// cmdDetailVisible returns the session context rows in the window (see
+// cmdDetailWindow)
+func (m *Model) cmdDetailVisible() []models.Command {
+	start, n := m.cmdDetailWindow()
+	return m.cmdDetailAll[start : start+n]
 }
 
 func (m *Model) handleCommandDetailKey(msg tea.KeyPressMsg) (*Model, tea.Cmd) {
@@ -779,17 +2062,27 @@ func (m *Model) handleCommandDetailKey(msg tea.KeyPressMsg) (*Model, tea.Cmd) {
 	case "j", "down":
 		if m.cmdDetailIdx < len(m.cmdDetailAll)-1 {
 			nextCmd := m.cmdDetailAll[m.cmdDetailIdx+1]
-			return m, m.loadCommandContext(nextCmd.ID)
+			return m, m.showCommandContext(nextCmd.ID)
 		}
 		return m, nil
 
 	case "k", "up":
 		if m.cmdDetailIdx > 0 {
 			prevCmd := m.cmdDetailAll[m.cmdDetailIdx-1]
-			return m, m.loadCommandContext(prevCmd.ID)
+			return m, m.showCommandContext(prevCmd.ID)
 		}
 		return m, nil
 
+	case "ctrl+n":
+		return m, m.scrollCmdDetail(1)
+
+	case "ctrl+p":
+		return m, m.scrollCmdDetail(-1)
+
+	case "z":
+		m.cmdDetailScroll = 0
+		return m, nil
+
 	case "enter":
 		if m.cmdDetailIdx < len(m.cmdDetailAll) {
 			m.viewState = CommandTextView
@@ -803,6 +2096,18 @@ func (m *Model) handleCommandDetailKey(msg tea.KeyPressMsg) (*Model, tea.Cmd) {
 		}
 		return m, nil
 
+	case "Y":
+		if target := m.CmdDetailTarget(); target != nil {
+			return m, yankRecordToClipboard(*target)
+		}
+		return m, nil
+
+	case "@":
+		if target := m.CmdDetailTarget(); target != nil {
+			return m, m.scopeToDir(target.WorkingDir)
+		}
+		return m, nil
+
 	case "S":
 		if m.cmdDetailIdx < len(m.cmdDetailAll) {
 			return m, m.toggleStar(m.cmdDetailAll[m.cmdDetailIdx].ID)
@@ -815,6 +2120,14 @@ func (m *Model) handleCommandDetailKey(msg tea.KeyPressMsg) (*Model, tea.Cmd) {
 		}
 		return m, nil
 
+	case "b":
+		m.hideBranchSwitch = !m.hideBranchSwitch
+		return m, nil
+
+	case "U":
+		m.utcTimes = !m.utcTimes
+		return m, nil
+
 	case "-":
 		// Return to ContextDetailView, restore selection to viewed command
 		m.viewState = ContextDetailView
@@ -880,20 +2193,74 @@ func (m *Model) handleHelpKey(msg tea.KeyPressMsg) (*Model, tea.Cmd) {
 	switch msg.String() {
 	case "q", "ctrl+c":
 		return m, tea.Quit
-	case "?", "esc":
+	case "/":
+		m.helpFilterActive = true
+		return m, nil
+	case "esc":
+		// The first esc clears a search, the next one closes help
+		if m.helpFilterText != "" {
+			m.helpFilterText = ""
+			return m, nil
+		}
+		m.viewState = m.helpPreviousView
+		return m, nil
+	case "?":
+		m.helpFilterText = ""
 		m.viewState = m.helpPreviousView
 		return m, nil
 	}
 	return m, nil
 }
 
+// handleHelpFilterKey edits the help search, which narrows the listed
+// bindings as it is typed
+func (m *Model) handleHelpFilterKey(msg tea.KeyPressMsg) (*Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+
+	case "enter":
+		m.helpFilterActive = false
+		return m, nil
+
+	case "esc":
+		m.helpFilterActive = false
+		m.helpFilterText = ""
+		return m, nil
+
+	case "backspace":
+		if len(m.helpFilterText) == 0 {
+			m.helpFilterActive = false
+			return m, nil
+		}
+		runes := []rune(m.helpFilterText)
+		m.helpFilterText = string(runes[:len(runes)-1])
+		return m, nil
+
+	default:
+		if msg.Text != "" {
+			m.helpFilterText += msg.Text
+		}
+	}
+	return m, nil
+}
+
+// handleFilterKey edits the filter, or the exclude when editingExclude is set
 func (m *Model) handleFilterKey(msg tea.KeyPressMsg) (*Model, tea.Cmd) {
+	text := &m.filterText
+	if m.editingExclude {
+		text = &m.excludeText
+	}
+
 	switch msg.String() {
 	case "ctrl+c":
 		return m, tea.Quit
 
 	case "enter":
 		m.filterActive = false
+		if !m.editingExclude {
+			m.recordFilterHistory(m.filterText)
+		}
 		// If in detail view, re-enter to apply filter
 		if m.viewState == ContextDetailView {
 			return m, m.refreshDetailView()
@@ -902,21 +2269,30 @@ func (m *Model) handleFilterKey(msg tea.KeyPressMsg) (*Model, tea.Cmd) {
 
 	case "esc":
 		m.filterActive = false
-		m.filterText = m.filterPrevText
+		*text = m.filterPrevText
 		// If in detail view, re-enter to restore filter
 		if m.viewState == ContextDetailView {
 			return m, m.refreshDetailView()
 		}
 		return m, nil
 
+	case "up", "down":
+		if m.editingExclude || !m.recallFilterHistory(msg.String() == "up") {
+			return m, nil
+		}
+		if m.viewState == ContextDetailView {
+			return m, m.refreshDetailView()
+		}
+		return m, nil
+
 	case "backspace":
-		if len(m.filterText) == 0 {
+		if len(*text) == 0 {
 			m.filterActive = false
 			return m, nil
 		}
 		// Remove last rune
-		runes := []rune(m.filterText)
-		m.filterText = string(runes[:len(runes)-1])
+		runes := []rune(*text)
+		*text = string(runes[:len(runes)-1])
 		// Live update in detail view
 		if m.viewState == ContextDetailView {
 			return m, m.refreshDetailView()
@@ -925,7 +2301,7 @@ func (m *Model) handleFilterKey(msg tea.KeyPressMsg) (*Model, tea.Cmd) {
 
 	default:
 		if msg.Text != "" {
-			m.filterText += msg.Text
+			*text += msg.Text
 			// Live update in detail view
 			if m.viewState == ContextDetailView {
 				return m, m.refreshDetailView()
@@ -937,15 +2313,279 @@ func (m *Model) handleFilterKey(msg tea.KeyPressMsg) (*Model, tea.Cmd) {
 	return m, nil
 }
 
+// filterHistorySize caps the number of remembered filters
+const filterHistorySize = 50
+
+// recordFilterHistory appends an applied filter to the filter history,
+// skipping empty filters and repeats of the most recent entry
+func (m *Model) recordFilterHistory(filter string) {
+	if filter == "" {
+		return
+	}
+	if n := len(m.filterHistory); n > 0 && m.filterHistory[n-1] == filter {
+		return
+	}
+	m.filterHistory = append(m.filterHistory, filter)
+	if len(m.filterHistory) > filterHistorySize {
+		m.filterHistory = m.filterHistory[len(m.filterHistory)-filterHistorySize:]
+	}
+}
+
+// recallFilterHistory replaces the filter text with the previous (older) or
+// next (newer) history entry, like shell history. Moving past the newest
+// entry restores the text typed before recalling. Returns false at either end.
+func (m *Model) recallFilterHistory(older bool) bool {
+	if older {
+		if m.filterHistoryIdx == 0 {
+			return false
+		}
+		if m.filterHistoryIdx == len(m.filterHistory) {
+			m.filterDraft = m.filterText
+		}
+		m.filterHistoryIdx--
+		m.filterText = m.filterHistory[m.filterHistoryIdx]
+		return true
+	}
+
+	if m.filterHistoryIdx >= len(m.filterHistory) {
+		return false
+	}
+	m.filterHistoryIdx++
+	if m.filterHistoryIdx == len(m.filterHistory) {
+		m.filterText = m.filterDraft
+	} else {
+		m.filterText = m.filterHistory[m.filterHistoryIdx]
+	}
+	return true
+}
+
+// quitNeedsConfirm reports whether q would throw away state: a filter,
+// exclude or multi-day selection, or a position inside command detail
+func (m *Model) quitNeedsConfirm() bool {
+	if m.filterText != "" || m.excludeText != "" || m.dirFilter != "" || m.selectActive {
+		return true
+	}
+	return m.viewState == CommandDetailView || m.viewState == CommandTextView
+}
+
+// handleQuitPromptKey answers the "Quit? y/n" prompt. Any key other than y
+// or ctrl+c cancels, leaving the view as it was.
+func (m *Model) handleQuitPromptKey(msg tea.KeyPressMsg) (*Model, tea.Cmd) {
+	m.quitPromptActive = false
+	switch msg.String() {
+	case "y", "Y", "ctrl+c":
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+func (m *Model) handleNoteKey(msg tea.KeyPressMsg) (*Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+
+	case "enter":
+		m.noteActive = false
+		key := noteKeyFor(m.detailContextKey, m.detailContextBranch)
+		return m, m.saveNote(key, strings.TrimSpace(m.noteText))
+
+	case "esc":
+		m.noteActive = false
+		m.noteText = ""
+		return m, nil
+
+	case "backspace":
+		if len(m.noteText) > 0 {
+			runes := []rune(m.noteText)
+			m.noteText = string(runes[:len(runes)-1])
+		}
+		return m, nil
+
+	default:
+		if msg.Text != "" {
+			m.noteText += msg.Text
+		}
+	}
+
+	return m, nil
+}
+
+func (m *Model) handleTagKey(msg tea.KeyPressMsg) (*Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+
+	case "enter":
+		m.tagActive = false
+		return m, m.tagSelectedContext(strings.TrimSpace(m.tagText))
+
+	case "esc":
+		m.tagActive = false
+		m.tagText = ""
+		return m, nil
+
+	case "backspace":
+		if len(m.tagText) > 0 {
+			runes := []rune(m.tagText)
+			m.tagText = string(runes[:len(runes)-1])
+		}
+		return m, nil
+
+	default:
+		if msg.Text != "" {
+			m.tagText += msg.Text
+		}
+	}
+
+	return m, nil
+}
+
+// tagSelectedContext tags every command of the selected context in the
+// displayed period, or untags them when input starts with "-". A merged
+// worktree context covers each of its directories, and a weekday filter
+// limits the change to the days shown.
+func (m *Model) tagSelectedContext(input string) tea.Cmd {
+	untag := strings.HasPrefix(input, "-")
+	tag := strings.TrimSpace(strings.TrimPrefix(input, "-"))
+	if tag == "" || m.selectedIdx >= len(m.contexts) {
+		return nil
+	}
+	ctx := m.contexts[m.selectedIdx]
+	dirs := ctx.WorkingDirs
+	if len(dirs) == 0 {
+		dirs = []string{ctx.Key.WorkingDir}
+	}
+	start, end := m.dateRange()
+	windows := [][2]int64{{start, end}}
+	if wd, ok := m.filteredWeekday(); ok {
+		windows = weekdayWindows(start, end, wd)
+	}
+
+	database := m.db
+	repo, branch := ctx.Key.GitRepo, ctx.Branch.DBValue()
+	return func() tea.Msg {
+		apply := database.TagContext
+		if untag {
+			apply = database.UntagContext
+		}
+		var count int64
+		for _, dir := range dirs {
+			for _, w := range windows {
+				n, err := apply(dir, repo, branch, w[0], w[1], tag)
+				if err != nil {
+					return tagResultMsg{tag: tag, untag: untag, count: count, err: err}
+				}
+				count += n
+			}
+		}
+		return tagResultMsg{tag: tag, untag: untag, count: count}
+	}
+}
+
+// contextDir returns the working directory of the context in view: the detail
+// view's context, or the selected context in the summary. "" if there is none.
+func (m *Model) contextDir() string {
+	if m.viewState == ContextDetailView {
+		// The collapsed non-git context spans directories: use the command's
+		if isNoRepoContext(m.detailContextKey) {
+			if m.detailCmdIdx < len(m.detailCommands) {
+				return m.detailCommands[m.detailCmdIdx].WorkingDir
+			}
+			return ""
+		}
+		return m.detailContextKey.WorkingDir
+	}
+	if m.selectedIdx < len(m.contexts) && !isNoRepoContext(m.contexts[m.selectedIdx].Key) {
+		return m.contexts[m.selectedIdx].Key.WorkingDir
+	}
+	return ""
+}
+
+// failureSearchDays bounds how far back F looks for a failed command
+const failureSearchDays = 90
+
+// findPrevFailureDay searches the days before the displayed period for the
+// most recent failed command of the selected (or detail) context
+func (m *Model) findPrevFailureDay() tea.Cmd {
+	var key summary.ContextKey
+	var branch summary.BranchKey
+	switch {
+	case m.viewState == ContextDetailView && !m.flatView:
+		key, branch = m.detailContextKey, m.detailContextBranch
+	case m.viewState == SummaryView && m.selectedIdx < len(m.contexts):
+		key, branch = m.contexts[m.selectedIdx].Key, m.contexts[m.selectedIdx].Branch
+	default:
+		return nil
+	}
+	if isNoRepoContext(key) {
+		return nil // not one directory to search
+	}
+
+	database := m.db
+	date, period := m.currentDate, m.period
+	start, _ := m.dateRange()
+	since := time.Unix(start, 0).AddDate(0, 0, -failureSearchDays).Unix()
+	return func() tea.Msg {
+		b := branch.DBValue()
+		ts, found, err := database.FindPrevFailureDay(key.WorkingDir, key.GitRepo, &b, since, start)
+		return failureDayMsg{ts: ts, found: found, err: err, date: date, period: period}
+	}
+}
+
+// randomCommand picks a random command of the period, to rediscover one
+// run and forgotten
+func (m *Model) randomCommand() tea.Cmd {
+	database := m.db
+	start, end := m.dateRange()
+	return func() tea.Msg {
+		cmd, err := database.GetRandomCommand(db.RandomOptions{StartTime: start, EndTime: end})
+		return randomCommandMsg{cmd: cmd, err: err}
+	}
+}
+
 // detailContextOrphaned returns true when the detail view's context is not
 // present in the current contexts list (e.g. after navigating to a period
 // where the context has no commands).
 func (m *Model) detailContextOrphaned() bool {
+	if m.flatView {
+		return false
+	}
 	if m.selectedIdx >= len(m.contexts) {
 		return true
 	}
-	ctx := m.contexts[m.selectedIdx]
-	return ctx.Key != m.detailContextKey || ctx.Branch != m.detailContextBranch
+	return !m.matchesContext(m.contexts[m.selectedIdx], m.detailContextKey, m.detailContextBranch)
+}
+
+// scopeToDir scopes the summary to the commands run in dir and returns to
+// it, leaving the flat view, so every context of that place is listed
+func (m *Model) scopeToDir(dir string) tea.Cmd {
+	m.dirFilter = dir
+	m.flatView = false
+	m.viewState = SummaryView
+	return m.reloadContexts("Scoped to " + formatDir(dir))
+}
+
+// rebuildDetailView rebuilds the detail view from reloaded contexts, keeping
+// the selection on the same command, or at the same position when it is gone
+func (m *Model) rebuildDetailView() tea.Cmd {
+	var selectedID int64
+	oldIdx := m.detailCmdIdx
+	if oldIdx < len(m.detailCommands) {
+		selectedID = m.detailCommands[oldIdx].ID
+	}
+	cmd := m.refreshDetailView()
+	if len(m.detailCommands) == 0 {
+		return cmd
+	}
+	m.detailCmdIdx = min(oldIdx, len(m.detailCommands)-1)
+	for i, c := range m.detailCommands {
+		if c.ID == selectedID {
+			m.detailCmdIdx = i
+			break
+		}
+	}
+	m.ensureDetailCmdVisible()
+	return cmd
 }
 
 // refreshDetailView re-applies filters to the current detail context without
@@ -966,6 +2606,9 @@ func (m *Model) refreshDetailView() tea.Cmd {
 func (m *Model) enterDetailView() tea.Cmd {
 	m.emptyPrevPeriod = nil
 	m.emptyNextPeriod = nil
+	if m.flatView {
+		return m.enterFlatView()
+	}
 
 	if len(m.contexts) == 0 || m.selectedIdx >= len(m.contexts) {
 		m.viewState = ContextDetailView
@@ -980,11 +2623,93 @@ func (m *Model) enterDetailView() tea.Cmd {
 
 	m.detailContextKey = ctx.Key
 	m.detailContextBranch = ctx.Branch
+	m.detailWorkingDirs = ctx.WorkingDirs
+
+	m.viewState = ContextDetailView
+	m.detailCmdIdx = 0
+	m.detailScrollOffset = 0
+
+	// A new generation drops pages still loading for the previous entry
+	m.detailPageGen++
+	m.detailPaged = m.shouldPageDetail(ctx)
+	if m.detailPaged {
+		m.detailLoaded = nil
+		m.detailExhausted = false
+		m.detailPageLoading = false
+		m.buildDetail(nil)
+		return m.loadNextDetailPage()
+	}
+
+	// Apply substring filter first, then mode filter, then duration threshold
+	m.buildDetail(filterMinDuration(visibleCommands(ctx.Commands, m.displayMode, m.filterText, m.excludeText), m.minDuration))
+	return m.detailBuilt()
+}
+
+// durationThresholds are the minimum durations T cycles the detail view through
+var durationThresholds = []time.Duration{0, time.Second, 5 * time.Second, 30 * time.Second, time.Minute, 5 * time.Minute}
+
+// nextDurationThreshold returns the threshold after d, wrapping back to 0
+func nextDurationThreshold(d time.Duration) time.Duration {
+	for i, t := range durationThresholds {
+		if t == d {
+			return durationThresholds[(i+1)%len(durationThresholds)]
+		}
+	}
+	return 0
+}
+
+// filterMinDuration keeps the commands that ran for at least min. Durations
+// are stored in milliseconds; commands without one are dropped once a
+// threshold is set, since how long they took is unknown.
+func filterMinDuration(cmds []models.Command, min time.Duration) []models.Command {
+	if min == 0 {
+		return cmds
+	}
+	minMs := min.Milliseconds()
+	var out []models.Command
+	for _, c := range cmds {
+		if c.Duration != nil && *c.Duration >= minMs {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// enterFlatView shows every command of the period in the detail view, in time
+// order and bucketed like a context's, with no context selected
+func (m *Model) enterFlatView() tea.Cmd {
+	m.viewState = ContextDetailView
+	m.detailContextKey = summary.ContextKey{}
+	m.detailContextBranch = ""
+	m.detailWorkingDirs = nil
+	m.detailCmdIdx = 0
+	m.detailScrollOffset = 0
+	m.detailPageGen++
+	m.detailPaged = false
+
+	m.buildDetail(filterMinDuration(visibleCommands(m.flatCommands(), m.displayMode, m.filterText, m.excludeText), m.minDuration))
+	return m.detailBuilt()
+}
 
-	// Apply substring filter first, then mode filter
-	subFiltered := filterBySubstring(ctx.Commands, m.filterText)
-	filtered := filterByMode(subFiltered, m.displayMode)
+// flatCommands returns the commands of every loaded context, oldest first
+func (m *Model) flatCommands() []models.Command {
+	var all []models.Command
+	for _, ctx := range m.contexts {
+		all = append(all, ctx.Commands...)
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].Timestamp == all[j].Timestamp && all[i].TimestampNs == all[j].TimestampNs {
+			return all[i].ID < all[j].ID
+		}
+		return all[i].RanBefore(&all[j])
+	})
+	return all
+}
 
+// buildDetail groups the detail view's commands, already filtered and sorted
+// by timestamp, into buckets and the flat selectable list. The selection is
+// left alone so pages can be appended under it.
+func (m *Model) buildDetail(filtered []models.Command) {
 	// Bucket size depends on period
 	var bucketSize summary.BucketSize
 	switch m.period {
@@ -996,12 +2721,30 @@ func (m *Model) enterDetailView() tea.Cmd {
 		bucketSize = summary.Hourly
 	}
 
+	m.detailMainSessionPid = 0
+	if m.detailMainSession {
+		m.detailMainSessionPid = busiestSession(filtered)
+		if m.detailMainSessionPid != 0 {
+			filtered = sessionCommands(filtered, m.detailMainSessionPid)
+		}
+	}
+
+	runCounts := make(map[int64]int)
+	if m.detailLatestUnique {
+		filtered = latestRuns(filtered, runCounts)
+	}
+
 	bucketMap := summary.BucketBy(filtered, bucketSize)
 	orderedIDs := summary.GetOrderedBuckets(bucketMap)
 
 	// Build detail buckets and flat command list
 	var buckets []DetailBucket
 	var flatCommands []models.Command
+	repeats := make(map[int64]repeatRun)
+
+	if m.detailNewestFirst {
+		slices.Reverse(orderedIDs)
+	}
 
 	for _, id := range orderedIDs {
 		bucket := bucketMap[id]
@@ -1013,53 +2756,119 @@ func (m *Model) enterDetailView() tea.Cmd {
 			t := time.Unix(int64(id), 0).Local()
 			label = t.Format("Mon Jan 2")
 		case MonthPeriod:
-			// Derive the Monday from the first command in this bucket
-			if len(bucket.Commands) > 0 {
-				t := time.Unix(bucket.Commands[0].Timestamp, 0).Local()
-				monday := mondayOfWeek(t)
-				label = fmt.Sprintf("Week of %s", monday.Format("Jan 2"))
-			} else {
-				label = fmt.Sprintf("Week %d", id)
-			}
+			label = m.weekBucketLabel(bucket.Commands, id)
 		default:
 			label = summary.FormatHour(id)
 		}
 
-		// Sort commands within bucket by timestamp
+		// Sort commands within bucket by timestamp, or by id when toggled
 		cmds := make([]models.Command, len(bucket.Commands))
 		copy(cmds, bucket.Commands)
 		sort.Slice(cmds, func(i, j int) bool {
-			return cmds[i].Timestamp < cmds[j].Timestamp
+			if m.detailIDOrder || cmds[i].Timestamp == cmds[j].Timestamp && cmds[i].TimestampNs == cmds[j].TimestampNs {
+				return cmds[i].ID < cmds[j].ID
+			}
+			return cmds[i].RanBefore(&cmds[j])
 		})
+		// Collapse in time order, so each run keeps its latest command and
+		// its first timestamp, before reversing
+		if m.collapseRepeats {
+			cmds = collapseConsecutive(cmds, repeats)
+		}
+		if m.detailNewestFirst {
+			slices.Reverse(cmds)
+		}
 
-		buckets = append(buckets, DetailBucket{
-			Label:    label,
-			Commands: cmds,
-		})
+		if bucketSize == summary.Hourly && len(cmds) > 0 && m.expandedHours[hourStart(cmds[0].Timestamp)] {
+			buckets = append(buckets, DetailBucket{Label: label})
+			buckets = append(buckets, minuteBuckets(cmds)...)
+		} else {
+			buckets = append(buckets, DetailBucket{
+				Label:    label,
+				Commands: cmds,
+			})
+		}
 		flatCommands = append(flatCommands, cmds...)
 	}
 
-	m.viewState = ContextDetailView
 	m.detailBuckets = buckets
 	m.detailCommands = flatCommands
-	m.detailCmdIdx = 0
-	m.detailScrollOffset = 0
+	m.detailRepeats = repeats
+	m.detailRunCounts = runCounts
+	m.detailCommitSegments = commitSegments(filtered)
+}
+
+// hourStart returns the Unix time of the start of ts's hour, in local time
+func hourStart(ts int64) int64 {
+	t := time.Unix(ts, 0)
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location()).Unix()
+}
+
+// minuteBuckets splits an hour's commands, in display order, into one
+// bucket per run of commands sharing a minute
+func minuteBuckets(cmds []models.Command) []DetailBucket {
+	var out []DetailBucket
+	for _, c := range cmds {
+		label := time.Unix(c.Timestamp, 0).Format("3:04pm")
+		if n := len(out); n > 0 && out[n-1].Label == label {
+			out[n-1].Commands = append(out[n-1].Commands, c)
+			continue
+		}
+		out = append(out, DetailBucket{Label: label, Commands: []models.Command{c}, Minute: true})
+	}
+	return out
+}
+
+// toggleMinuteTimeline expands the selected command's hour into minutes, or
+// collapses it back. Only the day view has hourly buckets. The selection
+// stays on the same command, since expanding only adds headers.
+func (m *Model) toggleMinuteTimeline() tea.Cmd {
+	if m.period != DayPeriod || len(m.detailCommands) == 0 {
+		return nil
+	}
+	selectedID := m.detailCommands[m.detailCmdIdx].ID
+	hour := hourStart(m.detailCommands[m.detailCmdIdx].Timestamp)
+	if m.expandedHours[hour] {
+		delete(m.expandedHours, hour)
+	} else {
+		if m.expandedHours == nil {
+			m.expandedHours = make(map[int64]bool)
+		}
+		m.expandedHours[hour] = true
+	}
+
+	cmd := m.refreshDetailView()
+	for i, c := range m.detailCommands {
+		if c.ID == selectedID {
+			m.detailCmdIdx = i
+			break
+		}
+	}
+	m.ensureDetailCmdVisible()
+	return cmd
+}
 
+// detailBuilt finishes entering the detail view once its commands are built:
+// it places the cursor after a delete and asks for peeks when empty.
+func (m *Model) detailBuilt() tea.Cmd {
 	// After a delete, position cursor at the closest command with ID < deleted ID
-	if m.pendingDeletedID > 0 && len(flatCommands) > 0 {
+	if m.pendingDeletedID > 0 && len(m.detailCommands) > 0 {
 		deletedID := m.pendingDeletedID
 		m.pendingDeletedID = 0
 		bestIdx := 0
-		for i, cmd := range flatCommands {
+		for i, cmd := range m.detailCommands {
 			if cmd.ID < deletedID {
 				bestIdx = i
+				if m.detailNewestFirst {
+					break // the closest older command is the first one listed
+				}
 			}
 		}
 		m.detailCmdIdx = bestIdx
 		m.ensureDetailCmdVisible()
 	}
 
-	if len(flatCommands) == 0 {
+	if len(m.detailCommands) == 0 {
 		return m.loadEmptyStatePeeks()
 	}
 	return nil
@@ -1068,36 +2877,42 @@ func (m *Model) enterDetailView() tea.Cmd {
 // loadEmptyStatePeeks returns an async command that queries adjacent periods
 // for the current context and returns peek data (date label + command count).
 func (m *Model) loadEmptyStatePeeks() tea.Cmd {
+	// Peek counts cover whole periods and every duration, so they would
+	// overstate a weekday or duration-filtered view; the flat view has no
+	// context to peek for
+	if m.weekdayFilter != 0 || m.minDuration != 0 || m.flatView || isNoRepoContext(m.detailContextKey) {
+		return nil
+	}
 	database := m.db
 	ctxKey := m.detailContextKey
 	ctxBranch := m.detailContextBranch
 	curDate := m.currentDate
 	period := m.period
+	weekLabels := m.weekLabels
 	mode := m.displayMode
 	filter := m.filterText
+	exclude := m.excludeText
 	nowFn := m.now
 	isCurrentPeriod := m.isCurrentPeriod()
+	acrossWorktrees := m.groupWorktrees && isWorktreeGroupable(ctxKey, ctxBranch)
 
 	return func() tea.Msg {
 		peekPeriod := func(date time.Time) *periodPeekData {
 			start, end := dateRangeForPeriod(date, period)
-			commands, err := database.GetCommandsByDateRange(start, end, nil)
+			branch := ctxBranch.DBValue()
+			var count int
+			var err error
+			if acrossWorktrees {
+				count, err = database.PeekBranchCount(ctxKey.GitRepo, &branch, start, end, mode, filter, exclude)
+			} else {
+				count, err = database.PeekContextCount(ctxKey.WorkingDir, ctxKey.GitRepo, &branch, start, end, mode, filter, exclude)
+			}
 			if err != nil {
 				return nil
 			}
-			grouped := summary.GroupByContext(commands)
-			if branches, ok := grouped.Contexts[ctxKey]; ok {
-				if cmds, ok := branches[ctxBranch]; ok {
-					count := filteredCommandCount(cmds, mode, filter)
-					return &periodPeekData{
-						dateLabel: periodDateLabel(date, period, nowFn),
-						count:     count,
-					}
-				}
-			}
 			return &periodPeekData{
-				dateLabel: periodDateLabel(date, period, nowFn),
-				count:     0,
+				dateLabel: periodDateLabel(date, period, weekLabels, nowFn),
+				count:     count,
 			}
 		}
 
@@ -1114,13 +2929,41 @@ func (m *Model) loadEmptyStatePeeks() tea.Cmd {
 	}
 }
 
+// weekBucketLabel labels a month view's week bucket from its first command:
+// "Week of Feb 2", or "W06" with ISO week labels
+func (m *Model) weekBucketLabel(commands []models.Command, id int) string {
+	if len(commands) == 0 {
+		return fmt.Sprintf("Week %d", id)
+	}
+	t := time.Unix(commands[0].Timestamp, 0).Local()
+	if m.weekLabels == ISOWeekLabels {
+		_, week := t.ISOWeek()
+		return fmt.Sprintf("W%02d", week)
+	}
+	return fmt.Sprintf("Week of %s", mondayOfWeek(t).Format("Jan 2"))
+}
+
+// relabelDetailBuckets refreshes the month view's week bucket labels after
+// the week label style changes, leaving the selection alone
+func (m *Model) relabelDetailBuckets() {
+	if m.period != MonthPeriod {
+		return
+	}
+	for i := range m.detailBuckets {
+		m.detailBuckets[i].Label = m.weekBucketLabel(m.detailBuckets[i].Commands, 0)
+	}
+}
+
 // periodDateLabel formats a date label for a period, similar to dateDisplayString
 // but without trailing spaces or indicators.
-func periodDateLabel(date time.Time, period Period, nowFn func() time.Time) string {
+func periodDateLabel(date time.Time, period Period, weekLabels WeekLabelStyle, nowFn func() time.Time) string {
 	currentYear := nowFn().Year()
 
 	switch period {
 	case WeekPeriod:
+		if weekLabels == ISOWeekLabels {
+			return isoWeekLabel(date)
+		}
 		monday := mondayOfWeek(date)
 		if monday.Year() == currentYear {
 			return fmt.Sprintf("Week of %s", monday.Format("Jan 2"))
@@ -1139,25 +2982,49 @@ func periodDateLabel(date time.Time, period Period, nowFn func() time.Time) stri
 
 // detailCmdBodyLine returns the body-line index and bucket-start line of the
 // currently selected command. bucketStart points to the blank line before the
-// bucket header, so scrolling to it reveals the full bucket context.
+// bucket header, so scrolling to it reveals the full bucket context. A minute
+// bucket's start is its hour's, so the hour header scrolls into view too.
 func (m *Model) detailCmdBodyLine() (cmdLine int, bucketStart int) {
-	line := 0
+	line := m.detailNoteLineCount()
 	cmdSeen := 0
+	bStart := 0
 	for _, bucket := range m.detailBuckets {
-		bStart := line
-		line++ // blank before bucket
+		if !bucket.Minute {
+			bStart = line
+			line++ // blank before bucket
+		}
 		line++ // bucket header
-		for range bucket.Commands {
+		for _, cmd := range bucket.Commands {
+			_, divider := m.commitDivider(cmd)
+			if divider && m.detailNewestFirst {
+				line++
+			}
 			if cmdSeen == m.detailCmdIdx {
 				return line, bStart
 			}
 			line++
+			if divider && !m.detailNewestFirst {
+				line++
+			}
 			cmdSeen++
 		}
 	}
 	return line, 0
 }
 
+// stepIndex moves idx by delta within a list of n items, clamping at the
+// ends or wrapping around with WithWrapNavigation
+func (m *Model) stepIndex(idx, delta, n int) int {
+	if n == 0 {
+		return idx
+	}
+	next := idx + delta
+	if m.wrapNavigation {
+		return (next%n + n) % n
+	}
+	return max(0, min(next, n-1))
+}
+
 // ensureDetailCmdVisible adjusts detailScrollOffset to keep selected command in view
 func (m *Model) ensureDetailCmdVisible() {
 	if m.height == 0 || len(m.detailCommands) == 0 {
@@ -1187,6 +3054,15 @@ func (m *Model) View() tea.View {
 type contextsLoadedMsg struct {
 	contexts   []ContextItem
 	starredIDs map[int64]bool
+	notes      map[db.ContextNoteKey]string
+	activity   []int            // commands per hour of the period, when the timeline is shown
+	slowest    []models.Command // the period's slowest commands, when that panel is shown
+	query      contextsQuery
+}
+
+type historySizeMsg struct {
+	count int
+	err   error
 }
 
 type errMsg struct {
@@ -1194,9 +3070,13 @@ type errMsg struct {
 }
 
 type commandContextLoadedMsg struct {
-	before []models.Command
-	target *models.Command
-	after  []models.Command
+	before     []models.Command
+	target     *models.Command
+	after      []models.Command
+	moreBefore bool // the session has commands before those in before
+	moreAfter  bool // and after those in after
+	gaps       map[int64]db.Gap
+	tags       []string
 }
 
 type emptyStatePeeksMsg struct {
@@ -1206,12 +3086,56 @@ type emptyStatePeeksMsg struct {
 
 type clearStatusMsg struct{}
 
+// failureDayMsg carries the result of an F search started on date and period
+type failureDayMsg struct {
+	ts     int64
+	found  bool
+	err    error
+	date   time.Time
+	period Period
+}
+
+// randomCommandMsg carries the command picked by ~, nil when the period has
+// none
+type randomCommandMsg struct {
+	cmd *models.Command
+	err error
+}
+
+type refreshTickMsg struct{}
+
+type contextsRefreshedMsg struct {
+	loaded contextsLoadedMsg
+	date   time.Time
+	period Period
+	status string // flashed once loaded, when asked for by a key (R) rather than the refresh timer
+}
+
 type starToggleResultMsg struct {
 	id      int64
 	starred bool
 	err     error
 }
 
+type noteSavedMsg struct {
+	key  db.ContextNoteKey
+	note string
+	err  error
+}
+
+type tagResultMsg struct {
+	tag   string
+	untag bool
+	count int64
+	err   error
+}
+
+type exportResultMsg struct {
+	dest  string
+	count int
+	err   error
+}
+
 type deleteResultMsg struct {
 	id    int64
 	count int64
@@ -1235,6 +3159,38 @@ func (m *Model) Focused() bool {
 	return m.focused
 }
 
+func (m *Model) DebugOverlay() bool {
+	return m.debugOverlay
+}
+
+func (m *Model) FilterHistory() []string {
+	return m.filterHistory
+}
+
+func (m *Model) Metric() Metric {
+	return m.metric
+}
+
+func (m *Model) QuitPromptActive() bool {
+	return m.quitPromptActive
+}
+
+func (m *Model) WeekdayFilter() int {
+	return m.weekdayFilter
+}
+
+func (m *Model) CompactLayout() bool {
+	return m.compactLayout
+}
+
+func (m *Model) SelectActive() bool {
+	return m.selectActive
+}
+
+func (m *Model) SelectionRange() (time.Time, time.Time) {
+	return m.selectionRange()
+}
+
 func (m *Model) ViewState() ViewState {
 	return m.viewState
 }
@@ -1243,10 +3199,128 @@ func (m *Model) DetailBuckets() []DetailBucket {
 	return m.detailBuckets
 }
 
+// CdRequested reports whether the user quit with o to cd into a context
+func (m *Model) CdRequested() bool {
+	return m.cdRequested
+}
+
+// CdTarget returns the directory to cd into when CdRequested is true
+func (m *Model) CdTarget() string {
+	return m.cdTarget
+}
+
+func (m *Model) DetailWorkingDirs() []string {
+	return m.detailWorkingDirs
+}
+
 func (m *Model) DetailCommands() []models.Command {
 	return m.detailCommands
 }
 
+func (m *Model) ExpandTarget() *models.Command {
+	return m.expandTarget
+}
+
+func (m *Model) ExpandScroll() int {
+	return m.expandScroll
+}
+
+func (m *Model) WeekLabelStyle() WeekLabelStyle {
+	return m.weekLabels
+}
+
+func (m *Model) RepeatsCollapsed() bool {
+	return m.collapseRepeats
+}
+
+func (m *Model) LatestUniqueShown() bool {
+	return m.detailLatestUnique
+}
+
+func (m *Model) MainSessionPid() int64 {
+	return m.detailMainSessionPid
+}
+
+func (m *Model) DetailIDOrder() bool {
+	return m.detailIDOrder
+}
+
+func (m *Model) DetailNewestFirst() bool {
+	return m.detailNewestFirst
+}
+
+func (m *Model) HourExpanded(ts int64) bool {
+	return m.expandedHours[hourStart(ts)]
+}
+
+func (m *Model) BranchSwitchShown() bool {
+	return !m.hideBranchSwitch
+}
+
+func (m *Model) UTCTimes() bool {
+	return m.utcTimes
+}
+
+func (m *Model) IDsShown() bool {
+	return m.showIDs
+}
+
+func (m *Model) CommitDividersShown() bool {
+	return m.commitDividers
+}
+
+func (m *Model) PreviewText(ctx ContextItem) string {
+	return m.previewText(ctx)
+}
+
+func (m *Model) SlowestShown() bool {
+	return m.showSlowest
+}
+
+func (m *Model) Slowest() []models.Command {
+	return m.slowest
+}
+
+func (m *Model) DirFilter() string {
+	return m.dirFilter
+}
+
+func (m *Model) PipelineHeadsShown() bool {
+	return m.pipelineHeads
+}
+
+func (m *Model) CompareFocus() int {
+	return m.compareFocus
+}
+
+func (m *Model) CompareCommands(pane int) []models.Command {
+	return m.comparePanes[pane].commands
+}
+
+func (m *Model) CompareCmdIdx(pane int) int {
+	return m.comparePanes[pane].cmdIdx
+}
+
+func (m *Model) TimelineShown() bool {
+	return m.showTimeline
+}
+
+func (m *Model) FlatView() bool {
+	return m.flatView
+}
+
+func (m *Model) MinDuration() time.Duration {
+	return m.minDuration
+}
+
+// DetailRepeatCount returns how many consecutive invocations a detail row stands for
+func (m *Model) DetailRepeatCount(cmd models.Command) int {
+	if run, ok := m.detailRepeats[cmd.ID]; ok {
+		return run.count
+	}
+	return 1
+}
+
 func (m *Model) DetailCmdIdx() int {
 	return m.detailCmdIdx
 }
@@ -1293,6 +3367,10 @@ func (m *Model) CmdDetailAll() []models.Command {
 	return m.cmdDetailAll
 }
 
+func (m *Model) CmdDetailVisible() []models.Command {
+	return m.cmdDetailVisible()
+}
+
 func (m *Model) CmdTextScrollOffset() int {
 	return m.cmdTextScrollOffset
 }
@@ -1305,6 +3383,10 @@ func (m *Model) FilterText() string {
 	return m.filterText
 }
 
+func (m *Model) ExcludeText() string {
+	return m.excludeText
+}
+
 func (m *Model) FilterActive() bool {
 	return m.filterActive
 }
@@ -1321,6 +3403,10 @@ func (m *Model) HelpPreviousView() ViewState {
 	return m.helpPreviousView
 }
 
+func (m *Model) HelpFilter() string {
+	return m.helpFilterText
+}
+
 func (m *Model) StatusMsg() string {
 	return m.statusMsg
 }
@@ -1329,14 +3415,36 @@ func (m *Model) StarredIDs() map[int64]bool {
 	return m.starredIDs
 }
 
-// filterBySubstring returns commands where CommandText contains the filter string
+func (m *Model) DetailNote() string {
+	return m.detailNote()
+}
+
+func (m *Model) NoteActive() bool {
+	return m.noteActive
+}
+
+func (m *Model) HistorySize() int {
+	return m.historySize
+}
+
+func (m *Model) TagActive() bool {
+	return m.tagActive
+}
+
+func (m *Model) CommandDetailTags() []string {
+	return m.cmdDetailTags
+}
+
+// filterBySubstring returns commands where CommandText contains the filter string,
+// or equals it when the filter is quoted (see db.ExactFilter)
 func filterBySubstring(commands []models.Command, filter string) []models.Command {
 	if filter == "" {
 		return commands
 	}
+	text, exact := db.ExactFilter(filter)
 	var result []models.Command
 	for _, cmd := range commands {
-		if strings.Contains(cmd.CommandText, filter) {
+		if exact && cmd.CommandText == text || !exact && strings.Contains(cmd.CommandText, text) {
 			result = append(result, cmd)
 		}
 	}
@@ -1368,8 +3476,147 @@ func filterByMode(commands []models.Command, mode DisplayMode) []models.Command
 	return result
 }
 
-// filteredCommandCount returns the count of commands matching the filter and mode
-func filteredCommandCount(commands []models.Command, mode DisplayMode, filter string) int {
-	filtered := filterBySubstring(commands, filter)
-	return len(filterByMode(filtered, mode))
+// collapseConsecutive folds runs of consecutive identical commands into their
+// most recent occurrence, recording each folded run in repeats.
+// commands must be sorted by timestamp.
+func collapseConsecutive(commands []models.Command, repeats map[int64]repeatRun) []models.Command {
+	var result []models.Command
+	for i := 0; i < len(commands); {
+		j := i + 1
+		for j < len(commands) && commands[j].CommandText == commands[i].CommandText {
+			j++
+		}
+		last := commands[j-1]
+		if j-i > 1 {
+			repeats[last.ID] = repeatRun{count: j - i, firstTimestamp: commands[i].Timestamp}
+		}
+		result = append(result, last)
+		i = j
+	}
+	return result
+}
+
+// latestRuns keeps the latest run of each distinct command text, in the
+// order of commands (oldest first), and records in runCounts how many times
+// each kept command ran
+func latestRuns(commands []models.Command, runCounts map[int64]int) []models.Command {
+	latest := make(map[string]int) // text → index of its latest run
+	counts := make(map[string]int)
+	for i, c := range commands {
+		latest[c.CommandText] = i
+		counts[c.CommandText]++
+	}
+	var result []models.Command
+	for i, c := range commands {
+		if latest[c.CommandText] != i {
+			continue
+		}
+		if n := counts[c.CommandText]; n > 1 {
+			runCounts[c.ID] = n
+		}
+		result = append(result, c)
+	}
+	return result
+}
+
+// busiestSession returns the source pid that ran the most of commands, the
+// earliest to reach the count on a tie, or 0 when none has a pid
+func busiestSession(commands []models.Command) int64 {
+	counts := make(map[int64]int)
+	var busiest int64
+	for _, c := range commands {
+		if c.SourcePid == nil {
+			continue
+		}
+		pid := *c.SourcePid
+		counts[pid]++
+		if counts[pid] > counts[busiest] {
+			busiest = pid
+		}
+	}
+	return busiest
+}
+
+// sessionCommands keeps the commands run by the session with pid
+func sessionCommands(commands []models.Command, pid int64) []models.Command {
+	var out []models.Command
+	for _, c := range commands {
+		if c.SourcePid != nil && *c.SourcePid == pid {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// filteredCommandCount returns the count of commands matching the filter,
+// exclude and mode
+func filteredCommandCount(commands []models.Command, mode DisplayMode, filter, exclude string) int {
+	return len(visibleCommands(commands, mode, filter, exclude))
+}
+
+// visibleCommands returns the commands matching the filter, exclude and mode
+func visibleCommands(commands []models.Command, mode DisplayMode, filter, exclude string) []models.Command {
+	return filterByMode(filterCommands(commands, filter, exclude), mode)
+}
+
+// filterByDir returns the commands run in dir
+func filterByDir(commands []models.Command, dir string) []models.Command {
+	var result []models.Command
+	for _, cmd := range commands {
+		if cmd.WorkingDir == dir {
+			result = append(result, cmd)
+		}
+	}
+	return result
+}
+
+// filterCommands applies the substring filter, then drops excluded commands
+func filterCommands(commands []models.Command, filter, exclude string) []models.Command {
+	return filterExcluded(filterBySubstring(commands, filter), exclude)
+}
+
+// filterExcluded returns the commands that match none of the exclude globs
+func filterExcluded(commands []models.Command, exclude string) []models.Command {
+	patterns := db.ExcludePatterns(exclude)
+	if len(patterns) == 0 {
+		return commands
+	}
+	matchers := make([]*regexp.Regexp, len(patterns))
+	for i, p := range patterns {
+		matchers[i] = globRegexp(p)
+	}
+
+	var result []models.Command
+	for _, cmd := range commands {
+		excluded := false
+		for _, re := range matchers {
+			if re.MatchString(cmd.CommandText) {
+				excluded = true
+				break
+			}
+		}
+		if !excluded {
+			result = append(result, cmd)
+		}
+	}
+	return result
+}
+
+// globRegexp compiles a whole-text glob where * matches any run of characters
+// (including newlines) and ? matches one character
+func globRegexp(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString(`(?s)^`)
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
 }
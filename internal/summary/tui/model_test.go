@@ -14,6 +14,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/chris/shy/internal/db"
+	"github.com/chris/shy/internal/summary"
 	"github.com/chris/shy/pkg/models"
 )
 
@@ -196,6 +197,105 @@ func TestSelectionStopsAtBoundaries(t *testing.T) {
 	assert.Equal(t, 0, model2.SelectedIdx())
 }
 
+// TestWrapNavigation tests that with WithWrapNavigation j/k wrap around the
+// summary list and the detail list, scrolling to the wrapped-to command
+func TestWrapNavigation(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+
+	commands := []models.Command{
+		makeCommand(yesterday, 9, "/home/user/projects/shy", strPtr("github.com/chris/shy"), strPtr("main")),
+		makeCommand(yesterday, 10, "/home/user/projects/shy", strPtr("github.com/chris/shy"), strPtr("bugfix")),
+		makeCommand(yesterday, 11, "/home/user/downloads", nil, nil),
+	}
+	for i := 0; i < 30; i++ {
+		commands = append(commands, makeCommandWithText(yesterday, 9, i, fmt.Sprintf("echo %d", i), "/home/user/projects/shy", strPtr("github.com/chris/shy"), strPtr("main")))
+	}
+
+	dbPath := setupTestDB(t, commands)
+	model := New(dbPath, WithNow(fixedTime(today)), WithWrapNavigation())
+	model.Update(model.Init()())
+	t.Cleanup(func() { model.Close() })
+	model.Update(tea.WindowSizeMsg{Width: 80, Height: 12})
+
+	// Summary: k at the top wraps to the bottom, j at the bottom back to the top
+	last := len(model.Contexts()) - 1
+	model.Update(tea.KeyPressMsg{Code: 'k', Text: "k"})
+	assert.Equal(t, last, model.SelectedIdx())
+	model.Update(tea.KeyPressMsg{Code: 'j', Text: "j"})
+	assert.Equal(t, 0, model.SelectedIdx())
+
+	// Detail: wrapping scrolls the viewport to the wrapped-to command
+	pressEnter(model)
+	require.Equal(t, ContextDetailView, model.ViewState())
+	lastCmd := len(model.DetailCommands()) - 1
+	model.Update(tea.KeyPressMsg{Code: 'k', Text: "k"})
+	assert.Equal(t, lastCmd, model.DetailCmdIdx())
+	assert.Greater(t, model.DetailScrollOffset(), 0)
+	model.Update(tea.KeyPressMsg{Code: 'j', Text: "j"})
+	assert.Equal(t, 0, model.DetailCmdIdx())
+	assert.Equal(t, 0, model.DetailScrollOffset())
+}
+
+// TestConfirmQuit tests that with WithConfirmQuit, q asks before quitting
+// while a filter is active or in command detail, and n cancels
+func TestConfirmQuit(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+
+	dbPath := setupTestDB(t, phase3Commands(yesterday))
+	model := New(dbPath, WithNow(fixedTime(today)), WithConfirmQuit())
+	model.Update(model.Init()())
+	t.Cleanup(func() { model.Close() })
+	model.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+
+	isQuit := func(cmd tea.Cmd) bool {
+		if cmd == nil {
+			return false
+		}
+		_, ok := cmd().(tea.QuitMsg)
+		return ok
+	}
+	q := tea.KeyPressMsg{Code: 'q', Text: "q"}
+
+	// Nothing to lose: q quits straight away
+	_, cmd := model.handleKey(q)
+	assert.True(t, isQuit(cmd))
+
+	// With a filter active q prompts; n cancels and keeps the filter
+	pressSlash(model)
+	typeString(model, "git")
+	pressEnter(model)
+	_, cmd = model.handleKey(q)
+	assert.False(t, isQuit(cmd))
+	assert.True(t, model.QuitPromptActive())
+	assert.Contains(t, ansi.Strip(model.renderView()), "Quit? y/n")
+	pressKey(model, 'n')
+	assert.False(t, model.QuitPromptActive())
+	assert.Equal(t, "git", model.FilterText())
+	assert.Equal(t, SummaryView, model.ViewState())
+
+	// y confirms
+	model.handleKey(q)
+	_, cmd = model.handleKey(tea.KeyPressMsg{Code: 'y', Text: "y"})
+	assert.True(t, isQuit(cmd))
+
+	// In command detail the prompt captures keys: n returns to the same row
+	pressEsc(model)
+	require.Equal(t, "", model.FilterText())
+	cmds := phase4aCommands(yesterday)
+	enterCommandDetailDirect(model, &cmds[1], cmds[:1], cmds[2:])
+	model.handleKey(q)
+	require.True(t, model.QuitPromptActive())
+	pressKey(model, 'n')
+	assert.Equal(t, CommandDetailView, model.ViewState())
+	assert.Equal(t, 1, model.cmdDetailIdx)
+
+	// ctrl+c always quits
+	_, cmd = model.handleKey(tea.KeyPressMsg{Code: 'c', Mod: tea.ModCtrl})
+	assert.True(t, isQuit(cmd))
+}
+
 // TestNavigateToPreviousDay tests the scenario:
 // "Navigate to previous day"
 func TestNavigateToPreviousDay(t *testing.T) {
@@ -286,6 +386,84 @@ func TestCannotNavigatePastToday(t *testing.T) {
 	assert.Equal(t, today.Format("2006-01-02"), model.CurrentDate().Format("2006-01-02"))
 }
 
+// TestJumpSameWeekday tests < and > moving to the same weekday a week away
+func TestJumpSameWeekday(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local) // Thursday
+	yesterday := today.AddDate(0, 0, -1)
+	lastWeek := yesterday.AddDate(0, 0, -7)
+
+	commands := []models.Command{
+		makeCommand(yesterday, 9, "/home/user/projects/shy", strPtr("github.com/chris/shy"), strPtr("main")),
+		makeCommand(lastWeek, 9, "/home/user/projects/shy", strPtr("github.com/chris/shy"), strPtr("main")),
+		makeCommand(lastWeek, 10, "/home/user/projects/other", nil, nil),
+	}
+
+	dbPath := setupTestDB(t, commands)
+	model := initModel(t, dbPath, today)
+
+	pressKey(model, '<')
+	assert.Equal(t, "2026-01-28", model.CurrentDate().Format("2006-01-02"))
+	assert.Equal(t, time.Wednesday, model.CurrentDate().Weekday())
+	assert.Len(t, model.Contexts(), 2)
+
+	// Selection resets on the date change
+	pressKey(model, 'j')
+	assert.Equal(t, 1, model.SelectedIdx())
+	pressKey(model, '>')
+	assert.Equal(t, "2026-02-04", model.CurrentDate().Format("2006-01-02"))
+	assert.Equal(t, 0, model.SelectedIdx())
+
+	// Forward jumps are clamped to today, and stop there
+	pressKey(model, '>')
+	assert.Equal(t, today.Format("2006-01-02"), model.CurrentDate().Format("2006-01-02"))
+	pressKey(model, '>')
+	assert.Equal(t, today.Format("2006-01-02"), model.CurrentDate().Format("2006-01-02"))
+}
+
+// TestSummaryShiftHLJumpWeek tests H/L as same-weekday jumps in the summary view
+func TestSummaryShiftHLJumpWeek(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+
+	commands := []models.Command{
+		makeCommand(yesterday, 9, "/home/user/projects/shy", strPtr("github.com/chris/shy"), strPtr("main")),
+	}
+
+	dbPath := setupTestDB(t, commands)
+	model := initModel(t, dbPath, today)
+
+	pressShiftKey(model, 'H')
+	assert.Equal(t, "2026-01-28", model.CurrentDate().Format("2006-01-02"))
+	assert.Equal(t, SummaryView, model.ViewState())
+
+	pressShiftKey(model, 'L')
+	assert.Equal(t, "2026-02-04", model.CurrentDate().Format("2006-01-02"))
+}
+
+// TestJumpToWeekStart tests w moving to the Monday of the displayed week
+func TestJumpToWeekStart(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+
+	commands := []models.Command{
+		makeCommand(yesterday, 9, "/home/user/projects/shy", strPtr("github.com/chris/shy"), strPtr("main")),
+	}
+
+	dbPath := setupTestDB(t, commands)
+	model := initModel(t, dbPath, today)
+
+	pressKey(model, 'w')
+	assert.Equal(t, "2026-02-02", model.CurrentDate().Format("2006-01-02"))
+	assert.Equal(t, time.Monday, model.CurrentDate().Weekday())
+	assert.Equal(t, DayPeriod, model.Period())
+
+	// Week jumps only apply to the day period
+	pressKey(model, ']')
+	pressKey(model, '<')
+	assert.Equal(t, WeekPeriod, model.Period())
+	assert.Equal(t, "2026-02-02", model.CurrentDate().Format("2006-01-02"))
+}
+
 // TestJumpToToday tests the scenario:
 // "Jump to today"
 func TestJumpToToday(t *testing.T) {
@@ -561,6 +739,72 @@ func TestLongContextNameTruncated(t *testing.T) {
 	assert.NotContains(t, view, longDir+":main")
 }
 
+// TestLongContextNameTruncatedLeft tests that WithTruncateSide("left") cuts
+// the head of a long context name, so the project name and branch survive
+func TestLongContextNameTruncatedLeft(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+
+	longDir := "/home/user/projects/very/deeply/nested/directory/structure/my-project"
+	commands := []models.Command{
+		makeCommand(yesterday, 9, longDir, strPtr("github.com/user/repo"), strPtr("main")),
+	}
+
+	dbPath := setupTestDB(t, commands)
+	model := New(dbPath, WithNow(fixedTime(today)), WithTruncateSide("left"))
+	model.Update(model.Init()())
+	t.Cleanup(func() { model.Close() })
+	model.Update(tea.WindowSizeMsg{Width: 60, Height: 24})
+
+	view := ansi.Strip(model.renderView())
+	assert.Contains(t, view, "…")
+	assert.Contains(t, view, "structure/my-project:main")
+	assert.NotContains(t, view, "/home/user/projects/very")
+
+	// The cut name still fits the row; short names are left alone
+	assert.Equal(t, "…project:main", model.truncatePath(longDir+":main", 13))
+	assert.Equal(t, "~/shy:main", model.truncatePath("~/shy:main", 13))
+}
+
+// TestHistorySizeFooter tests that WithHistorySize counts the history once
+// on Init and shows it in the footer with thousands separators
+func TestHistorySizeFooter(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+
+	commands := []models.Command{
+		makeCommand(yesterday, 9, "/home/user/projects/shy", nil, nil),
+		makeCommand(yesterday.AddDate(0, 0, -30), 9, "/home/user/projects/shy", nil, nil),
+	}
+	dbPath := setupTestDB(t, commands)
+
+	model := New(dbPath, WithNow(fixedTime(today)), WithHistorySize())
+	t.Cleanup(func() { model.Close() })
+	model.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	assert.Equal(t, -1, model.HistorySize())
+	for _, cmd := range model.Init()().(tea.BatchMsg) {
+		model.Update(cmd())
+	}
+	assert.Equal(t, 2, model.HistorySize())
+	assert.Contains(t, ansi.Strip(model.renderView()), "2 cmds")
+
+	// Later changes are not re-counted on render
+	pressKey(model, 'l')
+	assert.Equal(t, 2, model.HistorySize())
+
+	// Off by default
+	plain := initModel(t, dbPath, today)
+	plain.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	assert.NotContains(t, ansi.Strip(plain.renderView()), "cmds")
+
+	assert.Equal(t, "0", formatThousands(0))
+	assert.Equal(t, "999", formatThousands(999))
+	assert.Equal(t, "1,000", formatThousands(1000))
+	assert.Equal(t, "482,113", formatThousands(482113))
+	assert.Equal(t, "12,345,678", formatThousands(12345678))
+	assert.Equal(t, "-1,500", formatThousands(-1500))
+}
+
 // TestCommandCountsRightAligned tests the scenario:
 // "Command counts are right-aligned"
 func TestCommandCountsRightAligned(t *testing.T) {
@@ -606,6 +850,36 @@ func TestCommandCountsRightAligned(t *testing.T) {
 	}
 }
 
+// TestCountColumnHugsShortNames tests that on a wide terminal the count column
+// is placed after the longest context name rather than at the terminal edge
+func TestCountColumnHugsShortNames(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+
+	var commands []models.Command
+	for range 12 {
+		commands = append(commands, makeCommand(yesterday, 9, "/srv/api", nil, nil))
+	}
+	commands = append(commands, makeCommand(yesterday, 10, "/srv/web", strPtr("github.com/chris/web"), strPtr("main")))
+
+	dbPath := setupTestDB(t, commands)
+	model := initModel(t, dbPath, today)
+	model.Update(tea.WindowSizeMsg{Width: 200, Height: 24})
+
+	var contextLines []string
+	for _, line := range strings.Split(model.renderView(), "\n") {
+		plain := strings.TrimRight(ansi.Strip(line), " ")
+		if strings.HasSuffix(plain, "commands") || strings.HasSuffix(plain, "command") {
+			contextLines = append(contextLines, plain)
+		}
+	}
+	require.Len(t, contextLines, 2)
+
+	// margin(2) + prefix(2) + "/srv/web:main"(13) + gap(2) + "12 commands"(11)
+	assert.Equal(t, "  ▶ /srv/api       12 commands", contextLines[0])
+	assert.Equal(t, "    /srv/web:main   1 command", contextLines[1])
+}
+
 // TestHeaderIncludesDayOfWeek tests the scenario:
 // "Header includes day of the week"
 func TestHeaderIncludesDayOfWeek(t *testing.T) {
@@ -956,6 +1230,58 @@ func TestDetailSelectionStopsAtLast(t *testing.T) {
 	assert.Equal(t, lastIdx, model.DetailCmdIdx())
 }
 
+// TestDetailPagesLargeContext tests that a context with more commands than a
+// page loads only the first page on entry and more as the cursor nears the
+// end, with bucket headers spanning the page boundaries
+func TestDetailPagesLargeContext(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+
+	total := 2*detailPageSize + 200
+	commands := make([]*models.Command, total)
+	for i := range commands {
+		c := makeCommandWithText(yesterday, i/60, i%60, fmt.Sprintf("echo %d", i), "/home/user/big", nil, nil)
+		commands[i] = &c
+	}
+	dbPath := setupTestDB(t, nil)
+	database, err := db.NewForTesting(dbPath)
+	require.NoError(t, err)
+	_, err = database.InsertCommands(commands)
+	require.NoError(t, err)
+	require.NoError(t, database.Close())
+
+	model := initModel(t, dbPath, today)
+	model.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	pressEnter(model)
+	require.Equal(t, ContextDetailView, model.ViewState())
+	assert.Len(t, model.DetailCommands(), detailPageSize, "only the first page is loaded")
+	assert.Len(t, model.DetailBuckets(), detailPageSize/60+1)
+
+	// Moving within the first page loads nothing more
+	for range detailPageSize - 24 - 1 {
+		pressKey(model, 'j')
+	}
+	assert.Len(t, model.DetailCommands(), detailPageSize)
+
+	// Nearing the end of the page loads the next one; the boundary bucket
+	// (8 AM here) is merged rather than split
+	pressKey(model, 'j')
+	assert.Len(t, model.DetailCommands(), 2*detailPageSize)
+	assert.Len(t, model.DetailBuckets(), 2*detailPageSize/60+1)
+
+	// Walk to the very end: every command loads once, in order
+	for range total {
+		pressKey(model, 'j')
+	}
+	require.Len(t, model.DetailCommands(), total)
+	for i, c := range model.DetailCommands() {
+		require.Equal(t, fmt.Sprintf("echo %d", i), c.CommandText)
+	}
+	assert.Equal(t, total-1, model.DetailCmdIdx())
+	assert.Len(t, model.DetailBuckets(), (total+59)/60)
+	assert.Contains(t, ansi.Strip(model.renderView()), fmt.Sprintf("echo %d", total-1))
+}
+
 // TestDetailArrowKeys tests arrow keys in detail view
 func TestDetailArrowKeys(t *testing.T) {
 	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
@@ -1143,6 +1469,56 @@ func TestDetailContextSwitchFollowsOrder(t *testing.T) {
 	assert.Equal(t, 2, model.SelectedIdx())
 }
 
+// TestDetailContextSwitchFollowsNameOrder tests that with contexts sorted
+// by name (A), H/L step through them alphabetically and stop at the ends
+func TestDetailContextSwitchFollowsNameOrder(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+
+	dbPath := setupTestDB(t, phase2Commands(yesterday))
+	model := initModel(t, dbPath, today)
+	toggleOrder := func() {
+		// The status flash is checked before its clearing tick runs
+		model.handleKey(tea.KeyPressMsg{Code: 'A', Text: "A"})
+	}
+	detailName := func() string {
+		return formatContextName(model.detailContextKey, model.detailContextBranch)
+	}
+
+	// By count shy:main leads; by name downloads comes first
+	require.Equal(t, summary.BranchKey("main"), model.Contexts()[0].Branch)
+	toggleOrder()
+	assert.Equal(t, "Sorted by name", model.StatusMsg())
+	assert.Equal(t, "/home/user/downloads", model.Contexts()[0].Key.WorkingDir)
+	assert.Equal(t, 2, model.SelectedIdx(), "the selected context stays selected")
+
+	model.selectedIdx = 0
+	pressEnter(model)
+	assert.Equal(t, formatDir("/home/user/downloads"), detailName())
+
+	pressShiftKey(model, 'L')
+	assert.Equal(t, formatDir("/home/user/projects/shy")+":bugfix", detailName())
+	pressShiftKey(model, 'L')
+	assert.Equal(t, formatDir("/home/user/projects/shy")+":main", detailName())
+	pressShiftKey(model, 'L')
+	assert.Equal(t, formatDir("/home/user/projects/shy")+":main", detailName(), "clamps at the last context")
+	assert.Equal(t, 2, model.SelectedIdx())
+
+	pressShiftKey(model, 'H')
+	pressShiftKey(model, 'H')
+	pressShiftKey(model, 'H')
+	assert.Equal(t, formatDir("/home/user/downloads"), detailName(), "clamps at the first context")
+	assert.Equal(t, 0, model.SelectedIdx())
+
+	// Toggling back in the detail view restores the count order
+	toggleOrder()
+	assert.Equal(t, "Sorted by count", model.StatusMsg())
+	assert.Equal(t, summary.BranchKey("main"), model.Contexts()[0].Branch)
+	assert.Equal(t, 2, model.SelectedIdx(), "the open context stays selected")
+	pressShiftKey(model, 'H')
+	assert.Equal(t, formatDir("/home/user/projects/shy")+":bugfix", detailName())
+}
+
 // TestDetailNavigatePrevDay tests navigating to previous day stays in detail
 func TestDetailNavigatePrevDay(t *testing.T) {
 	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
@@ -1625,6 +2001,49 @@ func TestChangingModeResetsSelection(t *testing.T) {
 	assert.Equal(t, 0, model.DetailCmdIdx())
 }
 
+// TestTabCyclesDisplayMode tests tab and shift+tab cycling through every
+// display mode, resetting the detail selection and keeping the mode across
+// navigation
+func TestTabCyclesDisplayMode(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+
+	dbPath := setupTestDB(t, phase3Commands(yesterday))
+	model := initModel(t, dbPath, today)
+	tab := tea.KeyPressMsg{Code: tea.KeyTab}
+	shiftTab := tea.KeyPressMsg{Code: tea.KeyTab, Mod: tea.ModShift}
+
+	// Forward through every mode and back to the first
+	for i := range displayModes {
+		assert.Equal(t, displayModes[i], model.DisplayMode())
+		model.Update(tab)
+	}
+	assert.Equal(t, AllMode, model.DisplayMode(), "tab wraps around")
+
+	model.Update(shiftTab)
+	assert.Equal(t, displayModes[len(displayModes)-1], model.DisplayMode(), "shift+tab wraps backward")
+	assert.Contains(t, model.renderView(), "Uniq")
+	model.Update(shiftTab)
+	assert.Equal(t, AllMode, model.DisplayMode())
+	assert.Contains(t, model.renderView(), "All")
+
+	pressEnter(model)
+	for range 4 {
+		pressKey(model, 'j')
+	}
+	require.Equal(t, 4, model.DetailCmdIdx())
+	pressKeyChain(model, tab)
+	assert.Equal(t, UniqueMode, model.DisplayMode())
+	assert.Equal(t, 0, model.DetailCmdIdx(), "cycling resets the selection")
+
+	pressKey(model, 'h')
+	pressKey(model, 'l')
+	assert.Equal(t, UniqueMode, model.DisplayMode(), "the mode persists across navigation")
+
+	pressKey(model, 'a')
+	assert.Equal(t, AllMode, model.DisplayMode(), "the legacy keys still work")
+}
+
 // TestStatusBarShowsModeIndicator tests footer bar shows active display mode
 func TestStatusBarShowsModeIndicator(t *testing.T) {
 	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
@@ -1794,67 +2213,242 @@ func TestCmdDetailShowsMetadata(t *testing.T) {
 	assert.Contains(t, view, "main")
 }
 
-// TestCmdDetailExitStatusSuccess tests success indicator
-func TestCmdDetailExitStatusSuccess(t *testing.T) {
+// TestCmdDetailShowsRelativeTimestamp tests the relative time after the
+// timestamp, computed against the model's now
+func TestCmdDetailShowsRelativeTimestamp(t *testing.T) {
 	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
-	yesterday := today.AddDate(0, 0, -1)
+	threeDaysAgo := today.AddDate(0, 0, -3)
 
-	cmds := phase4aCommands(yesterday)
-	target := cmds[0] // exit status 0
+	cmds := phase4aCommands(threeDaysAgo)
+	target := cmds[0] // 8:15 three days before today's noon
 	target.ID = 1
 
 	dbPath := setupTestDB(t, cmds)
 	model := initModel(t, dbPath, today)
 
-	enterCommandDetailDirect(model, &target, nil, nil)
+	enterCommandDetailDirect(model, &target, nil, cmds[1:3])
 
-	view := model.renderView()
-	assert.Contains(t, view, "0 \u2713")
+	view := ansi.Strip(model.renderView())
+	assert.Contains(t, view, "2026-02-02 08:15 (3 days ago)")
+
+	// A command under a minute old reads "just now"
+	model.now = fixedTime(time.Unix(target.Timestamp, 0).Add(40 * time.Second))
+	assert.Contains(t, ansi.Strip(model.renderView()), "2026-02-02 08:15 (just now)")
 }
 
-// TestCmdDetailExitStatusFailure tests failure indicator
-func TestCmdDetailExitStatusFailure(t *testing.T) {
+// TestCmdDetailShowsEnv tests that captured environment variables are listed
+// in command detail, sorted, and that commands without any show no Env field
+func TestCmdDetailShowsEnv(t *testing.T) {
 	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
 	yesterday := today.AddDate(0, 0, -1)
 
 	cmds := phase4aCommands(yesterday)
-	target := cmds[2] // "go test ./... -v" with exit status 1
-	target.ID = 3
-
+	cmds[1].Env = map[string]string{"VIRTUAL_ENV": "/home/user/.venv", "AWS_PROFILE": "staging"}
 	dbPath := setupTestDB(t, cmds)
 	model := initModel(t, dbPath, today)
+	model.Update(tea.WindowSizeMsg{Width: 80, Height: 40})
 
-	enterCommandDetailDirect(model, &target, nil, nil)
+	model.Update(model.loadCommandContext(2)())
+	require.Equal(t, CommandDetailView, model.ViewState())
+	view := ansi.Strip(model.renderView())
+	assert.Regexp(t, `Env:\s+AWS_PROFILE=staging\n\s+VIRTUAL_ENV=/home/user/\.venv\n`, view)
+	assert.Equal(t, 40, strings.Count(model.renderView(), "\n")+1, "the env lines are counted in the padding")
 
-	view := model.renderView()
-	assert.Contains(t, view, "1 \u2717")
+	model.Update(model.loadCommandContext(1)())
+	assert.NotContains(t, ansi.Strip(model.renderView()), "Env:")
 }
 
-// TestCmdDetailDurationHumanReadable tests human-readable duration
-func TestCmdDetailDurationHumanReadable(t *testing.T) {
+// TestFormatRelativeTime tests singular and plural relative time labels
+func TestFormatRelativeTime(t *testing.T) {
+	assert.Equal(t, "just now", formatRelativeTime(59*time.Second))
+	assert.Equal(t, "1 minute ago", formatRelativeTime(time.Minute))
+	assert.Equal(t, "5 hours ago", formatRelativeTime(5*time.Hour+30*time.Minute))
+	assert.Equal(t, "1 day ago", formatRelativeTime(36*time.Hour))
+}
+
+// TestCmdDetailShowsSessionGaps tests gap dividers between same-session
+// context commands more than sessionGapThreshold apart
+func TestCmdDetailShowsSessionGaps(t *testing.T) {
 	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
 	yesterday := today.AddDate(0, 0, -1)
 
-	cmds := phase4aCommands(yesterday)
-	target := cmds[2] // duration 8200ms = 8s
-	target.ID = 3
-
-	dbPath := setupTestDB(t, cmds)
+	dbPath := setupTestDB(t, phase4aCommands(yesterday))
 	model := initModel(t, dbPath, today)
+	model.Update(tea.WindowSizeMsg{Width: 80, Height: 40})
 
-	enterCommandDetailDirect(model, &target, nil, nil)
+	pressEnter(model)
+	require.Equal(t, ContextDetailView, model.ViewState())
+	var target int64
+	for _, cmd := range model.DetailCommands() {
+		if cmd.CommandText == "git push" {
+			target = cmd.ID
+		}
+	}
+	require.NotZero(t, target)
+	model.Update(model.loadCommandContext(target)())
+	require.Equal(t, CommandDetailView, model.ViewState())
 
-	view := model.renderView()
-	assert.Contains(t, view, "Duration:")
-	assert.Contains(t, view, "8s")
+	view := ansi.Strip(model.renderView())
+	assert.Contains(t, view, "— 30m gap —")    // 8:30 → 9:00
+	assert.Contains(t, view, "— 25m gap —")    // 9:05 → 9:30
+	assert.Contains(t, view, "— 4h 50m gap —") // 9:30 → 14:20
+	assert.NotContains(t, view, "— 7m gap —")  // 8:15 → 8:22 is under the threshold
+	assert.Less(t, strings.Index(view, "git push"), strings.Index(view, "— 4h 50m gap —"))
 }
 
-// TestCmdDetailMissingDuration tests missing duration shows em dash
-func TestCmdDetailMissingDuration(t *testing.T) {
+// TestCmdDetailShowsBranchSwitches tests dividers where consecutive session
+// commands change git branch, and that b toggles them
+func TestCmdDetailShowsBranchSwitches(t *testing.T) {
 	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
 	yesterday := today.AddDate(0, 0, -1)
+	dir := "/home/user/projects/shy"
+	repo := strPtr("github.com/chris/shy")
+	pid := int64Ptr(10001)
 
-	target := makeCommandWithText(yesterday, 9, 0, "test cmd", "/home/user/projects/shy", strPtr("github.com/chris/shy"), strPtr("main"))
+	before := []models.Command{
+		makeCommandFull(yesterday, 9, 0, "git status", dir, repo, strPtr("main"), 0, nil, pid),
+		makeCommandFull(yesterday, 9, 1, "git switch bugfix", dir, repo, strPtr("main"), 0, nil, pid),
+	}
+	target := makeCommandFull(yesterday, 9, 2, "go test ./...", dir, repo, strPtr("bugfix"), 0, nil, pid)
+	after := []models.Command{
+		makeCommandFull(yesterday, 9, 3, "make", dir, repo, strPtr("bugfix"), 0, nil, pid),
+		makeCommandFull(yesterday, 9, 4, "cd ~", "/home/user", nil, nil, 0, nil, pid),
+	}
+
+	model := New("", WithNow(func() time.Time { return today }))
+	model.Update(tea.WindowSizeMsg{Width: 80, Height: 40})
+	enterCommandDetailDirect(model, &target, before, after)
+
+	view := ansi.Strip(model.renderView())
+	assert.True(t, model.BranchSwitchShown())
+	assert.Equal(t, 1, strings.Count(view, "— switched to bugfix —"))
+	assert.Contains(t, view, "— left bugfix —")
+	assert.NotContains(t, view, "switched to main")
+	assert.Less(t, strings.Index(view, "git switch bugfix"), strings.Index(view, "— switched to bugfix —"))
+	assert.Less(t, strings.Index(view, "— switched to bugfix —"), strings.LastIndex(view, "go test ./..."))
+
+	// Dividers are not rows: the selection still points at the target
+	assert.Len(t, model.cmdDetailAll, 5)
+	assert.Equal(t, 2, model.cmdDetailIdx)
+	assert.Equal(t, 2, model.cmdDetailDividerLines())
+
+	pressKey(model, 'b')
+	assert.False(t, model.BranchSwitchShown())
+	view = ansi.Strip(model.renderView())
+	assert.NotContains(t, view, "switched to")
+	assert.NotContains(t, view, "— left")
+	assert.Zero(t, model.cmdDetailDividerLines())
+}
+
+// TestSelectionDaysAcrossDST tests that the selection span counts calendar
+// days when a DST change shortens one of them to 23 hours
+func TestSelectionDaysAcrossDST(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no tzdata for America/New_York")
+	}
+	local := time.Local
+	time.Local = newYork
+	t.Cleanup(func() { time.Local = local })
+
+	model := New("", WithNow(fixedTime(time.Date(2026, 3, 10, 12, 0, 0, 0, time.Local))))
+	model.selectAnchor = time.Date(2026, 3, 7, 0, 0, 0, 0, time.Local)
+	model.currentDate = time.Date(2026, 3, 9, 0, 0, 0, 0, time.Local) // clocks went forward on Mar 8
+	assert.Equal(t, "Mar 7 – Mar 9 (3d)", model.selectionDisplayString())
+}
+
+// TestCmdDetailUTCToggle tests that U switches the command detail timestamp
+// between local time and UTC, flagging UTC in the footer
+func TestCmdDetailUTCToggle(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("EST", -5*60*60)
+	t.Cleanup(func() { time.Local = local })
+
+	// 2026-02-04 14:30 UTC is 09:30 EST
+	target := models.Command{ID: 1, CommandText: "make deploy", WorkingDir: "/home/user", Timestamp: 1770215400}
+	model := New("", WithNow(func() time.Time { return time.Unix(1770300000, 0) }))
+	model.Update(tea.WindowSizeMsg{Width: 80, Height: 40})
+	enterCommandDetailDirect(model, &target, nil, nil)
+
+	view := ansi.Strip(model.renderView())
+	assert.False(t, model.UTCTimes())
+	assert.Contains(t, view, "2026-02-04 09:30")
+	assert.NotContains(t, view, "UTC")
+
+	pressKey(model, 'U')
+	assert.True(t, model.UTCTimes())
+	view = ansi.Strip(model.renderView())
+	assert.Contains(t, view, "2026-02-04 14:30 UTC")
+	assert.NotContains(t, view, "09:30")
+	footer := view[strings.LastIndex(view, "\n")+1:]
+	assert.Contains(t, footer, " UTC ")
+
+	pressKey(model, 'U')
+	assert.False(t, model.UTCTimes())
+	assert.Contains(t, ansi.Strip(model.renderView()), "2026-02-04 09:30")
+}
+
+// TestCmdDetailExitStatusSuccess tests success indicator
+func TestCmdDetailExitStatusSuccess(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+
+	cmds := phase4aCommands(yesterday)
+	target := cmds[0] // exit status 0
+	target.ID = 1
+
+	dbPath := setupTestDB(t, cmds)
+	model := initModel(t, dbPath, today)
+
+	enterCommandDetailDirect(model, &target, nil, nil)
+
+	view := model.renderView()
+	assert.Contains(t, view, "0 \u2713")
+}
+
+// TestCmdDetailExitStatusFailure tests failure indicator
+func TestCmdDetailExitStatusFailure(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+
+	cmds := phase4aCommands(yesterday)
+	target := cmds[2] // "go test ./... -v" with exit status 1
+	target.ID = 3
+
+	dbPath := setupTestDB(t, cmds)
+	model := initModel(t, dbPath, today)
+
+	enterCommandDetailDirect(model, &target, nil, nil)
+
+	view := model.renderView()
+	assert.Contains(t, view, "1 \u2717")
+}
+
+// TestCmdDetailDurationHumanReadable tests human-readable duration
+func TestCmdDetailDurationHumanReadable(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+
+	cmds := phase4aCommands(yesterday)
+	target := cmds[2] // duration 8200ms = 8s
+	target.ID = 3
+
+	dbPath := setupTestDB(t, cmds)
+	model := initModel(t, dbPath, today)
+
+	enterCommandDetailDirect(model, &target, nil, nil)
+
+	view := model.renderView()
+	assert.Contains(t, view, "Duration:")
+	assert.Contains(t, view, "8s")
+}
+
+// TestCmdDetailMissingDuration tests missing duration shows em dash
+func TestCmdDetailMissingDuration(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+
+	target := makeCommandWithText(yesterday, 9, 0, "test cmd", "/home/user/projects/shy", strPtr("github.com/chris/shy"), strPtr("main"))
 	target.ID = 1
 	target.Duration = nil
 
@@ -2149,6 +2743,67 @@ func TestCmdDetailContextFillsHeight(t *testing.T) {
 	}
 }
 
+// TestCmdDetailContextScroll tests that ctrl+n/ctrl+p scroll the command
+// detail's session context without changing the target, loading more of the
+// session at the ends, and that z re-centers it
+func TestCmdDetailContextScroll(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+
+	var cmds []models.Command
+	for i := range 30 {
+		cmds = append(cmds, makeCommandFull(yesterday, 9, i, fmt.Sprintf("echo %d", i+1), "/home/user", nil, nil, 0, nil, int64Ptr(4242)))
+	}
+	dbPath := setupTestDB(t, cmds)
+	model := initModel(t, dbPath, today)
+	model.Update(tea.WindowSizeMsg{Width: 80, Height: 20}) // 4 context rows plus the target
+	model.Update(model.showCommandContext(15)())
+	require.Equal(t, CommandDetailView, model.ViewState())
+
+	visibleIDs := func() []int64 {
+		var ids []int64
+		for _, c := range model.CmdDetailVisible() {
+			ids = append(ids, c.ID)
+		}
+		return ids
+	}
+	ctrl := func(r rune) tea.KeyPressMsg { return tea.KeyPressMsg{Code: r, Mod: tea.ModCtrl} }
+	require.Equal(t, []int64{13, 14, 15, 16, 17}, visibleIDs())
+
+	pressKeyChain(model, ctrl('n'))
+	assert.Equal(t, []int64{14, 15, 16, 17, 18}, visibleIDs(), "the end of the loaded rows loads more")
+	assert.Greater(t, len(model.CmdDetailAll()), 5)
+	pressKeyChain(model, ctrl('n'))
+	assert.Equal(t, []int64{15, 16, 17, 18, 19}, visibleIDs())
+	assert.Equal(t, int64(15), model.CmdDetailTarget().ID, "scrolling keeps the target")
+
+	for range 4 {
+		pressKeyChain(model, ctrl('p'))
+	}
+	assert.Equal(t, []int64{11, 12, 13, 14, 15}, visibleIDs())
+	view := ansi.Strip(model.renderView())
+	assert.Contains(t, view, "▶ ")
+	assert.Contains(t, view, "   11  echo 11")
+	assert.NotContains(t, view, "   16  echo 16")
+
+	pressKey(model, 'z')
+	assert.Equal(t, []int64{13, 14, 15, 16, 17}, visibleIDs())
+
+	// j moves the target and re-centers on it
+	pressKeyChain(model, ctrl('n'))
+	pressKey(model, 'j')
+	assert.Equal(t, int64(16), model.CmdDetailTarget().ID)
+	assert.Equal(t, []int64{14, 15, 16, 17, 18}, visibleIDs())
+	assert.Len(t, model.CmdDetailAll(), 5)
+
+	// Scrolling stops at the session's ends
+	model.Update(model.showCommandContext(29)())
+	for range 5 {
+		pressKeyChain(model, ctrl('n'))
+	}
+	assert.Equal(t, []int64{26, 27, 28, 29, 30}, visibleIDs())
+}
+
 // TestCmdDetailContextCountMatchesHeight tests that the actual number of context
 // commands loaded matches what fits the terminal height
 func TestCmdDetailContextCountMatchesHeight(t *testing.T) {
@@ -2830,6 +3485,47 @@ func TestMonthDetailBucketsByWeek(t *testing.T) {
 	assert.Contains(t, view, "Week of Feb 2") // Feb 3-5 are in ISO week 6, Monday is Feb 2
 }
 
+// TestISOWeekLabels tests that ISO week labels replace week start dates in
+// the week header and the month view's buckets, and that W toggles them
+func TestISOWeekLabels(t *testing.T) {
+	today := time.Date(2026, 2, 6, 12, 0, 0, 0, time.Local)
+
+	dbPath := setupTestDB(t, phase4Commands())
+	model := New(dbPath, WithNow(fixedTime(today)), WithWeekLabelStyle(ISOWeekLabels))
+	model.Update(model.Init()())
+	t.Cleanup(func() { model.Close() })
+
+	pressBracketRight(model) // Day → Week
+	view := model.renderView()
+	assert.Contains(t, view, "2026-W06")
+	assert.NotContains(t, view, "Week of Feb 2")
+
+	pressBracketRight(model) // Week → Month
+	pressEnter(model)
+	require.Equal(t, ContextDetailView, model.ViewState())
+	view = model.renderView()
+	assert.Contains(t, view, "W06")
+	assert.NotContains(t, view, "Week of Feb 2")
+
+	pressKey(model, 'W')
+	assert.Equal(t, DateWeekLabels, model.WeekLabelStyle())
+	assert.Contains(t, model.renderView(), "Week of Feb 2")
+
+	pressKey(model, 'W')
+	assert.Equal(t, ISOWeekLabels, model.WeekLabelStyle())
+	assert.Contains(t, model.renderView(), "W06")
+}
+
+// TestISOWeekLabel pins ISO week labels for dates around year boundaries
+func TestISOWeekLabel(t *testing.T) {
+	assert.Equal(t, "2026-W06", isoWeekLabel(time.Date(2026, 2, 6, 12, 0, 0, 0, time.Local)))
+	assert.Equal(t, "2026-W01", isoWeekLabel(time.Date(2026, 1, 1, 12, 0, 0, 0, time.Local)))
+	// Dec 30, 2024 is the Monday of the first week of 2025
+	assert.Equal(t, "2025-W01", isoWeekLabel(time.Date(2024, 12, 30, 12, 0, 0, 0, time.Local)))
+	// Jan 1, 2027 falls in 2026's 53rd week
+	assert.Equal(t, "2026-W53", isoWeekLabel(time.Date(2027, 1, 1, 12, 0, 0, 0, time.Local)))
+}
+
 // TestHeaderWeekFormat tests header format in week view
 func TestHeaderWeekFormat(t *testing.T) {
 	today := time.Date(2026, 2, 6, 12, 0, 0, 0, time.Local)
@@ -3469,6 +4165,52 @@ func TestHelpViewRendersBindings(t *testing.T) {
 	assert.Contains(t, view, "Previous context")
 }
 
+// TestHelpViewSearch tests / in help filtering the listed bindings without
+// touching the command filter
+func TestHelpViewSearch(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+
+	dbPath := setupTestDB(t, []models.Command{
+		makeCommand(yesterday, 9, "/home/user/projects/shy", strPtr("github.com/chris/shy"), strPtr("main")),
+	})
+	model := initModel(t, dbPath, today)
+	model.Update(tea.WindowSizeMsg{Width: 80, Height: 40})
+	model.filterText = "git"
+
+	pressKey(model, '?')
+	pressKey(model, '/')
+	typeString(model, "NAVIGATE")
+	assert.Equal(t, "NAVIGATE", model.HelpFilter())
+	view := model.renderView()
+	assert.Contains(t, view, "Navigate down")
+	assert.Contains(t, view, "Navigate up")
+	assert.NotContains(t, view, "Open context")
+	assert.Contains(t, view, "Search: NAVIGATE")
+
+	pressEnter(model)
+	assert.Equal(t, HelpView, model.ViewState(), "enter keeps the search and stays in help")
+	assert.NotContains(t, model.renderView(), "Open context")
+
+	pressEsc(model)
+	assert.Equal(t, HelpView, model.ViewState(), "the first esc clears the search")
+	assert.Empty(t, model.HelpFilter())
+	assert.Contains(t, model.renderView(), "Open context")
+	assert.Equal(t, "git", model.FilterText(), "the command filter is untouched")
+
+	pressKey(model, '/')
+	typeString(model, "xyz")
+	assert.Contains(t, model.renderView(), "No matching keys")
+	pressKey(model, '?')
+	assert.Equal(t, HelpView, model.ViewState(), "? is typed into the search")
+	pressEnter(model)
+
+	pressKey(model, '?')
+	assert.Equal(t, SummaryView, model.ViewState())
+	assert.Empty(t, model.HelpFilter(), "leaving help drops the search")
+	assert.Equal(t, "git", model.FilterText())
+}
+
 // pressKeyChain simulates a key press and executes all resulting commands in chain
 func pressKeyChain(model *Model, key tea.KeyPressMsg) {
 	_, cmd := model.handleKey(key)
@@ -3500,8 +4242,8 @@ func TestDeleteFromContextDetailView(t *testing.T) {
 	// Press D to delete the first command (chains through deleteResultMsg -> loadContexts -> contextsLoadedMsg)
 	pressKeyChain(model, tea.KeyPressMsg{Code: 'D', Text: "D"})
 
-	// Should show "Deleted #1" status and reload
-	assert.Contains(t, model.StatusMsg(), "Deleted #1")
+	// Should show "Trashed #1" status and reload
+	assert.Contains(t, model.StatusMsg(), "Trashed #1")
 	assert.Equal(t, ContextDetailView, model.ViewState())
 
 	// After reload, the command count should be reduced
@@ -3539,7 +4281,7 @@ func TestDeleteFromContextDetailCursorPosition(t *testing.T) {
 	// Delete the second command
 	pressKeyChain(model, tea.KeyPressMsg{Code: 'D', Text: "D"})
 
-	assert.Contains(t, model.StatusMsg(), "Deleted #2")
+	assert.Contains(t, model.StatusMsg(), "Trashed #2")
 	assert.Equal(t, 2, len(model.DetailCommands()))
 
 	// Cursor should be on the command with ID < 2, which is ID 1 ("echo first") at index 0
@@ -3574,7 +4316,7 @@ func TestDeleteFromContextDetailCursorLastCommand(t *testing.T) {
 	// Delete the third command
 	pressKeyChain(model, tea.KeyPressMsg{Code: 'D', Text: "D"})
 
-	assert.Contains(t, model.StatusMsg(), "Deleted #3")
+	assert.Contains(t, model.StatusMsg(), "Trashed #3")
 	assert.Equal(t, 2, len(model.DetailCommands()))
 
 	// Cursor should be on "echo second" (ID 2), the closest with ID < 3
@@ -3606,7 +4348,7 @@ func TestDeleteFromCommandDetailView(t *testing.T) {
 	pressKeyChain(model, tea.KeyPressMsg{Code: 'D', Text: "D"})
 
 	// Should transition back to ContextDetailView with status message
-	assert.Contains(t, model.StatusMsg(), "Deleted #")
+	assert.Contains(t, model.StatusMsg(), "Trashed #")
 	assert.Equal(t, ContextDetailView, model.ViewState())
 }
 
@@ -3637,6 +4379,30 @@ func TestStarToggleContextDetail(t *testing.T) {
 	assert.True(t, model.StarredIDs()[model.DetailCommands()[0].ID])
 }
 
+// TestReadOnlyStarReportsReadOnly tests that a read-only summary browses
+// normally and reports writes as read-only
+func TestReadOnlyStarReportsReadOnly(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+
+	commands := []models.Command{
+		makeCommandWithText(yesterday, 9, 0, "echo first", "/home/user/projects/shy", strPtr("github.com/chris/shy"), strPtr("main")),
+	}
+
+	dbPath := setupTestDB(t, commands)
+	model := New(dbPath, WithNow(fixedTime(today)), WithReadOnly())
+	model.Update(model.Init()())
+	t.Cleanup(func() { model.Close() })
+	require.Len(t, model.Contexts(), 1)
+
+	pressEnter(model)
+	assert.Equal(t, ContextDetailView, model.ViewState())
+
+	pressKey(model, 'S')
+	assert.Equal(t, "Read-only database", model.StatusMsg())
+	assert.Empty(t, model.StarredIDs())
+}
+
 // TestStarToggleUnstar tests pressing S twice to unstar a command
 func TestStarToggleUnstar(t *testing.T) {
 	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
@@ -3767,3 +4533,2399 @@ func TestFooterHelpHintHiddenDuringStatus(t *testing.T) {
 	plain := ansi.Strip(view)
 	assert.NotContains(t, plain, "? help", "footer should not show help hint while status is displayed")
 }
+
+// TestNullAndEmptyBranchFormOneContext tests that commands with NULL and empty
+// branches in the same directory land in one context for both count and detail
+func TestNullAndEmptyBranchFormOneContext(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+	repo := strPtr("github.com/chris/shy")
+
+	commands := []models.Command{
+		makeCommandWithText(yesterday, 9, 0, "null one", "/home/user/projects/shy", repo, nil),
+		makeCommandWithText(yesterday, 9, 10, "empty one", "/home/user/projects/shy", repo, strPtr("")),
+		makeCommandWithText(yesterday, 9, 20, "null two", "/home/user/projects/shy", repo, nil),
+		makeCommandWithText(yesterday, 9, 30, "empty two", "/home/user/projects/shy", repo, strPtr("")),
+	}
+
+	dbPath := setupTestDB(t, commands)
+	model := initModel(t, dbPath, today)
+
+	require.Len(t, model.Contexts(), 1)
+	assert.Equal(t, 4, model.Contexts()[0].CommandCount)
+
+	pressEnter(model)
+	require.Equal(t, ContextDetailView, model.ViewState())
+	assert.Len(t, model.DetailCommands(), 4)
+}
+
+// TestContextNoteSavedAndRendered tests pressing n in ContextDetailView to attach a note
+func TestContextNoteSavedAndRendered(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+
+	commands := []models.Command{
+		makeCommandWithText(yesterday, 9, 0, "echo first", "/home/user/projects/shy", strPtr("github.com/chris/shy"), strPtr("main")),
+		makeCommandWithText(yesterday, 10, 0, "echo other", "/home/user/src/other", nil, nil),
+	}
+
+	dbPath := setupTestDB(t, commands)
+	model := initModel(t, dbPath, today)
+
+	pressEnter(model)
+	assert.Equal(t, ContextDetailView, model.ViewState())
+
+	// Open the note bar and type a note
+	pressKey(model, 'n')
+	assert.True(t, model.NoteActive())
+	typeString(model, "bump version before release")
+	assert.Contains(t, model.renderView(), "Note: bump version before release")
+
+	// Enter saves and closes the bar
+	pressEnter(model)
+	assert.False(t, model.NoteActive())
+	assert.Equal(t, "Note saved", model.StatusMsg())
+	assert.Equal(t, "bump version before release", model.DetailNote())
+
+	// Note renders above the first bucket
+	view := model.renderView()
+	noteIdx := strings.Index(view, "✎ bump version before release")
+	bucketIdx := strings.Index(view, "9am")
+	require.GreaterOrEqual(t, noteIdx, 0)
+	require.GreaterOrEqual(t, bucketIdx, 0)
+	assert.Less(t, noteIdx, bucketIdx)
+
+	// Other contexts do not show it
+	pressShiftKey(model, 'L')
+	assert.Equal(t, "", model.DetailNote())
+	assert.NotContains(t, model.renderView(), "bump version")
+}
+
+// TestTagContextFromSummary tests pressing # in the summary to tag, then
+// untag, every command of the selected context in the period
+func TestTagContextFromSummary(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+
+	commands := []models.Command{
+		makeCommandWithText(yesterday, 9, 0, "make", "/home/user/projects/shy", strPtr("github.com/chris/shy"), strPtr("main")),
+		makeCommandWithText(yesterday, 9, 5, "make test", "/home/user/projects/shy", strPtr("github.com/chris/shy"), strPtr("main")),
+		makeCommandWithText(yesterday, 9, 10, "git tag v1.0", "/home/user/projects/shy", strPtr("github.com/chris/shy"), strPtr("main")),
+		makeCommandWithText(yesterday, 10, 0, "echo other", "/home/user/src/other", nil, nil),
+		// Same context, another day: outside the period
+		makeCommandWithText(yesterday.AddDate(0, 0, -1), 9, 0, "make", "/home/user/projects/shy", strPtr("github.com/chris/shy"), strPtr("main")),
+	}
+
+	dbPath := setupTestDB(t, commands)
+	model := initModel(t, dbPath, today)
+	require.Equal(t, "/home/user/projects/shy", model.Contexts()[0].Key.WorkingDir)
+
+	pressKey(model, '#')
+	assert.True(t, model.TagActive())
+	typeString(model, "release-prep")
+	assert.Contains(t, model.renderView(), "Tag context: release-prep")
+	pressEnter(model)
+	assert.False(t, model.TagActive())
+	assert.Equal(t, "Tagged 3 as release-prep", model.StatusMsg())
+
+	// The command detail view lists the tag
+	pressEnter(model)
+	pressEnter(model)
+	require.Equal(t, CommandDetailView, model.ViewState())
+	assert.Equal(t, []string{"release-prep"}, model.CommandDetailTags())
+	assert.Contains(t, ansi.Strip(model.renderView()), "release-prep")
+
+	database, err := db.New(dbPath)
+	require.NoError(t, err)
+	defer database.Close()
+	other := model.Contexts()[1].Commands[0]
+	tags, err := database.GetCommandTags(other.ID)
+	require.NoError(t, err)
+	assert.Empty(t, tags)
+
+	// A leading "-" untags; esc cancels without touching anything
+	pressKey(model, '-')
+	pressKey(model, '-')
+	require.Equal(t, SummaryView, model.ViewState())
+	pressKey(model, '#')
+	typeString(model, "-release-prep")
+	pressEnter(model)
+	assert.Equal(t, "Untagged 3 from release-prep", model.StatusMsg())
+
+	pressKey(model, '#')
+	typeString(model, "ignored")
+	pressEsc(model)
+	assert.False(t, model.TagActive())
+	pressEnter(model)
+	pressEnter(model)
+	assert.Empty(t, model.CommandDetailTags())
+}
+
+// TestContextNotePersistsAcrossRuns tests that notes are reloaded from the database
+func TestContextNotePersistsAcrossRuns(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+
+	commands := []models.Command{
+		makeCommandWithText(yesterday, 9, 0, "echo first", "/home/user/projects/shy", strPtr("github.com/chris/shy"), strPtr("main")),
+	}
+
+	dbPath := setupTestDB(t, commands)
+	model := initModel(t, dbPath, today)
+
+	pressEnter(model)
+	pressKey(model, 'n')
+	typeString(model, "remember the changelog")
+	pressEnter(model)
+
+	// A fresh model on the same database sees the note
+	reopened := initModel(t, dbPath, today)
+	pressEnter(reopened)
+	assert.Equal(t, "remember the changelog", reopened.DetailNote())
+	assert.Contains(t, reopened.renderView(), "✎ remember the changelog")
+}
+
+// TestContextNoteEscCancelsAndEmptyClears tests cancelling an edit and clearing a note
+func TestContextNoteEscCancelsAndEmptyClears(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+
+	commands := []models.Command{
+		makeCommandWithText(yesterday, 9, 0, "echo first", "/home/user/projects/shy", strPtr("github.com/chris/shy"), strPtr("main")),
+	}
+
+	dbPath := setupTestDB(t, commands)
+	model := initModel(t, dbPath, today)
+
+	pressEnter(model)
+	pressKey(model, 'n')
+	typeString(model, "keep me")
+	pressEnter(model)
+
+	// Esc discards edits
+	pressKey(model, 'n')
+	assert.Contains(t, model.renderView(), "Note: keep me")
+	typeString(model, " and more")
+	pressEsc(model)
+	assert.False(t, model.NoteActive())
+	assert.Equal(t, "keep me", model.DetailNote())
+	assert.Equal(t, ContextDetailView, model.ViewState())
+
+	// Deleting all text and saving clears the note
+	pressKey(model, 'n')
+	for range len("keep me") {
+		pressBackspace(model)
+	}
+	pressEnter(model)
+	assert.Equal(t, "Note cleared", model.StatusMsg())
+	assert.Equal(t, "", model.DetailNote())
+}
+
+// TestCompactLayoutAutoEnabledOnShortTerminal tests the compact summary layout
+// switching on and off with the terminal height
+func TestCompactLayoutAutoEnabledOnShortTerminal(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+
+	commands := []models.Command{
+		makeCommand(yesterday, 9, "/home/user/projects/shy", strPtr("github.com/chris/shy"), strPtr("main")),
+		makeCommand(yesterday, 10, "/home/user/downloads", nil, nil),
+	}
+
+	dbPath := setupTestDB(t, commands)
+	model := initModel(t, dbPath, today)
+
+	model.Update(tea.WindowSizeMsg{Width: 80, Height: 12})
+	assert.True(t, model.CompactLayout())
+
+	lines := strings.Split(model.renderView(), "\n")
+	require.Len(t, lines, 12)
+	// No blank line between the header and the first context
+	assert.Contains(t, ansi.Strip(lines[1]), "downloads")
+	// Header is shortened: no weekday name, no focus indicator
+	assert.NotContains(t, ansi.Strip(lines[0]), "Wednesday")
+	assert.NotContains(t, ansi.Strip(lines[0]), "●")
+	assert.Contains(t, ansi.Strip(lines[0]), "Feb 4")
+	// Focus, mode and period indicators live in the footer
+	footer := ansi.Strip(lines[len(lines)-1])
+	assert.Contains(t, footer, "●")
+	assert.Contains(t, footer, "All")
+	assert.Contains(t, footer, "Day")
+	assert.NotContains(t, footer, "help")
+
+	model.Update(tea.BlurMsg{})
+	lines = strings.Split(model.renderView(), "\n")
+	assert.Contains(t, ansi.Strip(lines[len(lines)-1]), "○")
+
+	// Growing the terminal restores the full layout
+	model.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	assert.False(t, model.CompactLayout())
+	lines = strings.Split(model.renderView(), "\n")
+	assert.Contains(t, ansi.Strip(lines[0]), "○")
+	assert.Equal(t, "", strings.TrimSpace(ansi.Strip(lines[1])))
+	assert.Contains(t, ansi.Strip(lines[len(lines)-1]), "help")
+}
+
+// TestWithCompactForcesCompactLayout tests that WithCompact survives resizes
+func TestWithCompactForcesCompactLayout(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+
+	commands := []models.Command{
+		makeCommand(yesterday, 9, "/home/user/projects/shy", strPtr("github.com/chris/shy"), strPtr("main")),
+	}
+
+	dbPath := setupTestDB(t, commands)
+	model := New(dbPath, WithNow(fixedTime(today)), WithCompact())
+	model.Update(model.Init()())
+	t.Cleanup(func() { model.Close() })
+
+	model.Update(tea.WindowSizeMsg{Width: 80, Height: 40})
+	assert.True(t, model.CompactLayout())
+
+	lines := strings.Split(model.renderView(), "\n")
+	require.Len(t, lines, 40)
+	assert.Contains(t, ansi.Strip(lines[1]), "projects/shy")
+}
+
+// exportCapture records what the TUI hands to its exporter
+type exportCapture struct {
+	commands   []models.Command
+	start, end time.Time
+	calls      int
+}
+
+func (c *exportCapture) export(commands []models.Command, start, end time.Time) (string, error) {
+	c.commands = commands
+	c.start, c.end = start, end
+	c.calls++
+	return "out.history", nil
+}
+
+// TestMultiDaySelectionExport tests v/h/v exporting the span of selected days
+func TestMultiDaySelectionExport(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+
+	commands := []models.Command{
+		makeCommandWithText(yesterday.AddDate(0, 0, -3), 9, 0, "git outside", "/home/user/projects/shy", nil, nil),
+		makeCommandWithText(yesterday.AddDate(0, 0, -2), 9, 0, "git first", "/home/user/projects/shy", nil, nil),
+		makeCommandWithText(yesterday.AddDate(0, 0, -1), 9, 0, "make build", "/home/user/projects/shy", nil, nil),
+		makeCommandWithText(yesterday, 9, 0, "git last", "/home/user/projects/shy", nil, nil),
+		makeCommandWithText(today, 9, 0, "git today", "/home/user/projects/shy", nil, nil),
+	}
+
+	dbPath := setupTestDB(t, commands)
+	capture := &exportCapture{}
+	model := New(dbPath, WithNow(fixedTime(today)), WithExporter(capture.export))
+	model.Update(model.Init()())
+	t.Cleanup(func() { model.Close() })
+
+	pressKey(model, 'v')
+	assert.True(t, model.SelectActive())
+	pressKey(model, 'h')
+	pressKey(model, 'h')
+
+	start, end := model.SelectionRange()
+	assert.Equal(t, "2026-02-02", start.Format("2006-01-02"))
+	assert.Equal(t, "2026-02-04", end.Format("2006-01-02"))
+	header := ansi.Strip(strings.Split(model.renderView(), "\n")[0])
+	assert.Contains(t, header, "SELECT")
+	assert.Contains(t, header, "Feb 2 – Feb 4 (3d)")
+
+	pressKey(model, 'v')
+	assert.False(t, model.SelectActive())
+	require.Equal(t, 1, capture.calls)
+	assert.Equal(t, "2026-02-02", capture.start.Format("2006-01-02"))
+	assert.Equal(t, "2026-02-04", capture.end.Format("2006-01-02"))
+
+	var texts []string
+	for _, c := range capture.commands {
+		texts = append(texts, c.CommandText)
+	}
+	assert.Equal(t, []string{"git first", "make build", "git last"}, texts)
+	assert.Equal(t, "Exported 3 to out.history", model.StatusMsg())
+}
+
+// TestMultiDaySelectionRespectsFilter tests that the active filter limits the export
+func TestMultiDaySelectionRespectsFilter(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+
+	commands := []models.Command{
+		makeCommandWithText(yesterday.AddDate(0, 0, -1), 9, 0, "git first", "/home/user/projects/shy", nil, nil),
+		makeCommandWithText(yesterday, 9, 0, "make build", "/home/user/projects/shy", nil, nil),
+		makeCommandWithText(yesterday, 10, 0, "git last", "/home/user/projects/shy", nil, nil),
+	}
+
+	dbPath := setupTestDB(t, commands)
+	capture := &exportCapture{}
+	model := New(dbPath, WithNow(fixedTime(today)), WithExporter(capture.export))
+	model.Update(model.Init()())
+	t.Cleanup(func() { model.Close() })
+
+	pressSlash(model)
+	typeString(model, "git")
+	pressEnter(model)
+
+	pressKey(model, 'v')
+	pressKey(model, 'h')
+	pressKey(model, 'v')
+
+	require.Equal(t, 1, capture.calls)
+	require.Len(t, capture.commands, 2)
+	assert.Equal(t, "git first", capture.commands[0].CommandText)
+	assert.Equal(t, "git last", capture.commands[1].CommandText)
+}
+
+// TestMultiDaySelectionEscCancels tests that esc cancels without exporting or clearing the filter
+func TestMultiDaySelectionEscCancels(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+
+	commands := []models.Command{
+		makeCommandWithText(yesterday, 9, 0, "git last", "/home/user/projects/shy", nil, nil),
+	}
+
+	dbPath := setupTestDB(t, commands)
+	capture := &exportCapture{}
+	model := New(dbPath, WithNow(fixedTime(today)), WithExporter(capture.export))
+	model.Update(model.Init()())
+	t.Cleanup(func() { model.Close() })
+
+	pressSlash(model)
+	typeString(model, "git")
+	pressEnter(model)
+
+	pressKey(model, 'v')
+	pressEsc(model)
+	assert.False(t, model.SelectActive())
+	assert.Equal(t, "git", model.FilterText())
+	assert.Equal(t, 0, capture.calls)
+	assert.NotContains(t, model.renderView(), "SELECT")
+}
+
+// initAutoRefreshModel creates an auto-refreshing model and loads its initial contexts
+func initAutoRefreshModel(t *testing.T, dbPath string, today time.Time) *Model {
+	t.Helper()
+	model := New(dbPath, WithNow(fixedTime(today)), WithAutoRefresh(time.Millisecond))
+	model.Init()
+	model.Update(model.loadContexts())
+	t.Cleanup(func() { model.Close() })
+	return model
+}
+
+// TestAutoRefreshPicksUpNewCommandsToday tests that a refresh tick on today's
+// view reloads contexts and keeps the selected context selected
+func TestAutoRefreshPicksUpNewCommandsToday(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	commands := []models.Command{
+		makeCommandWithText(today, 9, 0, "make build", "/home/user/projects/beta", nil, nil),
+		makeCommandWithText(today, 9, 5, "make test", "/home/user/projects/gamma", nil, nil),
+	}
+	dbPath := setupTestDB(t, commands)
+
+	model := initAutoRefreshModel(t, dbPath, today)
+	pressKey(model, 'l') // yesterday -> today
+	require.Len(t, model.Contexts(), 2)
+	pressKey(model, 'j')
+	require.Equal(t, "/home/user/projects/gamma", model.Contexts()[model.SelectedIdx()].Key.WorkingDir)
+
+	// A new command lands in a context that sorts before the selected one
+	database, err := db.NewForTesting(dbPath)
+	require.NoError(t, err)
+	newCmd := makeCommandWithText(today, 11, 0, "ls", "/home/user/projects/alpha", nil, nil)
+	_, err = database.InsertCommand(&newCmd)
+	require.NoError(t, err)
+	database.Close()
+
+	_, cmd := model.Update(refreshTickMsg{})
+	require.NotNil(t, cmd)
+	batch, ok := cmd().(tea.BatchMsg)
+	require.True(t, ok, "refresh on today should reload and reschedule")
+	require.Len(t, batch, 2)
+	model.Update(batch[0]())
+
+	require.Len(t, model.Contexts(), 3)
+	assert.Equal(t, "/home/user/projects/gamma", model.Contexts()[model.SelectedIdx()].Key.WorkingDir)
+}
+
+// TestAutoRefreshSkipsHistoricalPeriods tests that browsing a past day only
+// reschedules the tick without reloading
+func TestAutoRefreshSkipsHistoricalPeriods(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	dbPath := setupTestDB(t, []models.Command{
+		makeCommandWithText(today.AddDate(0, 0, -1), 9, 0, "make build", "/home/user/projects/beta", nil, nil),
+	})
+
+	model := initAutoRefreshModel(t, dbPath, today)
+
+	_, cmd := model.Update(refreshTickMsg{})
+	require.NotNil(t, cmd)
+	assert.IsType(t, refreshTickMsg{}, cmd())
+}
+
+// TestAutoRefreshDiscardsStaleResults tests that a refresh loaded for a
+// period the user has since navigated away from is ignored
+func TestAutoRefreshDiscardsStaleResults(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	dbPath := setupTestDB(t, []models.Command{
+		makeCommandWithText(today, 9, 0, "make build", "/home/user/projects/beta", nil, nil),
+	})
+
+	model := initAutoRefreshModel(t, dbPath, today)
+	pressKey(model, 'l')
+	refresh := model.refreshContexts()
+	pressKey(model, 'h')
+	require.Len(t, model.Contexts(), 0)
+
+	model.Update(refresh())
+	assert.Len(t, model.Contexts(), 0)
+}
+
+// TestCollapseConsecutiveRepeats tests that c folds consecutive identical
+// commands within a bucket into their most recent occurrence
+func TestCollapseConsecutiveRepeats(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+	dir := "/home/user/projects/shy"
+	commands := []models.Command{
+		makeCommandWithText(yesterday, 9, 0, "go build", dir, nil, nil),
+		makeCommandWithText(yesterday, 9, 2, "go build", dir, nil, nil),
+		makeCommandWithText(yesterday, 9, 5, "go build", dir, nil, nil),
+		makeCommandWithText(yesterday, 9, 10, "git status", dir, nil, nil),
+		makeCommandWithText(yesterday, 9, 50, "go build", dir, nil, nil),
+		makeCommandWithText(yesterday, 10, 1, "go build", dir, nil, nil),
+	}
+	dbPath := setupTestDB(t, commands)
+	model := initModel(t, dbPath, today)
+	pressEnter(model)
+	require.Len(t, model.DetailCommands(), 6)
+
+	pressKey(model, 'c')
+	assert.True(t, model.RepeatsCollapsed())
+
+	detail := model.DetailCommands()
+	require.Len(t, detail, 4)
+	assert.Equal(t, "go build", detail[0].CommandText)
+	assert.Equal(t, time.Date(2026, 2, 4, 9, 5, 0, 0, time.Local).Unix(), detail[0].Timestamp)
+	assert.Equal(t, 3, model.DetailRepeatCount(detail[0]))
+	assert.Equal(t, 1, model.DetailRepeatCount(detail[1]))
+	// Runs don't cross hour buckets
+	assert.Equal(t, 1, model.DetailRepeatCount(detail[2]))
+	assert.Equal(t, 1, model.DetailRepeatCount(detail[3]))
+	require.Len(t, model.DetailBuckets(), 2)
+	assert.Len(t, model.DetailBuckets()[0].Commands, 3)
+
+	assert.Contains(t, ansi.Strip(model.renderView()), "go build ×3 :00–:05")
+
+	// Enter opens the most recent occurrence
+	pressEnter(model)
+	require.NotNil(t, model.CmdDetailTarget())
+	assert.Equal(t, detail[0].ID, model.CmdDetailTarget().ID)
+
+	pressKey(model, '-')
+	pressKey(model, 'c')
+	assert.False(t, model.RepeatsCollapsed())
+	assert.Len(t, model.DetailCommands(), 6)
+}
+
+// TestCollapseRepeatsNewestFirst tests that c with r still folds each run
+// into its most recent occurrence, labelled with the run's time range
+func TestCollapseRepeatsNewestFirst(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+	dir := "/home/user/projects/shy"
+	commands := []models.Command{
+		makeCommandWithText(yesterday, 9, 0, "go build", dir, nil, nil),
+		makeCommandWithText(yesterday, 9, 2, "go build", dir, nil, nil),
+		makeCommandWithText(yesterday, 9, 5, "go build", dir, nil, nil),
+		makeCommandWithText(yesterday, 9, 10, "git status", dir, nil, nil),
+	}
+	dbPath := setupTestDB(t, commands)
+	model := initModel(t, dbPath, today)
+	pressEnter(model)
+	pressKey(model, 'c')
+	pressKey(model, 'r')
+
+	detail := model.DetailCommands()
+	require.Len(t, detail, 2)
+	assert.Equal(t, "git status", detail[0].CommandText)
+	assert.Equal(t, "go build", detail[1].CommandText)
+	assert.Equal(t, time.Date(2026, 2, 4, 9, 5, 0, 0, time.Local).Unix(), detail[1].Timestamp)
+	assert.Equal(t, 3, model.DetailRepeatCount(detail[1]))
+	assert.Contains(t, ansi.Strip(model.renderView()), "go build ×3 :00–:05")
+}
+
+// TestCollapseRepeatsComposesWithFilter tests that folding runs after the
+// filter, so commands hidden by the filter don't break a run
+func TestCollapseRepeatsComposesWithFilter(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+	dir := "/home/user/projects/shy"
+	commands := []models.Command{
+		makeCommandWithText(yesterday, 9, 0, "go test ./...", dir, nil, nil),
+		makeCommandWithText(yesterday, 9, 1, "vim main.go", dir, nil, nil),
+		makeCommandWithText(yesterday, 9, 2, "go test ./...", dir, nil, nil),
+	}
+	dbPath := setupTestDB(t, commands)
+	model := initModel(t, dbPath, today)
+	pressEnter(model)
+	pressKey(model, 'c')
+	require.Len(t, model.DetailCommands(), 3)
+
+	pressSlash(model)
+	typeString(model, "test")
+	pressEnter(model)
+
+	require.Len(t, model.DetailCommands(), 1)
+	assert.Equal(t, 2, model.DetailRepeatCount(model.DetailCommands()[0]))
+}
+
+// TestWorktreeGroupingMergesSameBranch tests that worktrees of one branch
+// merge into a single context while other contexts stay separate
+func TestWorktreeGroupingMergesSameBranch(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+	repo := strPtr("github.com/chris/shy")
+	commands := []models.Command{
+		makeCommandWithText(yesterday, 9, 0, "make build", "/home/user/src/shy", repo, strPtr("main")),
+		makeCommandWithText(yesterday, 10, 0, "make test", "/home/user/src/shy-wt", repo, strPtr("main")),
+		makeCommandWithText(yesterday, 11, 0, "git log", "/home/user/src/shy", repo, strPtr("main")),
+		makeCommandWithText(yesterday, 9, 30, "git push", "/home/user/src/shy-wt", repo, strPtr("feature")),
+		makeCommandWithText(yesterday, 9, 45, "ls", "/tmp", nil, nil),
+	}
+	dbPath := setupTestDB(t, commands)
+
+	model := New(dbPath, WithNow(fixedTime(today)), WithWorktreeGrouping())
+	model.Update(model.Init()())
+	t.Cleanup(func() { model.Close() })
+
+	contexts := model.Contexts()
+	require.Len(t, contexts, 3)
+	merged := contexts[0]
+	assert.Equal(t, "/home/user/src/shy", merged.Key.WorkingDir)
+	assert.Equal(t, "main", string(merged.Branch))
+	assert.Equal(t, 3, merged.CommandCount)
+	assert.Equal(t, []string{"/home/user/src/shy", "/home/user/src/shy-wt"}, merged.WorkingDirs)
+	assert.Nil(t, contexts[1].WorkingDirs)
+	assert.Contains(t, ansi.Strip(model.renderView()), "/home/user/src/shy:main +1")
+
+	pressEnter(model)
+	require.Len(t, model.DetailCommands(), 3)
+	assert.Equal(t, "make build", model.DetailCommands()[0].CommandText)
+	assert.Equal(t, "make test", model.DetailCommands()[1].CommandText)
+	assert.Equal(t, []string{"/home/user/src/shy", "/home/user/src/shy-wt"}, model.DetailWorkingDirs())
+	model.width = 120
+	header := ansi.Strip(strings.Split(model.renderView(), "\n")[0])
+	assert.Contains(t, header, "(/home/user/src/shy, /home/user/src/shy-wt)")
+}
+
+// TestWorktreeGroupingFollowsBranchAcrossDays tests that the detail view stays
+// on a merged branch when the worktree directories differ between days
+func TestWorktreeGroupingFollowsBranchAcrossDays(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+	dayBefore := yesterday.AddDate(0, 0, -1)
+	repo := strPtr("github.com/chris/shy")
+	commands := []models.Command{
+		makeCommandWithText(dayBefore, 9, 0, "make build", "/home/user/src/shy-wt", repo, strPtr("main")),
+		makeCommandWithText(dayBefore, 10, 0, "make lint", "/home/user/src/shy-wt2", repo, strPtr("main")),
+		makeCommandWithText(yesterday, 9, 0, "make test", "/home/user/src/shy", repo, strPtr("main")),
+		makeCommandWithText(yesterday, 10, 0, "git log", "/home/user/src/shy-wt", repo, strPtr("main")),
+	}
+	dbPath := setupTestDB(t, commands)
+
+	model := New(dbPath, WithNow(fixedTime(today)), WithWorktreeGrouping())
+	model.Update(model.Init()())
+	t.Cleanup(func() { model.Close() })
+
+	pressEnter(model)
+	require.Len(t, model.DetailCommands(), 2)
+
+	pressKey(model, 'h')
+	require.Equal(t, ContextDetailView, model.ViewState())
+	require.Len(t, model.DetailCommands(), 2)
+	assert.Equal(t, "make build", model.DetailCommands()[0].CommandText)
+	assert.Equal(t, []string{"/home/user/src/shy-wt", "/home/user/src/shy-wt2"}, model.DetailWorkingDirs())
+}
+
+// TestEqualsFiltersToExactCommand tests that = filters to the selected
+// command's exact text and Esc clears it
+func TestEqualsFiltersToExactCommand(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+	dir := "/home/user/projects/shy"
+	commands := []models.Command{
+		makeCommandWithText(yesterday, 9, 0, "go build ./...", dir, nil, nil),
+		makeCommandWithText(yesterday, 9, 5, "go build", dir, nil, nil),
+		makeCommandWithText(yesterday, 9, 10, "git status", dir, nil, nil),
+		makeCommandWithText(yesterday, 10, 0, "go build", dir, nil, nil),
+	}
+	dbPath := setupTestDB(t, commands)
+	model := initModel(t, dbPath, today)
+	pressEnter(model)
+	require.Len(t, model.DetailCommands(), 4)
+
+	pressKey(model, 'j')
+	pressKey(model, '=')
+
+	assert.Equal(t, `"go build"`, model.FilterText())
+	assert.False(t, model.FilterActive())
+	require.Len(t, model.DetailCommands(), 2)
+	assert.Equal(t, "go build", model.DetailCommands()[0].CommandText)
+	assert.Equal(t, "go build", model.DetailCommands()[1].CommandText)
+	assert.Equal(t, 0, model.DetailCmdIdx(), "cursor stays on the selected command")
+
+	pressEsc(model)
+	assert.Equal(t, "", model.FilterText())
+	assert.Len(t, model.DetailCommands(), 4)
+}
+
+// TestCdOnQuit tests that o quits with the context's directory when enabled
+func TestCdOnQuit(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+	commands := []models.Command{
+		makeCommandWithText(yesterday, 9, 0, "make build", "/home/user/src/alpha", nil, nil),
+		makeCommandWithText(yesterday, 10, 0, "ls", "/home/user/src/beta", nil, nil),
+	}
+	dbPath := setupTestDB(t, commands)
+
+	t.Run("disabled by default", func(t *testing.T) {
+		model := initModel(t, dbPath, today)
+		_, cmd := model.handleKey(tea.KeyPressMsg{Code: 'o', Text: "o"})
+		assert.Nil(t, cmd)
+		assert.False(t, model.CdRequested())
+	})
+
+	t.Run("summary uses the selected context", func(t *testing.T) {
+		model := New(dbPath, WithNow(fixedTime(today)), WithCdOnQuit())
+		model.Update(model.Init()())
+		t.Cleanup(func() { model.Close() })
+
+		pressKey(model, 'j')
+		_, cmd := model.handleKey(tea.KeyPressMsg{Code: 'o', Text: "o"})
+		require.NotNil(t, cmd)
+		assert.IsType(t, tea.QuitMsg{}, cmd())
+		assert.True(t, model.CdRequested())
+		assert.Equal(t, "/home/user/src/beta", model.CdTarget())
+	})
+
+	t.Run("detail view uses its context even when empty", func(t *testing.T) {
+		model := New(dbPath, WithNow(fixedTime(today)), WithCdOnQuit())
+		model.Update(model.Init()())
+		t.Cleanup(func() { model.Close() })
+
+		pressEnter(model)
+		pressKey(model, 'h') // no commands on this day
+		require.Empty(t, model.DetailCommands())
+		_, cmd := model.handleKey(tea.KeyPressMsg{Code: 'o', Text: "o"})
+		require.NotNil(t, cmd)
+		assert.Equal(t, "/home/user/src/alpha", model.CdTarget())
+	})
+}
+
+// TestExcludeHidesMatchingCommands tests that ! excludes globs from counts
+// and detail lists, composes with the filter and persists across navigation
+func TestExcludeHidesMatchingCommands(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+	dir := "/home/user/projects/shy"
+	commands := []models.Command{
+		makeCommandWithText(yesterday, 9, 0, "ls -la", dir, nil, nil),
+		makeCommandWithText(yesterday, 9, 1, "cd src", dir, nil, nil),
+		makeCommandWithText(yesterday, 9, 2, "git status", dir, nil, nil),
+		makeCommandWithText(yesterday, 9, 3, "go test ./...", dir, nil, nil),
+		makeCommandWithText(yesterday, 9, 4, "git log", dir, nil, nil),
+		makeCommandWithText(yesterday.AddDate(0, 0, -1), 9, 0, "ls", dir, nil, nil),
+		makeCommandWithText(yesterday.AddDate(0, 0, -1), 9, 1, "make", dir, nil, nil),
+	}
+	dbPath := setupTestDB(t, commands)
+	model := initModel(t, dbPath, today)
+
+	pressKey(model, '!')
+	typeString(model, "ls*|cd *")
+	assert.Contains(t, ansi.Strip(model.renderView()), "Exclude: ls*|cd *█")
+	pressEnter(model)
+	assert.Equal(t, "ls*|cd *", model.ExcludeText())
+	assert.Equal(t, "", model.FilterText())
+	assert.Contains(t, ansi.Strip(model.renderView()), "3 commands")
+	assert.Contains(t, ansi.Strip(model.renderView()), "!ls*|cd *")
+
+	pressEnter(model)
+	require.Len(t, model.DetailCommands(), 3)
+	assert.Equal(t, "git status", model.DetailCommands()[0].CommandText)
+
+	// Composes with an inclusive filter
+	pressSlash(model)
+	typeString(model, "git")
+	pressEnter(model)
+	require.Len(t, model.DetailCommands(), 2)
+
+	// Esc clears the filter first, then the exclude
+	pressEsc(model)
+	assert.Equal(t, "", model.FilterText())
+	assert.Equal(t, "ls*|cd *", model.ExcludeText())
+	require.Len(t, model.DetailCommands(), 3)
+
+	// Persists across navigation
+	pressKey(model, 'h')
+	require.Len(t, model.DetailCommands(), 1)
+	assert.Equal(t, "make", model.DetailCommands()[0].CommandText)
+
+	pressEsc(model)
+	assert.Equal(t, "", model.ExcludeText())
+	assert.Len(t, model.DetailCommands(), 2)
+}
+
+// TestExcludeEscCancelsEdit tests that Esc in the exclude bar restores the previous exclude
+func TestExcludeEscCancelsEdit(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+	dbPath := setupTestDB(t, []models.Command{
+		makeCommandWithText(yesterday, 9, 0, "ls", "/home/user/projects/shy", nil, nil),
+	})
+	model := initModel(t, dbPath, today)
+
+	pressKey(model, '!')
+	typeString(model, "ls")
+	pressEnter(model)
+
+	pressKey(model, '!')
+	typeString(model, "|cd*")
+	pressEsc(model)
+	assert.Equal(t, "ls", model.ExcludeText())
+	assert.False(t, model.FilterActive())
+}
+
+// TestMetricCycle tests that m cycles the right column through count,
+// duration, last used, active time and the total / unique ratio, keeping the
+// column right-aligned
+func TestMetricCycle(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+
+	build := makeCommandWithText(today, 9, 0, "make build", "/home/user/api", nil, nil)
+	build.Duration = int64Ptr(60000)
+	test := makeCommandWithText(today, 9, 5, "make test", "/home/user/api", nil, nil)
+	test.Duration = int64Ptr(30000)
+	ls := makeCommandWithText(today, 11, 0, "ls", "/home/user/projects/website", nil, nil)
+
+	dbPath := setupTestDB(t, []models.Command{build, test, ls})
+	model := initModel(t, dbPath, today)
+	model.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	pressKey(model, 't')
+
+	contextLines := func() []string {
+		var lines []string
+		for _, line := range strings.Split(ansi.Strip(model.renderView()), "\n") {
+			if strings.Contains(line, "api") || strings.Contains(line, "website") {
+				lines = append(lines, strings.TrimRight(line, " "))
+			}
+		}
+		require.Len(t, lines, 2)
+		return lines
+	}
+
+	assert.Equal(t, CountMetric, model.Metric())
+	assert.NotContains(t, ansi.Strip(model.renderView()), "Duration")
+
+	pressKey(model, 'm')
+	assert.Equal(t, DurationMetric, model.Metric())
+	lines := contextLines()
+	assert.True(t, strings.HasSuffix(lines[0], "1m 30s"), "got %q", lines[0])
+	assert.True(t, strings.HasSuffix(lines[1], "0ms"), "got %q", lines[1])
+	assert.Equal(t, ansi.StringWidth(lines[0]), ansi.StringWidth(lines[1]), "metric column should be right-aligned")
+	assert.Contains(t, ansi.Strip(model.renderView()), " Duration ")
+
+	pressKey(model, 'm')
+	assert.Equal(t, LastUsedMetric, model.Metric())
+	lines = contextLines()
+	assert.True(t, strings.HasSuffix(lines[0], "2h ago"), "got %q", lines[0])
+	assert.True(t, strings.HasSuffix(lines[1], "1h ago"), "got %q", lines[1])
+	assert.Contains(t, ansi.Strip(model.renderView()), " Last used ")
+
+	pressKey(model, 'm')
+	assert.Equal(t, ActiveMetric, model.Metric())
+	lines = contextLines()
+	assert.True(t, strings.HasSuffix(lines[0], "active: 5m"), "got %q", lines[0])
+	assert.True(t, strings.HasSuffix(lines[1], "active: 0m"), "got %q", lines[1])
+	assert.Contains(t, ansi.Strip(model.renderView()), " Active ")
+
+	pressKey(model, 'm')
+	assert.Equal(t, RatioMetric, model.Metric())
+	lines = contextLines()
+	assert.True(t, strings.HasSuffix(lines[0], "2 / 2"), "got %q", lines[0])
+	assert.Contains(t, ansi.Strip(model.renderView()), " Total / unique ")
+
+	pressKey(model, 'm')
+	assert.Equal(t, CountMetric, model.Metric())
+	lines = contextLines()
+	assert.True(t, strings.HasSuffix(lines[0], "2 commands"), "got %q", lines[0])
+}
+
+// TestRatioMetric tests the total / unique column counting both modes at once
+// under the filter, with the slashes lined up across rows
+func TestRatioMetric(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	api, web := "/home/user/api", "/home/user/projects/website"
+	var commands []models.Command
+	for i := range 12 {
+		commands = append(commands, makeCommandWithText(today, 9, i, "make test", api, nil, nil))
+	}
+	for i, text := range []string{"make build", "git status", "git push"} {
+		commands = append(commands, makeCommandWithText(today, 10, i, text, api, nil, nil))
+	}
+	commands = append(commands, makeCommandWithText(today, 11, 0, "ls", web, nil, nil))
+
+	dbPath := setupTestDB(t, commands)
+	model := initModel(t, dbPath, today)
+	model.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	pressKey(model, 't')
+	for model.Metric() != RatioMetric {
+		pressKey(model, 'm')
+	}
+
+	lines := func() []string {
+		var out []string
+		for _, line := range strings.Split(ansi.Strip(model.renderView()), "\n") {
+			if strings.Contains(line, "api") || strings.Contains(line, "website") {
+				out = append(out, strings.TrimRight(line, " "))
+			}
+		}
+		require.Len(t, out, 2)
+		return out
+	}
+	rows := lines()
+	assert.True(t, strings.HasSuffix(rows[0], "15 / 3"), "got %q", rows[0])
+	assert.True(t, strings.HasSuffix(rows[1], " 1 / 1"), "got %q", rows[1])
+	slashColumn := func(row string) int { return ansi.StringWidth(row[:strings.LastIndex(row, "/")]) }
+	assert.Equal(t, slashColumn(rows[0]), slashColumn(rows[1]), "the slashes line up")
+
+	pressKey(model, 'u')
+	assert.True(t, strings.HasSuffix(lines()[0], "15 / 3"), "the ratio ignores the mode")
+
+	model.filterText = "git"
+	assert.True(t, strings.HasSuffix(lines()[0], "2 / 2"), "the ratio follows the filter")
+}
+
+// TestActiveTime tests that the active metric sums the gaps between
+// commands in timestamp order, capping long breaks
+func TestActiveTime(t *testing.T) {
+	day := time.Date(2026, 2, 5, 0, 0, 0, 0, time.Local)
+	dir := "/home/user/api"
+	commands := []models.Command{
+		makeCommandWithText(day, 9, 4, "make test", dir, nil, nil),
+		makeCommandWithText(day, 9, 0, "make build", dir, nil, nil),
+		makeCommandWithText(day, 9, 6, "git add .", dir, nil, nil),
+		// An hour-long break counts as activeGapCap
+		makeCommandWithText(day, 10, 6, "git commit", dir, nil, nil),
+	}
+	assert.Equal(t, 4*time.Minute+2*time.Minute+activeGapCap, activeTime(commands))
+	assert.Equal(t, time.Duration(0), activeTime(commands[:1]))
+	assert.Equal(t, time.Duration(0), activeTime(nil))
+}
+
+// TestFormatTimeAgo tests the last-used metric's elapsed time format
+func TestFormatTimeAgo(t *testing.T) {
+	assert.Equal(t, "just now", formatTimeAgo(30*time.Second))
+	assert.Equal(t, "5m ago", formatTimeAgo(5*time.Minute+10*time.Second))
+	assert.Equal(t, "2h ago", formatTimeAgo(2*time.Hour+59*time.Minute))
+	assert.Equal(t, "3d ago", formatTimeAgo(3*24*time.Hour+time.Hour))
+}
+
+// TestWeekdayFilter tests that d restricts a month to one weekday's commands
+// and shows the weekday in the header
+func TestWeekdayFilter(t *testing.T) {
+	today := time.Date(2026, 2, 10, 12, 0, 0, 0, time.Local) // Tuesday
+	monday := time.Date(2026, 2, 2, 0, 0, 0, 0, time.Local)
+
+	commands := []models.Command{
+		makeCommandWithText(monday, 9, 0, "make build", "/home/user/api", nil, nil),
+		makeCommandWithText(monday.AddDate(0, 0, 1), 9, 0, "make test", "/home/user/api", nil, nil),
+		makeCommandWithText(monday.AddDate(0, 0, 7), 23, 59, "git push", "/home/user/api", nil, nil),
+		makeCommandWithText(monday.AddDate(0, 0, 8), 10, 0, "ls", "/home/user/website", nil, nil),
+	}
+	dbPath := setupTestDB(t, commands)
+	model := initModel(t, dbPath, today)
+	model.Update(tea.WindowSizeMsg{Width: 100, Height: 24})
+	pressKey(model, ']')
+	pressKey(model, ']')
+	require.Equal(t, MonthPeriod, model.Period())
+	require.Len(t, model.Contexts(), 2)
+	assert.NotContains(t, ansi.Strip(model.renderView()), "Mondays")
+
+	pressKey(model, 'd')
+	assert.Equal(t, 1, model.WeekdayFilter())
+	require.Len(t, model.Contexts(), 1)
+	assert.Equal(t, 2, model.Contexts()[0].CommandCount)
+	assert.Contains(t, ansi.Strip(model.renderView()), " Mondays ")
+
+	// The debug overlay lists the query's Monday windows
+	model.Update(tea.KeyPressMsg{Code: 'g', Mod: tea.ModCtrl})
+	view := ansi.Strip(model.renderView())
+	assert.Contains(t, view, "Last query: GetCommandsByDateRange × 4 windows → 2 rows")
+	for _, day := range []int{2, 9, 16, 23} {
+		start := time.Date(2026, 2, day, 0, 0, 0, 0, time.Local)
+		assert.Contains(t, view, fmt.Sprintf("%d – %d  %s", start.Unix(), start.AddDate(0, 0, 1).Unix(), start.Format("Mon 2006-01-02")))
+	}
+	model.Update(tea.KeyPressMsg{Code: 'g', Mod: tea.ModCtrl})
+
+	// Composes with the text filter
+	pressSlash(model)
+	typeString(model, "git")
+	pressEnter(model)
+	assert.Equal(t, 1, filteredCommandCount(model.Contexts()[0].Commands, model.displayMode, model.FilterText(), ""))
+
+	pressKey(model, 'd')
+	assert.Contains(t, ansi.Strip(model.renderView()), " Tuesdays ")
+	require.Len(t, model.Contexts(), 2)
+
+	// Wraps through Sunday back to every day
+	for range 6 {
+		pressKey(model, 'd')
+	}
+	assert.Equal(t, 0, model.WeekdayFilter())
+	require.Len(t, model.Contexts(), 2)
+	assert.Equal(t, 4, model.Contexts()[0].CommandCount+model.Contexts()[1].CommandCount)
+}
+
+func TestWeekdayWindows(t *testing.T) {
+	start, end := dateRangeForPeriod(time.Date(2026, 2, 10, 0, 0, 0, 0, time.Local), MonthPeriod)
+	windows := weekdayWindows(start, end, time.Monday)
+	require.Len(t, windows, 4)
+	for i, w := range windows {
+		day := time.Date(2026, 2, 2+7*i, 0, 0, 0, 0, time.Local)
+		assert.Equal(t, [2]int64{day.Unix(), day.AddDate(0, 0, 1).Unix()}, w)
+	}
+
+	start, end = dateRangeForPeriod(time.Date(2026, 2, 10, 0, 0, 0, 0, time.Local), DayPeriod)
+	assert.Len(t, weekdayWindows(start, end, time.Tuesday), 1)
+	assert.Empty(t, weekdayWindows(start, end, time.Monday))
+}
+
+// TestFilterHistoryRecall tests that up/down in the filter bar cycle through
+// applied filters without moving the context selection
+func TestFilterHistoryRecall(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+
+	dbPath := setupTestDB(t, phase3Commands(yesterday))
+	model := initModel(t, dbPath, today)
+
+	applyFilter := func(text string) {
+		pressSlash(model)
+		for range model.FilterText() {
+			pressBackspace(model)
+		}
+		typeString(model, text)
+		pressEnter(model)
+	}
+	applyFilter("git")
+	applyFilter("git")
+	applyFilter("make")
+	assert.Equal(t, []string{"git", "make"}, model.FilterHistory(), "consecutive duplicates are dropped")
+
+	pressSlash(model)
+	typeString(model, "x")
+	assert.Equal(t, "makex", model.FilterText())
+	selected := model.SelectedIdx()
+
+	model.Update(tea.KeyPressMsg{Code: tea.KeyUp})
+	assert.Equal(t, "make", model.FilterText())
+	model.Update(tea.KeyPressMsg{Code: tea.KeyUp})
+	assert.Equal(t, "git", model.FilterText())
+	model.Update(tea.KeyPressMsg{Code: tea.KeyUp})
+	assert.Equal(t, "git", model.FilterText(), "up stops at the oldest entry")
+
+	model.Update(tea.KeyPressMsg{Code: tea.KeyDown})
+	assert.Equal(t, "make", model.FilterText())
+	model.Update(tea.KeyPressMsg{Code: tea.KeyDown})
+	assert.Equal(t, "makex", model.FilterText(), "down past the newest restores the typed text")
+
+	assert.True(t, model.FilterActive())
+	assert.Equal(t, selected, model.SelectedIdx(), "up/down must not navigate the list")
+}
+
+// TestDebugOverlay tests that ctrl+g shows the period range, filter state,
+// counts and last query over the current view, and esc dismisses it
+func TestDebugOverlay(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+
+	dbPath := setupTestDB(t, phase3Commands(yesterday))
+	model := initModel(t, dbPath, today)
+	model.Update(tea.WindowSizeMsg{Width: 100, Height: 30})
+	model.filterText = "git"
+
+	ctrlG := tea.KeyPressMsg{Code: 'g', Mod: tea.ModCtrl}
+	model.Update(ctrlG)
+	require.True(t, model.DebugOverlay())
+
+	start, end := dateRangeForPeriod(yesterday, DayPeriod)
+	view := ansi.Strip(model.renderView())
+	assert.Contains(t, view, "Debug (ctrl+g to close)")
+	assert.Contains(t, view, "Period:     Day 2026-02-04")
+	assert.Contains(t, view, fmt.Sprintf("Range:      %d – %d", start, end))
+	assert.Contains(t, view, `Filter:     "git"`)
+	assert.Contains(t, view, fmt.Sprintf("Contexts:   %d", len(model.Contexts())))
+	assert.Contains(t, view, fmt.Sprintf("Last query: GetCommandsByDateRange(%d, %d) → 10 rows", start, end))
+	assert.Equal(t, 30, len(strings.Split(view, "\n")), "overlay keeps the view height")
+
+	// esc closes the overlay without clearing the filter
+	pressEsc(model)
+	assert.False(t, model.DebugOverlay())
+	assert.Equal(t, "git", model.FilterText())
+
+	model.Update(ctrlG)
+	model.Update(ctrlG)
+	assert.False(t, model.DebugOverlay())
+}
+
+// TestDetailIDOrder tests that i orders the detail view by insertion id, and
+// that commands sharing a timestamp always come out in id order
+func TestDetailIDOrder(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+	dir := "/home/user/projects/shy"
+	commands := []models.Command{
+		makeCommandWithText(yesterday, 9, 0, "make", dir, nil, nil),
+		makeCommandWithText(yesterday, 9, 0, "make test", dir, nil, nil),
+		makeCommandWithText(yesterday, 9, 5, "git commit", dir, nil, nil),
+		// Backfilled after the fact, so its id is newer than its timestamp
+		makeCommandWithText(yesterday, 9, 0, "make install", dir, nil, nil),
+	}
+	dbPath := setupTestDB(t, commands)
+	model := initModel(t, dbPath, today)
+	pressEnter(model)
+
+	texts := func() []string {
+		var out []string
+		for _, c := range model.DetailCommands() {
+			out = append(out, c.CommandText)
+		}
+		return out
+	}
+	assert.False(t, model.DetailIDOrder())
+	assert.Equal(t, []string{"make", "make test", "make install", "git commit"}, texts())
+
+	pressKey(model, 'i')
+	assert.True(t, model.DetailIDOrder())
+	assert.Equal(t, []string{"make", "make test", "git commit", "make install"}, texts())
+
+	pressKey(model, 'i')
+	assert.False(t, model.DetailIDOrder())
+	assert.Equal(t, []string{"make", "make test", "make install", "git commit"}, texts())
+}
+
+// TestDetailNewestFirst tests that r reverses both the bucket order and the
+// commands within each bucket, and that the selection still clamps
+func TestDetailNewestFirst(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+	dir := "/home/user/projects/shy"
+	commands := []models.Command{
+		makeCommandWithText(yesterday, 9, 0, "git pull", dir, nil, nil),
+		makeCommandWithText(yesterday, 9, 30, "make", dir, nil, nil),
+		makeCommandWithText(yesterday, 14, 0, "go test", dir, nil, nil),
+		makeCommandWithText(yesterday, 14, 10, "git push", dir, nil, nil),
+	}
+	dbPath := setupTestDB(t, commands)
+	model := initModel(t, dbPath, today)
+	pressEnter(model)
+
+	texts := func() []string {
+		var out []string
+		for _, c := range model.DetailCommands() {
+			out = append(out, c.CommandText)
+		}
+		return out
+	}
+	labels := func() []string {
+		var out []string
+		for _, b := range model.DetailBuckets() {
+			out = append(out, b.Label)
+		}
+		return out
+	}
+	assert.False(t, model.DetailNewestFirst())
+	assert.Equal(t, []string{"git pull", "make", "go test", "git push"}, texts())
+	oldestFirst := labels()
+
+	pressKey(model, 'j')
+	pressKey(model, 'r')
+	assert.True(t, model.DetailNewestFirst())
+	assert.Equal(t, []string{"git push", "go test", "make", "git pull"}, texts())
+	assert.Equal(t, []string{oldestFirst[1], oldestFirst[0]}, labels())
+	assert.Equal(t, 0, model.DetailCmdIdx(), "selection moves to the newest command")
+
+	view := ansi.Strip(model.renderView())
+	assert.Less(t, strings.Index(view, "git push"), strings.Index(view, "git pull"))
+	assert.Less(t, strings.Index(view, oldestFirst[1]), strings.Index(view, oldestFirst[0]))
+
+	pressKey(model, 'k')
+	assert.Equal(t, 0, model.DetailCmdIdx())
+	for range 6 {
+		pressKey(model, 'j')
+	}
+	assert.Equal(t, 3, model.DetailCmdIdx())
+	assert.Equal(t, "git pull", model.DetailCommands()[model.DetailCmdIdx()].CommandText)
+
+	pressKey(model, 'r')
+	assert.False(t, model.DetailNewestFirst())
+	assert.Equal(t, []string{"git pull", "make", "go test", "git push"}, texts())
+	assert.Equal(t, 0, model.DetailCmdIdx())
+}
+
+// TestDetailMinuteTimeline tests that z expands the selected command's hour
+// into minute buckets under the hour header, keeps the selection on the same
+// command, and that navigation still skips the headers
+func TestDetailMinuteTimeline(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+	dir := "/home/user/projects/shy"
+	commands := []models.Command{
+		makeCommandWithText(yesterday, 9, 0, "git pull", dir, nil, nil),
+		makeCommandWithText(yesterday, 9, 0, "make", dir, nil, nil),
+		makeCommandWithText(yesterday, 9, 5, "go test", dir, nil, nil),
+		makeCommandWithText(yesterday, 14, 0, "git push", dir, nil, nil),
+	}
+	dbPath := setupTestDB(t, commands)
+	model := initModel(t, dbPath, today)
+	model.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	pressEnter(model)
+
+	type bucket struct {
+		label  string
+		minute bool
+		count  int
+	}
+	buckets := func() []bucket {
+		var out []bucket
+		for _, b := range model.DetailBuckets() {
+			out = append(out, bucket{b.Label, b.Minute, len(b.Commands)})
+		}
+		return out
+	}
+	assert.Equal(t, []bucket{{"9am", false, 3}, {"2pm", false, 1}}, buckets())
+
+	pressKey(model, 'j')
+	pressKey(model, 'z')
+	assert.Equal(t, []bucket{
+		{"9am", false, 0},
+		{"9:00am", true, 2},
+		{"9:05am", true, 1},
+		{"2pm", false, 1},
+	}, buckets())
+	assert.Equal(t, 1, model.DetailCmdIdx(), "selection stays on the same command")
+	assert.Equal(t, "make", model.DetailCommands()[model.DetailCmdIdx()].CommandText)
+
+	view := ansi.Strip(model.renderView())
+	assert.Contains(t, view, "    9:05am ─")
+	assert.Less(t, strings.Index(view, "9am"), strings.Index(view, "9:00am"))
+
+	// Headers are not selectable: j walks the commands and clamps at the end
+	pressKey(model, 'j')
+	assert.Equal(t, "go test", model.DetailCommands()[model.DetailCmdIdx()].CommandText)
+	for range 4 {
+		pressKey(model, 'j')
+	}
+	assert.Equal(t, 3, model.DetailCmdIdx())
+	assert.Equal(t, "git push", model.DetailCommands()[model.DetailCmdIdx()].CommandText)
+
+	// Only the selected command's hour expands or collapses
+	pressKey(model, 'z')
+	assert.Len(t, buckets(), 5)
+	pressKey(model, 'z')
+	pressKey(model, 'k')
+	pressKey(model, 'z')
+	assert.Equal(t, []bucket{{"9am", false, 3}, {"2pm", false, 1}}, buckets())
+	assert.Equal(t, 2, model.DetailCmdIdx())
+
+	// The week view's buckets are days, so z does nothing there
+	pressKey(model, ']')
+	require.Equal(t, WeekPeriod, model.Period())
+	require.Equal(t, ContextDetailView, model.ViewState())
+	before := buckets()
+	require.NotEmpty(t, before)
+	pressKey(model, 'z')
+	assert.Equal(t, before, buckets())
+}
+
+// TestDetailMinDuration tests that T cycles the detail view's minimum
+// duration, comparing against durations stored in milliseconds
+func TestDetailMinDuration(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+	dir := "/home/user/projects/shy"
+	withDuration := func(c models.Command, ms int64) models.Command {
+		c.Duration = &ms
+		return c
+	}
+	commands := []models.Command{
+		withDuration(makeCommandWithText(yesterday, 9, 0, "ls", dir, nil, nil), 40),
+		withDuration(makeCommandWithText(yesterday, 9, 1, "go build", dir, nil, nil), 1500),
+		withDuration(makeCommandWithText(yesterday, 9, 2, "go test", dir, nil, nil), 5000),
+		withDuration(makeCommandWithText(yesterday, 9, 3, "make release", dir, nil, nil), 95000),
+		makeCommandWithText(yesterday, 9, 4, "vim", dir, nil, nil),
+	}
+	dbPath := setupTestDB(t, commands)
+	model := initModel(t, dbPath, today)
+	pressEnter(model)
+
+	texts := func() []string {
+		var out []string
+		for _, c := range model.DetailCommands() {
+			out = append(out, c.CommandText)
+		}
+		return out
+	}
+	assert.Equal(t, time.Duration(0), model.MinDuration())
+	assert.Len(t, texts(), 5)
+
+	pressKey(model, 'T')
+	assert.Equal(t, time.Second, model.MinDuration())
+	assert.Equal(t, []string{"go build", "go test", "make release"}, texts())
+	assert.Contains(t, ansi.Strip(model.renderView()), "≥1s")
+
+	pressKey(model, 'T')
+	assert.Equal(t, 5*time.Second, model.MinDuration())
+	assert.Equal(t, []string{"go test", "make release"}, texts())
+
+	pressKey(model, 'T')
+	pressKey(model, 'T')
+	assert.Equal(t, time.Minute, model.MinDuration())
+	assert.Equal(t, []string{"make release"}, texts())
+
+	pressKey(model, 'T')
+	assert.Empty(t, texts())
+
+	pressKey(model, 'T')
+	assert.Equal(t, time.Duration(0), model.MinDuration())
+	assert.Len(t, texts(), 5)
+}
+
+// TestFlatView tests that 0 lists every command of the period in time order,
+// naming each row's context, and that rows open the command detail view
+func TestFlatView(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+	shy := "/home/user/projects/shy"
+	other := "/home/user/src/other"
+	commands := []models.Command{
+		makeCommandWithText(yesterday, 9, 0, "make", shy, strPtr("github.com/chris/shy"), strPtr("main")),
+		makeCommandWithText(yesterday, 9, 5, "ls", other, nil, nil),
+		makeCommandWithText(yesterday, 9, 10, "make test", shy, strPtr("github.com/chris/shy"), strPtr("main")),
+		makeCommandWithText(yesterday, 14, 0, "cat notes", other, nil, nil),
+		makeCommandWithText(yesterday.AddDate(0, 0, -1), 9, 0, "old", other, nil, nil),
+	}
+	dbPath := setupTestDB(t, commands)
+	model := initModel(t, dbPath, today)
+	model.Update(tea.WindowSizeMsg{Width: 100, Height: 30})
+
+	texts := func() []string {
+		var out []string
+		for _, c := range model.DetailCommands() {
+			out = append(out, c.CommandText)
+		}
+		return out
+	}
+
+	pressKey(model, '0')
+	require.Equal(t, ContextDetailView, model.ViewState())
+	assert.True(t, model.FlatView())
+	assert.Equal(t, []string{"make", "ls", "make test", "cat notes"}, texts())
+	assert.Len(t, model.DetailBuckets(), 2, "bucketed by hour")
+
+	view := ansi.Strip(model.renderView())
+	assert.Contains(t, view, "All contexts")
+	assert.Contains(t, view, "/home/user/src/other  ls")
+
+	// Context switching has nothing to switch between
+	pressShiftKey(model, 'L')
+	assert.True(t, model.FlatView())
+	assert.Len(t, model.DetailCommands(), 4)
+
+	// Period navigation stays flat
+	pressKey(model, 'h')
+	assert.True(t, model.FlatView())
+	assert.Equal(t, []string{"old"}, texts())
+	pressKey(model, 'l')
+	assert.Len(t, model.DetailCommands(), 4)
+
+	pressKey(model, 'j')
+	pressEnter(model)
+	require.Equal(t, CommandDetailView, model.ViewState())
+	assert.Equal(t, "ls", model.CmdDetailTarget().CommandText)
+	pressKey(model, '-')
+	assert.Equal(t, ContextDetailView, model.ViewState())
+	assert.True(t, model.FlatView())
+
+	pressKey(model, '0')
+	assert.Equal(t, SummaryView, model.ViewState())
+	assert.False(t, model.FlatView())
+}
+
+// TestDefaultBranchStyle tests that default branches render in their own
+// color and that WithDefaultBranches replaces the list
+func TestDefaultBranchStyle(t *testing.T) {
+	repo := "github.com/chris/shy"
+	key := summary.ContextKey{WorkingDir: "/home/user/projects/shy", GitRepo: repo}
+
+	model := New("")
+	assert.True(t, model.isDefaultBranch("main"))
+	assert.True(t, model.isDefaultBranch("master"))
+	assert.False(t, model.isDefaultBranch("feature/login"))
+
+	mainName := model.renderBarContextName(key, "main")
+	featureName := model.renderBarContextName(key, "feature/login")
+	assert.Contains(t, mainName, barDefaultBranchStyle.Render("main"))
+	assert.Contains(t, featureName, barBranchStyle.Render("feature/login"))
+	assert.NotEqual(t, barDefaultBranchStyle.Render("x"), barBranchStyle.Render("x"))
+	assert.Contains(t, model.styledSummaryContextName(key, "main", false), defaultBranchStyle.Render("main"))
+
+	model = New("", WithDefaultBranches([]string{"trunk"}))
+	assert.True(t, model.isDefaultBranch("trunk"))
+	assert.False(t, model.isDefaultBranch("main"))
+	assert.Contains(t, model.renderBarContextName(key, "main"), barBranchStyle.Render("main"))
+}
+
+// TestDimNoiseCommands tests that WithDimNoise renders successful runs of
+// the noise programs faint in the detail list and leaves the rest alone
+func TestDimNoiseCommands(t *testing.T) {
+	row := func(m *Model, text string, exit int) string {
+		return m.renderDetailCommand(models.Command{CommandText: text, ExitStatus: exit}, false, 0)
+	}
+	require.NotEqual(t, noiseStyle.Render("x"), normalStyle.Render("x"))
+
+	model := New("", WithDimNoise(DefaultNoiseCommands))
+	assert.Contains(t, row(model, "cd /tmp", 0), noiseStyle.Render("cd /tmp"))
+	assert.Contains(t, row(model, "cd .. && ls -la", 0), noiseStyle.Render("cd .. && ls -la"))
+	assert.Contains(t, row(model, "LC_ALL=C ls", 0), noiseStyle.Render("LC_ALL=C ls"))
+	assert.Contains(t, row(model, "make test", 0), normalStyle.Render("make test"))
+	assert.Contains(t, row(model, "cd src && make", 0), normalStyle.Render("cd src && make"), "one real command is enough")
+	assert.Contains(t, row(model, "cd /missing", 1), normalStyle.Render("cd /missing"), "a failure is worth seeing")
+	assert.Contains(t, model.renderDetailCommand(models.Command{CommandText: "pwd"}, true, 0), selectedStyle.Render("pwd"),
+		"the selected row keeps its highlight")
+
+	model = New("")
+	assert.Contains(t, row(model, "cd /tmp", 0), normalStyle.Render("cd /tmp"), "off by default")
+
+	model = New("", WithDimNoise([]string{"git"}))
+	assert.Contains(t, row(model, "git status", 0), noiseStyle.Render("git status"))
+	assert.Contains(t, row(model, "ls", 0), normalStyle.Render("ls"))
+}
+
+// TestHighlightCommand tests the styled spans of highlightCommand and that
+// WithHighlight applies it to unselected detail rows only
+func TestHighlightCommand(t *testing.T) {
+	line := `git commit -m "fix | typo" 2>&1 | tee log && FOO=1 make -j4`
+	out := highlightCommand(line, normalStyle)
+	assert.Equal(t, line, ansi.Strip(out), "highlighting only adds styles")
+	assert.True(t, strings.HasPrefix(out, highlightProgramStyle.Render("git")))
+	assert.Contains(t, out, normalStyle.Render("commit"))
+	assert.Contains(t, out, highlightFlagStyle.Render("-m"))
+	assert.Contains(t, out, highlightStringStyle.Render(`"fix | typo"`), "a quoted | is not a pipe")
+	assert.Contains(t, out, normalStyle.Render("2>&1"), "a redirection is not an operator")
+	assert.Contains(t, out, highlightOperatorStyle.Render("|")+" "+highlightProgramStyle.Render("tee"))
+	assert.Contains(t, out, highlightOperatorStyle.Render("&&"))
+	assert.Contains(t, out, normalStyle.Render("FOO=1")+" "+highlightProgramStyle.Render("make"))
+	assert.Contains(t, out, highlightFlagStyle.Render("-j4"))
+
+	assert.Equal(t, normalStyle.Render("--msg=")+highlightStringStyle.Render(`'a b'`), renderWord(`--msg='a b'`, normalStyle))
+
+	row := models.Command{CommandText: "ls -la"}
+	model := New("")
+	assert.NotContains(t, model.renderDetailCommand(row, false, 0), highlightProgramStyle.Render("ls"), "off by default")
+	model = New("", WithHighlight())
+	assert.Contains(t, model.renderDetailCommand(row, false, 0), highlightProgramStyle.Render("ls"))
+	assert.Contains(t, model.renderDetailCommand(row, true, 0), selectedStyle.Render("ls -la"), "the selected row keeps its color")
+}
+
+// TestExpandMultiLineCommand tests that x shows a detail command's full
+// text in a popup while the list keeps it on one line
+func TestExpandMultiLineCommand(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+	dir := "/home/user/projects/shy"
+	heredoc := "cat <<EOF > notes.txt\nfirst line\nsecond line\nthird line\nEOF"
+	commands := []models.Command{
+		makeCommandWithText(yesterday, 9, 0, heredoc, dir, nil, nil),
+		makeCommandWithText(yesterday, 9, 5, "ls", dir, nil, nil),
+	}
+	dbPath := setupTestDB(t, commands)
+	model := initModel(t, dbPath, today)
+	model.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	pressEnter(model)
+
+	view := ansi.Strip(model.renderView())
+	assert.Contains(t, view, "cat <<EOF > notes.txt")
+	assert.NotContains(t, view, "second line", "the list collapses newlines")
+
+	pressKey(model, 'x')
+	require.NotNil(t, model.ExpandTarget())
+	assert.Equal(t, heredoc, model.ExpandTarget().CommandText)
+	view = ansi.Strip(model.renderView())
+	assert.Contains(t, view, "1  cat <<EOF > notes.txt")
+	assert.Contains(t, view, "3  second line")
+	assert.Contains(t, view, "5  EOF")
+
+	// Keys go to the popup, not the list underneath
+	pressKey(model, 'j')
+	assert.Equal(t, 0, model.ExpandScroll(), "nothing to scroll when it all fits")
+	assert.Equal(t, 0, model.DetailCmdIdx())
+
+	pressEsc(model)
+	assert.Nil(t, model.ExpandTarget())
+	assert.Equal(t, ContextDetailView, model.ViewState())
+	assert.NotContains(t, ansi.Strip(model.renderView()), "second line")
+
+	// A popup taller than the screen scrolls
+	model.Update(tea.WindowSizeMsg{Width: 80, Height: 7})
+	pressKey(model, 'x')
+	view = ansi.Strip(model.renderView())
+	assert.Contains(t, view, "Command 1–3 of 5")
+	assert.NotContains(t, view, "4  third line")
+	pressKey(model, 'j')
+	pressKey(model, 'j')
+	pressKey(model, 'j')
+	assert.Equal(t, 2, model.ExpandScroll())
+	view = ansi.Strip(model.renderView())
+	assert.Contains(t, view, "5  EOF")
+	assert.NotContains(t, view, "1  cat")
+	pressKey(model, 'x')
+	assert.Nil(t, model.ExpandTarget())
+}
+
+// TestSummaryActivityTimeline tests T toggling the hourly activity line
+// under the summary header
+func TestSummaryActivityTimeline(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+
+	dbPath := setupTestDB(t, phase2Commands(yesterday))
+	model := initModel(t, dbPath, today)
+	// One column per hour of the day
+	model.Update(tea.WindowSizeMsg{Width: 24 + 2*marginX, Height: 20})
+	model.selectedIdx = 1
+
+	withoutTimeline := strings.Split(ansi.Strip(model.renderView()), "\n")
+	assert.False(t, model.TimelineShown())
+
+	pressKey(model, 'T')
+	require.True(t, model.TimelineShown())
+	assert.Equal(t, 1, model.selectedIdx, "toggling keeps the selection")
+
+	lines := strings.Split(ansi.Strip(model.renderView()), "\n")
+	assert.Len(t, lines, len(withoutTimeline), "the timeline takes a padding line")
+	assert.Contains(t, lines[0], "YESTERDAY Wednesday Feb 4")
+	// 8am has 6 commands, 9am 3, 10am and 2pm 2 each
+	assert.Equal(t, strings.Repeat(" ", marginX)+"────────█▄▃───▃─────────", lines[1])
+	assert.Equal(t, "", lines[2])
+
+	pressKey(model, 'T')
+	assert.False(t, model.TimelineShown())
+	assert.Equal(t, withoutTimeline, strings.Split(ansi.Strip(model.renderView()), "\n"))
+}
+
+// pressFailureJump presses F and applies the search result. When a failure
+// is found it also applies the reload, skipping the status-clearing tick.
+func pressFailureJump(t *testing.T, model *Model, found bool) {
+	t.Helper()
+	_, cmd := model.handleKey(tea.KeyPressMsg{Code: 'F', Text: "F"})
+	require.NotNil(t, cmd)
+	_, cmd = model.Update(cmd())
+	require.NotNil(t, cmd)
+	if found {
+		batch, ok := cmd().(tea.BatchMsg)
+		require.True(t, ok, "a found failure should reload and clear the status")
+		model.Update(batch[0]())
+	}
+}
+
+// TestFailureJumpAcrossDays tests F walking back to the previous days with a
+// failed command in the selected context
+func TestFailureJumpAcrossDays(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	shy, main := strPtr("github.com/chris/shy"), strPtr("main")
+	failed := func(c models.Command) models.Command {
+		c.ExitStatus = 1
+		return c
+	}
+	dbPath := setupTestDB(t, []models.Command{
+		makeCommandWithText(today.AddDate(0, 0, -7), 9, 0, "make test", "/home/user/projects/shy", shy, main),
+		failed(makeCommandWithText(today.AddDate(0, 0, -6), 9, 0, "make test", "/home/user/projects/shy", shy, main)),
+		failed(makeCommandWithText(today.AddDate(0, 0, -3), 10, 0, "make test", "/home/user/projects/shy", shy, main)),
+		failed(makeCommandWithText(today.AddDate(0, 0, -2), 9, 0, "curl -O example.com", "/home/user/downloads", nil, nil)),
+		makeCommandWithText(today.AddDate(0, 0, -1), 9, 0, "curl -O example.com", "/home/user/downloads", nil, nil),
+		makeCommandWithText(today.AddDate(0, 0, -1), 9, 0, "make test", "/home/user/projects/shy", shy, main),
+	})
+	model := initModel(t, dbPath, today)
+	require.Len(t, model.Contexts(), 2)
+	model.selectedIdx = 1
+	require.Equal(t, "/home/user/projects/shy", model.Contexts()[1].Key.WorkingDir)
+
+	// The downloads failure two days ago is another context's
+	pressFailureJump(t, model, true)
+	assert.Equal(t, today.AddDate(0, 0, -3).Format("2006-01-02"), model.CurrentDate().Format("2006-01-02"))
+	assert.Equal(t, "Failure on Mon Feb 2", model.statusMsg)
+	require.NotEmpty(t, model.Contexts())
+	assert.Equal(t, "/home/user/projects/shy", model.Contexts()[model.SelectedIdx()].Key.WorkingDir)
+
+	// From the detail view the jump re-enters the detail view on the new day
+	pressEnter(model)
+	require.Equal(t, ContextDetailView, model.ViewState())
+	pressFailureJump(t, model, true)
+	assert.Equal(t, today.AddDate(0, 0, -6).Format("2006-01-02"), model.CurrentDate().Format("2006-01-02"))
+	assert.Equal(t, ContextDetailView, model.ViewState())
+	require.Len(t, model.DetailCommands(), 1)
+	assert.Equal(t, 1, model.DetailCommands()[0].ExitStatus)
+
+	pressFailureJump(t, model, false)
+	assert.Equal(t, today.AddDate(0, 0, -6).Format("2006-01-02"), model.CurrentDate().Format("2006-01-02"))
+	assert.Equal(t, "No failures in the last 90 days", model.statusMsg)
+}
+
+// TestKeyMapOverride tests remapping navigation to Emacs-style keys
+func TestKeyMapOverride(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+
+	dbPath := setupTestDB(t, phase2Commands(yesterday))
+	model := New(dbPath, WithNow(fixedTime(today)), WithKeyMap(KeyMap{
+		Up:   []string{"ctrl+p"},
+		Down: []string{"ctrl+n"},
+		Help: []string{"H"},
+	}))
+	model.Update(model.Init()())
+	t.Cleanup(func() { model.Close() })
+	require.Len(t, model.Contexts(), 3)
+
+	model.handleKey(tea.KeyPressMsg{Code: 'n', Mod: tea.ModCtrl})
+	assert.Equal(t, 1, model.SelectedIdx())
+	model.handleKey(tea.KeyPressMsg{Code: 'n', Mod: tea.ModCtrl})
+	assert.Equal(t, 2, model.SelectedIdx())
+	model.handleKey(tea.KeyPressMsg{Code: 'p', Mod: tea.ModCtrl})
+	assert.Equal(t, 1, model.SelectedIdx())
+
+	// The replaced defaults do nothing; the arrow keys were defaults too
+	pressKey(model, 'j')
+	pressKey(model, 'k')
+	model.handleKey(tea.KeyPressMsg{Code: tea.KeyDown})
+	assert.Equal(t, 1, model.SelectedIdx())
+
+	// Actions left unset keep their keys
+	pressKey(model, 'u')
+	assert.Equal(t, UniqueMode, model.DisplayMode())
+
+	// H opens help in place of ?, which lists the active bindings
+	pressKey(model, 'H')
+	require.Equal(t, HelpView, model.ViewState())
+	view := ansi.Strip(model.renderView())
+	assert.Contains(t, view, "ctrl+n  Navigate down")
+	assert.Contains(t, view, "ctrl+p  Navigate up")
+	assert.Contains(t, view, "u       Unique mode")
+
+	pressKey(model, 'H')
+	assert.Equal(t, SummaryView, model.ViewState())
+}
+
+// TestDetailShowIDs tests N adding a right-aligned event id column to the
+// detail list
+func TestDetailShowIDs(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+
+	// Fill ids 1-8 on another day so the shown context has ids 9 and 10
+	var commands []models.Command
+	for i := range 8 {
+		commands = append(commands, makeCommandWithText(today.AddDate(0, 0, -3), 8, i, "ls", "/tmp", nil, nil))
+	}
+	commands = append(commands,
+		makeCommandWithText(yesterday, 9, 0, "echo nine", "/home/user/projects/shy", strPtr("github.com/chris/shy"), strPtr("main")),
+		makeCommandWithText(yesterday, 9, 5, "echo ten", "/home/user/projects/shy", strPtr("github.com/chris/shy"), strPtr("main")),
+	)
+	dbPath := setupTestDB(t, commands)
+	model := initModel(t, dbPath, today)
+	model.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	require.Equal(t, "/home/user/projects/shy", model.Contexts()[0].Key.WorkingDir)
+	pressEnter(model)
+	require.Equal(t, ContextDetailView, model.ViewState())
+
+	rowOf := func(text string) string {
+		for _, line := range strings.Split(ansi.Strip(model.renderView()), "\n") {
+			if strings.Contains(line, text) {
+				return line
+			}
+		}
+		t.Fatalf("no row for %q", text)
+		return ""
+	}
+	// Display column of the command text; the cursor marker is multibyte
+	textColumn := func(line string) int {
+		return ansi.StringWidth(line[:strings.Index(line, "echo")])
+	}
+	withoutIDs := rowOf("echo ten")
+	assert.False(t, model.IDsShown())
+
+	pressKey(model, 'N')
+	assert.True(t, model.IDsShown())
+	nine, ten := rowOf("echo nine"), rowOf("echo ten")
+	assert.Contains(t, nine, "   9  ")
+	assert.Contains(t, ten, "  10  ")
+	assert.Equal(t, textColumn(nine), textColumn(ten), "ids are right-aligned")
+	assert.Equal(t, textColumn(withoutIDs)+2, textColumn(ten), "the column is as wide as the widest id")
+
+	pressKey(model, 'N')
+	assert.Equal(t, withoutIDs, rowOf("echo ten"))
+}
+
+// TestGitCommitMessage tests recognising git commit in command text
+func TestGitCommitMessage(t *testing.T) {
+	tests := []struct {
+		text    string
+		ok      bool
+		message string
+	}{
+		{`git commit -m "fix parser"`, true, "fix parser"},
+		{`git commit`, true, ""},
+		{`git -C ~/src/shy commit -am 'wip'`, true, ""},
+		{`git commit -m"tidy"`, true, "tidy"},
+		{`git commit --message=release`, true, "release"},
+		{`go test ./... && git commit -m "a; b"`, true, "a; b"},
+		{`/usr/bin/git commit --amend`, true, ""},
+		{`git status`, false, ""},
+		{`echo git commit`, false, ""},
+		{`git log --grep commit`, false, ""},
+	}
+	for _, tt := range tests {
+		message, ok := gitCommitMessage(tt.text)
+		assert.Equal(t, tt.ok, ok, tt.text)
+		assert.Equal(t, tt.message, message, tt.text)
+	}
+}
+
+// TestDetailCommitDividers tests g dividing the detail list after each
+// successful git commit, labelled with the commands leading up to it
+func TestDetailCommitDividers(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+	dir, repo, branch := "/home/user/projects/shy", strPtr("github.com/chris/shy"), strPtr("main")
+
+	failed := makeCommandWithText(yesterday, 9, 20, `git commit -m "broken"`, dir, repo, branch)
+	failed.ExitStatus = 1
+	dbPath := setupTestDB(t, []models.Command{
+		makeCommandWithText(yesterday, 9, 0, "vim parser.go", dir, repo, branch),
+		makeCommandWithText(yesterday, 9, 10, "go test ./...", dir, repo, branch),
+		makeCommandWithText(yesterday, 9, 15, `git commit -m "fix parser"`, dir, repo, branch),
+		failed,
+		makeCommandWithText(yesterday, 10, 0, "git commit --amend", dir, repo, branch),
+		makeCommandWithText(yesterday, 10, 30, "make release", dir, repo, branch),
+	})
+	model := initModel(t, dbPath, today)
+	model.Update(tea.WindowSizeMsg{Width: 80, Height: 30})
+	pressEnter(model)
+	require.Equal(t, ContextDetailView, model.ViewState())
+
+	lines := func() []string {
+		var out []string
+		for _, line := range strings.Split(ansi.Strip(model.renderView()), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				out = append(out, line)
+			}
+		}
+		return out
+	}
+	before := lines()
+	assert.NotContains(t, strings.Join(before, "\n"), "commit 1 ·")
+
+	pressKey(model, 'g')
+	assert.True(t, model.CommitDividersShown())
+	after := lines()
+	assert.Len(t, after, len(before)+2, "one divider per successful commit")
+
+	indexOf := func(lines []string, prefix string) int {
+		for i, line := range lines {
+			if strings.Contains(line, prefix) {
+				return i
+			}
+		}
+		t.Fatalf("no line containing %q", prefix)
+		return -1
+	}
+	first := indexOf(after, "commit 1 · fix parser · 3 commands")
+	assert.Equal(t, indexOf(after, `fix parser"`)+1, first, "divider follows its commit")
+	second := indexOf(after, "commit 2 · 2 commands")
+	assert.Equal(t, indexOf(after, "--amend")+1, second)
+	assert.Greater(t, indexOf(after, "make release"), second)
+
+	// Newest first puts each divider above its commit instead
+	pressKey(model, 'r')
+	newest := lines()
+	assert.Equal(t, indexOf(newest, `fix parser"`)-1, indexOf(newest, "commit 1 · fix parser"))
+
+	pressKey(model, 'g')
+	assert.Len(t, lines(), len(before))
+}
+
+// TestDetailMainSession tests that s keeps only the commands of the
+// context's busiest session, noted in the footer, and toggles back
+func TestDetailMainSession(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+	dir := "/home/user/projects/shy"
+	short, main := int64Ptr(111), int64Ptr(222)
+	dbPath := setupTestDB(t, []models.Command{
+		makeCommandFull(yesterday, 9, 0, "vim notes", dir, nil, nil, 0, nil, short),
+		makeCommandFull(yesterday, 9, 10, "make", dir, nil, nil, 0, nil, main),
+		makeCommandFull(yesterday, 9, 20, "make test", dir, nil, nil, 0, nil, main),
+		makeCommandFull(yesterday, 10, 0, "ls", dir, nil, nil, 0, nil, short),
+		makeCommandFull(yesterday, 10, 5, "git push", dir, nil, nil, 0, nil, main),
+		makeCommandFull(yesterday, 11, 0, "uptime", dir, nil, nil, 0, nil, nil),
+	})
+	model := initModel(t, dbPath, today)
+	model.Update(tea.WindowSizeMsg{Width: 100, Height: 30})
+	pressEnter(model)
+	require.Len(t, model.DetailCommands(), 6)
+
+	pressKey(model, 's')
+	assert.Equal(t, int64(222), model.MainSessionPid())
+	var texts []string
+	for _, c := range model.DetailCommands() {
+		texts = append(texts, c.CommandText)
+	}
+	assert.Equal(t, []string{"make", "make test", "git push"}, texts)
+	assert.Contains(t, ansi.Strip(model.renderFooterBar()), "session 222 only")
+
+	pressKey(model, 's')
+	assert.Equal(t, int64(0), model.MainSessionPid())
+	assert.Len(t, model.DetailCommands(), 6)
+	assert.NotContains(t, ansi.Strip(model.renderFooterBar()), "session")
+}
+
+func TestBusiestSession(t *testing.T) {
+	cmd := func(pid *int64) models.Command { return models.Command{SourcePid: pid} }
+	assert.Equal(t, int64(0), busiestSession(nil))
+	assert.Equal(t, int64(0), busiestSession([]models.Command{cmd(nil), cmd(nil)}))
+	assert.Equal(t, int64(1), busiestSession([]models.Command{cmd(int64Ptr(1)), cmd(int64Ptr(2)), cmd(nil), cmd(nil)}),
+		"the first to reach the count wins a tie; commands without a pid are no session")
+	assert.Equal(t, int64(2), busiestSession([]models.Command{cmd(int64Ptr(1)), cmd(int64Ptr(2)), cmd(int64Ptr(2))}))
+}
+
+// TestDetailLatestUnique tests U listing each command once at its latest
+// run, bucketed by that run's hour
+func TestDetailLatestUnique(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+	dir := "/home/user/projects/shy"
+	dbPath := setupTestDB(t, []models.Command{
+		makeCommandWithText(yesterday, 9, 0, "make", dir, nil, nil),
+		makeCommandWithText(yesterday, 9, 10, "ls", dir, nil, nil),
+		makeCommandWithText(yesterday, 9, 20, "make", dir, nil, nil),
+		makeCommandWithText(yesterday, 10, 5, "make", dir, nil, nil),
+		makeCommandWithText(yesterday, 11, 0, "git status", dir, nil, nil),
+	})
+	model := initModel(t, dbPath, today)
+	pressEnter(model)
+	require.Len(t, model.DetailCommands(), 5)
+
+	pressKey(model, 'U')
+	assert.True(t, model.LatestUniqueShown())
+	detail := model.DetailCommands()
+	var texts []string
+	for _, c := range detail {
+		texts = append(texts, c.CommandText)
+	}
+	assert.Equal(t, []string{"ls", "make", "git status"}, texts)
+	assert.Equal(t, time.Date(2026, 2, 4, 10, 5, 0, 0, time.Local).Unix(), detail[1].Timestamp)
+
+	buckets := model.DetailBuckets()
+	require.Len(t, buckets, 3)
+	assert.Equal(t, "ls", buckets[0].Commands[0].CommandText, "9am keeps only ls")
+	assert.Equal(t, "make", buckets[1].Commands[0].CommandText, "make moves to its latest hour")
+	assert.Contains(t, ansi.Strip(model.renderView()), "make ×3")
+
+	pressKey(model, 'j')
+	pressKey(model, 'j')
+	assert.Equal(t, 2, model.DetailCmdIdx())
+	pressEnter(model)
+	require.NotNil(t, model.CmdDetailTarget())
+	assert.Equal(t, "git status", model.CmdDetailTarget().CommandText)
+
+	pressKey(model, '-')
+	pressKey(model, 'U')
+	assert.False(t, model.LatestUniqueShown())
+	assert.Len(t, model.DetailCommands(), 5)
+}
+
+func TestCompareContexts(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+	dir := "/home/user/projects/shy"
+	repo := strPtr("github.com/chris/shy")
+	dbPath := setupTestDB(t, []models.Command{
+		makeCommandWithText(yesterday, 9, 0, "make build", dir, repo, strPtr("main")),
+		makeCommandWithText(yesterday, 9, 5, "make test", dir, repo, strPtr("main")),
+		makeCommandWithText(yesterday, 10, 0, "git diff", dir, repo, strPtr("bugfix")),
+	})
+	model := initModel(t, dbPath, today)
+	model.Update(tea.WindowSizeMsg{Width: 120, Height: 20})
+	require.Len(t, model.Contexts(), 2)
+
+	_, cmd := model.handleKey(tea.KeyPressMsg{Code: 'C', Text: "C"})
+	assert.NotNil(t, cmd)
+	assert.Equal(t, "Mark a context with c first", model.statusMsg)
+	assert.Equal(t, SummaryView, model.ViewState())
+
+	pressKey(model, 'c')
+	assert.Contains(t, ansi.Strip(model.renderView()), "◆")
+	_, _ = model.handleKey(tea.KeyPressMsg{Code: 'C', Text: "C"})
+	assert.Equal(t, SummaryView, model.ViewState(), "a context is not compared with itself")
+
+	pressKey(model, 'j')
+	pressKey(model, 'C')
+	require.Equal(t, CompareView, model.ViewState())
+	first := model.Contexts()[0]
+	second := model.Contexts()[1]
+	assert.Len(t, model.CompareCommands(0), first.CommandCount)
+	assert.Len(t, model.CompareCommands(1), second.CommandCount)
+
+	view := ansi.Strip(model.renderView())
+	lines := strings.Split(view, "\n")
+	assert.Contains(t, lines[1], formatContextName(first.Key, first.Branch))
+	assert.Contains(t, lines[1], formatContextName(second.Key, second.Branch), "wide terminals put the panes side by side")
+
+	// j moves within the focused pane only; tab switches panes
+	pressKey(model, 'j')
+	assert.Equal(t, 1, model.CompareCmdIdx(0))
+	assert.Equal(t, 0, model.CompareCmdIdx(1))
+	model.handleKey(tea.KeyPressMsg{Code: tea.KeyTab})
+	assert.Equal(t, 1, model.CompareFocus())
+	pressKey(model, 'j')
+	assert.Equal(t, 1, model.CompareCmdIdx(0))
+	assert.Equal(t, 0, model.CompareCmdIdx(1), "the bugfix pane has one command")
+
+	pressKey(model, '-')
+	assert.Equal(t, SummaryView, model.ViewState())
+}
+
+func TestCompareContextsStackOnNarrowTerminal(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+	dbPath := setupTestDB(t, []models.Command{
+		makeCommandWithText(yesterday, 9, 0, "make build", "/home/user/projects/shy", nil, nil),
+		makeCommandWithText(yesterday, 10, 0, "ls", "/tmp", nil, nil),
+	})
+	model := initModel(t, dbPath, today)
+	model.Update(tea.WindowSizeMsg{Width: 60, Height: 20})
+	pressKey(model, 'c')
+	pressKey(model, 'j')
+	pressKey(model, 'C')
+	require.Equal(t, CompareView, model.ViewState())
+
+	lines := strings.Split(ansi.Strip(model.renderView()), "\n")
+	assert.Len(t, lines, 20)
+	first := formatContextName(model.Contexts()[0].Key, model.Contexts()[0].Branch)
+	second := formatContextName(model.Contexts()[1].Key, model.Contexts()[1].Branch)
+	assert.Contains(t, lines[1], first)
+	assert.NotContains(t, lines[1], second)
+	assert.Contains(t, lines[10], second, "the second pane starts halfway down")
+	for _, line := range lines {
+		assert.LessOrEqual(t, ansi.StringWidth(line), 60)
+	}
+
+	// The panes sit side by side from two minimum widths plus the 3-column gap
+	model.Update(tea.WindowSizeMsg{Width: 2*marginX + 2*comparePaneMinWidth + 3, Height: 20})
+	assert.True(t, model.compareSideBySide())
+	model.Update(tea.WindowSizeMsg{Width: 2*marginX + 2*comparePaneMinWidth + 2, Height: 20})
+	assert.False(t, model.compareSideBySide())
+}
+
+func TestCommandHeads(t *testing.T) {
+	tests := []struct {
+		text  string
+		heads string
+		ok    bool
+	}{
+		{`foo | grep bar && baz`, "foo | grep && baz", true},
+		{`make; make test || echo failed`, "make ; make || echo", true},
+		{`ls -la`, "", false},
+		{`grep "a|b" file.txt`, "", false},
+		{`grep 'x && y' f | wc -l`, "grep | wc", true},
+		{`echo a\|b`, "", false},
+		{`echo $(git log | head -1)`, "", false},
+		{`make 2>&1 | tee build.log`, "make | tee", true},
+		{`sleep 10 & wait`, "sleep & wait", true},
+		{"GOOS=linux go build ./... && ./shy", "go && ./shy", true},
+		{"cd src\nmake", "cd ; make", true},
+	}
+	for _, tt := range tests {
+		heads, ok := commandHeads(tt.text)
+		assert.Equal(t, tt.ok, ok, tt.text)
+		assert.Equal(t, tt.heads, heads, tt.text)
+	}
+}
+
+// TestDetailPipelineHeads tests p shortening compound commands in the detail
+// list to their program names, keeping the selected row in full
+func TestDetailPipelineHeads(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+	dir := "/home/user/projects/shy"
+	dbPath := setupTestDB(t, []models.Command{
+		makeCommandWithText(yesterday, 9, 0, "git status", dir, nil, nil),
+		makeCommandWithText(yesterday, 9, 10, "cat access.log | grep 404 | sort -u", dir, nil, nil),
+	})
+	model := initModel(t, dbPath, today)
+	model.Update(tea.WindowSizeMsg{Width: 100, Height: 20})
+	pressEnter(model)
+	assert.False(t, model.PipelineHeadsShown(), "full text by default")
+	assert.Contains(t, ansi.Strip(model.renderView()), "cat access.log | grep 404 | sort -u")
+
+	pressKey(model, 'p')
+	assert.True(t, model.PipelineHeadsShown())
+	view := ansi.Strip(model.renderView())
+	assert.Contains(t, view, "cat | grep | sort")
+	assert.NotContains(t, view, "access.log")
+	assert.Contains(t, view, "git status", "simple commands stay in full")
+
+	pressKey(model, 'j')
+	assert.Contains(t, ansi.Strip(model.renderView()), "cat access.log | grep 404 | sort -u", "the selected row is shown in full")
+
+	pressKey(model, 'p')
+	assert.False(t, model.PipelineHeadsShown())
+}
+
+// TestSummarySlowestPanel tests s listing the period's longest-running
+// commands under the summary, longest first
+func TestSummarySlowestPanel(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+	dir := "/home/user/projects/shy"
+	timed := func(minute int, text string, ms int64) models.Command {
+		cmd := makeCommandWithText(yesterday, 9, minute, text, dir, nil, nil)
+		cmd.Duration = &ms
+		return cmd
+	}
+	dbPath := setupTestDB(t, []models.Command{
+		timed(0, "ls", 40),
+		timed(5, "make test", 8250),
+		timed(10, "go build ./...", 72000),
+		makeCommandWithText(yesterday, 9, 15, "vim", dir, nil, nil),
+	})
+	model := initModel(t, dbPath, today)
+	model.Update(tea.WindowSizeMsg{Width: 80, Height: 20})
+	assert.NotContains(t, ansi.Strip(model.renderView()), "Slowest")
+
+	pressKey(model, 's')
+	assert.True(t, model.SlowestShown())
+	require.Len(t, model.Slowest(), 3)
+	view := ansi.Strip(model.renderView())
+	assert.Contains(t, view, "Slowest\n    1m12s  go build ./...\n     8.2s  make test\n     40ms  ls\n")
+	assert.Len(t, strings.Split(view, "\n"), 20, "the panel fits within the terminal")
+
+	pressKey(model, 's')
+	assert.False(t, model.SlowestShown())
+	assert.Nil(t, model.Slowest())
+}
+
+// TestSummaryContextPreview tests p cycling each summary row's preview
+// through its first and last command, truncated to the terminal width
+func TestSummaryContextPreview(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+	shy := "/home/user/projects/shy"
+	dbPath := setupTestDB(t, []models.Command{
+		makeCommandWithText(yesterday, 9, 0, "git pull", shy, strPtr("github.com/chris/shy"), strPtr("main")),
+		makeCommandWithText(yesterday, 9, 10, "make test", shy, strPtr("github.com/chris/shy"), strPtr("main")),
+		makeCommandWithText(yesterday, 9, 20, "git push origin main --force-with-lease\necho done", shy, strPtr("github.com/chris/shy"), strPtr("main")),
+		makeCommandWithText(yesterday, 10, 0, "ls", "/tmp", nil, nil),
+	})
+	model := initModel(t, dbPath, today)
+	model.Update(tea.WindowSizeMsg{Width: 80, Height: 20})
+	require.Len(t, model.Contexts(), 2)
+	assert.NotContains(t, ansi.Strip(model.renderView()), "—")
+
+	pressKey(model, 'p')
+	assert.Equal(t, "git pull", model.PreviewText(model.Contexts()[0]))
+	assert.Contains(t, ansi.Strip(model.renderView()), "shy:main — git pull")
+
+	pressKey(model, 'p')
+	assert.Equal(t, "git push origin main --force-with-lease", model.PreviewText(model.Contexts()[0]))
+	view := ansi.Strip(model.renderView())
+	assert.Contains(t, view, "shy:main — git push origin main --force-w…  3 commands", "previews are truncated to fit")
+	assert.Contains(t, view, "/tmp — ls")
+
+	// The name keeps priority: on a narrow terminal only short previews fit
+	model.Update(tea.WindowSizeMsg{Width: 40, Height: 20})
+	view = ansi.Strip(model.renderView())
+	for _, line := range strings.Split(view, "\n") {
+		assert.LessOrEqual(t, ansi.StringWidth(line), 40)
+	}
+	assert.NotContains(t, view, "git push")
+	assert.Contains(t, view, "/tmp — ls")
+
+	pressKey(model, 'p')
+	assert.Empty(t, model.PreviewText(model.Contexts()[0]))
+	assert.NotContains(t, ansi.Strip(model.renderView()), "—")
+}
+
+// TestReloadKeepsSelection tests R reloading the period from the database,
+// keeping the view, the selected context and the selected detail command
+func TestReloadKeepsSelection(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+	shy := "/home/user/projects/shy"
+	dbPath := setupTestDB(t, []models.Command{
+		makeCommandWithText(yesterday, 9, 0, "make", shy, nil, nil),
+		makeCommandWithText(yesterday, 9, 10, "git status", shy, nil, nil),
+		makeCommandWithText(yesterday, 9, 20, "ls", "/tmp", nil, nil),
+	})
+	model := initModel(t, dbPath, today)
+	require.Len(t, model.Contexts(), 2)
+	pressKey(model, 'j')
+	selected := model.Contexts()[model.SelectedIdx()].Key
+
+	insert := func(cmd models.Command) {
+		database, err := db.New(dbPath)
+		require.NoError(t, err)
+		defer database.Close()
+		_, err = database.InsertCommand(&cmd)
+		require.NoError(t, err)
+	}
+	// A context sorting first arrives from another terminal
+	insert(makeCommandWithText(yesterday, 10, 0, "htop", "/a", nil, nil))
+
+	pressKey(model, 'R')
+	assert.Equal(t, SummaryView, model.ViewState())
+	require.Len(t, model.Contexts(), 3)
+	assert.Equal(t, selected, model.Contexts()[model.SelectedIdx()].Key, "the selection follows the context")
+	assert.Equal(t, "Refreshed", model.StatusMsg())
+
+	// In the detail view the selected command is kept
+	for model.Contexts()[model.SelectedIdx()].Key.WorkingDir != shy {
+		pressKey(model, 'k')
+	}
+	pressEnter(model)
+	pressKey(model, 'j')
+	require.Equal(t, "git status", model.DetailCommands()[model.DetailCmdIdx()].CommandText)
+	insert(makeCommandWithText(yesterday, 8, 0, "vim", shy, nil, nil))
+
+	pressKey(model, 'R')
+	assert.Equal(t, ContextDetailView, model.ViewState())
+	assert.Len(t, model.DetailCommands(), 3)
+	assert.Equal(t, "git status", model.DetailCommands()[model.DetailCmdIdx()].CommandText)
+}
+
+// TestCollapseNonGitContexts tests WithCollapseNonGit merging every non-git
+// directory into one "(no repo)" context whose detail rows name their dirs
+func TestCollapseNonGitContexts(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+	repo := strPtr("github.com/chris/shy")
+	dbPath := setupTestDB(t, []models.Command{
+		makeCommandWithText(yesterday, 9, 0, "make build", "/home/user/src/shy", repo, strPtr("main")),
+		makeCommandWithText(yesterday, 9, 10, "ls", "/tmp", nil, nil),
+		makeCommandWithText(yesterday, 9, 20, "du -sh", "/var/log", nil, nil),
+		makeCommandWithText(yesterday, 9, 30, "rm a.out", "/tmp", nil, nil),
+	})
+
+	model := initModel(t, dbPath, today)
+	assert.Len(t, model.Contexts(), 3, "separate by default")
+
+	model = New(dbPath, WithNow(fixedTime(today)), WithCollapseNonGit())
+	model.Update(model.Init()())
+	t.Cleanup(func() { model.Close() })
+	model.Update(tea.WindowSizeMsg{Width: 100, Height: 20})
+
+	contexts := model.Contexts()
+	require.Len(t, contexts, 2)
+	noRepo := contexts[0]
+	assert.Equal(t, "(no repo)", noRepo.Key.WorkingDir)
+	assert.Equal(t, 3, noRepo.CommandCount)
+	assert.Equal(t, []string{"/tmp", "/var/log"}, noRepo.WorkingDirs)
+	assert.Equal(t, "/home/user/src/shy", contexts[1].Key.WorkingDir)
+	assert.Contains(t, ansi.Strip(model.renderView()), "(no repo) +1")
+
+	pressEnter(model)
+	require.Len(t, model.DetailCommands(), 3)
+	view := ansi.Strip(model.renderView())
+	assert.Contains(t, view, "/tmp  ls")
+	assert.Contains(t, view, "/var/log  du -sh")
+	assert.Equal(t, "/tmp", model.contextDir(), "the selected command's directory")
+}
+
+// TestScopeToCommandDir tests @ scoping the summary to the selected
+// command's directory, from the flat view and the command detail view
+func TestScopeToCommandDir(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+	shy, repo := "/home/user/projects/shy", strPtr("github.com/chris/shy")
+	dbPath := setupTestDB(t, []models.Command{
+		makeCommandWithText(yesterday, 9, 0, "ls", "/tmp", nil, nil),
+		makeCommandWithText(yesterday, 9, 10, "make", shy, repo, strPtr("main")),
+		makeCommandWithText(yesterday, 9, 20, "git push", shy, repo, strPtr("feature")),
+		makeCommandWithText(yesterday, 9, 30, "htop", "/var/log", nil, nil),
+	})
+	model := initModel(t, dbPath, today)
+	require.Len(t, model.Contexts(), 4)
+
+	pressKey(model, '0')
+	require.True(t, model.flatView)
+	pressKey(model, 'j')
+	require.Equal(t, "make", model.DetailCommands()[model.DetailCmdIdx()].CommandText)
+	pressKey(model, '@')
+	assert.Equal(t, SummaryView, model.ViewState())
+	assert.False(t, model.flatView)
+	assert.Equal(t, shy, model.DirFilter())
+	require.Len(t, model.Contexts(), 2, "both branches of the directory")
+	for _, ctx := range model.Contexts() {
+		assert.Equal(t, shy, ctx.Key.WorkingDir)
+	}
+	assert.Contains(t, ansi.Strip(model.renderView()), "@"+shy)
+
+	// The scope holds across views until esc lifts it
+	pressKey(model, 'l')
+	pressKey(model, 'h')
+	assert.Len(t, model.Contexts(), 2)
+	_, cmd := model.handleKey(tea.KeyPressMsg{Code: tea.KeyEscape})
+	model.Update(cmd())
+	assert.Equal(t, "", model.DirFilter())
+	assert.Len(t, model.Contexts(), 4)
+
+	// From the command detail view
+	for model.Contexts()[model.SelectedIdx()].Key.WorkingDir != "/var/log" {
+		pressKey(model, 'j')
+	}
+	pressEnter(model)
+	pressEnter(model)
+	require.Equal(t, CommandDetailView, model.ViewState())
+	pressKey(model, '@')
+	assert.Equal(t, SummaryView, model.ViewState())
+	require.Len(t, model.Contexts(), 1)
+	assert.Equal(t, "/var/log", model.Contexts()[0].Key.WorkingDir)
+}
+
+// TestRelativeLabels tests WithRelativeLabels replacing the header's
+// TODAY/YESTERDAY markers and adding week and month ones
+func TestRelativeLabels(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	dbPath := setupTestDB(t, []models.Command{
+		makeCommand(today.AddDate(0, 0, -1), 9, "/home/user/projects/shy", nil, nil),
+	})
+	model := New(dbPath, WithNow(fixedTime(today)), WithRelativeLabels(RelativeLabels{
+		Today: "now", Yesterday: "-1d", LastWeek: "last wk", ThisMonth: "this mo",
+	}))
+	model.Update(model.Init()())
+	t.Cleanup(func() { model.Close() })
+	model.Update(tea.WindowSizeMsg{Width: 100, Height: 20})
+
+	header := func() string { return strings.Split(ansi.Strip(model.renderView()), "\n")[0] }
+	assert.Contains(t, header(), "-1d Wednesday Feb 4")
+	assert.NotContains(t, header(), "YESTERDAY")
+	pressKey(model, 't')
+	assert.Contains(t, header(), "now Thursday Feb 5")
+	pressKey(model, 'h')
+	pressKey(model, 'h')
+	assert.NotContains(t, header(), "-1d", "two days ago has no marker")
+
+	pressKey(model, ']') // week
+	require.Equal(t, WeekPeriod, model.period)
+	assert.NotContains(t, header(), "wk", "no marker for this week")
+	pressKey(model, 'h')
+	assert.Contains(t, header(), "last wk")
+
+	pressKey(model, ']') // month
+	require.Equal(t, MonthPeriod, model.period)
+	assert.NotContains(t, header(), "this mo", "last week fell in January")
+	pressKey(model, 'l')
+	assert.Contains(t, header(), "this mo")
+
+	// Compact headers use the same markers
+	model.compactLayout = true
+	assert.Contains(t, header(), "this mo")
+}
+
+// TestRandomCommand tests that ~ flashes a random command of the period
+func TestRandomCommand(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	commands := []models.Command{
+		makeCommandWithText(today.AddDate(0, 0, -1), 9, 0, "git push", "/home/user/projects/shy", nil, nil),
+		makeCommandWithText(today, 9, 0, "make test\nmake lint", "/home/user/projects/shy", nil, nil),
+	}
+	model := initModel(t, setupTestDB(t, commands), today)
+
+	pressKey(model, 't')
+	pressKey(model, '~')
+	assert.Equal(t, "Random: make test", model.StatusMsg(), "only the period's commands, first line")
+
+	pressKey(model, 'h')
+	pressKey(model, 'h')
+	pressKey(model, '~')
+	assert.Equal(t, "No commands", model.StatusMsg())
+}
+
+// TestRepoListDrillDown tests g collapsing the summary to repos, enter
+// drilling into a repo's branches and then a branch, and - stepping back up
+func TestRepoListDrillDown(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+	dayBefore := today.AddDate(0, 0, -2)
+
+	shy, other := strPtr("github.com/chris/shy"), strPtr("github.com/chris/other")
+	commands := []models.Command{
+		makeCommandWithText(yesterday, 9, 0, "go build", "/home/user/projects/shy", shy, strPtr("main")),
+		makeCommandWithText(yesterday, 9, 5, "go test", "/home/user/projects/shy", shy, strPtr("main")),
+		makeCommandWithText(yesterday, 9, 10, "git diff", "/home/user/projects/shy", shy, strPtr("bugfix")),
+		makeCommandWithText(yesterday, 10, 0, "make", "/home/user/projects/other", other, strPtr("main")),
+		makeCommandWithText(yesterday, 11, 0, "ls", "/tmp", nil, nil),
+		makeCommandWithText(dayBefore, 9, 0, "make lint", "/home/user/projects/other", other, strPtr("main")),
+		makeCommandWithText(dayBefore, 9, 5, "make test", "/home/user/projects/other", other, strPtr("dev")),
+	}
+	dbPath := setupTestDB(t, commands)
+	model := initModel(t, dbPath, today)
+	require.Len(t, model.contexts, 4)
+
+	pressKey(model, 'g')
+	require.Equal(t, RepoListView, model.viewState)
+	repos := model.repoItems()
+	require.Len(t, repos, 3)
+	assert.Equal(t, "github.com/chris/shy", repos[0].gitRepo)
+	assert.Equal(t, 3, repos[0].commandCount)
+	assert.Equal(t, 2, repos[0].branchCount)
+	view := model.View().Content
+	assert.Contains(t, view, "Repos")
+	assert.Contains(t, view, "3 · 2 branches")
+	assert.Contains(t, view, "(no repo)")
+
+	// enter lists the repo's branches as contexts
+	pressEnter(model)
+	assert.Equal(t, SummaryView, model.viewState)
+	require.Len(t, model.contexts, 2)
+	for _, ctx := range model.contexts {
+		assert.Equal(t, "github.com/chris/shy", ctx.Key.GitRepo)
+	}
+
+	// enter again opens the branch's commands; - steps back to its repo
+	pressEnter(model)
+	assert.Equal(t, ContextDetailView, model.viewState)
+	assert.Equal(t, summary.BranchKey("main"), model.detailContextBranch)
+	pressKey(model, '-')
+	assert.Equal(t, SummaryView, model.viewState)
+	assert.Len(t, model.contexts, 2)
+
+	// - again returns to the repos, still on the repo left
+	pressKey(model, '-')
+	require.Equal(t, RepoListView, model.viewState)
+	assert.Len(t, model.repoItems(), 3)
+	repo, ok := model.selectedRepo()
+	require.True(t, ok)
+	assert.Equal(t, "github.com/chris/shy", repo.gitRepo)
+}
+
+// TestRepoListNavigatesDates tests h moving the repo list and a drilled-into
+// repo to the previous day
+func TestRepoListNavigatesDates(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+	dayBefore := today.AddDate(0, 0, -2)
+
+	shy, other := strPtr("github.com/chris/shy"), strPtr("github.com/chris/other")
+	commands := []models.Command{
+		makeCommandWithText(yesterday, 9, 0, "go build", "/home/user/projects/shy", shy, strPtr("main")),
+		makeCommandWithText(yesterday, 9, 5, "go test", "/home/user/projects/shy", shy, strPtr("main")),
+		makeCommandWithText(yesterday, 10, 0, "make", "/home/user/projects/other", other, strPtr("main")),
+		makeCommandWithText(dayBefore, 9, 0, "make lint", "/home/user/projects/other", other, strPtr("main")),
+		makeCommandWithText(dayBefore, 9, 5, "make test", "/home/user/projects/other", other, strPtr("dev")),
+		makeCommandWithText(dayBefore, 9, 10, "git log", "/home/user/projects/shy", shy, strPtr("main")),
+	}
+	dbPath := setupTestDB(t, commands)
+	model := initModel(t, dbPath, today)
+
+	pressKey(model, 'g')
+	pressKey(model, 'j')
+	repo, _ := model.selectedRepo()
+	require.Equal(t, "github.com/chris/other", repo.gitRepo)
+
+	// h keeps the repo selected though it now sorts first
+	pressKey(model, 'h')
+	assert.Equal(t, RepoListView, model.viewState)
+	assert.Equal(t, dayBefore.Day(), model.currentDate.Day())
+	repo, _ = model.selectedRepo()
+	assert.Equal(t, "github.com/chris/other", repo.gitRepo)
+	assert.Equal(t, 0, model.repoIdx)
+	assert.Equal(t, 2, repo.commandCount)
+
+	// Within a repo, l keeps the summary scoped to it
+	pressEnter(model)
+	require.Len(t, model.contexts, 2)
+	pressKey(model, 'l')
+	require.Len(t, model.contexts, 1)
+	assert.Equal(t, "github.com/chris/other", model.contexts[0].Key.GitRepo)
+
+	// g leaves the repos for the whole summary
+	pressKey(model, 'g')
+	pressKey(model, 'g')
+	assert.Equal(t, SummaryView, model.viewState)
+	assert.Len(t, model.contexts, 2)
+}
+
+// TestMarkedDayDelta tests ' marking a day and another day's header showing
+// its command total against the marked one, until the mark is cleared
+func TestMarkedDayDelta(t *testing.T) {
+	today := time.Date(2026, 2, 5, 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+	marked := today.AddDate(0, 0, -4)
+
+	repo := strPtr("github.com/chris/shy")
+	commands := []models.Command{
+		makeCommandWithText(marked, 9, 0, "go build", "/home/user/projects/shy", repo, strPtr("main")),
+		makeCommandWithText(marked, 9, 5, "go test", "/home/user/projects/shy", repo, strPtr("main")),
+	}
+	for i := range 10 {
+		commands = append(commands, makeCommandWithText(yesterday, 9, i, fmt.Sprintf("make %d", i), "/tmp", nil, nil))
+	}
+	dbPath := setupTestDB(t, commands)
+	model := initModel(t, dbPath, today)
+	model.width = 120
+
+	for range 3 {
+		pressKey(model, 'h')
+	}
+	require.Equal(t, marked.Day(), model.currentDate.Day())
+	model.handleKey(tea.KeyPressMsg{Code: '\'', Text: "'"})
+	assert.Equal(t, "Marked Feb 1", model.statusMsg)
+	assert.Contains(t, model.renderHeaderBar(), "◆ marked")
+
+	for range 3 {
+		pressKey(model, 'l')
+	}
+	require.Equal(t, yesterday.Day(), model.currentDate.Day())
+	assert.Contains(t, model.renderHeaderBar(), "+8 commands vs Feb 1")
+
+	// A week is not compared with the marked day
+	pressKey(model, ']')
+	assert.NotContains(t, model.renderHeaderBar(), "vs Feb 1")
+	pressKey(model, '[')
+	assert.Contains(t, model.renderHeaderBar(), "+8 commands vs Feb 1")
+
+	// Nor is a total counted under other filters than the mark's
+	model.Update(model.scopeToDir("/tmp")())
+	require.Len(t, model.Contexts(), 1)
+	assert.NotContains(t, model.renderHeaderBar(), "vs Feb 1")
+	model.dirFilter = ""
+	model.Update(model.loadContexts())
+	assert.Contains(t, model.renderHeaderBar(), "+8 commands vs Feb 1")
+
+	// ' on the marked day clears the mark
+	for range 3 {
+		pressKey(model, 'h')
+	}
+	model.handleKey(tea.KeyPressMsg{Code: '\'', Text: "'"})
+	assert.Equal(t, "Mark cleared", model.statusMsg)
+	assert.Nil(t, model.mark)
+	for range 3 {
+		pressKey(model, 'l')
+	}
+	assert.NotContains(t, model.renderHeaderBar(), "vs Feb 1")
+}
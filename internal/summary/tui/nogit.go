@@ -0,0 +1,51 @@
+package tui
+
+import (
+	"sort"
+
+	"github.com/chris/shy/internal/summary"
+	"github.com/chris/shy/pkg/models"
+)
+
+// noRepoKey keys the context that collapses every non-git directory into
+// one (WithCollapseNonGit). Its name is not a path, so it cannot collide
+// with a real directory.
+var noRepoKey = summary.ContextKey{WorkingDir: "(no repo)"}
+
+// isNoRepoContext reports whether key is the collapsed non-git context
+func isNoRepoContext(key summary.ContextKey) bool {
+	return key == noRepoKey
+}
+
+// collapseNonGitContexts merges every context outside a git repo into one
+// noRepoKey context listing their directories in WorkingDirs. Git contexts
+// are unchanged.
+func collapseNonGitContexts(items []ContextItem) []ContextItem {
+	var result, nonGit []ContextItem
+	for _, item := range items {
+		if item.Key.GitRepo == "" {
+			nonGit = append(nonGit, item)
+		} else {
+			result = append(result, item)
+		}
+	}
+	if len(nonGit) == 0 {
+		return result
+	}
+
+	sort.Slice(nonGit, func(i, j int) bool {
+		return nonGit[i].Key.WorkingDir < nonGit[j].Key.WorkingDir
+	})
+	merged := ContextItem{Key: noRepoKey, Branch: summary.NoBranch}
+	var commands []models.Command
+	for _, item := range nonGit {
+		merged.WorkingDirs = append(merged.WorkingDirs, item.Key.WorkingDir)
+		commands = append(commands, item.Commands...)
+	}
+	sort.SliceStable(commands, func(i, j int) bool {
+		return commands[i].RanBefore(&commands[j])
+	})
+	merged.Commands = commands
+	merged.CommandCount = len(commands)
+	return append(result, merged)
+}
@@ -0,0 +1,34 @@
+package tui
+
+import (
+	"slices"
+
+	"github.com/chris/shy/pkg/models"
+)
+
+// DefaultNoiseCommands are the programs WithDimNoise is usually given: the
+// navigation that dominates history but says little about the work
+var DefaultNoiseCommands = []string{"cd", "ls", "pwd", "clear"}
+
+// isNoise reports whether cmd is a successful run of only noise programs
+// (see WithDimNoise), such as `cd src` or `cd .. && ls`. Leading VAR=value
+// assignments are skipped when finding each program.
+func (m *Model) isNoise(cmd models.Command) bool {
+	if len(m.noiseCommands) == 0 || cmd.ExitStatus != 0 {
+		return false
+	}
+	segments := shellSegments(cmd.CommandText)
+	if len(segments) == 0 {
+		return false
+	}
+	for _, s := range segments {
+		i := 0
+		for i < len(s.words)-1 && isShellAssignment(s.words[i]) {
+			i++
+		}
+		if !slices.Contains(m.noiseCommands, s.words[i]) {
+			return false
+		}
+	}
+	return true
+}
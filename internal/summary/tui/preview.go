@@ -0,0 +1,48 @@
+package tui
+
+import (
+	"github.com/charmbracelet/x/ansi"
+)
+
+// contextPreview selects the command shown after each context's name in the
+// summary (p to cycle), to recall what a context was about without opening it
+type contextPreview int
+
+const (
+	noPreview    contextPreview = iota
+	firstPreview                // the context's first command of the period
+	lastPreview                 // its last command
+)
+
+// previewSeparator joins a context's name and its preview command
+const previewSeparator = " — "
+
+// previewText returns the first line of ctx's previewed command among the
+// commands the filter and exclude leave visible, or "" when previews are off
+// or none is visible. The display mode is ignored: unique mode would skip a
+// first or last command that was run again.
+func (m *Model) previewText(ctx ContextItem) string {
+	if m.contextPreview == noPreview {
+		return ""
+	}
+	commands := filterCommands(ctx.Commands, m.filterText, m.excludeText)
+	if len(commands) == 0 {
+		return ""
+	}
+	cmd := commands[0]
+	if m.contextPreview == lastPreview {
+		cmd = commands[len(commands)-1]
+	}
+	first, _ := firstLine(cmd.CommandText)
+	return first
+}
+
+// renderPreview renders ctx's preview to fit in avail columns after its
+// name, truncated with an ellipsis, or "" when there is no room for it
+func (m *Model) renderPreview(ctx ContextItem, avail int) string {
+	text := m.previewText(ctx)
+	if text == "" || avail < ansi.StringWidth(previewSeparator)+2 {
+		return ""
+	}
+	return truncateWithEllipsis(countStyle.Render(previewSeparator+text), avail)
+}
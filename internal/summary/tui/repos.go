@@ -0,0 +1,232 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tea "charm.land/bubbletea/v2"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// repoItem is one row of the repo list: a git repo with the total command
+// count of its branch contexts. gitRepo is "" for directories outside a repo.
+type repoItem struct {
+	gitRepo      string
+	commandCount int
+	branchCount  int
+}
+
+// repoName returns the display name of a repo list row
+func repoName(gitRepo string) string {
+	if gitRepo == "" {
+		return noRepoKey.WorkingDir
+	}
+	return formatDir(gitRepo)
+}
+
+// repoItems groups the period's contexts by git repo, ordered like the
+// contexts themselves: by command count, then name, or by name alone
+func (m *Model) repoItems() []repoItem {
+	index := make(map[string]int)
+	var items []repoItem
+	for _, ctx := range m.contexts {
+		i, ok := index[ctx.Key.GitRepo]
+		if !ok {
+			i = len(items)
+			index[ctx.Key.GitRepo] = i
+			items = append(items, repoItem{gitRepo: ctx.Key.GitRepo})
+		}
+		items[i].commandCount += ctx.CommandCount
+		items[i].branchCount++
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if m.contextOrder == countOrder && items[i].commandCount != items[j].commandCount {
+			return items[i].commandCount > items[j].commandCount
+		}
+		return repoName(items[i].gitRepo) < repoName(items[j].gitRepo)
+	})
+	return items
+}
+
+// selectedRepo returns the repo under the cursor of the repo list
+func (m *Model) selectedRepo() (repoItem, bool) {
+	items := m.repoItems()
+	if m.repoIdx < len(items) {
+		return items[m.repoIdx], true
+	}
+	return repoItem{}, false
+}
+
+// selectRepo moves the repo list cursor to gitRepo, or to the top when the
+// period has no commands in it
+func (m *Model) selectRepo(gitRepo string) {
+	m.repoIdx = 0
+	for i, item := range m.repoItems() {
+		if item.gitRepo == gitRepo {
+			m.repoIdx = i
+			return
+		}
+	}
+}
+
+// restoreRepoSelection keeps the repo list cursor on prev, when there was
+// one, after the contexts are reloaded
+func (m *Model) restoreRepoSelection(prev repoItem, ok bool) {
+	if !ok {
+		m.repoIdx = 0
+		return
+	}
+	m.selectRepo(prev.gitRepo)
+}
+
+// filterByRepo keeps the contexts of one git repo ("" for the directories
+// outside a repo)
+func filterByRepo(items []ContextItem, gitRepo string) []ContextItem {
+	var kept []ContextItem
+	for _, item := range items {
+		if item.Key.GitRepo == gitRepo {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+// enterRepoList collapses the summary to its repos. Leaving a repo's
+// branches reloads the period unscoped, keeping that repo selected.
+func (m *Model) enterRepoList() tea.Cmd {
+	m.viewState = RepoListView
+	if m.repoScoped {
+		m.repoScoped = false
+		m.repoIdx = 0 // the scoped contexts hold only the repo left
+		return m.loadContexts
+	}
+	m.repoIdx = 0
+	return nil
+}
+
+// enterRepo drills into the selected repo: the summary lists its branches
+func (m *Model) enterRepo() tea.Cmd {
+	item, ok := m.selectedRepo()
+	if !ok {
+		return nil
+	}
+	m.repoScoped = true
+	m.repoScope = item.gitRepo
+	m.viewState = SummaryView
+	m.contexts = filterByRepo(m.contexts, item.gitRepo)
+	m.selectedIdx = 0
+	return nil
+}
+
+func (m *Model) handleRepoListKey(msg tea.KeyPressMsg) (*Model, tea.Cmd) {
+	switch msg.String() {
+	case "j", "down":
+		m.repoIdx = m.stepIndex(m.repoIdx, 1, len(m.repoItems()))
+		return m, nil
+
+	case "k", "up":
+		m.repoIdx = m.stepIndex(m.repoIdx, -1, len(m.repoItems()))
+		return m, nil
+
+	case "enter":
+		return m, m.enterRepo()
+
+	case "g", "esc":
+		m.viewState = SummaryView
+		m.selectedIdx = 0
+		return m, nil
+
+	// The rest act on the selected context, which the repo list has none of
+	case "o", "Y", "F":
+		return m, nil
+	}
+
+	if model, cmd, handled := m.handleSharedKey(msg); handled {
+		return model, cmd
+	}
+	return m, nil
+}
+
+func (m *Model) renderRepoListView() string {
+	var b strings.Builder
+
+	contentWidth := max(m.width-2*marginX, 20)
+	margin := strings.Repeat(" ", marginX)
+
+	fixedLines := 3
+	if m.compactLayout {
+		b.WriteString(m.renderCompactHeaderBar())
+		fixedLines = 2
+	} else {
+		b.WriteString(m.renderHeaderBar())
+	}
+	b.WriteString("\n")
+	if !m.compactLayout {
+		b.WriteString("\n")
+	}
+
+	items := m.repoItems()
+	contentLines := 0
+	if len(items) == 0 {
+		b.WriteString(margin + "No commands found\n")
+		contentLines = 1
+	} else {
+		countWidth := 0
+		for _, item := range items {
+			countWidth = max(countWidth, ansi.StringWidth(repoCountText(item)))
+		}
+		for i, item := range items {
+			b.WriteString(margin + m.renderRepoItem(item, i == m.repoIdx, contentWidth, countWidth) + "\n")
+		}
+		contentLines = len(items)
+	}
+
+	if m.height > 0 {
+		avail := m.height - fixedLines
+		if avail > contentLines {
+			b.WriteString(strings.Repeat("\n", avail-contentLines))
+		}
+	}
+
+	if m.compactLayout && !m.filterActive {
+		b.WriteString(m.renderCompactFooterBar())
+	} else {
+		b.WriteString(m.renderFooterBar())
+	}
+
+	return b.String()
+}
+
+// repoCountText is a repo row's right column, e.g. "42 · 3 branches"
+func repoCountText(item repoItem) string {
+	unit := "branches"
+	if item.branchCount == 1 {
+		unit = "branch"
+	}
+	if item.gitRepo == "" {
+		unit = "dirs"
+		if item.branchCount == 1 {
+			unit = "dir"
+		}
+	}
+	return fmt.Sprintf("%d · %d %s", item.commandCount, item.branchCount, unit)
+}
+
+func (m *Model) renderRepoItem(item repoItem, selected bool, width, countWidth int) string {
+	prefix, nameStyle := "  ", normalStyle
+	if selected {
+		prefix, nameStyle = "▶ ", selectedStyle
+	}
+	countText := repoCountText(item)
+
+	// Counts are right-aligned at width; names get what the widest leaves
+	nameMaxWidth := max(width-ansi.StringWidth(prefix)-2-countWidth, 10)
+	name := nameStyle.Render(m.truncatePath(repoName(item.gitRepo), nameMaxWidth))
+	padding := max(width-ansi.StringWidth(prefix)-ansi.StringWidth(name)-ansi.StringWidth(countText), 1)
+
+	if selected {
+		return selectedStyle.Render(prefix) + name + strings.Repeat(" ", padding) + selectedStyle.Render(countText)
+	}
+	return normalStyle.Render(prefix) + name + strings.Repeat(" ", padding) + countStyle.Render(countText)
+}
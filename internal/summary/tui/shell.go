@@ -0,0 +1,152 @@
+package tui
+
+import "strings"
+
+// shellSegment is one command of a shell command line: its words, and the
+// operator that ends it ("|", "&&", "||", ";", "&", "|&"), or "" for the last
+// command
+type shellSegment struct {
+	words []string
+	op    string
+}
+
+// shellSegments splits text into its commands at top-level list operators
+// and pipes, honouring single and double quotes, backslash escapes and
+// parentheses, so a | inside quotes or $(…) does not split. A newline ends
+// a command like ;. It is only a heuristic for reading command lines, not a
+// shell parser.
+func shellSegments(text string) []shellSegment {
+	var segments []shellSegment
+	var words []string
+	var cur strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	depth := 0
+	endWord := func() {
+		if inWord {
+			words = append(words, cur.String())
+			cur.Reset()
+			inWord = false
+		}
+	}
+	endCommand := func(op string) {
+		endWord()
+		if len(words) > 0 {
+			segments = append(segments, shellSegment{words: words, op: op})
+			words = nil
+		}
+	}
+
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case escaped:
+			// A backslash-newline continues the line
+			if r != '\n' {
+				cur.WriteRune(r)
+				inWord = true
+			}
+			escaped = false
+		case quote != 0:
+			switch {
+			case r == quote:
+				quote = 0
+			case r == '\\' && quote == '"':
+				escaped = true
+			default:
+				cur.WriteRune(r)
+			}
+		case r == '\\':
+			escaped = true
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == '(':
+			depth++
+			cur.WriteRune(r)
+			inWord = true
+		case r == ')' && depth > 0:
+			depth--
+			cur.WriteRune(r)
+		case depth > 0:
+			cur.WriteRune(r)
+		case r == ' ' || r == '\t':
+			endWord()
+		case r == '\n' || r == ';':
+			endCommand(";")
+		case r == '&' && (strings.HasSuffix(cur.String(), ">") || strings.HasSuffix(cur.String(), "<") ||
+			i+1 < len(runes) && runes[i+1] == '>'):
+			// A redirection such as 2>&1 or &>log
+			cur.WriteRune(r)
+			inWord = true
+		case r == '|' || r == '&':
+			op := string(r)
+			if i+1 < len(runes) && (runes[i+1] == r || (r == '|' && runes[i+1] == '&')) {
+				op += string(runes[i+1])
+				i++
+			}
+			endCommand(op)
+		default:
+			cur.WriteRune(r)
+			inWord = true
+		}
+	}
+	endCommand("")
+	if n := len(segments); n > 0 {
+		segments[n-1].op = ""
+	}
+	return segments
+}
+
+// shellCommands returns the words of each command in text, as split by
+// shellSegments
+func shellCommands(text string) [][]string {
+	segments := shellSegments(text)
+	commands := make([][]string, len(segments))
+	for i, s := range segments {
+		commands[i] = s.words
+	}
+	return commands
+}
+
+// commandHeads summarises a pipeline or command list by its program names,
+// keeping the operators between them: `make | tee log && git push` becomes
+// "make | tee && git". Leading VAR=value assignments are skipped. It reports
+// false for a simple command, which is best shown in full.
+func commandHeads(text string) (string, bool) {
+	segments := shellSegments(text)
+	if len(segments) < 2 {
+		return "", false
+	}
+	var b strings.Builder
+	for i, s := range segments {
+		head := s.words[0]
+		for _, w := range s.words {
+			if !isShellAssignment(w) {
+				head = w
+				break
+			}
+		}
+		b.WriteString(head)
+		if i < len(segments)-1 {
+			b.WriteString(" " + s.op + " ")
+		}
+	}
+	return b.String(), true
+}
+
+// isShellAssignment reports whether word is a NAME=value assignment
+func isShellAssignment(word string) bool {
+	name, _, ok := strings.Cut(word, "=")
+	if !ok || name == "" {
+		return false
+	}
+	for i, r := range name {
+		if r != '_' && !(r >= 'a' && r <= 'z') && !(r >= 'A' && r <= 'Z') && (i == 0 || !(r >= '0' && r <= '9')) {
+			return false
+		}
+	}
+	return true
+}
@@ -4,11 +4,21 @@ import (
 	"sort"
 )
 
-// sortContextItems sorts contexts by command count descending,
-// then alphabetically by working dir and branch as a tiebreaker.
-func sortContextItems(items []ContextItem) {
+// contextOrder is the order of the summary's contexts (A to toggle). H/L in
+// the detail view step through the contexts in the same order.
+type contextOrder int
+
+const (
+	countOrder contextOrder = iota // most commands first
+	nameOrder                      // by working dir, then branch
+)
+
+// sortContextItems sorts contexts in order: by command count descending,
+// then alphabetically by working dir and branch as a tiebreaker, or
+// alphabetically alone for nameOrder.
+func sortContextItems(items []ContextItem, order contextOrder) {
 	sort.Slice(items, func(i, j int) bool {
-		if items[i].CommandCount != items[j].CommandCount {
+		if order == countOrder && items[i].CommandCount != items[j].CommandCount {
 			return items[i].CommandCount > items[j].CommandCount
 		}
 		if items[i].Key.WorkingDir != items[j].Key.WorkingDir {
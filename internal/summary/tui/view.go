@@ -2,7 +2,10 @@ package tui
 
 import (
 	"fmt"
+	"maps"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -18,10 +21,12 @@ var (
 	selectedStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("10"))
 	normalStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("15"))
 	countStyle       = lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+	noiseStyle       = lipgloss.NewStyle().Faint(true)
 	separatorStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
 	bucketLabelStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("13")).Bold(true)
 
 	starStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("11"))
+	noteStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("11")).Italic(true)
 
 	// Header/footer bar styles (ANSI 0-15 only, adapts to terminal colorscheme)
 	barStyle       = lipgloss.NewStyle().Background(lipgloss.Color("0")).Foreground(lipgloss.Color("7"))
@@ -30,6 +35,10 @@ var (
 	barDimStyle    = lipgloss.NewStyle().Background(lipgloss.Color("0")).Foreground(lipgloss.Color("4"))
 	barBranchStyle = lipgloss.NewStyle().Background(lipgloss.Color("0")).Foreground(lipgloss.Color("14"))
 
+	// Default branches (main, master, ...) render green so feature branches stand out
+	barDefaultBranchStyle = lipgloss.NewStyle().Background(lipgloss.Color("0")).Foreground(lipgloss.Color("2"))
+	defaultBranchStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+
 	// Hint key style (no background, for empty-state navigation hints)
 	hintKeyStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("4")).Bold(true)
 
@@ -46,18 +55,30 @@ var (
 const marginX = 2
 
 func (m *Model) renderView() string {
+	var view string
 	switch m.viewState {
 	case HelpView:
-		return m.renderHelpView()
+		view = m.renderHelpView()
+	case CompareView:
+		view = m.renderCompareView()
 	case CommandTextView:
-		return m.renderCommandTextView()
+		view = m.renderCommandTextView()
 	case CommandDetailView:
-		return m.renderCommandDetailView()
+		view = m.renderCommandDetailView()
 	case ContextDetailView:
-		return m.renderDetailView()
+		view = m.renderDetailView()
+	case RepoListView:
+		view = m.renderRepoListView()
 	default:
-		return m.renderSummaryView()
+		view = m.renderSummaryView()
+	}
+	if m.expandTarget != nil {
+		view = m.renderExpandOverlay(view)
+	}
+	if m.debugOverlay {
+		return m.renderDebugOverlay(view)
 	}
+	return view
 }
 
 func (m *Model) renderHelpView() string {
@@ -69,8 +90,8 @@ func (m *Model) renderHelpView() string {
 	b.WriteString(m.renderHelpHeaderBar())
 	b.WriteString("\n")
 
-	// Select bindings for the source view
-	bindings := bindingsForView(m.helpPreviousView)
+	// Select bindings for the source view, narrowed by the help search
+	bindings := filterHelpBindings(m.keyMap.label(bindingsForView(m.helpPreviousView)), m.helpFilterText)
 
 	// Find max key width for alignment
 	maxKeyWidth := 0
@@ -80,11 +101,19 @@ func (m *Model) renderHelpView() string {
 		}
 	}
 
+	title := titleStyle.Render("  Help")
+	if m.helpFilterText != "" {
+		title += countStyle.Render("  /" + m.helpFilterText)
+	}
 	b.WriteString("\n")
-	b.WriteString(margin + titleStyle.Render("  Help") + "\n")
+	b.WriteString(margin + title + "\n")
 	b.WriteString("\n")
 
 	contentLines := 3 // blank + title + blank
+	if len(bindings) == 0 {
+		b.WriteString(margin + countStyle.Render("  No matching keys") + "\n")
+		contentLines++
+	}
 	for _, bind := range bindings {
 		pad := maxKeyWidth - len(bind.key) + 2
 		line := margin + "  " + hintKeyStyle.Render(bind.key) + strings.Repeat(" ", pad) + normalStyle.Render(bind.desc)
@@ -118,8 +147,31 @@ func (m *Model) renderHelpHeaderBar() string {
 	return header
 }
 
+// filterHelpBindings keeps the bindings whose description contains text,
+// ignoring case
+func filterHelpBindings(bindings []helpBinding, text string) []helpBinding {
+	if text == "" {
+		return bindings
+	}
+	text = strings.ToLower(text)
+	var out []helpBinding
+	for _, bind := range bindings {
+		if strings.Contains(strings.ToLower(bind.desc), text) {
+			out = append(out, bind)
+		}
+	}
+	return out
+}
+
 func (m *Model) renderHelpFooterBar() string {
-	content := barStyle.Render(" Press ") + barBoldStyle.Render("?") + barStyle.Render(" or ") + barBoldStyle.Render("esc") + barStyle.Render(" to close")
+	if m.helpFilterActive {
+		content := barStyle.Render(fmt.Sprintf(" Search: %s█", m.helpFilterText))
+		contentWidth := ansi.StringWidth(content)
+		pad := max(m.width-contentWidth, 0)
+		return content + barStyle.Render(strings.Repeat(" ", pad))
+	}
+	content := barStyle.Render(" Press ") + barBoldStyle.Render("/") + barStyle.Render(" to search, ") +
+		barBoldStyle.Render("?") + barStyle.Render(" or ") + barBoldStyle.Render("esc") + barStyle.Render(" to close")
 	contentWidth := ansi.StringWidth(content)
 	pad := max(m.width-contentWidth, 0)
 	return content + barStyle.Render(strings.Repeat(" ", pad))
@@ -132,9 +184,22 @@ func (m *Model) renderSummaryView() string {
 	contentWidth := max(m.width-2*marginX, 20)
 	margin := strings.Repeat(" ", marginX)
 
-	// Header bar
-	b.WriteString(m.renderHeaderBar())
-	b.WriteString("\n\n")
+	// Header bar (compact layout drops the blank line below it)
+	fixedLines := 3
+	if m.compactLayout {
+		b.WriteString(m.renderCompactHeaderBar())
+		fixedLines = 2
+	} else {
+		b.WriteString(m.renderHeaderBar())
+	}
+	b.WriteString("\n")
+	if m.showTimeline {
+		b.WriteString(margin + m.renderActivityTimeline(contentWidth) + "\n")
+		fixedLines++
+	}
+	if !m.compactLayout {
+		b.WriteString("\n")
+	}
 
 	// Context list
 	contentLines := 0
@@ -142,27 +207,35 @@ func (m *Model) renderSummaryView() string {
 		b.WriteString(margin + "No commands found\n")
 		contentLines = 1
 	} else {
-		// Calculate the max count width for alignment
-		maxCount := 0
-		for _, ctx := range m.contexts {
-			c := filteredCommandCount(ctx.Commands, m.displayMode, m.filterText)
-			if c > maxCount {
-				maxCount = c
-			}
+		// Calculate the widest metric for alignment
+		uniqueWidth := m.ratioUniqueWidth()
+		countTexts := make([]string, len(m.contexts))
+		countWidth := 0
+		for i, ctx := range m.contexts {
+			countTexts[i] = m.metricText(ctx, uniqueWidth)
+			countWidth = max(countWidth, ansi.StringWidth(countTexts[i]))
 		}
-		countWidth := countColumnWidth(maxCount)
+		rowWidth := m.summaryRowWidth(contentWidth, countWidth)
 
 		for i, ctx := range m.contexts {
-			b.WriteString(margin + m.renderContextItem(ctx, i == m.selectedIdx, contentWidth, countWidth))
+			b.WriteString(margin + m.renderContextItem(ctx, i == m.selectedIdx, rowWidth, countTexts[i], countWidth))
 			b.WriteString("\n")
 		}
 		contentLines = len(m.contexts)
 	}
+	if m.showSlowest {
+		panel := m.renderSlowestPanel(contentWidth)
+		for _, line := range panel {
+			b.WriteString(margin + line + "\n")
+		}
+		contentLines += len(panel)
+	}
 
 	// Pad to push footer to bottom
-	// Fixed lines: headerBar(1) + blank(1) + content + footerBar(1) = 3 + content
+	// Fixed lines: headerBar(1) + timeline(1, when shown) + blank(1, not in
+	// compact) + content + footerBar(1)
 	if m.height > 0 {
-		avail := m.height - 3
+		avail := m.height - fixedLines
 		if avail > contentLines {
 			for i := 0; i < avail-contentLines; i++ {
 				b.WriteString("\n")
@@ -171,11 +244,109 @@ func (m *Model) renderSummaryView() string {
 	}
 
 	// Footer bar
-	b.WriteString(m.renderFooterBar())
+	if m.compactLayout && !m.filterActive {
+		b.WriteString(m.renderCompactFooterBar())
+	} else {
+		b.WriteString(m.renderFooterBar())
+	}
 
 	return b.String()
 }
 
+// slowestPanelSize is how many commands the slowest-commands panel lists
+const slowestPanelSize = 5
+
+// renderSlowestPanel renders the slowest-commands panel under the summary
+// list: a blank line, a heading, then each command's duration and text
+func (m *Model) renderSlowestPanel(width int) []string {
+	lines := []string{"", bucketLabelStyle.Render("Slowest")}
+	if len(m.slowest) == 0 {
+		return append(lines, countStyle.Render("  No timed commands"))
+	}
+	durWidth := 0
+	for _, cmd := range m.slowest {
+		durWidth = max(durWidth, len(models.FormatDurationTenths(*cmd.Duration)))
+	}
+	for _, cmd := range m.slowest {
+		first, _ := firstLine(cmd.CommandText)
+		dur := fmt.Sprintf("  %*s  ", durWidth, models.FormatDurationTenths(*cmd.Duration))
+		lines = append(lines, truncateWithEllipsis(countStyle.Render(dur)+normalStyle.Render(first), width))
+	}
+	return lines
+}
+
+// timelineLevels are the marks of the activity timeline, from the quietest
+// active column to the busiest
+var timelineLevels = []rune("▁▂▃▄▅▆▇█")
+
+// renderActivityTimeline renders the period's commands per hour as one line
+// of width columns. Each column sums the hours it covers and is drawn
+// relative to the busiest column; columns with no commands draw a rule.
+func (m *Model) renderActivityTimeline(width int) string {
+	n := len(m.activity)
+	sums := make([]int, width)
+	busiest := 0
+	for c := range sums {
+		if n == 0 {
+			break
+		}
+		lo := c * n / width
+		hi := max((c+1)*n/width, lo+1)
+		for _, count := range m.activity[lo:min(hi, n)] {
+			sums[c] += count
+		}
+		busiest = max(busiest, sums[c])
+	}
+
+	var line strings.Builder
+	for _, sum := range sums {
+		if sum == 0 {
+			line.WriteString(separatorStyle.Render("─"))
+			continue
+		}
+		level := (sum*len(timelineLevels) - 1) / busiest
+		line.WriteString(normalStyle.Render(string(timelineLevels[level])))
+	}
+	return line.String()
+}
+
+// renderCompactHeaderBar renders the summary header for the compact layout:
+// just the date, since focus and period move to the footer.
+func (m *Model) renderCompactHeaderBar() string {
+	left := m.relativeDateIndicator() + barStyle.Render(" "+formatShortDate(m.currentDate, m.now().Year())+" ")
+	if m.period != DayPeriod {
+		left = m.relativeDateIndicator() + barStyle.Render(" "+m.dateDisplayString())
+	}
+	left += m.renderMarkDelta() + m.renderWeekdayIndicator() + m.renderOrderIndicator()
+	padding := max(m.width-ansi.StringWidth(left), 0)
+	return left + barStyle.Render(strings.Repeat(" ", padding))
+}
+
+// renderCompactFooterBar renders the summary footer for the compact layout,
+// carrying the focus, mode and period indicators with trimmed hints.
+func (m *Model) renderCompactFooterBar() string {
+	if m.quitPromptActive {
+		return m.renderQuitPromptBar()
+	}
+	focus := barDimStyle.Render(" ○ ")
+	if m.focused {
+		focus = barDimStyle.Render(" ● ")
+	}
+	left := focus + barAccentStyle.Render(" "+m.activeModeName()+" ") +
+		barAccentStyle.Render(" "+m.periodName()+" ")
+	left += m.renderMetricIndicator()
+	left += m.renderFilterIndicators()
+	left += m.renderHistorySize()
+
+	right := barStyle.Render(" ") + barBoldStyle.Render("?") + barStyle.Render(" ")
+	if m.statusMsg != "" {
+		right = barDimStyle.Render(" " + m.statusMsg + " ")
+	}
+
+	padding := max(m.width-ansi.StringWidth(left)-ansi.StringWidth(right), 0)
+	return left + barStyle.Render(strings.Repeat(" ", padding)) + right
+}
+
 func (m *Model) renderDetailView() string {
 	var b strings.Builder
 
@@ -188,25 +359,46 @@ func (m *Model) renderDetailView() string {
 
 	contentLines := 0
 	if len(m.detailCommands) == 0 {
-		emptyLines := m.renderEmptyDetailState(margin)
+		var emptyLines []string
+		if note := m.renderDetailNote(contentWidth); note != "" {
+			emptyLines = append(emptyLines, margin+note)
+		}
+		emptyLines = append(emptyLines, m.renderEmptyDetailState(margin)...)
 		for _, line := range emptyLines {
 			b.WriteString(line + "\n")
 		}
 		contentLines = len(emptyLines)
 	} else {
-		// Build all body lines
+		// Build all body lines, starting with the context note
 		var bodyLines []string
+		if note := m.renderDetailNote(contentWidth); note != "" {
+			bodyLines = append(bodyLines, margin+note)
+		}
+		idWidth := m.detailIDWidth()
 		cmdIdx := 0
 		for _, bucket := range m.detailBuckets {
-			// Blank line before bucket
-			bodyLines = append(bodyLines, "")
-			// Bucket header
+			// Bucket header, after a blank line; an expanded hour's minutes
+			// are indented under it instead
+			indent := "  "
+			if bucket.Minute {
+				indent = "    "
+			} else {
+				bodyLines = append(bodyLines, "")
+			}
 			label := bucketLabelStyle.Render(bucket.Label)
-			dashWidth := max(contentWidth-2-ansi.StringWidth(bucket.Label)-1, 2)
-			bodyLines = append(bodyLines, margin+"  "+label+" "+separatorStyle.Render(strings.Repeat("─", dashWidth)))
-			// Commands
+			dashWidth := max(contentWidth-len(indent)-ansi.StringWidth(bucket.Label)-1, 2)
+			bodyLines = append(bodyLines, margin+indent+label+" "+separatorStyle.Render(strings.Repeat("─", dashWidth)))
+			// Commands, with a commit's divider between it and the commands
+			// that came after it
 			for _, cmd := range bucket.Commands {
-				bodyLines = append(bodyLines, margin+m.renderDetailCommand(cmd, cmdIdx == m.detailCmdIdx))
+				divider, ok := m.commitDivider(cmd)
+				if ok && m.detailNewestFirst {
+					bodyLines = append(bodyLines, margin+m.renderCommitDivider(divider, contentWidth))
+				}
+				bodyLines = append(bodyLines, margin+m.renderDetailCommand(cmd, cmdIdx == m.detailCmdIdx, idWidth))
+				if ok && !m.detailNewestFirst {
+					bodyLines = append(bodyLines, margin+m.renderCommitDivider(divider, contentWidth))
+				}
 				cmdIdx++
 			}
 		}
@@ -244,6 +436,24 @@ func (m *Model) renderDetailView() string {
 	return b.String()
 }
 
+// detailNoteLineCount returns the number of body lines used by the context note
+func (m *Model) detailNoteLineCount() int {
+	if m.detailNote() == "" {
+		return 0
+	}
+	return 1
+}
+
+// renderDetailNote renders the context note as a single truncated line,
+// or "" when the context has no note.
+func (m *Model) renderDetailNote(contentWidth int) string {
+	note := m.detailNote()
+	if note == "" {
+		return ""
+	}
+	return "  " + noteStyle.Render(truncateWithEllipsis("✎ "+singleLine(note), max(contentWidth-2, 1)))
+}
+
 // styledSegment is a piece of text with its visual width, used for word wrapping.
 type styledSegment struct {
 	text  string // rendered (may contain ANSI codes)
@@ -256,12 +466,12 @@ func (m *Model) emptyDetailMessageLines(contentWidth int) []string {
 	bold := lipgloss.NewStyle().Foreground(lipgloss.Color("15")).Bold(true)
 	dim := normalStyle // white (not bold) for prose
 
-	date := periodDateLabel(m.currentDate, m.period, m.now)
+	date := periodDateLabel(m.currentDate, m.period, m.weekLabels, m.now)
 
 	segments := []styledSegment{
 		{dim.Render("No commands found in "), ansi.StringWidth("No commands found in ")},
 	}
-	segments = append(segments, styledContextNameSegments(m.detailContextKey, m.detailContextBranch)...)
+	segments = append(segments, m.styledContextNameSegments(m.detailContextKey, m.detailContextBranch)...)
 	segments = append(segments,
 		styledSegment{dim.Render(" on "), ansi.StringWidth(" on ")},
 		styledSegment{bold.Render(date), ansi.StringWidth(date)},
@@ -273,6 +483,13 @@ func (m *Model) emptyDetailMessageLines(contentWidth int) []string {
 			styledSegment{bold.Render(q), ansi.StringWidth(q)},
 		)
 	}
+	if m.excludeText != "" {
+		q := fmt.Sprintf("%q", m.excludeText)
+		segments = append(segments,
+			styledSegment{dim.Render(" excluding "), ansi.StringWidth(" excluding ")},
+			styledSegment{bold.Render(q), ansi.StringWidth(q)},
+		)
+	}
 	if m.displayMode == UniqueMode {
 		segments = append(segments,
 			styledSegment{dim.Render(" (unique)"), ansi.StringWidth(" (unique)")},
@@ -350,8 +567,8 @@ func (m *Model) contextHintLine(key string, orphaned bool, orphanedIdx, neighbor
 	if ctx == nil {
 		return formatHintLineDisabled(key)
 	}
-	name := formatContextName(ctx.Key, ctx.Branch)
-	count := filteredCommandCount(ctx.Commands, m.displayMode, m.filterText)
+	name := formatContextName(ctx.Key, ctx.Branch) + worktreeSuffix(*ctx)
+	count := filteredCommandCount(ctx.Commands, m.displayMode, m.filterText, m.excludeText)
 	return formatHintLine(key, name, count, width)
 }
 
@@ -379,23 +596,86 @@ func formatHintLineDisabled(key string) string {
 	return countStyle.Render(" " + key + " ")
 }
 
-func (m *Model) renderDetailCommand(cmd models.Command, selected bool) string {
-	t := time.Unix(cmd.Timestamp, 0)
-	var minute string
+// formatDetailTimestamp formats the command detail view's timestamp, in UTC
+// while toggled with U. A start time recorded below the second is shown to
+// the millisecond.
+func (m *Model) formatDetailTimestamp(cmd models.Command) string {
+	t, layout := time.Unix(cmd.Timestamp, 0), "2006-01-02 15:04"
+	if cmd.TimestampNs%int64(time.Second) != 0 {
+		t, layout = time.Unix(0, cmd.TimestampNs), "2006-01-02 15:04:05.000"
+	}
+	if m.utcTimes {
+		return t.UTC().Format(layout) + " UTC"
+	}
+	return t.Format(layout)
+}
+
+// detailTimeLabel formats a detail row timestamp for the current period
+func (m *Model) detailTimeLabel(timestamp int64) string {
+	t := time.Unix(timestamp, 0)
 	switch m.period {
 	case MonthPeriod:
-		minute = fmt.Sprintf("%s %2d:%s", t.Format("Mon"), hour12(t), t.Format("04 PM"))
+		return fmt.Sprintf("%s %2d:%s", t.Format("Mon"), hour12(t), t.Format("04 PM"))
 	case DayPeriod:
-		minute = t.Format(":04")
+		return t.Format(":04")
 	default:
-		minute = fmt.Sprintf("%2d:%s", hour12(t), t.Format("04 PM"))
+		return fmt.Sprintf("%2d:%s", hour12(t), t.Format("04 PM"))
+	}
+}
+
+// detailIDWidth returns the width of the event id column of the detail list,
+// wide enough for the largest id shown, or 0 when ids are hidden
+func (m *Model) detailIDWidth() int {
+	if !m.showIDs {
+		return 0
+	}
+	var largest int64
+	for _, cmd := range m.detailCommands {
+		largest = max(largest, cmd.ID)
 	}
+	return len(strconv.FormatInt(largest, 10))
+}
+
+// commitDivider returns the segment cmd ends when commit dividers are shown
+// and cmd is a git commit
+func (m *Model) commitDivider(cmd models.Command) (commitSegment, bool) {
+	if !m.commitDividers {
+		return commitSegment{}, false
+	}
+	segment, ok := m.detailCommitSegments[cmd.ID]
+	return segment, ok
+}
+
+// renderCommitDivider renders the dashed line labelling a commit's segment
+func (m *Model) renderCommitDivider(segment commitSegment, contentWidth int) string {
+	indent := "    "
+	label := truncateWithEllipsis(segment.label(), max(contentWidth-len(indent)-4, 1))
+	dashWidth := max(contentWidth-len(indent)-ansi.StringWidth(label)-4, 2)
+	return indent + separatorStyle.Render("── ") + detailGitStyle.Render(label) + " " + separatorStyle.Render(strings.Repeat("─", dashWidth))
+}
+
+// renderDetailCommand renders one detail row. A nonzero idWidth adds the
+// command's event id, right-aligned, ahead of its time.
+func (m *Model) renderDetailCommand(cmd models.Command, selected bool, idWidth int) string {
+	minute := m.detailTimeLabel(cmd.Timestamp)
 
 	first, multi := firstLine(cmd.CommandText)
+	if m.pipelineHeads && !selected {
+		if heads, ok := commandHeads(cmd.CommandText); ok {
+			first, multi = heads, false
+		}
+	}
 	var indicator string
 	if multi {
 		indicator = detailErrorStyle.Render(" ↵")
 	}
+	if runs, ok := m.detailRunCounts[cmd.ID]; ok {
+		indicator += countStyle.Render(fmt.Sprintf(" ×%d", runs))
+	}
+	if run, ok := m.detailRepeats[cmd.ID]; ok {
+		indicator += countStyle.Render(fmt.Sprintf(" ×%d %s–%s", run.count,
+			strings.TrimSpace(m.detailTimeLabel(run.firstTimestamp)), strings.TrimSpace(minute)))
+	}
 
 	var starIndicator string
 	if m.starredIDs[cmd.ID] {
@@ -405,11 +685,23 @@ func (m *Model) renderDetailCommand(cmd models.Command, selected bool) string {
 	}
 
 	timeStr := "  " + minute + "  "
+	if idWidth > 0 {
+		timeStr = fmt.Sprintf("%*d", idWidth, cmd.ID) + timeStr
+	}
+	// The flat view has no context header, so each row names its own, and
+	// the collapsed non-git context spans directories, so its rows name theirs
+	if m.flatView || isNoRepoContext(m.detailContextKey) {
+		timeStr += commandContextName(cmd) + "  "
+	}
 
 	if selected {
 		return selectedStyle.Render("▶ ") + starIndicator + countStyle.Render(timeStr) + selectedStyle.Render(first) + indicator
 	}
-	return countStyle.Render("  ") + starIndicator + countStyle.Render(timeStr) + normalStyle.Render(first) + indicator
+	text := m.renderCommandLine(first, normalStyle)
+	if m.isNoise(cmd) {
+		text = noiseStyle.Render(first)
+	}
+	return countStyle.Render("  ") + starIndicator + countStyle.Render(timeStr) + text + indicator
 }
 
 func (m *Model) renderHeaderBar() string {
@@ -425,20 +717,44 @@ func (m *Model) renderHeaderBar() string {
 	var infoSegment string
 	switch m.viewState {
 	case ContextDetailView:
-		infoSegment = renderBarContextName(m.detailContextKey, m.detailContextBranch)
+		if m.flatView {
+			infoSegment = barBoldStyle.Render(" All contexts")
+		} else {
+			infoSegment = m.renderBarContextName(m.detailContextKey, m.detailContextBranch)
+		}
 	case CommandDetailView:
 		if target := m.CmdDetailTarget(); target != nil {
 			infoSegment = barBoldStyle.Render(fmt.Sprintf(" Event: %d", target.ID))
 		}
+	case CompareView:
+		infoSegment = barBoldStyle.Render(" Compare")
+	case RepoListView:
+		infoSegment = barBoldStyle.Render(" Repos")
+	case SummaryView:
+		if m.repoScoped {
+			infoSegment = barBoldStyle.Render(" " + repoName(m.repoScope))
+		}
 	}
 
-	// Right side: date display + period indicator
+	// Right side: date display (or selected span) + period indicator
 	dateSegment := m.relativeDateIndicator() + barStyle.Render(" "+m.dateDisplayString())
+	if m.selectActive {
+		dateSegment = barAccentStyle.Render(" SELECT ") + barStyle.Render(" "+m.selectionDisplayString()+" ")
+	}
 	periodSegment := barAccentStyle.Render(" " + m.periodName() + " ")
 
+	right := m.renderMarkDelta() + dateSegment + m.renderWeekdayIndicator() + m.renderOrderIndicator() + periodSegment
+
+	// A merged worktree context lists its directories, space permitting
+	if m.viewState == ContextDetailView && len(m.detailWorkingDirs) > 1 {
+		avail := m.width - ansi.StringWidth(focusSegment+infoSegment) - ansi.StringWidth(right) - 2
+		if avail > 5 {
+			infoSegment += barDimStyle.Render(" " + m.truncatePath("("+formatWorkingDirs(m.detailWorkingDirs)+")", avail))
+		}
+	}
+
 	// Compose with padding
 	left := focusSegment + infoSegment
-	right := dateSegment + periodSegment
 
 	leftWidth := ansi.StringWidth(left)
 	rightWidth := ansi.StringWidth(right)
@@ -447,11 +763,40 @@ func (m *Model) renderHeaderBar() string {
 	return left + barStyle.Render(strings.Repeat(" ", padding)) + right
 }
 
+// selectionDisplayString formats the selected day span, e.g. "Feb 1 – Feb 4"
+func (m *Model) selectionDisplayString() string {
+	currentYear := m.now().Year()
+	start, end := m.selectionRange()
+	days := calendarDays(start, end) + 1
+	span := formatShortDate(start, currentYear)
+	if days > 1 {
+		span += " – " + formatShortDate(end, currentYear)
+	}
+	return fmt.Sprintf("%s (%dd)", span, days)
+}
+
+// dateOnly truncates a time to local midnight
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// calendarDays counts the calendar days from start's date to end's. The
+// dates are compared as UTC midnights, so a day that a DST change makes
+// 23 or 25 hours long still counts as one.
+func calendarDays(start, end time.Time) int {
+	from := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	to := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, time.UTC)
+	return int(to.Sub(from).Hours() / 24)
+}
+
 func (m *Model) dateDisplayString() string {
 	currentYear := m.now().Year()
 
 	switch m.period {
 	case WeekPeriod:
+		if m.weekLabels == ISOWeekLabels {
+			return isoWeekLabel(m.currentDate) + " "
+		}
 		monday := mondayOfWeek(m.currentDate)
 		return fmt.Sprintf("Week of %s ", formatShortDate(monday, currentYear))
 	case MonthPeriod:
@@ -471,45 +816,112 @@ func formatShortDate(t time.Time, currentYear int) string {
 	return t.Format("Jan 2, 2006")
 }
 
-// relativeDateIndicator returns a unicode marker for today/yesterday, empty otherwise.
+// isoWeekLabel formats the ISO week containing t as "2026-W06". The year is
+// the ISO week-numbering year, which differs near January 1.
+func isoWeekLabel(t time.Time) string {
+	year, week := t.ISOWeek()
+	return fmt.Sprintf("%d-W%02d", year, week)
+}
+
+// relativeDateIndicator returns the configured marker when the displayed
+// period is the current or previous day, week or month, empty otherwise
 func (m *Model) relativeDateIndicator() string {
-	if m.period != DayPeriod {
-		return ""
+	if label := m.relativeLabel(); label != "" {
+		return dayStyle.Render(label)
 	}
+	return ""
+}
 
+// relativeLabel returns the RelativeLabels entry for the displayed period
+func (m *Model) relativeLabel() string {
+	current, _ := dateRangeForPeriod(m.currentDate, m.period)
 	now := m.now()
-	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
-	current := time.Date(m.currentDate.Year(), m.currentDate.Month(), m.currentDate.Day(), 0, 0, 0, 0, time.Local)
-
-	diff := today.Sub(current)
-	days := int(diff.Hours() / 24)
+	thisStart, _ := dateRangeForPeriod(now, m.period)
+	lastStart, _ := dateRangeForPeriod(adjacentDate(now, m.period, -1), m.period)
 
-	switch days {
-	case 0:
-		return dayStyle.Render("TODAY")
-	case 1:
-		return dayStyle.Render("YESTERDAY")
+	labels := m.relativeLabels
+	var this, last string
+	switch m.period {
+	case WeekPeriod:
+		this, last = labels.ThisWeek, labels.LastWeek
+	case MonthPeriod:
+		this, last = labels.ThisMonth, labels.LastMonth
+	default:
+		this, last = labels.Today, labels.Yesterday
+	}
+	switch current {
+	case thisStart:
+		return this
+	case lastStart:
+		return last
 	default:
 		return ""
 	}
 }
 
+// renderFilterIndicators renders the active filter ("/text"), exclude
+// ("!globs"), detail duration threshold ("≥5s") and busiest session
+// ("session 123 only") for the footer, or "" when none is set
+func (m *Model) renderFilterIndicators() string {
+	var out string
+	if m.utcTimes && m.viewState == CommandDetailView {
+		out += barStyle.Render(" UTC ")
+	}
+	if m.minDuration != 0 && m.viewState == ContextDetailView {
+		out += barStyle.Render(" ≥" + m.minDuration.String() + " ")
+	}
+	if m.detailMainSessionPid != 0 && m.viewState == ContextDetailView {
+		out += barStyle.Render(fmt.Sprintf(" session %d only ", m.detailMainSessionPid))
+	}
+	if m.filterText != "" {
+		out += barStyle.Render(" /" + singleLine(m.filterText) + " ")
+	}
+	if m.excludeText != "" {
+		out += barStyle.Render(" !" + m.excludeText + " ")
+	}
+	if m.dirFilter != "" {
+		out += barStyle.Render(" @" + formatDir(m.dirFilter) + " ")
+	}
+	return out
+}
+
 func (m *Model) renderFooterBar() string {
+	if m.filterActive && m.editingExclude {
+		content := barStyle.Render(fmt.Sprintf(" Exclude: %s█", m.excludeText))
+		contentWidth := ansi.StringWidth(content)
+		pad := max(m.width-contentWidth, 0)
+		return content + barStyle.Render(strings.Repeat(" ", pad))
+	}
 	if m.filterActive {
 		content := barStyle.Render(fmt.Sprintf(" Filter: %s█", m.filterText))
 		contentWidth := ansi.StringWidth(content)
 		pad := max(m.width-contentWidth, 0)
 		return content + barStyle.Render(strings.Repeat(" ", pad))
 	}
+	if m.noteActive {
+		content := barStyle.Render(fmt.Sprintf(" Note: %s█", m.noteText))
+		contentWidth := ansi.StringWidth(content)
+		pad := max(m.width-contentWidth, 0)
+		return content + barStyle.Render(strings.Repeat(" ", pad))
+	}
+	if m.tagActive {
+		content := barStyle.Render(fmt.Sprintf(" Tag context: %s█", m.tagText))
+		contentWidth := ansi.StringWidth(content)
+		pad := max(m.width-contentWidth, 0)
+		return content + barStyle.Render(strings.Repeat(" ", pad))
+	}
+	if m.quitPromptActive {
+		return m.renderQuitPromptBar()
+	}
 
 	// Left: mode indicator (not shown in command detail view) + filter indicator
 	var left string
 	if m.viewState != CommandDetailView {
 		left = barAccentStyle.Render(" " + m.activeModeName() + " ")
 	}
-	if m.filterText != "" {
-		left += barStyle.Render(" /" + m.filterText + " ")
-	}
+	left += m.renderMetricIndicator()
+	left += m.renderFilterIndicators()
+	left += m.renderHistorySize()
 
 	// Right: status flash message or help hints
 	var right string
@@ -517,7 +929,15 @@ func (m *Model) renderFooterBar() string {
 		right = barDimStyle.Render(" " + m.statusMsg + " ")
 	} else {
 		var hints string
-		if m.viewState == ContextDetailView || m.viewState == CommandDetailView {
+		if m.selectActive {
+			hints += barStyle.Render(" ") + barBoldStyle.Render("v") + barStyle.Render(" export") +
+				barStyle.Render(" ") + barBoldStyle.Render("esc") + barStyle.Render(" cancel")
+		}
+		if m.viewState == CompareView {
+			hints += barStyle.Render(" ") + barBoldStyle.Render("tab") + barStyle.Render(" pane")
+		}
+		if m.viewState == ContextDetailView || m.viewState == CommandDetailView || m.viewState == CompareView ||
+			(m.viewState == SummaryView && m.repoScoped) {
 			hints += barStyle.Render(" ") + barBoldStyle.Render("-") + barStyle.Render(" back")
 		}
 		hints += barStyle.Render(" ") + barBoldStyle.Render("?") + barStyle.Render(" help ")
@@ -531,6 +951,34 @@ func (m *Model) renderFooterBar() string {
 	return left + barStyle.Render(strings.Repeat(" ", padding)) + right
 }
 
+// renderHistorySize renders the footer's total history size, once counted
+// and when WithHistorySize asked for it
+func (m *Model) renderHistorySize() string {
+	if !m.showHistorySize || m.historySize < 0 {
+		return ""
+	}
+	return barDimStyle.Render(" " + formatThousands(m.historySize) + " cmds ")
+}
+
+// formatThousands formats n with comma thousands separators, e.g. "482,113"
+func formatThousands(n int) string {
+	if n < 0 {
+		return "-" + formatThousands(-n)
+	}
+	s := strconv.Itoa(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
+// renderQuitPromptBar renders the footer asking to confirm a quit
+func (m *Model) renderQuitPromptBar() string {
+	content := barBoldStyle.Render(" Quit? ") + barBoldStyle.Render("y") + barStyle.Render("/") + barBoldStyle.Render("n")
+	pad := max(m.width-ansi.StringWidth(content), 0)
+	return content + barStyle.Render(strings.Repeat(" ", pad))
+}
+
 func (m *Model) activeModeName() string {
 	switch m.displayMode {
 	case UniqueMode:
@@ -540,6 +988,33 @@ func (m *Model) activeModeName() string {
 	}
 }
 
+// weekdayName returns the plural name of the filtered weekday, e.g. "Mondays"
+func (m *Model) weekdayName() string {
+	wd, ok := m.filteredWeekday()
+	if !ok {
+		return "All days"
+	}
+	return wd.String() + "s"
+}
+
+// renderWeekdayIndicator renders the header segment for an active weekday
+// filter, or nothing when every day is shown
+func (m *Model) renderWeekdayIndicator() string {
+	if m.weekdayFilter == 0 {
+		return ""
+	}
+	return barAccentStyle.Render(" " + m.weekdayName() + " ")
+}
+
+// renderOrderIndicator renders the header segment for contexts sorted by
+// name, or nothing in the default count order
+func (m *Model) renderOrderIndicator() string {
+	if m.contextOrder != nameOrder {
+		return ""
+	}
+	return barAccentStyle.Render(" A–Z ")
+}
+
 func (m *Model) periodName() string {
 	switch m.period {
 	case WeekPeriod:
@@ -551,13 +1026,7 @@ func (m *Model) periodName() string {
 	}
 }
 
-func (m *Model) renderContextItem(ctx ContextItem, selected bool, width int, countWidth int) string {
-	// Format command count
-	count := filteredCommandCount(ctx.Commands, m.displayMode, m.filterText)
-	countText := fmt.Sprintf("%d commands", count)
-	if count == 1 {
-		countText = "1 command "
-	}
+func (m *Model) renderContextItem(ctx ContextItem, selected bool, width int, countText string, countWidth int) string {
 
 	prefix := "  "
 	if selected {
@@ -566,11 +1035,13 @@ func (m *Model) renderContextItem(ctx ContextItem, selected bool, width int, cou
 
 	// Available space for name: width - prefix(2) - gap(2) - countWidth
 	gap := 2
-	nameMaxWidth := max(width-len(prefix)-gap-countWidth, 10)
+	nameMaxWidth := max(width-ansi.StringWidth(prefix)-gap-countWidth, 10)
 
 	// Build styled context name with green branch
-	name := styledSummaryContextName(ctx.Key, ctx.Branch, selected)
-	name = truncateWithEllipsis(name, nameMaxWidth)
+	name := m.styledSummaryContextName(ctx.Key, ctx.Branch, selected) + countStyle.Render(worktreeSuffix(ctx))
+	name += starStyle.Render(m.compareMarkSuffix(ctx))
+	name = m.truncatePath(name, nameMaxWidth)
+	name += m.renderPreview(ctx, nameMaxWidth-ansi.StringWidth(name))
 
 	// Build the line with right-aligned count
 	padding := max(width-ansi.StringWidth(prefix)-ansi.StringWidth(name)-ansi.StringWidth(countText), 1)
@@ -581,11 +1052,15 @@ func (m *Model) renderContextItem(ctx ContextItem, selected bool, width int, cou
 	return normalStyle.Render(prefix) + name + strings.Repeat(" ", padding) + countStyle.Render(countText)
 }
 
-// styledSummaryContextName renders a context name with the branch in cyan.
-func styledSummaryContextName(key summary.ContextKey, branch summary.BranchKey, selected bool) string {
+// styledSummaryContextName renders a context name with the branch in cyan,
+// or green for a default branch.
+func (m *Model) styledSummaryContextName(key summary.ContextKey, branch summary.BranchKey, selected bool) string {
 	dir := formatDir(key.WorkingDir)
 	branchCyanStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("14"))
 	dirStyle, sepStyle, brStyle := normalStyle, countStyle, branchCyanStyle
+	if m.isDefaultBranch(branch) {
+		brStyle = defaultBranchStyle
+	}
 	if selected {
 		dirStyle = selectedStyle
 	}
@@ -595,10 +1070,200 @@ func styledSummaryContextName(key summary.ContextKey, branch summary.BranchKey,
 	return dirStyle.Render(dir)
 }
 
-// countColumnWidth returns the width of the count column for alignment
-func countColumnWidth(maxCount int) int {
-	// "N commands" where N is the max count
-	return len(fmt.Sprintf("%d commands", maxCount))
+// summaryRowWidth returns the width of a summary row: sized to the longest
+// context name, with its preview, so counts sit next to the names, capped at
+// contentWidth.
+func (m *Model) summaryRowWidth(contentWidth, countWidth int) int {
+	longest := 0
+	for _, ctx := range m.contexts {
+		name := formatContextName(ctx.Key, ctx.Branch) + worktreeSuffix(ctx) + m.compareMarkSuffix(ctx)
+		if text := m.previewText(ctx); text != "" {
+			name += previewSeparator + text
+		}
+		longest = max(longest, ansi.StringWidth(name))
+	}
+	// prefix(2) + name + gap(2) + count
+	return min(2+longest+2+countWidth, contentWidth)
+}
+
+// metricText returns the right-column text for a context under the active
+// metric, computed over the commands the filter, exclude and mode leave
+// visible. The ratio shows both modes at once, so it ignores the mode, and
+// pads the unique count to uniqueWidth (see ratioUniqueWidth).
+func (m *Model) metricText(ctx ContextItem, uniqueWidth int) string {
+	commands := visibleCommands(ctx.Commands, m.displayMode, m.filterText, m.excludeText)
+	switch m.metric {
+	case DurationMetric:
+		var total int64
+		for _, cmd := range commands {
+			if cmd.Duration != nil {
+				total += *cmd.Duration
+			}
+		}
+		return formatDurationHuman(&total)
+	case LastUsedMetric:
+		if len(commands) == 0 {
+			return "\u2014"
+		}
+		var last int64
+		for _, cmd := range commands {
+			last = max(last, cmd.Timestamp)
+		}
+		return formatTimeAgo(m.now().Sub(time.Unix(last, 0)))
+	case ActiveMetric:
+		return "active: " + formatGapDuration(activeTime(commands))
+	case RatioMetric:
+		total := filteredCommandCount(ctx.Commands, AllMode, m.filterText, m.excludeText)
+		return fmt.Sprintf("%d / %*d", total, uniqueWidth, m.uniqueCount(ctx))
+	default:
+		// "1 command " keeps the word aligned with "N commands"
+		if len(commands) == 1 {
+			return "1 command "
+		}
+		return fmt.Sprintf("%d commands", len(commands))
+	}
+}
+
+// ratioUniqueWidth returns the width of the widest unique count among the
+// listed contexts under the ratio metric, so padding every row's count to it
+// lines up the slashes; 0 under the other metrics
+func (m *Model) ratioUniqueWidth() int {
+	if m.metric != RatioMetric {
+		return 0
+	}
+	width := 0
+	for _, ctx := range m.contexts {
+		width = max(width, len(strconv.Itoa(m.uniqueCount(ctx))))
+	}
+	return width
+}
+
+// uniqueCount returns how many of ctx's commands the filter and exclude leave
+// visible that Unique mode would show, whatever the current mode
+func (m *Model) uniqueCount(ctx ContextItem) int {
+	return filteredCommandCount(ctx.Commands, UniqueMode, m.filterText, m.excludeText)
+}
+
+// activeTime sums the gaps between consecutive commands, each capped at
+// activeGapCap. Unlike summed durations, which only cover the commands'
+// runtime, this is the wall time spent between prompts.
+func activeTime(commands []models.Command) time.Duration {
+	timestamps := make([]int64, len(commands))
+	for i, cmd := range commands {
+		timestamps[i] = cmd.Timestamp
+	}
+	slices.Sort(timestamps)
+
+	var total time.Duration
+	for i := 1; i < len(timestamps); i++ {
+		gap := time.Duration(timestamps[i]-timestamps[i-1]) * time.Second
+		total += min(gap, activeGapCap)
+	}
+	return total
+}
+
+// formatTimeAgo formats an elapsed duration as "just now", "5m ago", "2h ago" or "3d ago"
+func formatTimeAgo(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d/time.Minute))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d/time.Hour))
+	default:
+		return fmt.Sprintf("%dd ago", int(d/(24*time.Hour)))
+	}
+}
+
+// cmdDetailDividerLines counts the gap and branch dividers drawn between
+// context commands
+func (m *Model) cmdDetailDividerLines() int {
+	n := 0
+	allCmds := m.cmdDetailVisible()
+	for i, cmd := range allCmds {
+		if _, ok := m.cmdDetailGaps[cmd.ID]; ok && i > 0 {
+			n++
+		}
+		if _, ok := m.branchSwitch(allCmds, i); ok {
+			n++
+		}
+	}
+	return n
+}
+
+// branchSwitch returns the divider label when the context command at i is on
+// a different git branch than the one before it, so a session's branch
+// changes can be followed. Dividers are drawn between rows, never selected.
+func (m *Model) branchSwitch(cmds []models.Command, i int) (string, bool) {
+	if m.hideBranchSwitch || i == 0 {
+		return "", false
+	}
+	prev, cur := summary.BranchKeyFor(cmds[i-1].GitBranch), summary.BranchKeyFor(cmds[i].GitBranch)
+	if prev == cur {
+		return "", false
+	}
+	if cur == summary.NoBranch {
+		return "— left " + string(prev) + " —", true
+	}
+	return "— switched to " + string(cur) + " —", true
+}
+
+// formatGapDuration formats an idle gap as "45m", "2h 5m" or "3d 4h"
+func formatGapDuration(d time.Duration) string {
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d/time.Minute))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh %dm", int(d/time.Hour), int(d%time.Hour/time.Minute))
+	default:
+		return fmt.Sprintf("%dd %dh", int(d/(24*time.Hour)), int(d%(24*time.Hour)/time.Hour))
+	}
+}
+
+// formatRelativeTime formats an elapsed duration as "just now", "1 minute ago",
+// "5 hours ago" or "3 days ago"
+func formatRelativeTime(d time.Duration) string {
+	var n int
+	var unit string
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		n, unit = int(d/time.Minute), "minute"
+	case d < 24*time.Hour:
+		n, unit = int(d/time.Hour), "hour"
+	default:
+		n, unit = int(d/(24*time.Hour)), "day"
+	}
+	if n != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("%d %s ago", n, unit)
+}
+
+// metricName returns the footer label for a non-default metric, or "" for counts
+func (m *Model) metricName() string {
+	switch m.metric {
+	case DurationMetric:
+		return "Duration"
+	case LastUsedMetric:
+		return "Last used"
+	case ActiveMetric:
+		return "Active"
+	case RatioMetric:
+		return "Total / unique"
+	default:
+		return ""
+	}
+}
+
+// renderMetricIndicator renders the active metric label for the summary footer
+func (m *Model) renderMetricIndicator() string {
+	if m.viewState != SummaryView || m.metricName() == "" {
+		return ""
+	}
+	return barAccentStyle.Render(" " + m.metricName() + " ")
 }
 
 // truncateWithEllipsis truncates a string to maxWidth, adding … if truncated
@@ -611,6 +1276,17 @@ func truncateWithEllipsis(s string, maxWidth int) string {
 	return truncated + "…"
 }
 
+// truncatePath truncates a context name or path to maxWidth, from the side
+// chosen with WithTruncateSide
+func (m *Model) truncatePath(s string, maxWidth int) string {
+	width := ansi.StringWidth(s)
+	if !m.truncateLeft || width <= maxWidth {
+		return truncateWithEllipsis(s, maxWidth)
+	}
+	// Drop enough cells from the left to leave room for …
+	return "…" + ansi.TruncateLeft(s, width-maxWidth+1, "")
+}
+
 // cmdTextVisualLine is a single rendered line in the command text view.
 type cmdTextVisualLine struct {
 	lineNum    int    // 1-indexed source line number (0 = continuation)
@@ -727,6 +1403,10 @@ func (m *Model) renderCommandTextView() string {
 	}
 
 	// Footer bar
+	if m.quitPromptActive {
+		b.WriteString(m.renderQuitPromptBar())
+		return b.String()
+	}
 	content := barStyle.Render(" Press ") + barBoldStyle.Render("-") + barStyle.Render(" or ") + barBoldStyle.Render("esc") + barStyle.Render(" to go back")
 	contentWidth := ansi.StringWidth(content)
 	pad := max(m.width-contentWidth, 0)
@@ -754,7 +1434,7 @@ func (m *Model) renderCommandDetailView() string {
 		b.WriteString("\n")
 		// Metadata fields (label in blue, value in white — matching tv preview)
 		cmdFirst, cmdMulti := firstLine(cmd.CommandText)
-		cmdField := normalStyle.Render(cmdFirst)
+		cmdField := m.renderCommandLine(cmdFirst, normalStyle)
 		if cmdMulti {
 			cmdField += detailErrorStyle.Render(" ↵")
 		}
@@ -780,9 +1460,22 @@ func (m *Model) renderCommandDetailView() string {
 		}
 
 		t := time.Unix(cmd.Timestamp, 0)
-		b.WriteString(margin + "  " + renderDetailField("Timestamp:", t.Format("2006-01-02 15:04"), normalStyle) + "\n")
+		relative := countStyle.Render(" (" + formatRelativeTime(m.now().Sub(t)) + ")")
+		b.WriteString(margin + "  " + renderDetailField("Timestamp:", m.formatDetailTimestamp(*cmd), normalStyle) + relative + "\n")
 		b.WriteString(margin + "  " + renderDetailField("Duration:", formatDurationHuman(cmd.Duration), normalStyle) + "\n")
 		b.WriteString(margin + "  " + renderDetailField("Exit Status:", renderExitStatus(cmd.ExitStatus), lipgloss.NewStyle()) + "\n")
+		if len(m.cmdDetailTags) > 0 {
+			b.WriteString(margin + "  " + renderDetailField("Tags:", strings.Join(m.cmdDetailTags, ", "), normalStyle) + "\n")
+		}
+
+		// Captured environment, one variable per line (only when the hook sent any)
+		for i, line := range envLines(cmd.Env) {
+			label := ""
+			if i == 0 {
+				label = "Env:"
+			}
+			b.WriteString(margin + "  " + renderDetailField(label, line, normalStyle) + "\n")
+		}
 
 		// Separator
 		b.WriteString("\n")
@@ -792,8 +1485,15 @@ func (m *Model) renderCommandDetailView() string {
 		// Session context
 		b.WriteString(margin + "  " + detailLabelStyle.Render("Context (same session):") + "\n")
 
-		allCmds := m.cmdDetailAllCommands()
+		start, _ := m.cmdDetailWindow()
+		allCmds := m.cmdDetailVisible()
 		for i, ctxCmd := range allCmds {
+			if gap, ok := m.cmdDetailGaps[ctxCmd.ID]; ok && i > 0 {
+				b.WriteString(margin + "    " + countStyle.Render("— "+formatGapDuration(gap.Duration())+" gap —") + "\n")
+			}
+			if label, ok := m.branchSwitch(allCmds, i); ok {
+				b.WriteString(margin + "    " + countStyle.Render(label) + "\n")
+			}
 			first, multi := firstLine(ctxCmd.CommandText)
 			idStr := fmt.Sprintf("%5d  ", ctxCmd.ID)
 			var indicator string
@@ -806,10 +1506,10 @@ func (m *Model) renderCommandDetailView() string {
 			} else {
 				cmdStarIndicator = "  "
 			}
-			if i == m.cmdDetailIdx {
+			if start+i == m.cmdDetailIdx {
 				b.WriteString(margin + "  " + selectedStyle.Render("▶ ") + cmdStarIndicator + countStyle.Render(idStr) + selectedStyle.Render(first) + indicator + "\n")
 			} else {
-				b.WriteString(margin + "  " + countStyle.Render("  ") + cmdStarIndicator + countStyle.Render(idStr) + normalStyle.Render(first) + indicator + "\n")
+				b.WriteString(margin + "  " + countStyle.Render("  ") + cmdStarIndicator + countStyle.Render(idStr) + m.renderCommandLine(first, normalStyle) + indicator + "\n")
 			}
 		}
 	}
@@ -819,9 +1519,12 @@ func (m *Model) renderCommandDetailView() string {
 	contentLines := 0
 	if target != nil {
 		// blank + 5 metadata + 2 git + 1 session + blank + separator + blank + "Context" + context cmds
-		contentLines = 1 + 5 + 2 + 1
+		contentLines = 1 + 5 + 2 + 1 + len(target.Env)
+		if len(m.cmdDetailTags) > 0 {
+			contentLines++
+		}
 		contentLines += 3 + 1 // blank + separator + blank + "Context"
-		contentLines += len(m.cmdDetailAllCommands())
+		contentLines += len(m.cmdDetailVisible()) + m.cmdDetailDividerLines()
 	} else {
 		contentLines = 2
 	}
@@ -841,6 +1544,16 @@ func (m *Model) renderCommandDetailView() string {
 	return b.String()
 }
 
+// envLines returns captured environment variables as NAME=value lines,
+// sorted by name
+func envLines(env map[string]string) []string {
+	lines := make([]string, 0, len(env))
+	for _, name := range slices.Sorted(maps.Keys(env)) {
+		lines = append(lines, name+"="+env[name])
+	}
+	return lines
+}
+
 // renderDetailField renders a label:value pair with the label in blue and the
 // value in the given style, padded to align at column 13.
 func renderDetailField(label string, value string, valueStyle lipgloss.Style) string {
@@ -918,12 +1631,21 @@ func hasBranch(key summary.ContextKey, branch summary.BranchKey) bool {
 	return key.GitRepo != "" && branch != summary.NoBranch
 }
 
+// isDefaultBranch reports whether branch is one of the configured default
+// branches (see WithDefaultBranches)
+func (m *Model) isDefaultBranch(branch summary.BranchKey) bool {
+	return slices.Contains(m.defaultBranches, string(branch))
+}
+
 // styledContextNameSegments returns styled segments for a context name with
 // distinct styles for directory, separator, and branch (no background).
-func styledContextNameSegments(key summary.ContextKey, branch summary.BranchKey) []styledSegment {
+func (m *Model) styledContextNameSegments(key summary.ContextKey, branch summary.BranchKey) []styledSegment {
 	bold := lipgloss.NewStyle().Foreground(lipgloss.Color("15")).Bold(true)
 	sep := lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
 	branchStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("14"))
+	if m.isDefaultBranch(branch) {
+		branchStyle = defaultBranchStyle
+	}
 
 	dir := formatDir(key.WorkingDir)
 	if hasBranch(key, branch) {
@@ -941,16 +1663,47 @@ func styledContextNameSegments(key summary.ContextKey, branch summary.BranchKey)
 
 // renderBarContextName renders the context name for the header bar with
 // distinct styles for directory, separator, and branch.
-func renderBarContextName(key summary.ContextKey, branch summary.BranchKey) string {
+func (m *Model) renderBarContextName(key summary.ContextKey, branch summary.BranchKey) string {
 	dir := formatDir(key.WorkingDir)
 	if hasBranch(key, branch) {
+		branchStyle := barBranchStyle
+		if m.isDefaultBranch(branch) {
+			branchStyle = barDefaultBranchStyle
+		}
 		return barBoldStyle.Render(" "+dir) +
 			barDimStyle.Render(":") +
-			barBranchStyle.Render(string(branch))
+			branchStyle.Render(string(branch))
 	}
 	return barBoldStyle.Render(" " + dir)
 }
 
+// worktreeSuffix marks a merged worktree context with the number of other
+// directories it includes, e.g. " +2"
+func worktreeSuffix(ctx ContextItem) string {
+	if len(ctx.WorkingDirs) < 2 {
+		return ""
+	}
+	return fmt.Sprintf(" +%d", len(ctx.WorkingDirs)-1)
+}
+
+// formatWorkingDirs lists the directories of a merged worktree context
+func formatWorkingDirs(dirs []string) string {
+	formatted := make([]string, len(dirs))
+	for i, dir := range dirs {
+		formatted[i] = formatDir(dir)
+	}
+	return strings.Join(formatted, ", ")
+}
+
+// commandContextName returns the name of the context a command belongs to
+func commandContextName(cmd models.Command) string {
+	key := summary.ContextKey{WorkingDir: cmd.WorkingDir}
+	if cmd.GitRepo != nil {
+		key.GitRepo = *cmd.GitRepo
+	}
+	return formatContextName(key, summary.BranchKeyFor(cmd.GitBranch))
+}
+
 func formatContextName(key summary.ContextKey, branch summary.BranchKey) string {
 	dir := formatDir(key.WorkingDir)
 	if hasBranch(key, branch) {
@@ -0,0 +1,76 @@
+package tui
+
+import (
+	"sort"
+
+	"github.com/chris/shy/internal/summary"
+	"github.com/chris/shy/pkg/models"
+)
+
+// worktreeKey identifies a logical branch across git worktrees
+type worktreeKey struct {
+	gitRepo string
+	branch  summary.BranchKey
+}
+
+// isWorktreeGroupable reports whether a context belongs to a git branch and
+// can therefore be merged with other worktrees of the same branch.
+func isWorktreeGroupable(key summary.ContextKey, branch summary.BranchKey) bool {
+	return key.GitRepo != "" && branch != summary.NoBranch
+}
+
+// mergeWorktreeContexts merges contexts that share a git repo and branch but
+// live in different directories (git worktrees) into one context. The merged
+// context is keyed by its alphabetically first directory and lists all of its
+// directories in WorkingDirs. Non-git and branchless contexts are unchanged.
+func mergeWorktreeContexts(items []ContextItem) []ContextItem {
+	groups := make(map[worktreeKey][]ContextItem)
+	var result []ContextItem
+	for _, item := range items {
+		if !isWorktreeGroupable(item.Key, item.Branch) {
+			result = append(result, item)
+			continue
+		}
+		key := worktreeKey{gitRepo: item.Key.GitRepo, branch: item.Branch}
+		groups[key] = append(groups[key], item)
+	}
+
+	for _, group := range groups {
+		if len(group) == 1 {
+			result = append(result, group[0])
+			continue
+		}
+
+		sort.Slice(group, func(i, j int) bool {
+			return group[i].Key.WorkingDir < group[j].Key.WorkingDir
+		})
+		merged := ContextItem{
+			Key:    group[0].Key,
+			Branch: group[0].Branch,
+		}
+		var commands []models.Command
+		for _, item := range group {
+			merged.WorkingDirs = append(merged.WorkingDirs, item.Key.WorkingDir)
+			commands = append(commands, item.Commands...)
+		}
+		sort.SliceStable(commands, func(i, j int) bool {
+			return commands[i].RanBefore(&commands[j])
+		})
+		merged.Commands = commands
+		merged.CommandCount = len(commands)
+		result = append(result, merged)
+	}
+
+	return result
+}
+
+// matchesContext reports whether ctx is the context identified by key and
+// branch. With worktree grouping a git branch context is identified by its
+// repo and branch alone, since the directory it is keyed by can change as
+// worktrees come and go.
+func (m *Model) matchesContext(ctx ContextItem, key summary.ContextKey, branch summary.BranchKey) bool {
+	if m.groupWorktrees && isWorktreeGroupable(key, branch) {
+		return ctx.Key.GitRepo == key.GitRepo && ctx.Branch == branch
+	}
+	return ctx.Key == key && ctx.Branch == branch
+}
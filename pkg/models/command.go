@@ -7,17 +7,28 @@ import (
 
 // Command represents a shell command entry in the history database
 type Command struct {
-	ID           int64
-	Timestamp    int64
-	ExitStatus   int
-	CommandText  string
-	WorkingDir   string
-	GitRepo      *string
-	GitBranch    *string
-	Duration     *int64  // Duration in milliseconds, null if not captured
-	SourceApp    *string // Shell application (e.g., "zsh", "bash"), null if not tracked
-	SourcePid    *int64  // Process ID of the shell session, null if not tracked
-	SourceActive *bool   // Whether the shell session is still active, null if not tracked
+	ID           int64             `json:"id"`
+	Timestamp    int64             `json:"timestamp"`
+	ExitStatus   int               `json:"exit_status"`
+	CommandText  string            `json:"command_text"`
+	WorkingDir   string            `json:"working_dir"`
+	GitRepo      *string           `json:"git_repo"`
+	GitBranch    *string           `json:"git_branch"`
+	Duration     *int64            `json:"duration"`      // Duration in milliseconds, null if not captured
+	SourceApp    *string           `json:"source_app"`    // Shell application (e.g., "zsh", "bash"), null if not tracked
+	SourcePid    *int64            `json:"source_pid"`    // Process ID of the shell session, null if not tracked
+	SourceActive *bool             `json:"source_active"` // Whether the shell session is still active, null if not tracked
+	Env          map[string]string `json:"env,omitempty"` // Environment variables captured with the command, nil if none
+	TimestampNs  int64             `json:"timestamp_ns"`  // Start time in Unix nanoseconds; 0 when inserting means the whole Timestamp second
+}
+
+// RanBefore reports whether c started before other: by Timestamp, then by
+// TimestampNs for commands within the same second
+func (c *Command) RanBefore(other *Command) bool {
+	if c.Timestamp != other.Timestamp {
+		return c.Timestamp < other.Timestamp
+	}
+	return c.TimestampNs < other.TimestampNs
 }
 
 func (c *Command) TrimCommandText() {
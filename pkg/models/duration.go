@@ -0,0 +1,21 @@
+package models
+
+import "fmt"
+
+// FormatDurationTenths formats a duration in milliseconds to a tenth of a
+// second under a minute ("820ms", "8.2s") and to the second above it
+// ("1m12s", "1h2m3s")
+func FormatDurationTenths(durationMs int64) string {
+	switch {
+	case durationMs < 1000:
+		return fmt.Sprintf("%dms", durationMs)
+	case durationMs < 60*1000:
+		return fmt.Sprintf("%d.%ds", durationMs/1000, durationMs%1000/100)
+	}
+	seconds := durationMs / 1000
+	hours, minutes := seconds/3600, seconds%3600/60
+	if hours > 0 {
+		return fmt.Sprintf("%dh%dm%ds", hours, minutes, seconds%60)
+	}
+	return fmt.Sprintf("%dm%ds", minutes, seconds%60)
+}
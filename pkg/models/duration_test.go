@@ -0,0 +1,15 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatDurationTenths(t *testing.T) {
+	assert.Equal(t, "820ms", FormatDurationTenths(820))
+	assert.Equal(t, "8.2s", FormatDurationTenths(8250))
+	assert.Equal(t, "59.9s", FormatDurationTenths(59999))
+	assert.Equal(t, "1m0s", FormatDurationTenths(60000))
+	assert.Equal(t, "1h2m3s", FormatDurationTenths(3723000))
+}